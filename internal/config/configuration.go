@@ -8,11 +8,39 @@ import (
 )
 
 type Configuration struct {
-	ServerPort      string `mapstructure:"SERVER_PORT"`
-	SecretKey       string `mapstructure:"SECRET_KEY"`
-	MongoHost       string `mapstructure:"MONGO_HOST"`
-	MailgunEmailKey string `mapstructure:"MAILGUN_EMAIL_KEY"`
-	EmailDomain     string `mapstructure:"EMAIL_DOMAIN"`
+	ServerPort           string `mapstructure:"SERVER_PORT"`
+	AppBaseURL           string `mapstructure:"APP_BASE_URL"`
+	APIBaseURL           string `mapstructure:"API_BASE_URL"`
+	SecretKey            string `mapstructure:"SECRET_KEY"`
+	AdminEmail           string `mapstructure:"ADMIN_EMAIL"`
+	AdminPassword        string `mapstructure:"ADMIN_PASSWORD"`
+	AccessTokenTTLMins   int    `mapstructure:"ACCESS_TOKEN_TTL_MINUTES"`
+	RefreshTokenTTLHours int    `mapstructure:"REFRESH_TOKEN_TTL_HOURS"`
+	MongoHost            string `mapstructure:"MONGO_HOST"`
+	MongoTransactionMode string `mapstructure:"MONGO_TRANSACTION_MODE"`
+	MailgunEmailKey      string `mapstructure:"MAILGUN_EMAIL_KEY"`
+	EmailDomain          string `mapstructure:"EMAIL_DOMAIN"`
+
+	EmailProvider string `mapstructure:"EMAIL_PROVIDER"`
+	EmailFrom     string `mapstructure:"EMAIL_FROM"`
+	SMTPHost      string `mapstructure:"SMTP_HOST"`
+	SMTPPort      int    `mapstructure:"SMTP_PORT"`
+	SMTPUsername  string `mapstructure:"SMTP_USERNAME"`
+	SMTPPassword  string `mapstructure:"SMTP_PASSWORD"`
+
+	SMSBaseURL    string `mapstructure:"SMS_BASE_URL"`
+	SMSAccountSID string `mapstructure:"SMS_ACCOUNT_SID"`
+	SMSAuthToken  string `mapstructure:"SMS_AUTH_TOKEN"`
+	SMSFrom       string `mapstructure:"SMS_FROM"`
+
+	PushURL              string `mapstructure:"PUSH_URL"`
+	PushServerKey        string `mapstructure:"PUSH_SERVER_KEY"`
+	WebhookSigningSecret string `mapstructure:"WEBHOOK_SIGNING_SECRET"`
+
+	ReminderPollIntervalSeconds int `mapstructure:"REMINDER_POLL_INTERVAL_SECONDS"`
+	ReminderGracePeriodMinutes  int `mapstructure:"REMINDER_GRACE_PERIOD_MINUTES"`
+	ReminderLeaseSeconds        int `mapstructure:"REMINDER_LEASE_SECONDS"`
+	MissedDoseGracePeriodMins   int `mapstructure:"MISSED_DOSE_GRACE_PERIOD_MINUTES"`
 }
 
 // Setup initialize configuration
@@ -7,6 +7,8 @@ const AppName = "medbuddy"
 const (
 	Practitioner string = "practitioner"
 	Patient      string = "patient"
+	Admin        string = "admin"
+	Caregiver    string = "caregiver"
 	CounterKey   string = "counter"
 )
 
@@ -18,8 +20,26 @@ const (
 var Roles = map[string]int{
 	Practitioner: 1,
 	Patient:      2,
+	Admin:        3,
+	Caregiver:    4,
 }
 
+// RoleName returns the name of a role from its id
+func RoleName(role int) string {
+	for name, id := range Roles {
+		if id == role {
+			return name
+		}
+	}
+
+	return ""
+}
+
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
 const (
 	UsersCollection         = "users"
 	PatientsCollection      = "patients"
@@ -28,20 +48,188 @@ const (
 	MedicationCollection    = "medications"
 	DosageCollection        = "dosages"
 	TaskCollection          = "tasks"
+	LockCollection          = "locks"
+	RefreshTokenCollection  = "refresh_tokens"
+	RevokedTokenCollection  = "revoked_tokens"
+	UserTokenCollection     = "user_tokens"
+	LoginAttemptCollection  = "login_attempts"
+	CaregiversCollection    = "caregivers"
+	CaregiverLinkCollection = "caregiver_links"
+	AccessGrantCollection   = "access_grants"
+	AlertRuleCollection     = "alert_rules"
+	AlertCollection         = "alerts"
+	PrescriptionCollection  = "prescriptions"
+	DoseCountCollection     = "dose_counts"
 )
 
 const (
 	DosageTaken    = "taken"
 	DosageSkipped  = "skipped"
 	DosageNotTaken = "not taken"
+	DosageMissed   = "missed" // not taken nor skipped within the missed dose grace period
+)
+
+// types of medication schedules
+const (
+	ScheduleDaily      = "daily"        // DailyDosage dosages every day, at the dosage times
+	ScheduleInterval   = "interval"     // a dosage every IntervalHours, from the first dosage time
+	ScheduleWeekly     = "weekly"       // the dosage times of the given weekdays
+	ScheduleCyclic     = "cyclic"       // DaysOn days with the dosage times, then DaysOff days without
+	ScheduleEveryNDays = "every_n_days" // the dosage times of every EveryNDays-th day
+	ScheduleAsNeeded   = "as_needed"    // no reminders, up to MaxPerDay dosages recorded when taken
+
+	MaxScheduleIntervalHours = 72
+)
+
+const (
+	AdherenceIntervalDay  = "day"
+	AdherenceIntervalWeek = "week"
+
+	DefaultOnTimeWindow    = time.Hour
+	DefaultAdherencePeriod = 30 * 24 * time.Hour
+	MaxAdherencePeriod     = 366 * 24 * time.Hour
+)
+
+// risk flags raised on the patients of a practitioner's roster
+const (
+	RiskConsecutiveMissed = "consecutive_missed"
+	RiskLowAdherence      = "low_adherence"
+
+	RiskConsecutiveMissedThreshold = 3    // dosages missed in a row
+	RiskLowAdherenceThreshold      = 50.0 // recent adherence rate, in percent
+	RosterAdherencePeriod          = 7 * 24 * time.Hour
+)
+
+// fields the roster of a practitioner can be sorted by
+const (
+	RosterSortRisk      = "risk"
+	RosterSortName      = "name"
+	RosterSortAdherence = "adherence"
+	RosterSortLastTaken = "last_taken"
+)
+
+// names of the events published on the event bus
+const (
+	EventDoseMissed        = "dose.missed"
+	EventMedicationDeleted = "medication.deleted"
+	EventRefillNeeded      = "medication.refill_needed"
+)
+
+// states of a medication prescribed by a practitioner
+const (
+	PrescriptionPending   = "pending"
+	PrescriptionAccepted  = "accepted"
+	PrescriptionDeclined  = "declined"
+	PrescriptionCancelled = "cancelled" // withdrawn by the practitioner before the patient answered
+)
+
+// types of the rules practitioners are alerted on
+const (
+	AlertConsecutiveMissed = "consecutive_missed" // threshold is a number of doses missed in a row
+	AlertLowAdherence      = "low_adherence"      // threshold is an adherence rate over the last 7 days, in percent
+	AlertMedicationDeleted = "medication_deleted"
+)
+
+const (
+	AlertOpen         = "open"
+	AlertAcknowledged = "acknowledged"
+	AlertResolved     = "resolved"
+
+	AlertEvaluationInterval = 15 * time.Minute
+	AlertCooldown           = 24 * time.Hour // a rule does not alert twice on the same patient within this time
+)
+
+// states of a caregiver's or practitioner's access to a patient
+const (
+	InvitationPending  = "pending"
+	InvitationActive   = "active"
+	InvitationDeclined = "declined"
+	InvitationRevoked  = "revoked"
+	InvitationExpired  = "expired" // reported for active grants past their expiry date, never stored
+)
+
+const (
+	ChannelEmail   = "email"
+	ChannelSMS     = "sms"
+	ChannelPush    = "push"
+	ChannelWebhook = "webhook"
+)
+
+const (
+	TaskDone    = "done"
+	TaskUndone  = "undone"
+	TaskClaimed = "claimed"
+	TaskFailed  = "failed"
+	TaskExpired = "expired" // task was not dispatched within the grace period
+)
+
+const (
+	TaskKindSnooze   = "snooze"    // reminder of a snoozed dosage
+	TaskKindReRemind = "re_remind" // reminder sent again to a patient who did not set the status of the dosage
+)
+
+const (
+	DefaultReminderPollInterval = 30 * time.Second
+	DefaultReminderGracePeriod  = 60 * time.Minute
+	DefaultReminderLease        = 60 * time.Second
+	MaxTaskAttempts             = 3
+	// a failed task waits TaskRetryBackoffBase before it is tried again, twice as long after each
+	// further failure
+	TaskRetryBackoffBase = time.Minute
+	TaskRetryBackoffMax  = 15 * time.Minute
+
+	DefaultMissedDoseGracePeriod = 2 * time.Hour
+	// MissedDoseCorrectionWindow is how long after its reminder time a missed dosage can still be
+	// recorded as taken or skipped
+	MissedDoseCorrectionWindow = 24 * time.Hour
+	// MissedDoseBatchSize is the number of overdue dosages marked as missed per query
+	MissedDoseBatchSize = 500
+
+	// DefaultRefillThresholdDays is how many days of stock are left when a refill is needed, unless
+	// the medication has its own threshold
+	DefaultRefillThresholdDays = 7
+
+	// MaxSnoozeMinutes is the longest a reminder can be snoozed for at once
+	MaxSnoozeMinutes = 240
+	// MaxSnoozesPerDosage is how many times the reminder of a dosage can be snoozed
+	MaxSnoozesPerDosage = 5
+)
+
+const (
+	DefaultAccessTokenTTL  = 15 * time.Minute
+	DefaultRefreshTokenTTL = 30 * 24 * time.Hour
+	ResetPasswordTokenTTL  = time.Hour
+	VerifyEmailTokenTTL    = 24 * time.Hour
+	UnlockAccountTokenTTL  = 24 * time.Hour
+	InvitationTTL          = 7 * 24 * time.Hour
+	DosageActionLinkTTL    = 24 * time.Hour // how long the taken and skip links of a reminder email work
+)
+
+// Failed logins are counted per account and per client IP. Past a threshold every further attempt
+// has to wait twice as long as the previous one, and an account is locked after MaxFailedLogins.
+const (
+	LoginBackoffThreshold      = 3
+	LoginBackoffThresholdPerIP = 20
+	LoginBackoffBase           = time.Second
+	LoginBackoffMax            = 15 * time.Minute
+	MaxFailedLogins            = 10
+	LoginAttemptWindow         = 24 * time.Hour // failures are forgotten after a day without one
+)
+
+const (
+	LockedByFailedLogins = "failed_logins"
+	LockedByAdmin        = "admin"
 )
 
+// purposes of the single use tokens emailed to users
 const (
-	TaskDone   = "done"
-	TaskUndone = "undone"
+	TokenPurposeResetPassword = "reset_password"
+	TokenPurposeVerifyEmail   = "verify_email"
+	TokenPurposeUnlockAccount = "unlock_account"
+	TokenPurposeDosageAction  = "dosage_action" // recorded when a signed dosage action link is used
 )
 
 const (
-	TimeLapseForJobs   = 10 * time.Minute
-	TimeLapseInMinutes = 10
+	ReminderMaintenanceLock = "reminder-maintenance"
+	AlertEvaluationLock     = "alert-evaluation"
 )
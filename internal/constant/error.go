@@ -6,10 +6,13 @@ const (
 	ErrValidation   = "validation error"
 	ErrServer       = "server error"
 	ErrUnauthorized = "unauthorized"
+	ErrForbidden    = "forbidden"
 	ErrBinding      = "binding error"
 	ErrRequest      = "could not execute request"
 )
 
 var (
 	ErrResourceAlreadyExists = errors.New("resource already exists")
+	ErrDosageChanged         = errors.New("dosage was changed in the meantime")
+	ErrMedicationChanged     = errors.New("medication was changed in the meantime")
 )
@@ -39,6 +39,14 @@ func ResourceNotFoundError(message string) InternalError {
 	return newInternalError(http.StatusNotFound, message)
 }
 
+func UnauthorizedError(message string) InternalError {
+	return newInternalError(http.StatusUnauthorized, message)
+}
+
+func TooManyRequestsError(message string) InternalError {
+	return newInternalError(http.StatusTooManyRequests, message)
+}
+
 func ForbiddenError(message string) InternalError {
 	return newInternalError(http.StatusForbidden, message)
 }
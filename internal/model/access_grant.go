@@ -0,0 +1,50 @@
+package model
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// AccessGrant gives a practitioner read access to a medication of a patient, or to all of their
+// medications when MedicationID is not set. It starts pending and becomes active once the
+// practitioner accepts it. Either side can revoke it.
+type AccessGrant struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	PatientID      primitive.ObjectID `bson:"patient_id"`
+	PractitionerID primitive.ObjectID `bson:"practitioner_id"`
+	MedicationID   primitive.ObjectID `bson:"medication_id,omitempty"` // not set when the whole profile is shared
+	Status         string             `bson:"status"`
+	ExpiresAt      *time.Time         `bson:"expires_at,omitempty"` // not set when the grant does not expire
+	CreatedAt      time.Time          `bson:"created_at"`
+	RespondedAt    *time.Time         `bson:"responded_at,omitempty"`
+	RevokedAt      *time.Time         `bson:"revoked_at,omitempty"`
+	RevokedBy      string             `bson:"revoked_by,omitempty"` // role of the user who revoked the grant
+}
+
+type AccessGrantResponse struct {
+	ID             primitive.ObjectID   `json:"_id" bson:"_id"`
+	PatientID      primitive.ObjectID   `json:"patient_id" bson:"patient_id"`
+	PractitionerID primitive.ObjectID   `json:"practitioner_id" bson:"practitioner_id"`
+	MedicationID   primitive.ObjectID   `json:"medication_id,omitempty" bson:"medication_id,omitempty"`
+	Status         string               `json:"status" bson:"status"`
+	ExpiresAt      *time.Time           `json:"expires_at,omitempty" bson:"expires_at,omitempty"`
+	CreatedAt      time.Time            `json:"created_at" bson:"created_at"`
+	RespondedAt    *time.Time           `json:"responded_at,omitempty" bson:"responded_at,omitempty"`
+	RevokedAt      *time.Time           `json:"revoked_at,omitempty" bson:"revoked_at,omitempty"`
+	RevokedBy      string               `json:"revoked_by,omitempty" bson:"revoked_by,omitempty"`
+	Patient        PatientForDosage     `json:"patient,omitempty" bson:"patient"`
+	Practitioner   Practitioner         `json:"practitioner,omitempty" bson:"practitioner"`
+	Medication     *MedicationForDosage `json:"medication,omitempty" bson:"medication,omitempty"`
+}
+
+type AccessGrantFilter struct {
+	PatientID      primitive.ObjectID
+	PractitionerID primitive.ObjectID
+	Status         string
+}
+
+type ShareRequest struct {
+	PractitionerEmails []string `json:"practitioner_emails" validate:"required,min=1,dive,email"`
+	MedicationID       string   `json:"medication_id"` // empty to share the whole profile
+	ExpiresAt          string   `json:"expires_at"`    // YYYY-MM-DD, empty for a grant that does not expire
+}
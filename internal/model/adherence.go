@@ -0,0 +1,54 @@
+package model
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// AdherenceQuery holds the query parameters of the adherence endpoints
+type AdherenceQuery struct {
+	From          string // YYYY-MM-DD, defaults to 30 days ago
+	To            string // YYYY-MM-DD, inclusive, defaults to today
+	Interval      string // day or week
+	OnTimeMinutes int    // a dosage taken within this many minutes of its reminder time is on time
+}
+
+type AdherenceFilter struct {
+	PatientID    primitive.ObjectID
+	MedicationID primitive.ObjectID
+	From         time.Time
+	To           time.Time // exclusive
+	Interval     string
+	OnTimeWindow time.Duration
+	TimeZone     string // IANA time zone of the days and weeks of the series, UTC when not set
+}
+
+// AdherenceStats counts the dosages due in a period by status. Pending dosages are past their
+// reminder time but not yet taken, skipped or missed, they are left out of the rates.
+type AdherenceStats struct {
+	Taken         int     `json:"taken" bson:"taken"`
+	Skipped       int     `json:"skipped" bson:"skipped"`
+	Missed        int     `json:"missed" bson:"missed"`
+	Pending       int     `json:"pending" bson:"pending"`
+	OnTime        int     `json:"on_time" bson:"on_time"`
+	AdherenceRate float64 `json:"adherence_rate" bson:"adherence_rate"` // percentage of the dosages that were taken
+	OnTimeRate    float64 `json:"on_time_rate" bson:"on_time_rate"`     // percentage of the taken dosages taken on time
+}
+
+type AdherencePoint struct {
+	Period         time.Time `json:"period" bson:"_id"` // start of the day or week
+	AdherenceStats `bson:",inline"`
+}
+
+type AdherenceReport struct {
+	PatientID           primitive.ObjectID  `json:"patient_id"`
+	MedicationID        *primitive.ObjectID `json:"medication_id,omitempty"`
+	From                time.Time           `json:"from"`
+	To                  time.Time           `json:"to"`
+	Interval            string              `json:"interval"`
+	OnTimeWindowMinutes int                 `json:"on_time_window_minutes"`
+	Summary             AdherenceStats      `json:"summary"`
+	LongestStreak       int                 `json:"longest_streak"` // most dosages taken in a row
+	CurrentStreak       int                 `json:"current_streak"` // dosages taken in a row up to the last one
+	Series              []AdherencePoint    `json:"series"`
+}
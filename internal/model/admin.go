@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+type AdminResponse struct {
+	User         User   `json:"user"`
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+type UserFilter struct {
+	Role   int
+	Locked *bool
+	Email  string
+	Page   int
+	Limit  int
+}
+
+type UserListResponse struct {
+	Users []User `json:"users"`
+	Total int64  `json:"total"`
+	Page  int    `json:"page"`
+	Limit int    `json:"limit"`
+}
+
+type MedicineListResponse struct {
+	Medicines []Medicine `json:"medicines"`
+	Total     int64      `json:"total"`
+	Page      int        `json:"page"`
+	Limit     int        `json:"limit"`
+}
+
+// JobStatus describes the state of the reminder jobs shared by every server instance
+type JobStatus struct {
+	Tasks           map[string]int64 `json:"tasks"`         // number of tasks per status
+	OverdueTasks    int64            `json:"overdue_tasks"` // due tasks that should have been sent already
+	NextTaskAt      *time.Time       `json:"next_task_at,omitempty"`
+	PollInterval    string           `json:"poll_interval"`
+	MaintenanceLock *Lock            `json:"maintenance_lock,omitempty"` // instance running the housekeeping jobs
+}
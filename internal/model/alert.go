@@ -0,0 +1,80 @@
+package model
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// AlertRule tells when a practitioner wants to be alerted about the patients shared with them. The
+// threshold is not used by medication_deleted rules.
+type AlertRule struct {
+	ID             primitive.ObjectID `json:"_id" bson:"_id"`
+	PractitionerID primitive.ObjectID `json:"practitioner_id" bson:"practitioner_id"`
+	PatientID      primitive.ObjectID `json:"patient_id,omitempty" bson:"patient_id,omitempty"` // not set when the rule applies to every patient
+	Type           string             `json:"type" bson:"type"`
+	Threshold      float64            `json:"threshold,omitempty" bson:"threshold,omitempty"`
+	Enabled        bool               `json:"enabled" bson:"enabled"`
+	CreatedAt      time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt      time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+type AlertRuleRequest struct {
+	Type      string  `json:"type" validate:"required,oneof=consecutive_missed low_adherence medication_deleted"`
+	Threshold float64 `json:"threshold"`
+	PatientID string  `json:"patient_id"` // empty for a rule on every patient
+	Enabled   *bool   `json:"enabled"`    // defaults to true
+}
+
+// UpdateAlertRuleRequest holds the fields of a rule that can be changed, fields left out keep their
+// current value
+type UpdateAlertRuleRequest struct {
+	Threshold *float64 `json:"threshold,omitempty"`
+	Enabled   *bool    `json:"enabled,omitempty"`
+}
+
+type AlertRuleFilter struct {
+	PractitionerIDs []primitive.ObjectID
+	Types           []string
+}
+
+// Alert is raised by a rule on a patient. It stays open until the practitioner acknowledges or
+// resolves it.
+type Alert struct {
+	ID             primitive.ObjectID `json:"_id" bson:"_id"`
+	RuleID         primitive.ObjectID `json:"rule_id" bson:"rule_id"`
+	PractitionerID primitive.ObjectID `json:"practitioner_id" bson:"practitioner_id"`
+	PatientID      primitive.ObjectID `json:"patient_id" bson:"patient_id"`
+	MedicationID   primitive.ObjectID `json:"medication_id,omitempty" bson:"medication_id,omitempty"`
+	Type           string             `json:"type" bson:"type"`
+	Message        string             `json:"message" bson:"message"`
+	Value          float64            `json:"value,omitempty" bson:"value,omitempty"` // what crossed the threshold of the rule
+	Status         string             `json:"status" bson:"status"`
+	CreatedAt      time.Time          `json:"created_at" bson:"created_at"`
+	AcknowledgedAt *time.Time         `json:"acknowledged_at,omitempty" bson:"acknowledged_at,omitempty"`
+	ResolvedAt     *time.Time         `json:"resolved_at,omitempty" bson:"resolved_at,omitempty"`
+	Patient        *PatientForDosage  `json:"patient,omitempty" bson:"patient,omitempty"`
+}
+
+type AlertFilter struct {
+	PractitionerID primitive.ObjectID
+	Status         string
+	Page           int
+	Limit          int
+}
+
+type AlertListResponse struct {
+	Alerts []Alert `json:"alerts"`
+	Total  int64   `json:"total"`
+	Page   int     `json:"page"`
+	Limit  int     `json:"limit"`
+}
+
+// DeletedMedication is the payload of the medication.deleted event
+type DeletedMedication struct {
+	MedicationID    primitive.ObjectID
+	PatientID       primitive.ObjectID
+	PatientName     string
+	Name            string
+	PractitionerIDs []primitive.ObjectID // practitioners who had access to the medication
+	DeletedAt       time.Time
+}
@@ -0,0 +1,84 @@
+package model
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type Caregiver struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	FullName string             `bson:"full_name,omitempty" json:"fullname,omitempty"`
+	Email    string             `bson:"email,omitempty" json:"email,omitempty"`
+	Phone    string             `bson:"phone,omitempty" json:"phone,omitempty"`
+	UserID   primitive.ObjectID `bson:"user_id,omitempty" json:"user_id,omitempty"`
+}
+
+type CaregiverRequest struct {
+	Firstname string `json:"firstname,omitempty" validate:"required"`
+	Lastname  string `json:"lastname,omitempty" validate:"required"`
+	DOB       string `json:"dob,omitempty"`
+	Gender    string `json:"gender" validate:"required,oneof='male' 'female''"`
+	Email     string `json:"email,omitempty" validate:"email,required"`
+	Phone     string `json:"phone,omitempty"`
+	Password  string `json:"password,omitempty" validate:"required,min=8"`
+}
+
+type CaregiverResponse struct {
+	ID           primitive.ObjectID `json:"_id,omitempty" bson:"_id"`
+	FullName     string             `json:"fullname,omitempty" bson:"full_name"`
+	Email        string             `json:"email,omitempty" bson:"email"`
+	Phone        string             `json:"phone,omitempty" bson:"phone"`
+	UserID       primitive.ObjectID `json:"user_id,omitempty" bson:"user_id"`
+	User         User               `json:"user" bson:"user"`
+	Token        string             `json:"token,omitempty"`
+	RefreshToken string             `json:"refresh_token,omitempty"`
+}
+
+// CaregiverLink gives a caregiver delegated access to the schedule of a patient. It starts as an
+// invitation emailed to the caregiver and becomes active once the caregiver accepts it.
+type CaregiverLink struct {
+	ID               primitive.ObjectID `bson:"_id"`
+	PatientID        primitive.ObjectID `bson:"patient_id"`
+	CaregiverID      primitive.ObjectID `bson:"caregiver_id,omitempty"` // set when the invitation is accepted
+	Email            string             `bson:"email"`                  // address the invitation was sent to
+	Status           string             `bson:"status"`
+	ReceiveReminders bool               `bson:"receive_reminders"` // whether the caregiver gets copies of the reminders
+	TokenHash        string             `bson:"token_hash,omitempty"`
+	ExpiresAt        time.Time          `bson:"expires_at"` // when the invitation can no longer be accepted
+	CreatedAt        time.Time          `bson:"created_at"`
+	AcceptedAt       *time.Time         `bson:"accepted_at,omitempty"`
+	RevokedAt        *time.Time         `bson:"revoked_at,omitempty"`
+}
+
+type CaregiverLinkResponse struct {
+	ID               primitive.ObjectID `json:"_id" bson:"_id"`
+	PatientID        primitive.ObjectID `json:"patient_id" bson:"patient_id"`
+	CaregiverID      primitive.ObjectID `json:"caregiver_id,omitempty" bson:"caregiver_id,omitempty"`
+	Email            string             `json:"email" bson:"email"`
+	Status           string             `json:"status" bson:"status"`
+	ReceiveReminders bool               `json:"receive_reminders" bson:"receive_reminders"`
+	ExpiresAt        time.Time          `json:"expires_at" bson:"expires_at"`
+	CreatedAt        time.Time          `json:"created_at" bson:"created_at"`
+	AcceptedAt       *time.Time         `json:"accepted_at,omitempty" bson:"accepted_at,omitempty"`
+	Patient          PatientForDosage   `json:"patient,omitempty" bson:"patient"`
+	Caregiver        Caregiver          `json:"caregiver,omitempty" bson:"caregiver"`
+}
+
+type InviteCaregiverRequest struct {
+	Email            string `json:"email" validate:"required,email"`
+	ReceiveReminders bool   `json:"receive_reminders"`
+}
+
+type UpdateCaregiverLinkRequest struct {
+	ReceiveReminders *bool `json:"receive_reminders" validate:"required"`
+}
+
+type AcceptInvitationRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// RecordedBy is the user who set the status of a dosage, the patient or one of their caregivers
+type RecordedBy struct {
+	ID   primitive.ObjectID `json:"id" bson:"id"`
+	Role string             `json:"role" bson:"role"`
+}
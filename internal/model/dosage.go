@@ -11,9 +11,22 @@ type Dosage struct {
 	Status       string             `json:"status" bson:"status"`
 	TimeTaken    time.Time          `json:"time_taken,omitempty" bson:"time_taken"`
 	TimeSkipped  time.Time          `json:"time_skipped,omitempty" bson:"time_skipped"`
+	TimeMissed   *time.Time         `json:"time_missed,omitempty" bson:"time_missed,omitempty"`
 	IsActive     bool               `json:"is_active" bson:"is_active"`
 	MedicationID primitive.ObjectID `json:"medication_id" bson:"medication_id"`
 	PatientID    primitive.ObjectID `json:"patient_id" bson:"patient_id"`
+	RecordedBy   *RecordedBy        `json:"recorded_by,omitempty" bson:"recorded_by,omitempty"`
+	AsNeeded     bool               `json:"as_needed,omitempty" bson:"as_needed,omitempty"` // recorded when taken, without a reminder
+	Quantity     string             `json:"quantity,omitempty" bson:"quantity,omitempty"`   // dosage quantity of the medication when the dosage was scheduled
+	Snoozes      []Snooze           `json:"snoozes,omitempty" bson:"snoozes,omitempty"`
+	SnoozedUntil *time.Time         `json:"snoozed_until,omitempty" bson:"snoozed_until,omitempty"` // the dosage is reminded again, and becomes overdue, from then on
+}
+
+// Snooze is a reminder of a dosage put off until later
+type Snooze struct {
+	SnoozedAt time.Time   `json:"snoozed_at" bson:"snoozed_at"`
+	Until     time.Time   `json:"until" bson:"until"`
+	SnoozedBy *RecordedBy `json:"snoozed_by,omitempty" bson:"snoozed_by,omitempty"`
 }
 
 type DosageResponse struct {
@@ -22,10 +35,18 @@ type DosageResponse struct {
 	Status       string              `json:"status" bson:"status"`
 	TimeTaken    time.Time           `json:"time_taken,omitempty" bson:"time_taken"`
 	TimeSkipped  time.Time           `json:"time_skipped,omitempty" bson:"time_skipped"`
+	TimeMissed   *time.Time          `json:"time_missed,omitempty" bson:"time_missed,omitempty"`
 	IsActive     bool                `json:"is_active" bson:"is_active"`
 	MedicationID primitive.ObjectID  `json:"medication_id" bson:"medication_id"`
 	Medication   MedicationForDosage `json:"medication,omitempty" bson:"medication"`
 	PatientID    primitive.ObjectID  `json:"patient_id" bson:"patient_id"`
+	RecordedBy   *RecordedBy         `json:"recorded_by,omitempty" bson:"recorded_by,omitempty"`
+	AsNeeded     bool                `json:"as_needed,omitempty" bson:"as_needed,omitempty"`
+	Quantity     string              `json:"quantity,omitempty" bson:"quantity,omitempty"`
+	Snoozes      []Snooze            `json:"snoozes,omitempty" bson:"snoozes,omitempty"`
+	SnoozedUntil *time.Time          `json:"snoozed_until,omitempty" bson:"snoozed_until,omitempty"`
+
+	CaregiverIDs []primitive.ObjectID `json:"-" bson:"caregiver_ids"`
 }
 
 type MedicationForDosage struct {
@@ -35,18 +56,25 @@ type MedicationForDosage struct {
 	DailyDosage         int                `bson:"daily_dosage" json:"daily_dosage,omitempty"`                     // measure (quantity) of dosage per day
 	TotalNumberOfDosage int                `bson:"total_number_of_dosage" json:"total_number_of_dosage,omitempty"` // total number of dosages
 	DosagesTaken        int                `bson:"dosages_taken" json:"dosages_taken,omitempty"`
+	DosagesSkipped      int                `bson:"dosages_skipped" json:"dosages_skipped,omitempty"`
+	DosagesMissed       int                `bson:"dosages_missed" json:"dosages_missed,omitempty"`
 	Treatment           string             `bson:"treatment" json:"treatment,omitempty"` // sickness/disease
 	Comment             string             `bson:"comment" json:"comment,omitempty"`
+	TimeZone            string             `bson:"time_zone" json:"time_zone,omitempty"`
 	MedicineID          primitive.ObjectID `bson:"medicine_id" json:"medicine_id"`
 	Medicine            Medicine           `bson:"medicine" json:"medicine"`
 	PatientID           primitive.ObjectID `bson:"patient_id" json:"patient_id"`
 	Patient             PatientForDosage   `bson:"patient" json:"patient"`
+
+	PractitionerIDs []primitive.ObjectID `bson:"practitioner_ids" json:"-"`
 }
 
 type PatientForDosage struct {
 	FullName string             `bson:"full_name" json:"full_name,omitempty"`
 	Email    string             `bson:"email" json:"email,omitempty"`
 	UserID   primitive.ObjectID `bson:"user_id" json:"user_id,omitempty"`
+
+	NotificationPreferences NotificationPreferences `bson:"notification_preferences" json:"-"`
 }
 
 type DosageFilter struct {
@@ -55,6 +83,29 @@ type DosageFilter struct {
 	IsActive     *bool
 }
 
+// MissedDose is the payload of the dose.missed event
+type MissedDose struct {
+	DosageID     primitive.ObjectID
+	MedicationID primitive.ObjectID
+	PatientID    primitive.ObjectID
+	ReminderTime time.Time
+	MissedAt     time.Time
+}
+
 type SetStatusRequest struct {
 	Status string `json:"status" validate:"required"`
 }
+
+// DosageAction is the status a one-click link of a reminder email sets a dosage to
+type DosageAction struct {
+	DosageID     primitive.ObjectID
+	Status       string
+	PatientName  string
+	Medicine     string
+	Quantity     string
+	ReminderTime time.Time // in the time zone of the medication
+}
+
+type SnoozeRequest struct {
+	Minutes int `json:"minutes" validate:"required,min=1"`
+}
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// Lock is a named lease held by one server instance, used to elect the instance
+// that runs a background job
+type Lock struct {
+	Name      string    `json:"name" bson:"_id"`
+	Owner     string    `json:"owner" bson:"owner"`
+	ExpiresAt time.Time `json:"expires_at" bson:"expires_at"`
+	RenewedAt time.Time `json:"renewed_at" bson:"renewed_at"`
+}
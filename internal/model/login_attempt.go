@@ -0,0 +1,11 @@
+package model
+
+import "time"
+
+// LoginAttempt counts the failed logins for an account or a client IP since the last success
+type LoginAttempt struct {
+	Key           string    `bson:"_id"` // "email:<address>" or "ip:<address>"
+	Failures      int       `bson:"failures"`
+	LastFailureAt time.Time `bson:"last_failure_at"`
+	ExpiresAt     time.Time `bson:"expires_at"`
+}
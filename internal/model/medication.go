@@ -12,8 +12,11 @@ type Medication struct {
 	EndDate             time.Time            `bson:"end_date"`
 	DosageQuantity      string               `bson:"dosage_quantity"`        // measure (quantity) of medicine taken per dosage
 	DailyDosage         int                  `bson:"daily_dosage"`           // measure (quantity) of dosage per day
+	DosageTimes         []string             `bson:"dosage_times"`           // time of day for each daily dosage
 	TotalNumberOfDosage int                  `bson:"total_number_of_dosage"` // total number of dosages
 	DosagesTaken        int                  `bson:"dosages_taken"`
+	DosagesSkipped      int                  `bson:"dosages_skipped"`
+	DosagesMissed       int                  `bson:"dosages_missed"`
 	Treatment           string               `bson:"treatment"` // sickness/disease
 	Comment             string               `bson:"comment"`
 	IsActive            bool                 `bson:"is_active"`
@@ -21,23 +24,132 @@ type Medication struct {
 	UpdatedAt           time.Time            `bson:"updated_at"`
 	PatientID           primitive.ObjectID   `bson:"patient_id"`
 	MedicineID          primitive.ObjectID   `bson:"medicine_id"`
-	PractitionerIDs     []primitive.ObjectID `bson:"practitioner_ids"`
+	PractitionerIDs     []primitive.ObjectID `bson:"practitioner_ids,omitempty"` // no longer stored, access is given through access grants
+	PrescribedBy        primitive.ObjectID   `bson:"prescribed_by,omitempty"`    // practitioner who prescribed the medication, if any
+	Schedule            *Schedule            `bson:"schedule,omitempty"`         // not set for daily medications
+	Phases              []Phase              `bson:"phases"`                     // not set for medications taken at the same dosage throughout
+	TimeZone            string               `bson:"time_zone,omitempty"`        // IANA time zone the dosage times are in, the server's when not set
+	Inventory           *Inventory           `bson:"inventory,omitempty"`        // not set when the stock is not tracked
+	PausedAt            *time.Time           `bson:"paused_at,omitempty"`        // set while the medication is paused
+	ResumeAt            *time.Time           `bson:"resume_at,omitempty"`        // when a paused medication is resumed automatically, if ever
+	DiscontinuedAt      *time.Time           `bson:"discontinued_at,omitempty"`
+	DiscontinuedReason  string               `bson:"discontinued_reason,omitempty"`
 }
 
+// Inventory is the stock of a medication the patient has on hand, counted in the units of its
+// dosage quantity, e.g. 30 for "1 tablet" dosages
+type Inventory struct {
+	Stock               float64    `json:"stock" bson:"stock"`
+	RefillThresholdDays int        `json:"refill_threshold_days" bson:"refill_threshold_days"` // a refill is needed when the stock lasts fewer days
+	RefillNotifiedAt    *time.Time `json:"refill_notified_at,omitempty" bson:"refill_notified_at,omitempty"`
+	LastRefilledAt      *time.Time `json:"last_refilled_at,omitempty" bson:"last_refilled_at,omitempty"`
+}
+
+type InventoryRequest struct {
+	Stock               float64 `json:"stock" bson:"stock" validate:"min=0"`
+	RefillThresholdDays int     `json:"refill_threshold_days,omitempty" bson:"refill_threshold_days,omitempty" validate:"omitempty,min=1,max=90"`
+}
+
+type RefillRequest struct {
+	Quantity float64 `json:"quantity" validate:"required,gt=0"`
+}
+
+// RefillNeeded is the payload of the medication.refill_needed event
+type RefillNeeded struct {
+	MedicationID primitive.ObjectID
+	PatientID    primitive.ObjectID
+	Stock        float64
+	Unit         string // e.g. tablets
+	RunsOutAt    time.Time
+}
+
+// PauseMedicationRequest pauses the reminders of a medication, until the day it is resumed on
+// when one is given
+type PauseMedicationRequest struct {
+	ResumeOn string `json:"resume_on,omitempty"` // YYYY-MM-DD, in the time zone of the medication
+}
+
+type DiscontinueMedicationRequest struct {
+	Reason string `json:"reason" validate:"required"`
+}
+
+// Phase is a number of days during which a tapered or titrated medication is taken at the same
+// dosage quantity and times
+type Phase struct {
+	DosageQuantity string   `json:"dosage_quantity" bson:"dosage_quantity"`
+	DosageTimes    []string `json:"dosage_times" bson:"dosage_times"`
+	Days           int      `json:"days" bson:"days"`
+}
+
+// Schedule describes when the dosages of a medication are due. Only the fields of its type are used.
+type Schedule struct {
+	Type          string   `json:"type" bson:"type" validate:"required,oneof=daily interval weekly cyclic every_n_days as_needed"`
+	IntervalHours int      `json:"interval_hours,omitempty" bson:"interval_hours,omitempty"` // interval
+	Weekdays      []string `json:"weekdays,omitempty" bson:"weekdays,omitempty"`             // weekly, e.g. monday
+	DaysOn        int      `json:"days_on,omitempty" bson:"days_on,omitempty"`               // cyclic
+	DaysOff       int      `json:"days_off,omitempty" bson:"days_off,omitempty"`             // cyclic
+	EveryNDays    int      `json:"every_n_days,omitempty" bson:"every_n_days,omitempty"`     // every_n_days
+	MaxPerDay     int      `json:"max_per_day,omitempty" bson:"max_per_day,omitempty"`       // as_needed
+}
+
+// MedicationRequest is stored as is on the prescriptions waiting for the patient to accept them
 type MedicationRequest struct {
-	Name                string               `json:"name,omitempty" validate:"required"`
-	StartDate           string               `json:"start_date" validate:"required"`
-	EndDate             string               `json:"end_date"`
-	DosageQuantity      string               `json:"dosage_quantity,omitempty" validate:"required"`
-	DailyDosage         int                  `json:"daily_dosage,omitempty" validate:"required"`
-	TotalNumberOfDosage int                  `json:"total_number_of_dosage"` // total number of dosages
-	DosageTimes         []string             `json:"dosage_times,omitempty" validate:"required"`
-	Treatment           string               `json:"treatment,omitempty" validate:"required"`
-	Comment             string               `json:"comment"`
-	CreatedAt           time.Time            `json:"created_at"`
-	UpdatedAt           time.Time            `json:"updated_at"`
-	Medicine            Medicine             `json:"medicine" validate:"required"`
-	PractitionerIDs     []primitive.ObjectID `json:"practitioner_ids"`
+	Name                string               `json:"name,omitempty" bson:"name" validate:"required"`
+	StartDate           string               `json:"start_date" bson:"start_date" validate:"required"`
+	EndDate             string               `json:"end_date" bson:"end_date"`
+	DosageQuantity      string               `json:"dosage_quantity,omitempty" bson:"dosage_quantity"`                    // given per phase for phased medications
+	DailyDosage         int                  `json:"daily_dosage,omitempty" bson:"daily_dosage"`                          // not used by as needed schedules
+	TotalNumberOfDosage int                  `json:"total_number_of_dosage" bson:"total_number_of_dosage"`                // total number of dosages, optional for as needed schedules
+	DosageTimes         []string             `json:"dosage_times,omitempty" bson:"dosage_times"`                          // only the first dosage time for interval schedules
+	Schedule            *Schedule            `json:"schedule,omitempty" bson:"schedule,omitempty" validate:"omitempty"`   // daily when not set
+	Phases              []Phase              `json:"phases,omitempty" bson:"phases,omitempty"`                            // the phases of a tapered or titrated medication, in order
+	TimeZone            string               `json:"time_zone,omitempty" bson:"time_zone,omitempty"`                      // the patient's time zone when not set
+	Inventory           *InventoryRequest    `json:"inventory,omitempty" bson:"inventory,omitempty" validate:"omitempty"` // tracks the stock when set
+	Treatment           string               `json:"treatment,omitempty" bson:"treatment" validate:"required"`
+	Comment             string               `json:"comment" bson:"comment"`
+	CreatedAt           time.Time            `json:"created_at" bson:"-"`
+	UpdatedAt           time.Time            `json:"updated_at" bson:"-"`
+	Medicine            Medicine             `json:"medicine" bson:"medicine" validate:"required"`
+	PractitionerIDs     []primitive.ObjectID `json:"practitioner_ids" bson:"-"`
+}
+
+// UpdateMedicationRequest holds the fields of a medication that can be changed after it has
+// been created. Fields left out of the request keep their current value.
+type UpdateMedicationRequest struct {
+	Name                *string           `json:"name,omitempty"`
+	StartDate           *string           `json:"start_date,omitempty"`
+	DosageQuantity      *string           `json:"dosage_quantity,omitempty"`
+	DailyDosage         *int              `json:"daily_dosage,omitempty" validate:"omitempty,min=1"`
+	TotalNumberOfDosage *int              `json:"total_number_of_dosage,omitempty" validate:"omitempty,min=1"`
+	DosageTimes         []string          `json:"dosage_times,omitempty"`
+	Schedule            *Schedule         `json:"schedule,omitempty" validate:"omitempty"`
+	Phases              []Phase           `json:"phases,omitempty"` // replaces the phases of the medication, dosage times without phases remove them
+	TimeZone            *string           `json:"time_zone,omitempty"`
+	Inventory           *InventoryRequest `json:"inventory,omitempty" validate:"omitempty"` // replaces the stock and the refill threshold
+	Treatment           *string           `json:"treatment,omitempty"`
+	Comment             *string           `json:"comment,omitempty"`
+}
+
+// MedicationUpdate holds what an update writes to a medication. Fields left nil are not written.
+type MedicationUpdate struct {
+	Name      *string
+	Treatment *string
+	Comment   *string
+	Schedule  *MedicationSchedule // written as a whole, its fields are worked out together
+	Inventory *Inventory
+	UpdatedAt time.Time
+}
+
+// MedicationSchedule holds the fields of a medication its dosages are scheduled from
+type MedicationSchedule struct {
+	StartDate           time.Time
+	DosageQuantity      string
+	DailyDosage         int
+	DosageTimes         []string
+	TotalNumberOfDosage int
+	Schedule            *Schedule
+	Phases              []Phase
+	TimeZone            string
 }
 
 type MedicationResponse struct {
@@ -47,8 +159,11 @@ type MedicationResponse struct {
 	EndDate             time.Time            `json:"end_date" bson:"end_date"`
 	DosageQuantity      string               `json:"dosage_quantity,omitempty" bson:"dosage_quantity"`
 	DailyDosage         int                  `json:"daily_dosage,omitempty" bson:"daily_dosage"`
+	DosageTimes         []string             `json:"dosage_times,omitempty" bson:"dosage_times"`
 	Dosages             []Dosage             `json:"dosages,omitempty" bson:"dosages"`
 	DosagesTaken        int                  `json:"dosages_taken" bson:"dosages_taken"`
+	DosagesSkipped      int                  `json:"dosages_skipped" bson:"dosages_skipped"`
+	DosagesMissed       int                  `json:"dosages_missed" bson:"dosages_missed"`
 	TotalNumberOfDosage int                  `json:"total_number_of_dosage" bson:"total_number_of_dosage"` // total number of dosages
 	Treatment           string               `json:"treatment,omitempty" bson:"treatment"`
 	Comment             string               `json:"comment" bson:"comment"`
@@ -60,4 +175,15 @@ type MedicationResponse struct {
 	PatientID           primitive.ObjectID   `json:"patient_id" bson:"patient_id"`
 	Patient             Patient              `json:"patient" bson:"patient"`
 	PractitionerIDs     []primitive.ObjectID `json:"practitioner_ids,omitempty" bson:"practitioner_ids"`
+	PrescribedBy        primitive.ObjectID   `json:"prescribed_by,omitempty" bson:"prescribed_by,omitempty"`
+	Schedule            *Schedule            `json:"schedule,omitempty" bson:"schedule,omitempty"`
+	Phases              []Phase              `json:"phases,omitempty" bson:"phases,omitempty"`
+	TimeZone            string               `json:"time_zone,omitempty" bson:"time_zone,omitempty"`
+	Inventory           *Inventory           `json:"inventory,omitempty" bson:"inventory,omitempty"`
+	PausedAt            *time.Time           `json:"paused_at,omitempty" bson:"paused_at,omitempty"`
+	ResumeAt            *time.Time           `json:"resume_at,omitempty" bson:"resume_at,omitempty"`
+	DiscontinuedAt      *time.Time           `json:"discontinued_at,omitempty" bson:"discontinued_at,omitempty"`
+	DiscontinuedReason  string               `json:"discontinued_reason,omitempty" bson:"discontinued_reason,omitempty"`
+
+	CaregiverIDs []primitive.ObjectID `json:"-" bson:"caregiver_ids"`
 }
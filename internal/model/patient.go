@@ -7,6 +7,31 @@ type Patient struct {
 	FullName string             `bson:"full_name" json:"full_name,omitempty"`
 	Email    string             `bson:"email" json:"email,omitempty"`
 	UserID   primitive.ObjectID `bson:"user_id" json:"user_id,omitempty"`
+	TimeZone string             `bson:"time_zone,omitempty" json:"time_zone,omitempty"` // IANA time zone, the server's when not set
+
+	NotificationPreferences NotificationPreferences `bson:"notification_preferences" json:"notification_preferences"`
+}
+
+// NotificationPreferences holds the channels a patient is reminded on and their addresses on
+// the channels that do not use the account email
+type NotificationPreferences struct {
+	Channels   []string `bson:"channels" json:"channels"`
+	Phone      string   `bson:"phone,omitempty" json:"phone,omitempty"`
+	PushToken  string   `bson:"push_token,omitempty" json:"push_token,omitempty"`
+	WebhookURL string   `bson:"webhook_url,omitempty" json:"webhook_url,omitempty"`
+
+	// ReRemindMinutes is how long after a reminder it is sent again when the dosage still has no
+	// status, 0 to remind once
+	ReRemindMinutes int `bson:"re_remind_minutes,omitempty" json:"re_remind_minutes,omitempty"`
+}
+
+type UpdateNotificationPreferencesRequest struct {
+	Channels   []string `json:"channels" validate:"required,min=1,unique,dive,oneof=email sms push webhook"`
+	Phone      string   `json:"phone,omitempty" validate:"omitempty,e164"`
+	PushToken  string   `json:"push_token,omitempty"`
+	WebhookURL string   `json:"webhook_url,omitempty" validate:"omitempty,url,startswith=https://"`
+
+	ReRemindMinutes int `json:"re_remind_minutes,omitempty" validate:"omitempty,min=5,max=120"`
 }
 
 type CreatePatientReq struct {
@@ -16,6 +41,17 @@ type CreatePatientReq struct {
 	Gender    string `json:"gender" validate:"required,oneof='male' 'female''"`
 	Email     string `json:"email,omitempty" validate:"email,required"`
 	Password  string `json:"password,omitempty" validate:"required,min=8"`
+	TimeZone  string `json:"time_zone,omitempty"` // IANA time zone, e.g. Africa/Lagos
+}
+
+type UpdateTimeZoneRequest struct {
+	TimeZone     string `json:"time_zone" validate:"required"`
+	ShiftDosages bool   `json:"shift_dosages"` // move the upcoming dosages to the same time of day in the new time zone
+}
+
+type UpdateTimeZoneResponse struct {
+	TimeZone           string `json:"time_zone"`
+	ShiftedMedications int    `json:"shifted_medications"`
 }
 
 type PatientResponse struct {
@@ -23,6 +59,11 @@ type PatientResponse struct {
 	FullName string             `json:"fullname,omitempty" bson:"full_name"`
 	Email    string             `json:"email,omitempty" bson:"email"`
 	UserID   primitive.ObjectID `json:"user_id,omitempty" bson:"user_id"`
+	TimeZone string             `json:"time_zone,omitempty" bson:"time_zone,omitempty"`
 	User     User               `json:"user" bson:"user"`
-	Token    string             `json:"token,omitempty"`
+
+	NotificationPreferences NotificationPreferences `json:"notification_preferences" bson:"notification_preferences"`
+
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
 }
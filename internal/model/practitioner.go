@@ -1,14 +1,17 @@
 package model
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
 
 type Practitioner struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty"`
-	FullName  string             `bson:"full_name,omitempty"`
-	Title     string             `bson:"title,omitempty"`
-	Email     string             `bson:"email,omitempty"`
-	UserId    primitive.ObjectID `bson:"user_id,omitempty"`
-	Expertise string             `bson:"expertise,omitempty"`
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	FullName  string             `bson:"full_name,omitempty" json:"fullname,omitempty"`
+	Title     string             `bson:"title,omitempty" json:"title,omitempty"`
+	Email     string             `bson:"email,omitempty" json:"email,omitempty"`
+	UserId    primitive.ObjectID `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	Expertise string             `bson:"expertise,omitempty" json:"expertise,omitempty"`
 }
 
 type PractitionerRequest struct {
@@ -23,12 +26,75 @@ type PractitionerRequest struct {
 }
 
 type PractitionerResponse struct {
-	ID        primitive.ObjectID `json:"_id,omitempty" bson:"_id"`
-	FullName  string             `json:"fullname,omitempty" bson:"full_name"`
-	Email     string             `json:"email,omitempty" bson:"email"`
-	Expertise string             `json:"expertise,omitempty"`
-	Title     string             `json:"title,omitempty"`
-	UserID    primitive.ObjectID `json:"user_id,omitempty" bson:"user_id"`
-	User      User               `json:"user" bson:"user"`
-	Token     string             `json:"token,omitempty"`
+	ID           primitive.ObjectID `json:"_id,omitempty" bson:"_id"`
+	FullName     string             `json:"fullname,omitempty" bson:"full_name"`
+	Email        string             `json:"email,omitempty" bson:"email"`
+	Expertise    string             `json:"expertise,omitempty"`
+	Title        string             `json:"title,omitempty"`
+	UserID       primitive.ObjectID `json:"user_id,omitempty" bson:"user_id"`
+	User         User               `json:"user" bson:"user"`
+	Token        string             `json:"token,omitempty"`
+	RefreshToken string             `json:"refresh_token,omitempty"`
+}
+
+type RosterQuery struct {
+	Sort  string // risk, name, adherence or last_taken
+	Order string // asc or desc, risk defaults to desc and the others to asc
+	Page  int
+	Limit int
+}
+
+type RosterFilter struct {
+	PractitionerID primitive.ObjectID
+	Since          time.Time // start of the period of the recent adherence
+	Sort           string
+	Descending     bool
+	Page           int
+	Limit          int
+}
+
+// RosterPatient sums up how a patient shared with a practitioner is doing with the medications the
+// practitioner has access to
+type RosterPatient struct {
+	PatientID         primitive.ObjectID `json:"patient_id" bson:"_id"`
+	Patient           PatientForDosage   `json:"patient" bson:"patient"`
+	WholeProfile      bool               `json:"whole_profile" bson:"whole_profile"` // false when only some medications are shared
+	ActiveMedications []RosterMedication `json:"active_medications" bson:"active_medications"`
+	LastDosageTaken   *RosterDosage      `json:"last_dosage_taken,omitempty" bson:"last_dosage_taken,omitempty"`
+	RecentAdherence   RosterAdherence    `json:"recent_adherence" bson:"recent_adherence"`
+	ConsecutiveMissed int                `json:"consecutive_missed" bson:"consecutive_missed"` // dosages missed since the last one taken or skipped
+	RiskFlags         []string           `json:"risk_flags" bson:"risk_flags"`
+	AtRisk            bool               `json:"at_risk" bson:"at_risk"`
+}
+
+type RosterMedication struct {
+	ID             primitive.ObjectID `json:"_id" bson:"_id"`
+	Name           string             `json:"name" bson:"name"`
+	DosageQuantity string             `json:"dosage_quantity" bson:"dosage_quantity"`
+	DailyDosage    int                `json:"daily_dosage" bson:"daily_dosage"`
+	StartDate      time.Time          `json:"start_date" bson:"start_date"`
+	EndDate        time.Time          `json:"end_date" bson:"end_date"`
+	Treatment      string             `json:"treatment" bson:"treatment"`
+}
+
+type RosterDosage struct {
+	ID           primitive.ObjectID `json:"_id" bson:"_id"`
+	MedicationID primitive.ObjectID `json:"medication_id" bson:"medication_id"`
+	ReminderTime time.Time          `json:"reminder_time" bson:"reminder_time"`
+	TimeTaken    time.Time          `json:"time_taken" bson:"time_taken"`
+}
+
+// RosterAdherence counts the dosages due over the last days. Rate is not set when none was due.
+type RosterAdherence struct {
+	Taken   int      `json:"taken" bson:"taken"`
+	Skipped int      `json:"skipped" bson:"skipped"`
+	Missed  int      `json:"missed" bson:"missed"`
+	Rate    *float64 `json:"rate" bson:"rate,omitempty"`
+}
+
+type RosterResponse struct {
+	Patients []RosterPatient `json:"patients"`
+	Total    int64           `json:"total"`
+	Page     int             `json:"page"`
+	Limit    int             `json:"limit"`
 }
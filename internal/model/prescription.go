@@ -0,0 +1,47 @@
+package model
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// Prescription is a medication plan a practitioner proposes to a patient. The medication, with its
+// dosages and reminders, is only created once the patient accepts it.
+type Prescription struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	PatientID      primitive.ObjectID `bson:"patient_id"`
+	PractitionerID primitive.ObjectID `bson:"practitioner_id"`
+	Medication     MedicationRequest  `bson:"medication"`
+	Status         string             `bson:"status"`
+	MedicationID   primitive.ObjectID `bson:"medication_id,omitempty"` // medication created when the patient accepted
+	CreatedAt      time.Time          `bson:"created_at"`
+	RespondedAt    *time.Time         `bson:"responded_at,omitempty"`
+}
+
+type PrescriptionResponse struct {
+	ID             primitive.ObjectID `json:"_id" bson:"_id"`
+	PatientID      primitive.ObjectID `json:"patient_id" bson:"patient_id"`
+	PractitionerID primitive.ObjectID `json:"practitioner_id" bson:"practitioner_id"`
+	Medication     MedicationRequest  `json:"medication" bson:"medication"`
+	Status         string             `json:"status" bson:"status"`
+	MedicationID   primitive.ObjectID `json:"medication_id,omitempty" bson:"medication_id,omitempty"`
+	CreatedAt      time.Time          `json:"created_at" bson:"created_at"`
+	RespondedAt    *time.Time         `json:"responded_at,omitempty" bson:"responded_at,omitempty"`
+	Patient        PatientForDosage   `json:"patient,omitempty" bson:"patient"`
+	Practitioner   Practitioner       `json:"practitioner,omitempty" bson:"practitioner"`
+}
+
+type PrescriptionRequest struct {
+	PatientID  string            `json:"patient_id" validate:"required"`
+	Medication MedicationRequest `json:"medication" validate:"required"`
+}
+
+type AcceptPrescriptionRequest struct {
+	StartDate string `json:"start_date"` // YYYY-MM-DD, replaces the prescribed start date when set
+}
+
+type PrescriptionFilter struct {
+	PatientID      primitive.ObjectID
+	PractitionerID primitive.ObjectID
+	Status         string
+}
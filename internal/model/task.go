@@ -6,10 +6,19 @@ import (
 )
 
 type Task struct {
-	ID           primitive.ObjectID `bson:"_id"`
-	Time         time.Time          `bson:"time"`
-	Status       string             `bson:"status"`
-	MedicationID primitive.ObjectID `bson:"medication_id"`
+	ID             primitive.ObjectID `bson:"_id"`
+	Time           time.Time          `bson:"time"`
+	Status         string             `bson:"status"`
+	MedicationID   primitive.ObjectID `bson:"medication_id"`
+	DosageID       primitive.ObjectID `bson:"dosage_id"`
+	ClaimedAt      time.Time          `bson:"claimed_at,omitempty"`
+	LeaseOwner     string             `bson:"lease_owner,omitempty"` // instance currently dispatching the task
+	LeaseExpiresAt time.Time          `bson:"lease_expires_at,omitempty"`
+	CompletedAt    time.Time          `bson:"completed_at,omitempty"`
+	Attempts       int                `bson:"attempts"`
+	LastError      string             `bson:"last_error,omitempty"`
+	NextAttemptAt  time.Time          `bson:"next_attempt_at,omitempty"` // a failed task is not tried again before
+	Kind           string             `bson:"kind,omitempty"`            // empty for the scheduled reminder of a dosage
 }
 
 type LatestTaskResponse struct {
@@ -17,5 +26,10 @@ type LatestTaskResponse struct {
 	Time         time.Time           `bson:"time"`
 	Status       string              `bson:"status"`
 	MedicationID primitive.ObjectID  `bson:"medication_id"`
+	DosageID     primitive.ObjectID  `bson:"dosage_id"`
+	Attempts     int                 `bson:"attempts"`
+	Kind         string              `bson:"kind,omitempty"`
 	Medication   MedicationForDosage `bson:"medication"`
+	DosageStatus string              `bson:"dosage_status,omitempty"` // empty when the dosage no longer exists
+	DosageActive bool                `bson:"dosage_active"`
 }
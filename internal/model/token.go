@@ -0,0 +1,40 @@
+package model
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// RefreshToken is a single use token exchanged for a new access token. Tokens issued from the
+// same login share a family, a token that is presented twice revokes its whole family.
+type RefreshToken struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	TokenHash string             `bson:"token_hash"` // sha256 of the token, the token itself is never stored
+	FamilyID  string             `bson:"family_id"`
+	Subject   string             `bson:"subject"` // id of the patient or practitioner
+	UserID    primitive.ObjectID `bson:"user_id"`
+	Email     string             `bson:"email"`
+	Role      int                `bson:"role"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+	CreatedAt time.Time          `bson:"created_at"`
+	UsedAt    *time.Time         `bson:"used_at,omitempty"`
+	RevokedAt *time.Time         `bson:"revoked_at,omitempty"`
+}
+
+// RevokedToken revokes an access token by its jti, or every access token of a refresh token family
+type RevokedToken struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	TokenID   string             `bson:"jti,omitempty"`
+	FamilyID  string             `bson:"family_id,omitempty"`
+	ExpiresAt time.Time          `bson:"expires_at"` // when every token it matches has expired
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type TokenResponse struct {
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
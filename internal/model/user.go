@@ -15,6 +15,9 @@ type User struct {
 	Password  string             `json:"password,omitempty" validate:"required,min=8" bson:"password"`
 	Role      int                `json:"role,omitempty" bson:"role"`
 	IsLocked  bool               `json:"is_locked,omitempty" bson:"is_locked"`
+	LockedBy  string             `json:"locked_by,omitempty" bson:"locked_by,omitempty"` // what locked the account
+	MustReset bool               `json:"password_reset_required,omitempty" bson:"password_reset_required,omitempty"`
+	Verified  bool               `json:"email_verified" bson:"email_verified"`
 	Salt      string             `json:"salt,omitempty" bson:"salt"`
 	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
 	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
@@ -23,19 +26,33 @@ type User struct {
 type UserLogin struct {
 	Email    string `json:"email,omitempty"`
 	Password string `json:"password,omitempty"`
+	IP       string `json:"-" swaggerignore:"true"` // client IP, failed logins are also counted per IP
 }
 
 type ResetPassword struct {
-	Password string `json:"password,omitempty"`
+	Token    string `json:"token,omitempty" validate:"required"`
+	Password string `json:"password,omitempty" validate:"required,min=8"`
 	Salt     string `json:"-" swaggerignore:"true"`
 }
 
 type ForgotPassword struct {
-	Email string `json:"email,omitempty"`
+	Email string `json:"email,omitempty" validate:"required,email"`
+}
+
+type VerifyEmail struct {
+	Token string `json:"token,omitempty" validate:"required"`
+}
+
+type UnlockAccount struct {
+	Token string `json:"token,omitempty" validate:"required"`
 }
 
 type ContextInfo struct {
 	ID    string
 	Role  int
 	Email string
+
+	TokenID        string // jti of the access token used for the request
+	TokenFamilyID  string // refresh token family the access token was issued for
+	TokenExpiresAt time.Time
 }
@@ -0,0 +1,19 @@
+package model
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// UserToken is a single use token emailed to a user, e.g. to reset their password
+type UserToken struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	TokenHash string             `bson:"token_hash"` // sha256 of the token, the token itself is never stored
+	Purpose   string             `bson:"purpose"`
+	UserID    primitive.ObjectID `bson:"user_id"`
+	Email     string             `bson:"email"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+	CreatedAt time.Time          `bson:"created_at"`
+	UsedAt    *time.Time         `bson:"used_at,omitempty"`
+	DosageID  primitive.ObjectID `bson:"dosage_id,omitempty"` // dosage of a one-click link in a reminder
+}
@@ -0,0 +1,229 @@
+package policy
+
+import (
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"medbuddy-backend/internal/constant"
+	"medbuddy-backend/internal/errors"
+	"medbuddy-backend/internal/model"
+)
+
+// Action is an operation a user performs on a kind of resource
+type Action string
+
+const (
+	MedicationCreate Action = "medication:create"
+	MedicationRead   Action = "medication:read"
+	MedicationList   Action = "medication:list"
+	MedicationUpdate Action = "medication:update"
+	MedicationDelete Action = "medication:delete"
+	MedicationShare  Action = "medication:share"
+	MedicationRefill Action = "medication:refill"
+
+	DosageRead      Action = "dosage:read"
+	DosageList      Action = "dosage:list"
+	DosageSetStatus Action = "dosage:set-status"
+	DosageRecord    Action = "dosage:record"
+	DosageSnooze    Action = "dosage:snooze"
+
+	PatientRead   Action = "patient:read"
+	PatientUpdate Action = "patient:update"
+
+	CaregiverManage Action = "caregiver:manage" // invite, list and remove the caregivers of a patient
+	CaregiverRead   Action = "caregiver:read"   // caregiver profile and invitations
+
+	AdherenceRead Action = "adherence:read"
+
+	PrescriptionCreate  Action = "prescription:create"
+	PrescriptionList    Action = "prescription:list"
+	PrescriptionRespond Action = "prescription:respond" // accept or decline
+	PrescriptionCancel  Action = "prescription:cancel"
+
+	AlertRuleManage Action = "alert-rule:manage"
+	AlertList       Action = "alert:list"
+	AlertUpdate     Action = "alert:update" // acknowledge or resolve
+
+	AccessGrantList    Action = "access-grant:list"
+	AccessGrantRespond Action = "access-grant:respond" // accept or decline
+	AccessGrantRevoke  Action = "access-grant:revoke"
+
+	PractitionerRead            Action = "practitioner:read"
+	PractitionerMedicationsList Action = "practitioner:list-medications"
+	PractitionerPatientsList    Action = "practitioner:list-patients"
+
+	MedicineCreate Action = "medicine:create"
+	MedicineRead   Action = "medicine:read"
+	MedicineUpdate Action = "medicine:update"
+	MedicineDelete Action = "medicine:delete"
+
+	AccountManage Action = "account:manage" // logout, resend verification email...
+	UserManage    Action = "user:manage"
+	JobRead       Action = "job:read"
+)
+
+// Scope is the set of resources an action is granted on
+type Scope int
+
+const (
+	// Any grants the action on every resource
+	Any Scope = iota + 1
+	// Own grants the action on the resources of the user's own profile
+	Own
+	// Assigned grants the action on the resources the user has been assigned to
+	Assigned
+	// Delegated grants the action on the resources of the patients the user is a caregiver of
+	Delegated
+)
+
+// matrix lists, for each action, the roles allowed to perform it and on which resources.
+// Roles that are not listed are denied.
+var matrix = map[Action]map[string]Scope{
+	MedicationCreate: {constant.Patient: Own},
+	MedicationRead:   {constant.Patient: Own, constant.Practitioner: Assigned, constant.Caregiver: Delegated},
+	MedicationList:   {constant.Patient: Own, constant.Caregiver: Delegated},
+	MedicationUpdate: {constant.Patient: Own},
+	MedicationDelete: {constant.Patient: Own},
+	MedicationShare:  {constant.Patient: Own},
+	MedicationRefill: {constant.Patient: Own, constant.Caregiver: Delegated},
+
+	DosageRead:      {constant.Patient: Own, constant.Practitioner: Assigned, constant.Caregiver: Delegated},
+	DosageList:      {constant.Patient: Own, constant.Caregiver: Delegated},
+	DosageSetStatus: {constant.Patient: Own, constant.Caregiver: Delegated},
+	DosageRecord:    {constant.Patient: Own, constant.Caregiver: Delegated},
+	DosageSnooze:    {constant.Patient: Own, constant.Caregiver: Delegated},
+
+	PatientRead:   {constant.Patient: Own},
+	PatientUpdate: {constant.Patient: Own},
+
+	CaregiverManage: {constant.Patient: Own},
+	CaregiverRead:   {constant.Caregiver: Own},
+
+	AdherenceRead: {constant.Patient: Own, constant.Practitioner: Assigned},
+
+	PrescriptionCreate:  {constant.Practitioner: Assigned},
+	PrescriptionList:    {constant.Patient: Own, constant.Practitioner: Own},
+	PrescriptionRespond: {constant.Patient: Own},
+	PrescriptionCancel:  {constant.Practitioner: Assigned},
+
+	AlertRuleManage: {constant.Practitioner: Own},
+	AlertList:       {constant.Practitioner: Own},
+	AlertUpdate:     {constant.Practitioner: Own},
+
+	AccessGrantList:    {constant.Patient: Own, constant.Practitioner: Own},
+	AccessGrantRespond: {constant.Practitioner: Assigned},
+	AccessGrantRevoke:  {constant.Patient: Own, constant.Practitioner: Assigned},
+
+	PractitionerRead:            {constant.Practitioner: Any},
+	PractitionerMedicationsList: {constant.Practitioner: Own},
+	PractitionerPatientsList:    {constant.Practitioner: Own},
+
+	MedicineCreate: {constant.Patient: Any, constant.Practitioner: Any, constant.Admin: Any},
+	MedicineRead:   {constant.Patient: Any, constant.Practitioner: Any, constant.Admin: Any, constant.Caregiver: Any},
+	MedicineUpdate: {constant.Admin: Any},
+	MedicineDelete: {constant.Admin: Any},
+
+	AccountManage: {constant.Patient: Own, constant.Practitioner: Own, constant.Admin: Own, constant.Caregiver: Own},
+	UserManage:    {constant.Admin: Any},
+	JobRead:       {constant.Admin: Any},
+}
+
+// Resource describes who a resource belongs to
+type Resource struct {
+	Name         string // used in error messages, e.g. "medication"
+	OwnerID      primitive.ObjectID
+	AssignedIDs  []primitive.ObjectID
+	CaregiverIDs []primitive.ObjectID // caregivers of the owner
+}
+
+// Medication returns the resource a medication represents
+func Medication(m *model.MedicationResponse) Resource {
+	return Resource{Name: "medication", OwnerID: m.PatientID, AssignedIDs: m.PractitionerIDs, CaregiverIDs: m.CaregiverIDs}
+}
+
+// Dosage returns the resource a dosage represents, it is shared like its medication
+func Dosage(d *model.DosageResponse) Resource {
+	return Resource{Name: "dosage", OwnerID: d.PatientID, AssignedIDs: d.Medication.PractitionerIDs, CaregiverIDs: d.CaregiverIDs}
+}
+
+// AccessGrant returns the resource a grant represents, it belongs to the patient and is assigned to
+// the practitioner it was given to
+func AccessGrant(g *model.AccessGrantResponse) Resource {
+	return Resource{Name: "access grant", OwnerID: g.PatientID, AssignedIDs: []primitive.ObjectID{g.PractitionerID}}
+}
+
+// Prescription returns the resource a prescription represents, it belongs to the patient and is
+// assigned to the practitioner who prescribed it
+func Prescription(p *model.PrescriptionResponse) Resource {
+	return Resource{Name: "prescription", OwnerID: p.PatientID, AssignedIDs: []primitive.ObjectID{p.PractitionerID}}
+}
+
+// AlertRule returns the resource a rule represents, it belongs to its practitioner
+func AlertRule(r *model.AlertRule) Resource {
+	return Resource{Name: "alert rule", OwnerID: r.PractitionerID}
+}
+
+// Alert returns the resource an alert represents, it belongs to the practitioner it was raised for
+func Alert(a *model.Alert) Resource {
+	return Resource{Name: "alert", OwnerID: a.PractitionerID}
+}
+
+// Patient returns the resource the schedule of a patient represents, practitionerIDs are the
+// practitioners the whole profile is shared with
+func Patient(id primitive.ObjectID, caregiverIDs, practitionerIDs []primitive.ObjectID) Resource {
+	return Resource{Name: "patient", OwnerID: id, AssignedIDs: practitionerIDs, CaregiverIDs: caregiverIDs}
+}
+
+// Can reports whether a role may perform an action on at least some resources
+func Can(role int, action Action) bool {
+	_, ok := matrix[action][constant.RoleName(role)]
+	return ok
+}
+
+// Check returns a forbidden error if the user's role may not perform the action at all
+func Check(user *model.ContextInfo, action Action) errors.InternalError {
+	if user == nil || !Can(user.Role, action) {
+		return errors.ForbiddenError("you are not allowed to perform this action")
+	}
+
+	return nil
+}
+
+// Authorize returns a forbidden error if the user may not perform the action on res
+func Authorize(user *model.ContextInfo, action Action, res Resource) errors.InternalError {
+	if err := Check(user, action); err != nil {
+		return err
+	}
+
+	userID, err := primitive.ObjectIDFromHex(user.ID)
+	if err != nil {
+		return errors.ForbiddenError("you are not allowed to perform this action")
+	}
+
+	var allowed bool
+	switch matrix[action][constant.RoleName(user.Role)] {
+	case Any:
+		allowed = true
+	case Own:
+		allowed = res.OwnerID == userID
+	case Assigned:
+		allowed = contains(res.AssignedIDs, userID)
+	case Delegated:
+		allowed = contains(res.CaregiverIDs, userID)
+	}
+
+	if !allowed {
+		return errors.ForbiddenError(fmt.Sprintf("you do not have access to this %s", res.Name))
+	}
+
+	return nil
+}
+
+func contains(ids []primitive.ObjectID, id primitive.ObjectID) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+
+	return false
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	mdb "medbuddy-backend/pkg/repository/mongo"
+	"medbuddy-backend/service/admin"
 	"medbuddy-backend/service/jobs"
 	"medbuddy-backend/utility"
 	"net/http"
@@ -11,6 +12,7 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata" // patients' time zones do not depend on the zoneinfo of the host
 
 	"medbuddy-backend/internal/config"
 	"medbuddy-backend/pkg/router"
@@ -23,6 +25,11 @@ func init() {
 	config.Setup()
 	mdb.ConnectToDB()
 
+	// Create the first administrator from ADMIN_EMAIL and ADMIN_PASSWORD
+	if err := admin.NewAdminService(mdb.GetDB()).BootstrapAdmin(); err != nil {
+		utility.NewLogger().Error("Error creating the first administrator, error: ", err.Error())
+	}
+
 	// Start background cron jobs
 	cJobs := jobs.NewCronJob()
 	cJobs.StartJobs()
@@ -62,8 +69,9 @@ func main() {
 			}
 		}()
 
+		// Stop background jobs first so in-flight reminders can still update their tasks
+		jobs.StopJobs(shutdownCtx)
 		mdb.DisconnectDB(shutdownCtx)
-		jobs.StopJobs()
 
 		// Store counter variable in redis
 		// redis.StoreCounter()
@@ -0,0 +1,74 @@
+package events
+
+import (
+	"context"
+	"medbuddy-backend/utility"
+	"sync"
+	"time"
+)
+
+// Event is something that happened in the system that other parts of it may react to
+type Event struct {
+	Name       string
+	OccurredAt time.Time
+	Data       interface{} // payload, its type depends on Name
+}
+
+// Handler reacts to an event. Handlers run one after the other on the goroutine publishing the
+// event, they should not block for long.
+type Handler func(ctx context.Context, event Event)
+
+// Bus delivers the events published on it to the handlers subscribed to their name
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+}
+
+var (
+	logger = utility.NewLogger()
+
+	defaultBus = NewBus()
+)
+
+func NewBus() *Bus {
+	return &Bus{handlers: map[string][]Handler{}}
+}
+
+// Subscribe registers h to be called with every event named name
+func (b *Bus) Subscribe(name string, h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.handlers[name] = append(b.handlers[name], h)
+}
+
+// Publish calls the handlers subscribed to the event. A handler that panics is logged and does not
+// keep the others from running.
+func (b *Bus) Publish(ctx context.Context, name string, data interface{}) {
+	b.mu.RLock()
+	handlers := b.handlers[name]
+	b.mu.RUnlock()
+
+	event := Event{Name: name, OccurredAt: time.Now(), Data: data}
+	for _, h := range handlers {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					logger.Errorf("Handler of event '%s' panicked: %v", name, r)
+				}
+			}()
+
+			h(ctx, event)
+		}()
+	}
+}
+
+// Subscribe registers h on the default bus
+func Subscribe(name string, h Handler) {
+	defaultBus.Subscribe(name, h)
+}
+
+// Publish publishes an event on the default bus
+func Publish(ctx context.Context, name string, data interface{}) {
+	defaultBus.Publish(ctx, name, data)
+}
@@ -0,0 +1,105 @@
+package adherence
+
+import (
+	"github.com/gin-gonic/gin"
+	"medbuddy-backend/internal/constant"
+	"medbuddy-backend/internal/model"
+	"medbuddy-backend/utility"
+	"net/http"
+	"strconv"
+)
+
+// GetOwnAdherence reports on all the medications of the patient making the request
+func (base *Controller) GetOwnAdherence(c *gin.Context) {
+	uInfo, exists := c.Get("user info")
+	if !exists {
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrServer, constant.ErrRequest, nil)
+		c.JSON(http.StatusInternalServerError, rd)
+		return
+	}
+	userInfo := uInfo.(*model.ContextInfo)
+
+	query, ok := bindAdherenceQuery(c)
+	if !ok {
+		return
+	}
+
+	response, err := base.AdherenceService.GetPatientAdherence(userInfo, userInfo.ID, query)
+	if err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "", response)
+	c.JSON(rd.Code, rd)
+}
+
+func (base *Controller) GetPatientAdherence(c *gin.Context) {
+	patientId := c.Param("id")
+	uInfo, exists := c.Get("user info")
+	if !exists {
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrServer, constant.ErrRequest, nil)
+		c.JSON(http.StatusInternalServerError, rd)
+		return
+	}
+	userInfo := uInfo.(*model.ContextInfo)
+
+	query, ok := bindAdherenceQuery(c)
+	if !ok {
+		return
+	}
+
+	response, err := base.AdherenceService.GetPatientAdherence(userInfo, patientId, query)
+	if err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "", response)
+	c.JSON(rd.Code, rd)
+}
+
+func (base *Controller) GetMedicationAdherence(c *gin.Context) {
+	medicationId := c.Param("id")
+	uInfo, exists := c.Get("user info")
+	if !exists {
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrServer, constant.ErrRequest, nil)
+		c.JSON(http.StatusInternalServerError, rd)
+		return
+	}
+	userInfo := uInfo.(*model.ContextInfo)
+
+	query, ok := bindAdherenceQuery(c)
+	if !ok {
+		return
+	}
+
+	response, err := base.AdherenceService.GetMedicationAdherence(userInfo, medicationId, query)
+	if err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "", response)
+	c.JSON(rd.Code, rd)
+}
+
+// bindAdherenceQuery reads the query parameters, it writes the error response when they are invalid
+func bindAdherenceQuery(c *gin.Context) (*model.AdherenceQuery, bool) {
+	query := &model.AdherenceQuery{From: c.Query("from"), To: c.Query("to"), Interval: c.Query("interval")}
+
+	if value, ok := c.GetQuery("on_time_minutes"); ok {
+		minutes, err := strconv.Atoi(value)
+		if err != nil {
+			rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrValidation, "on_time_minutes must be a number", nil)
+			c.JSON(rd.Code, rd)
+			return nil, false
+		}
+		query.OnTimeMinutes = minutes
+	}
+
+	return query, true
+}
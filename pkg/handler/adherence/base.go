@@ -0,0 +1,19 @@
+package adherence
+
+import (
+	"github.com/go-playground/validator/v10"
+	log "github.com/sirupsen/logrus"
+	"medbuddy-backend/service/adherence"
+)
+
+type Controller struct {
+	Validate         *validator.Validate
+	Logger           *log.Logger
+	AdherenceService adherence.AdherenceService
+}
+
+func NewController(validate *validator.Validate, logger *log.Logger, aService adherence.AdherenceService) *Controller {
+	return &Controller{
+		validate, logger, aService,
+	}
+}
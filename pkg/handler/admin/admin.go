@@ -0,0 +1,121 @@
+package admin
+
+import (
+	"medbuddy-backend/internal/constant"
+	"medbuddy-backend/internal/model"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"medbuddy-backend/utility"
+)
+
+func (base *Controller) LoginAdmin(c *gin.Context) {
+	var data model.UserLogin
+
+	if err := c.BindJSON(&data); err != nil {
+		base.Logger.Error("Error when binding request body on LoginAdmin, error: ", err.Error())
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrRequest, constant.ErrRequest, nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	if err := base.Validate.Struct(data); err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrValidation, err.Error(), nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	data.IP = c.ClientIP()
+	response, err := base.AdminService.LoginAdmin(&data)
+	if err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, http.StatusText(err.Code()), err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "admin logged in successfully", response)
+	c.JSON(rd.Code, rd)
+}
+
+func (base *Controller) ListUsers(c *gin.Context) {
+	page, limit := utility.ParsePagination(c.Query("page"), c.Query("limit"))
+	filter := model.UserFilter{Email: c.Query("email"), Page: page, Limit: limit}
+
+	if role, ok := c.GetQuery("role"); ok {
+		roleID, exists := constant.Roles[role]
+		if !exists {
+			rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrValidation, "unknown role", nil)
+			c.JSON(rd.Code, rd)
+			return
+		}
+		filter.Role = roleID
+	}
+
+	if value, ok := c.GetQuery("locked"); ok {
+		locked, err := strconv.ParseBool(value)
+		if err != nil {
+			rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrValidation, "locked must be true or false", nil)
+			c.JSON(rd.Code, rd)
+			return
+		}
+		filter.Locked = &locked
+	}
+
+	response, err := base.AdminService.ListUsers(&filter)
+	if err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "", response)
+	c.JSON(rd.Code, rd)
+}
+
+func (base *Controller) LockUser(c *gin.Context) {
+	base.setUserLock(c, true)
+}
+
+func (base *Controller) UnlockUser(c *gin.Context) {
+	base.setUserLock(c, false)
+}
+
+func (base *Controller) setUserLock(c *gin.Context, locked bool) {
+	if err := base.AdminService.SetUserLock(c.Param("id"), locked); err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	message := "user unlocked successfully"
+	if locked {
+		message = "user locked successfully"
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, message, nil)
+	c.JSON(rd.Code, rd)
+}
+
+func (base *Controller) ForcePasswordReset(c *gin.Context) {
+	if err := base.AdminService.ForcePasswordReset(c.Param("id")); err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "password reset email sent", nil)
+	c.JSON(rd.Code, rd)
+}
+
+func (base *Controller) GetJobStatus(c *gin.Context) {
+	response, err := base.AdminService.GetJobStatus()
+	if err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "", response)
+	c.JSON(rd.Code, rd)
+}
@@ -0,0 +1,19 @@
+package admin
+
+import (
+	"github.com/go-playground/validator/v10"
+	log "github.com/sirupsen/logrus"
+	"medbuddy-backend/service/admin"
+)
+
+type Controller struct {
+	Validate     *validator.Validate
+	Logger       *log.Logger
+	AdminService admin.AdminService
+}
+
+func NewController(validate *validator.Validate, logger *log.Logger, aService admin.AdminService) *Controller {
+	return &Controller{
+		validate, logger, aService,
+	}
+}
@@ -0,0 +1,183 @@
+package alert
+
+import (
+	"github.com/gin-gonic/gin"
+	"medbuddy-backend/internal/constant"
+	"medbuddy-backend/internal/model"
+	"medbuddy-backend/utility"
+	"net/http"
+)
+
+func (base *Controller) CreateAlertRule(c *gin.Context) {
+	var data model.AlertRuleRequest
+
+	uInfo, exists := c.Get("user info")
+	if !exists {
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrServer, constant.ErrRequest, nil)
+		c.JSON(http.StatusInternalServerError, rd)
+		return
+	}
+	userInfo := uInfo.(*model.ContextInfo)
+
+	if err := c.BindJSON(&data); err != nil {
+		base.Logger.Error("Error when binding request body, error: ", err.Error())
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrRequest, constant.ErrRequest, nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	if err := base.Validate.Struct(data); err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrValidation, err.Error(), nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	response, err := base.AlertService.CreateAlertRule(userInfo, &data)
+	if err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusCreated, "alert rule created successfully", response)
+	c.JSON(rd.Code, rd)
+}
+
+func (base *Controller) GetAlertRules(c *gin.Context) {
+	uInfo, exists := c.Get("user info")
+	if !exists {
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrServer, constant.ErrRequest, nil)
+		c.JSON(http.StatusInternalServerError, rd)
+		return
+	}
+	userInfo := uInfo.(*model.ContextInfo)
+
+	response, err := base.AlertService.GetAlertRules(userInfo)
+	if err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "", response)
+	c.JSON(rd.Code, rd)
+}
+
+func (base *Controller) UpdateAlertRule(c *gin.Context) {
+	var data model.UpdateAlertRuleRequest
+
+	id := c.Param("id")
+	uInfo, exists := c.Get("user info")
+	if !exists {
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrServer, constant.ErrRequest, nil)
+		c.JSON(http.StatusInternalServerError, rd)
+		return
+	}
+	userInfo := uInfo.(*model.ContextInfo)
+
+	if err := c.BindJSON(&data); err != nil {
+		base.Logger.Error("Error when binding request body, error: ", err.Error())
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrRequest, constant.ErrRequest, nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	if err := base.Validate.Struct(data); err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrValidation, err.Error(), nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	response, err := base.AlertService.UpdateAlertRule(userInfo, id, &data)
+	if err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "alert rule updated successfully", response)
+	c.JSON(rd.Code, rd)
+}
+
+func (base *Controller) DeleteAlertRule(c *gin.Context) {
+	id := c.Param("id")
+	uInfo, exists := c.Get("user info")
+	if !exists {
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrServer, constant.ErrRequest, nil)
+		c.JSON(http.StatusInternalServerError, rd)
+		return
+	}
+	userInfo := uInfo.(*model.ContextInfo)
+
+	if err := base.AlertService.DeleteAlertRule(userInfo, id); err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "alert rule deleted successfully", nil)
+	c.JSON(rd.Code, rd)
+}
+
+func (base *Controller) GetAlerts(c *gin.Context) {
+	uInfo, exists := c.Get("user info")
+	if !exists {
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrServer, constant.ErrRequest, nil)
+		c.JSON(http.StatusInternalServerError, rd)
+		return
+	}
+	userInfo := uInfo.(*model.ContextInfo)
+
+	page, limit := utility.ParsePagination(c.Query("page"), c.Query("limit"))
+	filter := model.AlertFilter{Status: c.Query("status"), Page: page, Limit: limit}
+
+	response, err := base.AlertService.GetAlerts(userInfo, &filter)
+	if err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "", response)
+	c.JSON(rd.Code, rd)
+}
+
+func (base *Controller) AcknowledgeAlert(c *gin.Context) {
+	id := c.Param("id")
+	uInfo, exists := c.Get("user info")
+	if !exists {
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrServer, constant.ErrRequest, nil)
+		c.JSON(http.StatusInternalServerError, rd)
+		return
+	}
+	userInfo := uInfo.(*model.ContextInfo)
+
+	if err := base.AlertService.AcknowledgeAlert(userInfo, id); err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "alert acknowledged", nil)
+	c.JSON(rd.Code, rd)
+}
+
+func (base *Controller) ResolveAlert(c *gin.Context) {
+	id := c.Param("id")
+	uInfo, exists := c.Get("user info")
+	if !exists {
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrServer, constant.ErrRequest, nil)
+		c.JSON(http.StatusInternalServerError, rd)
+		return
+	}
+	userInfo := uInfo.(*model.ContextInfo)
+
+	if err := base.AlertService.ResolveAlert(userInfo, id); err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "alert resolved", nil)
+	c.JSON(rd.Code, rd)
+}
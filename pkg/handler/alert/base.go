@@ -0,0 +1,19 @@
+package alert
+
+import (
+	"github.com/go-playground/validator/v10"
+	log "github.com/sirupsen/logrus"
+	"medbuddy-backend/service/alert"
+)
+
+type Controller struct {
+	Validate     *validator.Validate
+	Logger       *log.Logger
+	AlertService alert.AlertService
+}
+
+func NewController(validate *validator.Validate, logger *log.Logger, aService alert.AlertService) *Controller {
+	return &Controller{
+		validate, logger, aService,
+	}
+}
@@ -0,0 +1,189 @@
+package auth
+
+import (
+	"medbuddy-backend/internal/constant"
+	"medbuddy-backend/internal/model"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"medbuddy-backend/utility"
+)
+
+func (base *Controller) RefreshToken(c *gin.Context) {
+	var data model.RefreshTokenRequest
+
+	if err := c.BindJSON(&data); err != nil {
+		base.Logger.Error("Error when binding request body, error: ", err.Error())
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrRequest, constant.ErrRequest, nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	if err := base.Validate.Struct(data); err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrValidation, err.Error(), nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	response, err := base.AuthService.RefreshToken(&data)
+	if err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, http.StatusText(err.Code()), err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "token refreshed successfully", response)
+	c.JSON(rd.Code, rd)
+}
+
+func (base *Controller) Logout(c *gin.Context) {
+	uInfo, exists := c.Get("user info")
+	if !exists {
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrServer, constant.ErrRequest, nil)
+		c.JSON(http.StatusInternalServerError, rd)
+		return
+	}
+
+	if err := base.AuthService.Logout(uInfo.(*model.ContextInfo)); err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "logged out successfully", nil)
+	c.JSON(rd.Code, rd)
+}
+
+func (base *Controller) ForgotPatientPassword(c *gin.Context) {
+	base.forgotPassword(c, constant.Patient)
+}
+
+func (base *Controller) ForgotPractitionerPassword(c *gin.Context) {
+	base.forgotPassword(c, constant.Practitioner)
+}
+
+func (base *Controller) ForgotCaregiverPassword(c *gin.Context) {
+	base.forgotPassword(c, constant.Caregiver)
+}
+
+func (base *Controller) forgotPassword(c *gin.Context, role string) {
+	var data model.ForgotPassword
+
+	if err := c.BindJSON(&data); err != nil {
+		base.Logger.Error("Error when binding request body, error: ", err.Error())
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrRequest, constant.ErrRequest, nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	if err := base.Validate.Struct(data); err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrValidation, err.Error(), nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	if err := base.AuthService.ForgotPassword(role, &data); err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "if an account exists for this email, a password reset link has been sent to it", nil)
+	c.JSON(rd.Code, rd)
+}
+
+func (base *Controller) ResetPassword(c *gin.Context) {
+	var data model.ResetPassword
+
+	if err := c.BindJSON(&data); err != nil {
+		base.Logger.Error("Error when binding request body, error: ", err.Error())
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrRequest, constant.ErrRequest, nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	if err := base.Validate.Struct(data); err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrValidation, err.Error(), nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	if err := base.AuthService.ResetPassword(&data); err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "password reset successfully", nil)
+	c.JSON(rd.Code, rd)
+}
+
+func (base *Controller) VerifyEmail(c *gin.Context) {
+	var data model.VerifyEmail
+
+	if err := c.BindJSON(&data); err != nil {
+		base.Logger.Error("Error when binding request body, error: ", err.Error())
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrRequest, constant.ErrRequest, nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	if err := base.Validate.Struct(data); err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrValidation, err.Error(), nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	if err := base.AuthService.VerifyEmail(&data); err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "email verified successfully", nil)
+	c.JSON(rd.Code, rd)
+}
+
+func (base *Controller) ResendVerificationEmail(c *gin.Context) {
+	uInfo, exists := c.Get("user info")
+	if !exists {
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrServer, constant.ErrRequest, nil)
+		c.JSON(http.StatusInternalServerError, rd)
+		return
+	}
+
+	if err := base.AuthService.ResendVerificationEmail(uInfo.(*model.ContextInfo)); err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "verification email sent", nil)
+	c.JSON(rd.Code, rd)
+}
+
+func (base *Controller) UnlockAccount(c *gin.Context) {
+	var data model.UnlockAccount
+
+	if err := c.BindJSON(&data); err != nil {
+		base.Logger.Error("Error when binding request body, error: ", err.Error())
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrRequest, constant.ErrRequest, nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	if err := base.Validate.Struct(data); err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrValidation, err.Error(), nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	if err := base.AuthService.UnlockAccount(&data); err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "account unlocked successfully", nil)
+	c.JSON(rd.Code, rd)
+}
@@ -0,0 +1,19 @@
+package auth
+
+import (
+	"github.com/go-playground/validator/v10"
+	log "github.com/sirupsen/logrus"
+	"medbuddy-backend/service/auth"
+)
+
+type Controller struct {
+	Validate    *validator.Validate
+	Logger      *log.Logger
+	AuthService auth.AuthService
+}
+
+func NewController(validate *validator.Validate, logger *log.Logger, aService auth.AuthService) *Controller {
+	return &Controller{
+		validate, logger, aService,
+	}
+}
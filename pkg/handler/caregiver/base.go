@@ -0,0 +1,19 @@
+package caregiver
+
+import (
+	"github.com/go-playground/validator/v10"
+	log "github.com/sirupsen/logrus"
+	"medbuddy-backend/service/caregiver"
+)
+
+type Controller struct {
+	Validate         *validator.Validate
+	Logger           *log.Logger
+	CaregiverService caregiver.CaregiverService
+}
+
+func NewController(validate *validator.Validate, logger *log.Logger, cService caregiver.CaregiverService) *Controller {
+	return &Controller{
+		validate, logger, cService,
+	}
+}
@@ -0,0 +1,308 @@
+package caregiver
+
+import (
+	"github.com/gin-gonic/gin"
+	"medbuddy-backend/internal/constant"
+	"medbuddy-backend/internal/model"
+	"medbuddy-backend/utility"
+	"net/http"
+	"strings"
+)
+
+func (base *Controller) CreateCaregiver(c *gin.Context) {
+	var data model.CaregiverRequest
+
+	if err := c.BindJSON(&data); err != nil {
+		base.Logger.Error("Error when binding request body, error: ", err.Error())
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrRequest, constant.ErrRequest, nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	if err := base.Validate.Struct(data); err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrValidation, err.Error(), nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	response, err := base.CaregiverService.CreateCaregiver(&data)
+	if err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusCreated, "", response)
+	c.JSON(rd.Code, rd)
+}
+
+func (base *Controller) LoginCaregiver(c *gin.Context) {
+	var data model.UserLogin
+
+	if err := c.BindJSON(&data); err != nil {
+		base.Logger.Error("Error when binding request body on LoginCaregiver, error: ", err.Error())
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrRequest, constant.ErrRequest, nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	if err := base.Validate.Struct(data); err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrValidation, err.Error(), nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	data.IP = c.ClientIP()
+	response, err := base.CaregiverService.LoginCaregiver(&data)
+	if err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, http.StatusText(err.Code()), err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "", response)
+	c.JSON(rd.Code, rd)
+}
+
+func (base *Controller) GetCaregiver(c *gin.Context) {
+	uInfo, exists := c.Get("user info")
+	if !exists {
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrServer, constant.ErrRequest, nil)
+		c.JSON(http.StatusInternalServerError, rd)
+		return
+	}
+	userInfo := uInfo.(*model.ContextInfo)
+
+	response, err := base.CaregiverService.GetCaregiver(userInfo)
+	if err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "", response)
+	c.JSON(rd.Code, rd)
+}
+
+func (base *Controller) InviteCaregiver(c *gin.Context) {
+	var data model.InviteCaregiverRequest
+
+	uInfo, exists := c.Get("user info")
+	if !exists {
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrServer, constant.ErrRequest, nil)
+		c.JSON(http.StatusInternalServerError, rd)
+		return
+	}
+	userInfo := uInfo.(*model.ContextInfo)
+
+	if err := c.BindJSON(&data); err != nil {
+		base.Logger.Error("Error when binding request body, error: ", err.Error())
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrRequest, constant.ErrRequest, nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	if err := base.Validate.Struct(data); err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrValidation, err.Error(), nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	response, err := base.CaregiverService.InviteCaregiver(userInfo, &data)
+	if err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusCreated, "invitation sent successfully", response)
+	c.JSON(rd.Code, rd)
+}
+
+func (base *Controller) GetPatientCaregivers(c *gin.Context) {
+	uInfo, exists := c.Get("user info")
+	if !exists {
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrServer, constant.ErrRequest, nil)
+		c.JSON(http.StatusInternalServerError, rd)
+		return
+	}
+	userInfo := uInfo.(*model.ContextInfo)
+
+	response, err := base.CaregiverService.GetPatientCaregivers(userInfo)
+	if err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "", response)
+	c.JSON(rd.Code, rd)
+}
+
+func (base *Controller) UpdateCaregiverLink(c *gin.Context) {
+	var data model.UpdateCaregiverLinkRequest
+
+	id := c.Param("id")
+	uInfo, exists := c.Get("user info")
+	if !exists {
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrServer, constant.ErrRequest, nil)
+		c.JSON(http.StatusInternalServerError, rd)
+		return
+	}
+	userInfo := uInfo.(*model.ContextInfo)
+
+	if err := c.BindJSON(&data); err != nil {
+		base.Logger.Error("Error when binding request body, error: ", err.Error())
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrRequest, constant.ErrRequest, nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	if err := base.Validate.Struct(data); err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrValidation, err.Error(), nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	if err := base.CaregiverService.UpdateCaregiverLink(userInfo, id, &data); err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "caregiver updated successfully", nil)
+	c.JSON(rd.Code, rd)
+}
+
+func (base *Controller) RevokeCaregiver(c *gin.Context) {
+	id := c.Param("id")
+	uInfo, exists := c.Get("user info")
+	if !exists {
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrServer, constant.ErrRequest, nil)
+		c.JSON(http.StatusInternalServerError, rd)
+		return
+	}
+	userInfo := uInfo.(*model.ContextInfo)
+
+	if err := base.CaregiverService.RevokeCaregiver(userInfo, id); err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "caregiver removed successfully", nil)
+	c.JSON(rd.Code, rd)
+}
+
+func (base *Controller) AcceptInvitation(c *gin.Context) {
+	var data model.AcceptInvitationRequest
+
+	uInfo, exists := c.Get("user info")
+	if !exists {
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrServer, constant.ErrRequest, nil)
+		c.JSON(http.StatusInternalServerError, rd)
+		return
+	}
+	userInfo := uInfo.(*model.ContextInfo)
+
+	if err := c.BindJSON(&data); err != nil {
+		base.Logger.Error("Error when binding request body, error: ", err.Error())
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrRequest, constant.ErrRequest, nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	if err := base.Validate.Struct(data); err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrValidation, err.Error(), nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	response, err := base.CaregiverService.AcceptInvitation(userInfo, &data)
+	if err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "invitation accepted", response)
+	c.JSON(rd.Code, rd)
+}
+
+func (base *Controller) GetCaregiverPatients(c *gin.Context) {
+	uInfo, exists := c.Get("user info")
+	if !exists {
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrServer, constant.ErrRequest, nil)
+		c.JSON(http.StatusInternalServerError, rd)
+		return
+	}
+	userInfo := uInfo.(*model.ContextInfo)
+
+	response, err := base.CaregiverService.GetCaregiverPatients(userInfo)
+	if err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "", response)
+	c.JSON(rd.Code, rd)
+}
+
+func (base *Controller) GetPatientMedications(c *gin.Context) {
+	patientId := c.Param("id")
+	uInfo, exists := c.Get("user info")
+	if !exists {
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrServer, constant.ErrRequest, nil)
+		c.JSON(http.StatusInternalServerError, rd)
+		return
+	}
+	userInfo := uInfo.(*model.ContextInfo)
+
+	response, err := base.CaregiverService.GetPatientMedications(userInfo, patientId)
+	if err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "", response)
+	c.JSON(rd.Code, rd)
+}
+
+func (base *Controller) GetPatientDosages(c *gin.Context) {
+	patientId := c.Param("id")
+	uInfo, exists := c.Get("user info")
+	if !exists {
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrServer, constant.ErrRequest, nil)
+		c.JSON(http.StatusInternalServerError, rd)
+		return
+	}
+
+	var isActive *bool
+	userInfo := uInfo.(*model.ContextInfo)
+	medId, _ := c.GetQuery("medication_id")
+	isActiveParam, exists := c.GetQuery("is_active")
+	if exists {
+		isActiveTemp := strings.TrimSpace(strings.ToLower(isActiveParam)) == "true"
+		isActive = &isActiveTemp
+	}
+
+	loc, tzErr := utility.ParseTimeZoneQuery(c.Query("time_zone"))
+	if tzErr != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrRequest, tzErr.Error(), nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	response, err := base.CaregiverService.GetPatientDosages(userInfo, patientId, isActive, medId)
+	if err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+	utility.DosagesIn(response, loc)
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "", response)
+	c.JSON(rd.Code, rd)
+}
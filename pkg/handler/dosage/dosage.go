@@ -9,6 +9,8 @@ import (
 	"strings"
 )
 
+var dosageActionTemplate = "utility/template/dosage_action.html"
+
 func (base *Controller) GetPatientDosages(c *gin.Context) {
 	uInfo, exists := c.Get("user info")
 	if !exists {
@@ -25,12 +27,20 @@ func (base *Controller) GetPatientDosages(c *gin.Context) {
 		isActive = &isActiveTemp
 	}
 
+	loc, tzErr := utility.ParseTimeZoneQuery(c.Query("time_zone"))
+	if tzErr != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrRequest, tzErr.Error(), nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
 	dosages, err := base.DosageService.GetPatientsDosages(*userInfo, isActive, "")
 	if err != nil {
 		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
 		c.JSON(err.Code(), rd)
 		return
 	}
+	utility.DosagesIn(dosages, loc)
 
 	rd := utility.BuildSuccessResponse(http.StatusOK, "", dosages)
 	c.JSON(rd.Code, rd)
@@ -53,12 +63,20 @@ func (base *Controller) GetMedicationDosages(c *gin.Context) {
 		isActive = &isActiveTemp
 	}
 
+	loc, tzErr := utility.ParseTimeZoneQuery(c.Query("time_zone"))
+	if tzErr != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrRequest, tzErr.Error(), nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
 	dosages, err := base.DosageService.GetPatientsDosages(*userInfo, isActive, medId)
 	if err != nil {
 		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
 		c.JSON(err.Code(), rd)
 		return
 	}
+	utility.DosagesIn(dosages, loc)
 
 	rd := utility.BuildSuccessResponse(http.StatusOK, "", dosages)
 	c.JSON(rd.Code, rd)
@@ -107,13 +125,122 @@ func (base *Controller) GetDosage(c *gin.Context) {
 		return
 	}
 
-	response, err := base.DosageService.GetDosage(id)
+	uInfo, exists := c.Get("user info")
+	if !exists {
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrServer, constant.ErrRequest, nil)
+		c.JSON(http.StatusInternalServerError, rd)
+		return
+	}
+	userInfo := uInfo.(*model.ContextInfo)
+
+	loc, tzErr := utility.ParseTimeZoneQuery(c.Query("time_zone"))
+	if tzErr != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrRequest, tzErr.Error(), nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	response, err := base.DosageService.GetDosage(userInfo, id)
 	if err != nil {
 		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
 		c.JSON(err.Code(), rd)
 		return
 	}
+	utility.DosageIn(&response, loc)
 
 	rd := utility.BuildSuccessResponse(http.StatusOK, "", response)
 	c.JSON(rd.Code, rd)
 }
+
+func (base *Controller) RecordDose(c *gin.Context) {
+	uInfo, exists := c.Get("user info")
+	if !exists {
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrServer, constant.ErrRequest, nil)
+		c.JSON(http.StatusInternalServerError, rd)
+		return
+	}
+	userInfo := uInfo.(*model.ContextInfo)
+
+	response, err := base.DosageService.RecordDose(userInfo, c.Param("id"))
+	if err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusCreated, "dosage recorded successfully", response)
+	c.JSON(rd.Code, rd)
+}
+
+func (base *Controller) SnoozeDosage(c *gin.Context) {
+	var data model.SnoozeRequest
+
+	uInfo, exists := c.Get("user info")
+	if !exists {
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrServer, constant.ErrRequest, nil)
+		c.JSON(http.StatusInternalServerError, rd)
+		return
+	}
+	userInfo := uInfo.(*model.ContextInfo)
+
+	if err := c.BindJSON(&data); err != nil {
+		base.Logger.Error("Error when binding request body, error: ", err.Error())
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrRequest, constant.ErrRequest, nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	if err := base.Validate.Struct(data); err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrValidation, err.Error(), nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	response, err := base.DosageService.SnoozeDosage(userInfo, c.Param("id"), data.Minutes)
+	if err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "dosage snoozed successfully", response)
+	c.JSON(rd.Code, rd)
+}
+
+// GetDosageAction shows what a one-click link of a reminder email does, the patient confirms it on
+// the page so that link scanners opening the link change nothing
+func (base *Controller) GetDosageAction(c *gin.Context) {
+	action, err := base.DosageService.GetDosageAction(c.Param("token"))
+	if err != nil {
+		base.renderDosageAction(c, err.Code(), gin.H{"Error": err.Error()})
+		return
+	}
+
+	base.renderDosageAction(c, http.StatusOK, gin.H{"Action": action})
+}
+
+func (base *Controller) ApplyDosageAction(c *gin.Context) {
+	action, err := base.DosageService.ApplyDosageAction(c.Param("token"))
+	if err != nil {
+		base.renderDosageAction(c, err.Code(), gin.H{"Error": err.Error()})
+		return
+	}
+
+	base.renderDosageAction(c, http.StatusOK, gin.H{"Action": action, "Done": true})
+}
+
+func (base *Controller) renderDosageAction(c *gin.Context, code int, data gin.H) {
+	html, err := utility.RenderTemplate(dosageActionTemplate, data)
+	if err != nil {
+		base.Logger.Error("Error rendering dosage action page, error: ", err.Error())
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrServer, constant.ErrRequest, nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	// the page is reached with a token in its url, it is not cached nor passed on to other sites
+	c.Header("Cache-Control", "no-store")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Header("X-Robots-Tag", "noindex")
+	c.Data(code, "text/html; charset=utf-8", []byte(html))
+}
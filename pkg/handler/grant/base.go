@@ -0,0 +1,19 @@
+package grant
+
+import (
+	"github.com/go-playground/validator/v10"
+	log "github.com/sirupsen/logrus"
+	"medbuddy-backend/service/grant"
+)
+
+type Controller struct {
+	Validate     *validator.Validate
+	Logger       *log.Logger
+	GrantService grant.GrantService
+}
+
+func NewController(validate *validator.Validate, logger *log.Logger, gService grant.GrantService) *Controller {
+	return &Controller{
+		validate, logger, gService,
+	}
+}
@@ -0,0 +1,128 @@
+package grant
+
+import (
+	"github.com/gin-gonic/gin"
+	"medbuddy-backend/internal/constant"
+	"medbuddy-backend/internal/model"
+	"medbuddy-backend/utility"
+	"net/http"
+	"strings"
+)
+
+func (base *Controller) ShareWithPractitioners(c *gin.Context) {
+	var data model.ShareRequest
+
+	uInfo, exists := c.Get("user info")
+	if !exists {
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrServer, constant.ErrRequest, nil)
+		c.JSON(http.StatusInternalServerError, rd)
+		return
+	}
+	userInfo := uInfo.(*model.ContextInfo)
+
+	if err := c.BindJSON(&data); err != nil {
+		base.Logger.Error("Error when binding request body, error: ", err.Error())
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrRequest, constant.ErrRequest, nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	if err := base.Validate.Struct(data); err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrValidation, err.Error(), nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	response, err := base.GrantService.ShareWithPractitioners(userInfo, &data)
+	if err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusCreated, "access requested, waiting for the practitioner(s) to accept", response)
+	c.JSON(rd.Code, rd)
+}
+
+func (base *Controller) GetAccessGrants(c *gin.Context) {
+	uInfo, exists := c.Get("user info")
+	if !exists {
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrServer, constant.ErrRequest, nil)
+		c.JSON(http.StatusInternalServerError, rd)
+		return
+	}
+	userInfo := uInfo.(*model.ContextInfo)
+	status := strings.TrimSpace(strings.ToLower(c.Query("status")))
+
+	response, err := base.GrantService.GetAccessGrants(userInfo, status)
+	if err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "", response)
+	c.JSON(rd.Code, rd)
+}
+
+func (base *Controller) AcceptAccessGrant(c *gin.Context) {
+	id := c.Param("id")
+	uInfo, exists := c.Get("user info")
+	if !exists {
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrServer, constant.ErrRequest, nil)
+		c.JSON(http.StatusInternalServerError, rd)
+		return
+	}
+	userInfo := uInfo.(*model.ContextInfo)
+
+	response, err := base.GrantService.AcceptAccessGrant(userInfo, id)
+	if err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "access grant accepted", response)
+	c.JSON(rd.Code, rd)
+}
+
+func (base *Controller) DeclineAccessGrant(c *gin.Context) {
+	id := c.Param("id")
+	uInfo, exists := c.Get("user info")
+	if !exists {
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrServer, constant.ErrRequest, nil)
+		c.JSON(http.StatusInternalServerError, rd)
+		return
+	}
+	userInfo := uInfo.(*model.ContextInfo)
+
+	response, err := base.GrantService.DeclineAccessGrant(userInfo, id)
+	if err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "access grant declined", response)
+	c.JSON(rd.Code, rd)
+}
+
+func (base *Controller) RevokeAccessGrant(c *gin.Context) {
+	id := c.Param("id")
+	uInfo, exists := c.Get("user info")
+	if !exists {
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrServer, constant.ErrRequest, nil)
+		c.JSON(http.StatusInternalServerError, rd)
+		return
+	}
+	userInfo := uInfo.(*model.ContextInfo)
+
+	if err := base.GrantService.RevokeAccessGrant(userInfo, id); err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "access grant revoked", nil)
+	c.JSON(rd.Code, rd)
+}
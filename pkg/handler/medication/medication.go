@@ -2,6 +2,7 @@ package medication
 
 import (
 	"github.com/gin-gonic/gin"
+	"io"
 	"medbuddy-backend/internal/constant"
 	"medbuddy-backend/internal/model"
 	"medbuddy-backend/utility"
@@ -52,7 +53,15 @@ func (base *Controller) GetMedication(c *gin.Context) {
 		return
 	}
 
-	response, err := base.MedicationService.GetMedication(id)
+	uInfo, exists := c.Get("user info")
+	if !exists {
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrServer, constant.ErrRequest, nil)
+		c.JSON(http.StatusInternalServerError, rd)
+		return
+	}
+	userInfo := uInfo.(*model.ContextInfo)
+
+	response, err := base.MedicationService.GetMedication(userInfo, id)
 	if err != nil {
 		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
 		c.JSON(err.Code(), rd)
@@ -83,6 +92,48 @@ func (base *Controller) GetPatientMedications(c *gin.Context) {
 	c.JSON(rd.Code, rd)
 }
 
+func (base *Controller) UpdateMedication(c *gin.Context) {
+	var data model.UpdateMedicationRequest
+
+	id := c.Param("id")
+	if id == "" {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrRequest, "missing id parameter", nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	uInfo, exists := c.Get("user info")
+	if !exists {
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrServer, constant.ErrRequest, nil)
+		c.JSON(http.StatusInternalServerError, rd)
+		return
+	}
+	userInfo := uInfo.(*model.ContextInfo)
+
+	if err := c.BindJSON(&data); err != nil {
+		base.Logger.Error("Error when binding request body, error: ", err.Error())
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrRequest, constant.ErrRequest, nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	if err := base.Validate.Struct(data); err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrValidation, err.Error(), nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	response, err := base.MedicationService.UpdateMedication(userInfo, id, &data)
+	if err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "updated medication successfully", response)
+	c.JSON(rd.Code, rd)
+}
+
 func (base *Controller) DeleteMedication(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
@@ -110,7 +161,7 @@ func (base *Controller) DeleteMedication(c *gin.Context) {
 }
 
 func (base *Controller) AddPractitionerToMeds(c *gin.Context) {
-	id := c.Param("medication-id")
+	id := c.Param("id")
 	if id == "" {
 		rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrRequest, "missing id parameter", nil)
 		c.JSON(rd.Code, rd)
@@ -149,3 +200,123 @@ func (base *Controller) AddPractitionerToMeds(c *gin.Context) {
 	rd := utility.BuildSuccessResponse(http.StatusOK, response, nil)
 	c.JSON(rd.Code, rd)
 }
+
+func (base *Controller) RefillMedication(c *gin.Context) {
+	var data model.RefillRequest
+
+	uInfo, exists := c.Get("user info")
+	if !exists {
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrServer, constant.ErrRequest, nil)
+		c.JSON(http.StatusInternalServerError, rd)
+		return
+	}
+	userInfo := uInfo.(*model.ContextInfo)
+
+	if err := c.BindJSON(&data); err != nil {
+		base.Logger.Error("Error when binding request body, error: ", err.Error())
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrRequest, constant.ErrRequest, nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	if err := base.Validate.Struct(data); err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrValidation, err.Error(), nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	response, err := base.MedicationService.RefillMedication(userInfo, c.Param("id"), &data)
+	if err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "refill recorded successfully", response)
+	c.JSON(rd.Code, rd)
+}
+
+func (base *Controller) PauseMedication(c *gin.Context) {
+	var data model.PauseMedicationRequest
+
+	uInfo, exists := c.Get("user info")
+	if !exists {
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrServer, constant.ErrRequest, nil)
+		c.JSON(http.StatusInternalServerError, rd)
+		return
+	}
+	userInfo := uInfo.(*model.ContextInfo)
+
+	// the body is optional, medications without a resume date are paused until they are resumed
+	if err := c.ShouldBindJSON(&data); err != nil && err != io.EOF {
+		base.Logger.Error("Error when binding request body, error: ", err.Error())
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrRequest, constant.ErrRequest, nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	response, err := base.MedicationService.PauseMedication(userInfo, c.Param("id"), &data)
+	if err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "medication paused", response)
+	c.JSON(rd.Code, rd)
+}
+
+func (base *Controller) ResumeMedication(c *gin.Context) {
+	uInfo, exists := c.Get("user info")
+	if !exists {
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrServer, constant.ErrRequest, nil)
+		c.JSON(http.StatusInternalServerError, rd)
+		return
+	}
+	userInfo := uInfo.(*model.ContextInfo)
+
+	response, err := base.MedicationService.ResumeMedication(userInfo, c.Param("id"))
+	if err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "medication resumed", response)
+	c.JSON(rd.Code, rd)
+}
+
+func (base *Controller) DiscontinueMedication(c *gin.Context) {
+	var data model.DiscontinueMedicationRequest
+
+	uInfo, exists := c.Get("user info")
+	if !exists {
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrServer, constant.ErrRequest, nil)
+		c.JSON(http.StatusInternalServerError, rd)
+		return
+	}
+	userInfo := uInfo.(*model.ContextInfo)
+
+	if err := c.BindJSON(&data); err != nil {
+		base.Logger.Error("Error when binding request body, error: ", err.Error())
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrRequest, constant.ErrRequest, nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	if err := base.Validate.Struct(data); err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, constant.StatusFailed, constant.ErrValidation, err.Error(), nil)
+		c.JSON(rd.Code, rd)
+		return
+	}
+
+	response, err := base.MedicationService.DiscontinueMedication(userInfo, c.Param("id"), &data)
+	if err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "medication discontinued", response)
+	c.JSON(rd.Code, rd)
+}
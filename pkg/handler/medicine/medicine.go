@@ -136,3 +136,17 @@ func (base *Controller) DeleteMedicine(c *gin.Context) {
 	rd := utility.BuildSuccessResponse(http.StatusOK, "medicine deleted successfully", nil)
 	c.JSON(rd.Code, rd)
 }
+
+func (base *Controller) ListMedicines(c *gin.Context) {
+	page, limit := utility.ParsePagination(c.Query("page"), c.Query("limit"))
+
+	response, err := base.MedicineService.ListMedicines(page, limit)
+	if err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "", response)
+	c.JSON(rd.Code, rd)
+}
@@ -53,6 +53,7 @@ func (base *Controller) LoginPractitioner(c *gin.Context) {
 		return
 	}
 
+	data.IP = c.ClientIP()
 	response, err := base.PractitionerService.LoginPractitioner(&data)
 	if err != nil {
 		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, http.StatusText(err.Code()), err.Error(), nil)
@@ -157,3 +158,26 @@ func (base *Controller) GetPractitionerMedications(c *gin.Context) {
 	rd := utility.BuildSuccessResponse(http.StatusOK, "", response)
 	c.JSON(rd.Code, rd)
 }
+
+func (base *Controller) GetPatientRoster(c *gin.Context) {
+	uInfo, exists := c.Get("user info")
+	if !exists {
+		rd := utility.BuildErrorResponse(http.StatusInternalServerError, constant.StatusFailed, constant.ErrServer, constant.ErrRequest, nil)
+		c.JSON(http.StatusInternalServerError, rd)
+		return
+	}
+	userInfo := uInfo.(*model.ContextInfo)
+
+	page, limit := utility.ParsePagination(c.Query("page"), c.Query("limit"))
+	query := model.RosterQuery{Sort: c.Query("sort"), Order: c.Query("order"), Page: page, Limit: limit}
+
+	response, err := base.PractitionerService.GetPatientRoster(userInfo, &query)
+	if err != nil {
+		rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed, constant.ErrRequest, err.Error(), nil)
+		c.JSON(err.Code(), rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "", response)
+	c.JSON(rd.Code, rd)
+}
@@ -0,0 +1,19 @@
+package prescription
+
+import (
+	"github.com/go-playground/validator/v10"
+	log "github.com/sirupsen/logrus"
+	"medbuddy-backend/service/prescription"
+)
+
+type Controller struct {
+	Validate            *validator.Validate
+	Logger              *log.Logger
+	PrescriptionService prescription.PrescriptionService
+}
+
+func NewController(validate *validator.Validate, logger *log.Logger, pService prescription.PrescriptionService) *Controller {
+	return &Controller{
+		validate, logger, pService,
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"medbuddy-backend/internal/constant"
 	"medbuddy-backend/internal/model"
+	"medbuddy-backend/internal/policy"
 	"medbuddy-backend/utility"
 	"net/http"
 	"strings"
@@ -14,7 +15,7 @@ func CORS() gin.HandlerFunc {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
 			return
@@ -23,8 +24,10 @@ func CORS() gin.HandlerFunc {
 	}
 }
 
-// Practitioner is the middleware for admin-only endpoints
-func Practitioner() gin.HandlerFunc {
+// Authorize is the middleware for authenticated endpoints, it lets the request through if the
+// role of the user is allowed to perform action. Whether the user may perform it on the requested
+// resource is checked by the services through the policy package.
+func Authorize(action policy.Action) gin.HandlerFunc {
 	return func(c *gin.Context) {
 
 		token := getToken(c.Request)
@@ -45,95 +48,29 @@ func Practitioner() gin.HandlerFunc {
 			return
 		}
 
-		// Check if request is from a practitioner
-		if claims.Role != constant.Roles[constant.Practitioner] {
-			rd := utility.BuildErrorResponse(http.StatusUnauthorized, constant.StatusFailed,
-				constant.ErrUnauthorized, "cannot access this endpoint", nil)
-			c.JSON(http.StatusUnauthorized, rd)
-			c.Abort()
-			return
-		}
-
-		// Set details from token into context and execute next handler
-		c.Set("user info", &model.ContextInfo{
-			ID:    claims.ID,
-			Role:  claims.Role,
-			Email: claims.Email,
-		})
-		c.Next()
-	}
-}
-
-// Patient is the middleware for patient endpoints
-func Patient() gin.HandlerFunc {
-	return func(c *gin.Context) {
-
-		token := getToken(c.Request)
-		if token == "" {
-			rd := utility.BuildErrorResponse(http.StatusUnauthorized, constant.StatusFailed,
-				constant.ErrUnauthorized, "no token specified", nil)
-			c.JSON(http.StatusUnauthorized, rd)
-			c.Abort()
-			return
-		}
-
-		claims, err := VerifyToken(token)
-		if err != nil {
-			rd := utility.BuildErrorResponse(http.StatusUnauthorized, constant.StatusFailed,
-				constant.ErrUnauthorized, err.Error(), nil)
-			c.JSON(http.StatusUnauthorized, rd)
-			c.Abort()
-			return
-		}
-
-		// Check if request is from a patient
-		if claims.Role != constant.Roles[constant.Patient] {
-			rd := utility.BuildErrorResponse(http.StatusUnauthorized, constant.StatusFailed,
-				constant.ErrUnauthorized, "cannot access this endpoint", nil)
-			c.JSON(http.StatusUnauthorized, rd)
+		userInfo := contextInfo(claims)
+		if err := policy.Check(userInfo, action); err != nil {
+			rd := utility.BuildErrorResponse(err.Code(), constant.StatusFailed,
+				constant.ErrForbidden, err.Error(), nil)
+			c.JSON(err.Code(), rd)
 			c.Abort()
 			return
 		}
 
 		// Set details from token in context and execute next handler
-		c.Set("user info", &model.ContextInfo{
-			ID:    claims.ID,
-			Role:  claims.Role,
-			Email: claims.Email,
-		})
+		c.Set("user info", userInfo)
 		c.Next()
 	}
 }
 
-// Generic is the middleware for patient endpoints
-func Generic() gin.HandlerFunc {
-	return func(c *gin.Context) {
-
-		token := getToken(c.Request)
-		if token == "" {
-			rd := utility.BuildErrorResponse(http.StatusUnauthorized, constant.StatusFailed,
-				constant.ErrUnauthorized, "no token specified", nil)
-			c.JSON(http.StatusUnauthorized, rd)
-			c.Abort()
-			return
-		}
-
-		claims, err := VerifyToken(token)
-		if err != nil {
-			rd := utility.BuildErrorResponse(http.StatusUnauthorized, constant.StatusFailed,
-				constant.ErrUnauthorized, err.Error(), nil)
-			c.JSON(http.StatusUnauthorized, rd)
-			c.Abort()
-			return
-		}
-
-		// Set details from token in context and execute next handler
-		c.Set("user info", &model.ContextInfo{
-			ID:    claims.ID,
-			Role:  claims.Role,
-			Email: claims.Email,
-		})
-		c.Next()
+func contextInfo(claims *Claims) *model.ContextInfo {
+	return &model.ContextInfo{
+		ID:             claims.Subject,
+		Role:           claims.Role,
+		Email:          claims.Email,
+		TokenID:        claims.ID,
+		TokenFamilyID:  claims.FamilyID,
+		TokenExpiresAt: claims.ExpiresAt.Time,
 	}
 }
 
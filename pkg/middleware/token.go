@@ -1,37 +1,68 @@
 package middleware
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"medbuddy-backend/internal/config"
 	"medbuddy-backend/internal/constant"
+	"medbuddy-backend/pkg/repository/mongo"
+	"medbuddy-backend/utility"
 	"time"
 )
 
+var logger = utility.NewLogger()
+
 type Claims struct {
-	Email string
-	Role  int
+	Email    string
+	Role     int
+	FamilyID string `json:"fid,omitempty"` // refresh token family the token was issued for
 	jwt.RegisteredClaims
 }
 
-func CreateToken(id, email string, role int) (string, error) {
+// CreateToken issues a short-lived access token for the patient or practitioner with the given
+// id. Every token gets its own jti so that it can be revoked on its own.
+func CreateToken(id, email string, role int, familyID string) (token string, expiresAt time.Time, err error) {
+	now := time.Now()
+	expiresAt = now.Add(AccessTokenTTL())
+
 	// Create a new token object, specifying signing method and the claims
 	// you would like it to contain.
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
-		Email: email,
-		Role:  role,
+	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		Email:    email,
+		Role:     role,
+		FamilyID: familyID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
 			Issuer:    constant.AppName,
-			ID:        id,
+			Subject:   id,
+			ID:        primitive.NewObjectID().Hex(),
 		},
 	})
 
 	// Sign and get the complete encoded token as a string using the secret
-	return token.SignedString([]byte(config.GetConfig().SecretKey))
+	token, err = jwtToken.SignedString([]byte(config.GetConfig().SecretKey))
+	return token, expiresAt, err
+}
+
+func AccessTokenTTL() time.Duration {
+	if mins := config.GetConfig().AccessTokenTTLMins; mins > 0 {
+		return time.Duration(mins) * time.Minute
+	}
+
+	return constant.DefaultAccessTokenTTL
+}
+
+func RefreshTokenTTL() time.Duration {
+	if hours := config.GetConfig().RefreshTokenTTLHours; hours > 0 {
+		return time.Duration(hours) * time.Hour
+	}
+
+	return constant.DefaultRefreshTokenTTL
 }
 
 func VerifyToken(tokenString string) (*Claims, error) {
@@ -57,9 +88,20 @@ func VerifyToken(tokenString string) (*Claims, error) {
 	}
 
 	claims, ok := token.Claims.(*Claims)
-	if !(ok && token.Valid) {
+	if !(ok && token.Valid) || claims.Subject == "" || claims.ID == "" {
 		return nil, errors.New("invalid token")
 	}
 
+	// Tokens are revoked on logout and when their refresh token family is revoked
+	revoked, err := mongo.GetDB().IsTokenRevoked(context.Background(), claims.ID, claims.FamilyID)
+	if err != nil {
+		logger.Error("Error checking token revocation, error: ", err.Error())
+		return nil, errors.New("could not verify token")
+	}
+
+	if revoked {
+		return nil, errors.New("revoked token")
+	}
+
 	return claims, nil
 }
@@ -0,0 +1,70 @@
+package notification
+
+import (
+	"strings"
+
+	"medbuddy-backend/internal/config"
+)
+
+const (
+	defaultSMSBaseURL = "https://api.twilio.com"
+	defaultPushURL    = "https://fcm.googleapis.com/fcm/send"
+	defaultSMTPPort   = 587
+)
+
+// NewDispatcherFromConfig returns a dispatcher for the channels that are configured. Email
+// goes through Mailgun unless EMAIL_PROVIDER is smtp.
+func NewDispatcherFromConfig(conf *config.Configuration) *Dispatcher {
+	var notifiers []Notifier
+
+	switch strings.ToLower(conf.EmailProvider) {
+	case "smtp":
+		if conf.SMTPHost != "" {
+			port := conf.SMTPPort
+			if port == 0 {
+				port = defaultSMTPPort
+			}
+
+			notifiers = append(notifiers, &SMTPNotifier{
+				Host:     conf.SMTPHost,
+				Port:     port,
+				Username: conf.SMTPUsername,
+				Password: conf.SMTPPassword,
+				From:     conf.EmailFrom,
+			})
+		}
+	default:
+		if conf.EmailDomain != "" && conf.MailgunEmailKey != "" {
+			notifiers = append(notifiers, &MailgunNotifier{Domain: conf.EmailDomain, APIKey: conf.MailgunEmailKey})
+		}
+	}
+
+	if conf.SMSAccountSID != "" {
+		baseURL := conf.SMSBaseURL
+		if baseURL == "" {
+			baseURL = defaultSMSBaseURL
+		}
+
+		notifiers = append(notifiers, &SMSNotifier{
+			BaseURL:    baseURL,
+			AccountSID: conf.SMSAccountSID,
+			AuthToken:  conf.SMSAuthToken,
+			From:       conf.SMSFrom,
+		})
+	}
+
+	if conf.PushServerKey != "" {
+		url := conf.PushURL
+		if url == "" {
+			url = defaultPushURL
+		}
+
+		notifiers = append(notifiers, &PushNotifier{URL: url, ServerKey: conf.PushServerKey})
+	}
+
+	if conf.WebhookSigningSecret != "" {
+		notifiers = append(notifiers, &WebhookNotifier{Secret: conf.WebhookSigningSecret})
+	}
+
+	return NewDispatcher(notifiers...)
+}
@@ -0,0 +1,101 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+
+	"github.com/mailgun/mailgun-go/v4"
+	"medbuddy-backend/internal/constant"
+)
+
+// MailgunNotifier sends email through the Mailgun API
+type MailgunNotifier struct {
+	Domain string
+	APIKey string
+	// APIBase overrides the Mailgun API address, it defaults to mailgun.APIBase
+	APIBase string
+}
+
+func (n *MailgunNotifier) Channel() string {
+	return constant.ChannelEmail
+}
+
+func (n *MailgunNotifier) Send(ctx context.Context, to Recipient, msg *Message) error {
+	if to.Email == "" {
+		return ErrNoAddress
+	}
+
+	mg := mailgun.NewMailgun(n.Domain, n.APIKey)
+	if n.APIBase != "" {
+		mg.SetAPIBase(n.APIBase)
+	}
+
+	sender := "MedBuddy HQ <" + constant.AppName + "@" + n.Domain + ">"
+	message := mg.NewMessage(sender, msg.Subject, msg.Text, to.Email)
+	if msg.HTML != "" {
+		message.SetHtml(msg.HTML)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	_, _, err := mg.Send(ctx, message)
+	return err
+}
+
+// SMTPNotifier sends email through an SMTP server
+type SMTPNotifier struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+func (n *SMTPNotifier) Channel() string {
+	return constant.ChannelEmail
+}
+
+func (n *SMTPNotifier) Send(ctx context.Context, to Recipient, msg *Message) error {
+	if to.Email == "" {
+		return ErrNoAddress
+	}
+
+	contentType, body := "text/plain", msg.Text
+	if msg.HTML != "" {
+		contentType, body = "text/html", msg.HTML
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", n.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", to.Email)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: %s; charset=\"utf-8\"\r\n\r\n", contentType)
+	buf.WriteString(body)
+
+	var auth smtp.Auth
+	if n.Username != "" {
+		auth = smtp.PlainAuth("", n.Username, n.Password, n.Host)
+	}
+
+	addr := net.JoinHostPort(n.Host, strconv.Itoa(n.Port))
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(addr, auth, n.From, []string{to.Email}, buf.Bytes())
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
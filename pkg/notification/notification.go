@@ -0,0 +1,92 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ErrNoAddress is returned when a recipient has no address for the channel of a notifier
+var ErrNoAddress = errors.New("recipient has no address for this channel")
+
+// defaultTimeout bounds the requests made by the HTTP based notifiers
+const defaultTimeout = 10 * time.Second
+
+// Message is the content of a notification. Each channel uses the parts that fit it.
+type Message struct {
+	Subject string
+	HTML    string                 // body of email notifications
+	Text    string                 // body of sms and push notifications
+	Event   string                 // event name sent with webhook notifications
+	Data    map[string]interface{} // payload of push and webhook notifications
+}
+
+// Recipient holds the addresses a notification can be delivered to
+type Recipient struct {
+	Name       string
+	Email      string
+	Phone      string
+	PushToken  string
+	WebhookURL string
+}
+
+// Notifier delivers messages over a single channel
+type Notifier interface {
+	Channel() string
+	Send(ctx context.Context, to Recipient, msg *Message) error
+}
+
+// Dispatcher sends messages over the channels chosen for a recipient
+type Dispatcher struct {
+	notifiers map[string]Notifier
+}
+
+func NewDispatcher(notifiers ...Notifier) *Dispatcher {
+	d := &Dispatcher{notifiers: map[string]Notifier{}}
+	for _, n := range notifiers {
+		d.notifiers[n.Channel()] = n
+	}
+
+	return d
+}
+
+// Send delivers msg on each of the given channels and returns the channels it was delivered
+// on. An error is returned for every channel that is not configured or failed.
+func (d *Dispatcher) Send(ctx context.Context, channels []string, to Recipient, msg *Message) (delivered []string, err error) {
+	var errs []error
+	for _, channel := range channels {
+		notifier, ok := d.notifiers[channel]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: channel is not configured", channel))
+			continue
+		}
+
+		if err := notifier.Send(ctx, to, msg); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", channel, err))
+			continue
+		}
+
+		delivered = append(delivered, channel)
+	}
+
+	return delivered, errors.Join(errs...)
+}
+
+func httpClient(client *http.Client) *http.Client {
+	if client != nil {
+		return client
+	}
+
+	return &http.Client{Timeout: defaultTimeout}
+}
+
+// checkResponse turns a non 2xx response into an error
+func checkResponse(res *http.Response) error {
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+
+	return fmt.Errorf("unexpected response status: %s", res.Status)
+}
@@ -0,0 +1,63 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"medbuddy-backend/internal/constant"
+)
+
+// PushNotifier sends push notifications to a device or browser token through a push
+// gateway compatible with the Firebase Cloud Messaging HTTP API
+type PushNotifier struct {
+	URL       string // e.g. https://fcm.googleapis.com/fcm/send
+	ServerKey string
+	Client    *http.Client
+}
+
+type pushRequest struct {
+	To           string                 `json:"to"`
+	Notification pushNotification       `json:"notification"`
+	Data         map[string]interface{} `json:"data,omitempty"`
+}
+
+type pushNotification struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+func (n *PushNotifier) Channel() string {
+	return constant.ChannelPush
+}
+
+func (n *PushNotifier) Send(ctx context.Context, to Recipient, msg *Message) error {
+	if to.PushToken == "" {
+		return ErrNoAddress
+	}
+
+	body, err := json.Marshal(pushRequest{
+		To:           to.PushToken,
+		Notification: pushNotification{Title: msg.Subject, Body: msg.Text},
+		Data:         msg.Data,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "key="+n.ServerKey)
+
+	res, err := httpClient(n.Client).Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return checkResponse(res)
+}
@@ -0,0 +1,51 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"medbuddy-backend/internal/constant"
+)
+
+// SMSNotifier sends text messages through an API compatible with Twilio's Messages resource
+type SMSNotifier struct {
+	BaseURL    string // e.g. https://api.twilio.com
+	AccountSID string
+	AuthToken  string
+	From       string
+	Client     *http.Client
+}
+
+func (n *SMSNotifier) Channel() string {
+	return constant.ChannelSMS
+}
+
+func (n *SMSNotifier) Send(ctx context.Context, to Recipient, msg *Message) error {
+	if to.Phone == "" {
+		return ErrNoAddress
+	}
+
+	form := url.Values{}
+	form.Set("To", to.Phone)
+	form.Set("From", n.From)
+	form.Set("Body", msg.Text)
+
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", strings.TrimRight(n.BaseURL, "/"), n.AccountSID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(n.AccountSID, n.AuthToken)
+
+	res, err := httpClient(n.Client).Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return checkResponse(res)
+}
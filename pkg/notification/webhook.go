@@ -0,0 +1,152 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"medbuddy-backend/internal/constant"
+)
+
+const (
+	SignatureHeader = "X-MedBuddy-Signature"
+	TimestampHeader = "X-MedBuddy-Timestamp"
+)
+
+// ErrNonPublicAddress is returned for webhooks whose host is, or resolves to, a loopback, private or
+// link-local address, so that patients cannot make the server post to its own network
+var ErrNonPublicAddress = errors.New("webhook host is not a public address")
+
+// webhookClient only connects to public addresses. The address is checked when the connection is
+// made, after the host has been resolved, so a host that resolves to a public address when the
+// webhook is set and to a private one later is refused too. Proxies are not used, the address
+// connected to would be the proxy's.
+var webhookClient = &http.Client{
+	Timeout: defaultTimeout,
+	Transport: &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: defaultTimeout, Control: dialPublicOnly}).DialContext,
+		TLSHandshakeTimeout: defaultTimeout,
+		ForceAttemptHTTP2:   true,
+	},
+}
+
+// WebhookNotifier posts notifications as JSON to the recipient's webhook URL. Each request is
+// signed with an HMAC-SHA256 of "<timestamp>.<body>" so receivers can verify it came from us.
+type WebhookNotifier struct {
+	Secret string
+	Client *http.Client
+}
+
+type webhookPayload struct {
+	Event   string                 `json:"event"`
+	Subject string                 `json:"subject,omitempty"`
+	Text    string                 `json:"text,omitempty"`
+	Data    map[string]interface{} `json:"data,omitempty"`
+	SentAt  time.Time              `json:"sent_at"`
+}
+
+func (n *WebhookNotifier) Channel() string {
+	return constant.ChannelWebhook
+}
+
+func (n *WebhookNotifier) Send(ctx context.Context, to Recipient, msg *Message) error {
+	if to.WebhookURL == "" {
+		return ErrNoAddress
+	}
+
+	sentAt := time.Now().UTC()
+	body, err := json.Marshal(webhookPayload{
+		Event:   msg.Event,
+		Subject: msg.Subject,
+		Text:    msg.Text,
+		Data:    msg.Data,
+		SentAt:  sentAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(sentAt.Unix(), 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, to.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, "sha256="+Sign(n.Secret, timestamp, body))
+
+	client := n.Client
+	if client == nil {
+		client = webhookClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return checkResponse(res)
+}
+
+// CheckWebhookURL resolves the host of a webhook URL and reports ErrNonPublicAddress when one of
+// its addresses is not public. Webhooks are checked again whenever they are sent.
+func CheckWebhookURL(ctx context.Context, webhookURL string) error {
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return err
+	}
+
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return fmt.Errorf("could not resolve webhook host %s", u.Hostname())
+	}
+
+	for _, ip := range ips {
+		if !isPublicIP(ip.IP) {
+			return ErrNonPublicAddress
+		}
+	}
+
+	return nil
+}
+
+// dialPublicOnly refuses connections to addresses that are not public
+func dialPublicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+		return fmt.Errorf("%w: %s", ErrNonPublicAddress, host)
+	}
+
+	return nil
+}
+
+func isPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() && !ip.IsUnspecified()
+}
+
+// Sign returns the hex encoded signature of a webhook body sent at timestamp
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,304 @@
+package mongo
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"medbuddy-backend/internal/constant"
+	"medbuddy-backend/internal/model"
+	"time"
+)
+
+func (m *Mongo) CreateAccessGrant(ctx context.Context, grant *model.AccessGrant) error {
+	db := m.mongoclient.Database(constant.AppName)
+	gColl := db.Collection(constant.AccessGrantCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	if _, err := gColl.InsertOne(ctx, grant); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetOpenAccessGrant returns the pending or active grant that already gives the practitioner access
+// to the medication, either directly or through a grant on the whole profile. A zero medicationID
+// only matches grants on the whole profile.
+func (m *Mongo) GetOpenAccessGrant(ctx context.Context, patientID, practitionerID, medicationID primitive.ObjectID, now time.Time) (grant model.AccessGrant, found bool, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	gColl := db.Collection(constant.AccessGrantCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	scope := bson.A{bson.D{{Key: "medication_id", Value: bson.D{{Key: "$exists", Value: false}}}}}
+	if !medicationID.IsZero() {
+		scope = append(scope, bson.D{{Key: "medication_id", Value: medicationID}})
+	}
+
+	filter := bson.D{
+		{Key: "patient_id", Value: patientID},
+		{Key: "practitioner_id", Value: practitionerID},
+		{Key: "status", Value: bson.D{{Key: "$in", Value: bson.A{constant.InvitationPending, constant.InvitationActive}}}},
+		{Key: "$and", Value: bson.A{
+			bson.D{{Key: "$or", Value: scope}},
+			bson.D{{Key: "$or", Value: notExpiredFilter(now)}},
+		}},
+	}
+
+	if err := gColl.FindOne(ctx, filter).Decode(&grant); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return model.AccessGrant{}, false, nil
+		}
+		return model.AccessGrant{}, false, err
+	}
+
+	return grant, true, nil
+}
+
+func (m *Mongo) GetAccessGrant(ctx context.Context, id primitive.ObjectID) (grant model.AccessGrantResponse, found bool, err error) {
+	grants, err := m.getAccessGrants(ctx, bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		return model.AccessGrantResponse{}, false, err
+	}
+
+	if len(grants) == 0 {
+		return model.AccessGrantResponse{}, false, nil
+	}
+
+	return grants[0], true, nil
+}
+
+// GetAccessGrants returns the grants of a patient or of a practitioner. Grants past their expiry
+// date are only returned when filtering on the expired status.
+func (m *Mongo) GetAccessGrants(ctx context.Context, request *model.AccessGrantFilter, now time.Time) (grants []model.AccessGrantResponse, err error) {
+	filter := bson.D{}
+	if !request.PatientID.IsZero() {
+		filter = append(filter, bson.E{Key: "patient_id", Value: request.PatientID})
+	}
+
+	if !request.PractitionerID.IsZero() {
+		filter = append(filter, bson.E{Key: "practitioner_id", Value: request.PractitionerID})
+	}
+
+	switch request.Status {
+	case "":
+	case constant.InvitationExpired:
+		filter = append(filter,
+			bson.E{Key: "status", Value: bson.D{{Key: "$in", Value: bson.A{constant.InvitationPending, constant.InvitationActive}}}},
+			bson.E{Key: "expires_at", Value: bson.D{{Key: "$lte", Value: now}}},
+		)
+	case constant.InvitationPending, constant.InvitationActive:
+		filter = append(filter,
+			bson.E{Key: "status", Value: request.Status},
+			bson.E{Key: "$or", Value: notExpiredFilter(now)},
+		)
+	default:
+		filter = append(filter, bson.E{Key: "status", Value: request.Status})
+	}
+
+	return m.getAccessGrants(ctx, filter)
+}
+
+func (m *Mongo) getAccessGrants(ctx context.Context, filter bson.D) (grants []model.AccessGrantResponse, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	gColl := db.Collection(constant.AccessGrantCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	matchStage := bson.D{{Key: "$match", Value: filter}}
+	patientLookupStage, patientUnwindStage := getPatientLookupAndUnwindStage()
+	practitionerLookupStage, practitionerUnwindStage := getPractitionerLookupAndUnwindStage()
+	medicLookupStage, medicUnwindStage := getMedicationLookupAndUnwindStage()
+	sortStage := bson.D{{Key: "$sort", Value: bson.D{{Key: "created_at", Value: -1}}}}
+
+	pipeline := mongo.Pipeline{matchStage, patientLookupStage, patientUnwindStage, practitionerLookupStage,
+		practitionerUnwindStage, medicLookupStage, medicUnwindStage, sortStage}
+	cur, err := gColl.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	grants = []model.AccessGrantResponse{}
+	if err := cur.All(ctx, &grants); err != nil {
+		return nil, err
+	}
+
+	return grants, nil
+}
+
+// RespondToAccessGrant accepts or declines a pending grant that has not expired yet
+func (m *Mongo) RespondToAccessGrant(ctx context.Context, id primitive.ObjectID, status string, now time.Time) (found bool, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	gColl := db.Collection(constant.AccessGrantCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "status", Value: constant.InvitationPending},
+		{Key: "$or", Value: notExpiredFilter(now)},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: status},
+		{Key: "responded_at", Value: now},
+	}}}
+
+	res, err := gColl.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return res.MatchedCount > 0, nil
+}
+
+// RevokeAccessGrant ends a pending or active grant. revokedBy is the role of the user revoking it.
+func (m *Mongo) RevokeAccessGrant(ctx context.Context, id primitive.ObjectID, revokedBy string, now time.Time) (found bool, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	gColl := db.Collection(constant.AccessGrantCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "status", Value: bson.D{{Key: "$in", Value: bson.A{constant.InvitationPending, constant.InvitationActive}}}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: constant.InvitationRevoked},
+		{Key: "revoked_at", Value: now},
+		{Key: "revoked_by", Value: revokedBy},
+	}}}
+
+	res, err := gColl.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return res.MatchedCount > 0, nil
+}
+
+// GetProfilePractitionerIDs returns the practitioners with an active, unexpired grant on the whole
+// profile of the patient
+func (m *Mongo) GetProfilePractitionerIDs(ctx context.Context, patientID primitive.ObjectID, now time.Time) (ids []primitive.ObjectID, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	gColl := db.Collection(constant.AccessGrantCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "patient_id", Value: patientID},
+		{Key: "status", Value: constant.InvitationActive},
+		{Key: "medication_id", Value: bson.D{{Key: "$exists", Value: false}}},
+		{Key: "$or", Value: notExpiredFilter(now)},
+	}
+	values, err := gColl.Distinct(ctx, "practitioner_id", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	ids = make([]primitive.ObjectID, 0, len(values))
+	for _, v := range values {
+		if id, ok := v.(primitive.ObjectID); ok {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
+// notExpiredFilter matches the grants that have no expiry date or expire after now, it is meant to be
+// used as the value of an $or
+func notExpiredFilter(now time.Time) bson.A {
+	return bson.A{
+		bson.D{{Key: "expires_at", Value: bson.D{{Key: "$exists", Value: false}}}},
+		bson.D{{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: now}}}},
+	}
+}
+
+func getPractitionerLookupAndUnwindStage() (practitionerLookup bson.D, practitionerUnwind bson.D) {
+	practitionerLookup = bson.D{{
+		Key: "$lookup",
+		Value: bson.D{{
+			Key:   "from",
+			Value: constant.PractitionersCollection,
+		}, {
+			Key:   "localField",
+			Value: "practitioner_id",
+		}, {
+			Key:   "foreignField",
+			Value: "_id",
+		}, {
+			Key:   "as",
+			Value: "practitioner",
+		}},
+	}}
+
+	practitionerUnwind = bson.D{{
+		Key: "$unwind",
+		Value: bson.D{{
+			Key:   "path",
+			Value: "$practitioner",
+		}, {
+			Key:   "preserveNullAndEmptyArrays",
+			Value: true,
+		}},
+	}}
+
+	return
+}
+
+// getGrantedPractitionerStages sets the field as of the documents to the ids of the practitioners
+// with an active, unexpired grant on the medication, either on the medication itself or on the
+// whole profile of its patient
+func getGrantedPractitionerStages(patientField, medicationField, as string) (grantLookup bson.D, practitionerIDs bson.D) {
+	grantLookup = bson.D{{
+		Key: "$lookup",
+		Value: bson.D{{
+			Key:   "from",
+			Value: constant.AccessGrantCollection,
+		}, {
+			Key: "let",
+			Value: bson.D{
+				{Key: "patient", Value: "$" + patientField},
+				{Key: "medication", Value: "$" + medicationField},
+			},
+		}, {
+			Key: "pipeline",
+			Value: bson.A{
+				bson.D{{Key: "$match", Value: bson.D{{Key: "$expr", Value: bson.D{{Key: "$and", Value: bson.A{
+					bson.D{{Key: "$eq", Value: bson.A{"$patient_id", "$$patient"}}},
+					bson.D{{Key: "$eq", Value: bson.A{"$status", constant.InvitationActive}}},
+					bson.D{{Key: "$or", Value: bson.A{
+						bson.D{{Key: "$eq", Value: bson.A{bson.D{{Key: "$type", Value: "$medication_id"}}, "missing"}}},
+						bson.D{{Key: "$eq", Value: bson.A{"$medication_id", "$$medication"}}},
+					}}},
+					bson.D{{Key: "$or", Value: bson.A{
+						bson.D{{Key: "$eq", Value: bson.A{bson.D{{Key: "$type", Value: "$expires_at"}}, "missing"}}},
+						bson.D{{Key: "$gt", Value: bson.A{"$expires_at", "$$NOW"}}},
+					}}},
+				}}}}}}},
+				bson.D{{Key: "$project", Value: bson.D{{Key: "practitioner_id", Value: 1}}}},
+			},
+		}, {
+			Key:   "as",
+			Value: as,
+		}},
+	}}
+
+	practitionerIDs = bson.D{{Key: "$addFields", Value: bson.D{{Key: as, Value: "$" + as + ".practitioner_id"}}}}
+
+	return
+}
@@ -0,0 +1,145 @@
+package mongo
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"medbuddy-backend/internal/constant"
+	"medbuddy-backend/internal/model"
+)
+
+// GetAdherence aggregates the dosages of a patient, or of one of their medications, that were due
+// in the period of the filter. It fills the summary, time series and streaks of the report, the
+// rates are left to the caller.
+func (m *Mongo) GetAdherence(ctx context.Context, filter *model.AdherenceFilter) (report model.AdherenceReport, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	dColl := db.Collection(constant.DosageCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	match := bson.D{
+		{Key: "patient_id", Value: filter.PatientID},
+		{Key: "reminder_time", Value: bson.D{{Key: "$gte", Value: filter.From}, {Key: "$lt", Value: filter.To}}},
+		// as needed dosages are recorded when taken, they are never due
+		{Key: "as_needed", Value: bson.D{{Key: "$ne", Value: true}}},
+		// neither are the dosages of a paused medication
+		{Key: "$nor", Value: bson.A{bson.D{
+			{Key: "status", Value: constant.DosageNotTaken},
+			{Key: "is_active", Value: false},
+		}}},
+	}
+	if !filter.MedicationID.IsZero() {
+		match = append(match, bson.E{Key: "medication_id", Value: filter.MedicationID})
+	}
+
+	matchStage := bson.D{{Key: "$match", Value: match}}
+	onTimeStage := bson.D{{Key: "$addFields", Value: bson.D{{Key: "on_time", Value: bson.D{{Key: "$and", Value: bson.A{
+		bson.D{{Key: "$eq", Value: bson.A{"$status", constant.DosageTaken}}},
+		bson.D{{Key: "$lte", Value: bson.A{
+			bson.D{{Key: "$abs", Value: bson.D{{Key: "$subtract", Value: bson.A{"$time_taken", "$reminder_time"}}}}},
+			filter.OnTimeWindow.Milliseconds(),
+		}}},
+	}}}}}}}
+
+	summary := bson.A{bson.D{{Key: "$group", Value: append(bson.D{{Key: "_id", Value: nil}}, adherenceCounts()...)}}}
+
+	trunc := bson.D{
+		{Key: "date", Value: "$reminder_time"},
+		{Key: "unit", Value: filter.Interval},
+		{Key: "startOfWeek", Value: "monday"},
+	}
+	if filter.TimeZone != "" {
+		trunc = append(trunc, bson.E{Key: "timezone", Value: filter.TimeZone})
+	}
+	period := bson.D{{Key: "$dateTrunc", Value: trunc}}
+	series := bson.A{
+		bson.D{{Key: "$group", Value: append(bson.D{{Key: "_id", Value: period}}, adherenceCounts()...)}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+	}
+
+	// streaks count the dosages taken in a row, pending dosages do not break them
+	streak := bson.A{
+		bson.D{{Key: "$match", Value: bson.D{{Key: "status", Value: bson.D{{Key: "$in", Value: bson.A{
+			constant.DosageTaken, constant.DosageSkipped, constant.DosageMissed,
+		}}}}}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "reminder_time", Value: 1}}}},
+		bson.D{{Key: "$group", Value: bson.D{{Key: "_id", Value: nil}, {Key: "statuses", Value: bson.D{{Key: "$push", Value: "$status"}}}}}},
+		bson.D{{Key: "$replaceWith", Value: bson.D{{Key: "$reduce", Value: bson.D{
+			{Key: "input", Value: "$statuses"},
+			{Key: "initialValue", Value: bson.D{{Key: "current", Value: 0}, {Key: "longest", Value: 0}}},
+			{Key: "in", Value: bson.D{{Key: "$let", Value: bson.D{
+				{Key: "vars", Value: bson.D{{Key: "current", Value: bson.D{{Key: "$cond", Value: bson.A{
+					bson.D{{Key: "$eq", Value: bson.A{"$$this", constant.DosageTaken}}},
+					bson.D{{Key: "$add", Value: bson.A{"$$value.current", 1}}},
+					0,
+				}}}}}},
+				{Key: "in", Value: bson.D{
+					{Key: "current", Value: "$$current"},
+					{Key: "longest", Value: bson.D{{Key: "$max", Value: bson.A{"$$value.longest", "$$current"}}}},
+				}},
+			}}}},
+		}}}}},
+	}
+
+	facetStage := bson.D{{Key: "$facet", Value: bson.D{
+		{Key: "summary", Value: summary},
+		{Key: "series", Value: series},
+		{Key: "streak", Value: streak},
+	}}}
+
+	pipeline := mongo.Pipeline{matchStage, onTimeStage, facetStage}
+	cur, err := dColl.Aggregate(ctx, pipeline)
+	if err != nil {
+		return model.AdherenceReport{}, err
+	}
+
+	var results []struct {
+		Summary []model.AdherenceStats `bson:"summary"`
+		Series  []model.AdherencePoint `bson:"series"`
+		Streak  []struct {
+			Current int `bson:"current"`
+			Longest int `bson:"longest"`
+		} `bson:"streak"`
+	}
+	if err := cur.All(ctx, &results); err != nil {
+		return model.AdherenceReport{}, err
+	}
+
+	report.Series = []model.AdherencePoint{}
+	if len(results) == 0 {
+		return report, nil
+	}
+
+	if len(results[0].Summary) > 0 {
+		report.Summary = results[0].Summary[0]
+	}
+	if len(results[0].Series) > 0 {
+		report.Series = results[0].Series
+	}
+	if len(results[0].Streak) > 0 {
+		report.CurrentStreak = results[0].Streak[0].Current
+		report.LongestStreak = results[0].Streak[0].Longest
+	}
+
+	return report, nil
+}
+
+// adherenceCounts returns the $group accumulators counting dosages by status
+func adherenceCounts() bson.D {
+	count := func(cond interface{}) bson.D {
+		return bson.D{{Key: "$sum", Value: bson.D{{Key: "$cond", Value: bson.A{cond, 1, 0}}}}}
+	}
+	is := func(status string) bson.D {
+		return bson.D{{Key: "$eq", Value: bson.A{"$status", status}}}
+	}
+
+	return bson.D{
+		{Key: "taken", Value: count(is(constant.DosageTaken))},
+		{Key: "skipped", Value: count(is(constant.DosageSkipped))},
+		{Key: "missed", Value: count(is(constant.DosageMissed))},
+		{Key: "pending", Value: count(is(constant.DosageNotTaken))},
+		{Key: "on_time", Value: count("$on_time")},
+	}
+}
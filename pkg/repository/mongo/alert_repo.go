@@ -0,0 +1,254 @@
+package mongo
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"medbuddy-backend/internal/constant"
+	"medbuddy-backend/internal/model"
+	"time"
+)
+
+func (m *Mongo) CreateAlertRule(ctx context.Context, rule *model.AlertRule) error {
+	db := m.mongoclient.Database(constant.AppName)
+	rColl := db.Collection(constant.AlertRuleCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	if _, err := rColl.InsertOne(ctx, rule); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *Mongo) GetAlertRule(ctx context.Context, id primitive.ObjectID) (rule model.AlertRule, found bool, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	rColl := db.Collection(constant.AlertRuleCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	if err := rColl.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(&rule); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return model.AlertRule{}, false, nil
+		}
+		return model.AlertRule{}, false, err
+	}
+
+	return rule, true, nil
+}
+
+func (m *Mongo) GetAlertRules(ctx context.Context, practitionerID primitive.ObjectID) (rules []model.AlertRule, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	rColl := db.Collection(constant.AlertRuleCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cur, err := rColl.Find(ctx, bson.D{{Key: "practitioner_id", Value: practitionerID}}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	rules = []model.AlertRule{}
+	if err := cur.All(ctx, &rules); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// GetEnabledAlertRules returns the enabled rules of the given types, of the given practitioners
+// when there are any, sorted by practitioner
+func (m *Mongo) GetEnabledAlertRules(ctx context.Context, filter *model.AlertRuleFilter) (rules []model.AlertRule, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	rColl := db.Collection(constant.AlertRuleCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	query := bson.D{
+		{Key: "enabled", Value: true},
+		{Key: "type", Value: bson.D{{Key: "$in", Value: filter.Types}}},
+	}
+	if len(filter.PractitionerIDs) > 0 {
+		query = append(query, bson.E{Key: "practitioner_id", Value: bson.D{{Key: "$in", Value: filter.PractitionerIDs}}})
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "practitioner_id", Value: 1}, {Key: "created_at", Value: 1}})
+	cur, err := rColl.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	rules = []model.AlertRule{}
+	if err := cur.All(ctx, &rules); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+func (m *Mongo) UpdateAlertRule(ctx context.Context, rule *model.AlertRule) (found bool, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	rColl := db.Collection(constant.AlertRuleCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "threshold", Value: rule.Threshold},
+		{Key: "enabled", Value: rule.Enabled},
+		{Key: "updated_at", Value: rule.UpdatedAt},
+	}}}
+
+	res, err := rColl.UpdateByID(ctx, rule.ID, update)
+	if err != nil {
+		return false, err
+	}
+
+	return res.MatchedCount > 0, nil
+}
+
+func (m *Mongo) DeleteAlertRule(ctx context.Context, id primitive.ObjectID) (found bool, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	rColl := db.Collection(constant.AlertRuleCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	res, err := rColl.DeleteOne(ctx, bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		return false, err
+	}
+
+	return res.DeletedCount > 0, nil
+}
+
+// RaiseAlert stores the alert unless its rule already has an alert on the patient that is still
+// open, acknowledged or was raised after since. It reports whether the alert was stored.
+func (m *Mongo) RaiseAlert(ctx context.Context, alert *model.Alert, since time.Time) (created bool, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	aColl := db.Collection(constant.AlertCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "rule_id", Value: alert.RuleID},
+		{Key: "patient_id", Value: alert.PatientID},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "status", Value: bson.D{{Key: "$in", Value: bson.A{constant.AlertOpen, constant.AlertAcknowledged}}}}},
+			bson.D{{Key: "created_at", Value: bson.D{{Key: "$gt", Value: since}}}},
+		}},
+	}
+	if !alert.MedicationID.IsZero() {
+		filter = append(filter, bson.E{Key: "medication_id", Value: alert.MedicationID})
+	}
+	update := bson.D{{Key: "$setOnInsert", Value: alert}}
+
+	res, err := aColl.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return false, err
+	}
+
+	return res.UpsertedCount > 0, nil
+}
+
+func (m *Mongo) GetAlert(ctx context.Context, id primitive.ObjectID) (alert model.Alert, found bool, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	aColl := db.Collection(constant.AlertCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	if err := aColl.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(&alert); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return model.Alert{}, false, nil
+		}
+		return model.Alert{}, false, err
+	}
+
+	return alert, true, nil
+}
+
+func (m *Mongo) GetAlerts(ctx context.Context, filter *model.AlertFilter) (alerts []model.Alert, total int64, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	aColl := db.Collection(constant.AlertCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	query := bson.D{{Key: "practitioner_id", Value: filter.PractitionerID}}
+	if filter.Status != "" {
+		query = append(query, bson.E{Key: "status", Value: filter.Status})
+	}
+
+	total, err = aColl.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	matchStage := bson.D{{Key: "$match", Value: query}}
+	sortStage := bson.D{{Key: "$sort", Value: bson.D{{Key: "created_at", Value: -1}}}}
+	skipStage := bson.D{{Key: "$skip", Value: (filter.Page - 1) * filter.Limit}}
+	limitStage := bson.D{{Key: "$limit", Value: filter.Limit}}
+	patientLookupStage, patientUnwindStage := getPatientLookupAndUnwindStage()
+
+	pipeline := mongo.Pipeline{matchStage, sortStage, skipStage, limitStage, patientLookupStage, patientUnwindStage}
+	cur, err := aColl.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	alerts = []model.Alert{}
+	if err := cur.All(ctx, &alerts); err != nil {
+		return nil, 0, err
+	}
+
+	return alerts, total, nil
+}
+
+// SetAlertStatus moves an alert that is in one of the from statuses to status
+func (m *Mongo) SetAlertStatus(ctx context.Context, id primitive.ObjectID, from []string, status string, now time.Time) (found bool, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	aColl := db.Collection(constant.AlertCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	set := bson.D{{Key: "status", Value: status}}
+	switch status {
+	case constant.AlertAcknowledged:
+		set = append(set, bson.E{Key: "acknowledged_at", Value: now})
+	case constant.AlertResolved:
+		set = append(set, bson.E{Key: "resolved_at", Value: now})
+	}
+
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "status", Value: bson.D{{Key: "$in", Value: from}}},
+	}
+
+	res, err := aColl.UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: set}})
+	if err != nil {
+		return false, err
+	}
+
+	return res.MatchedCount > 0, nil
+}
@@ -0,0 +1,356 @@
+package mongo
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"medbuddy-backend/internal/constant"
+	"medbuddy-backend/internal/model"
+	"time"
+)
+
+func (m *Mongo) CreateCaregiver(ctx context.Context, data *model.Caregiver) error {
+	db := m.mongoclient.Database(constant.AppName)
+	cColl := db.Collection(constant.CaregiversCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	if _, err := cColl.InsertOne(ctx, data); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *Mongo) GetCaregiverByID(ctx context.Context, id primitive.ObjectID) (caregiver model.CaregiverResponse, found bool, err error) {
+	return m.getCaregiver(ctx, bson.D{{Key: "_id", Value: id}})
+}
+
+func (m *Mongo) GetCaregiverByEmail(ctx context.Context, email string) (caregiver model.CaregiverResponse, found bool, err error) {
+	return m.getCaregiver(ctx, bson.D{{Key: "email", Value: email}})
+}
+
+func (m *Mongo) getCaregiver(ctx context.Context, filter bson.D) (caregiver model.CaregiverResponse, found bool, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	cColl := db.Collection(constant.CaregiversCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	matchStage := bson.D{{Key: "$match", Value: filter}}
+	userLookupStage, userUnwindStage := getUserLookupAndUnwindStage()
+
+	pipeline := mongo.Pipeline{matchStage, userLookupStage, userUnwindStage}
+	cur, err := cColl.Aggregate(ctx, pipeline)
+	if err != nil {
+		return model.CaregiverResponse{}, false, err
+	}
+
+	var caregivers []model.CaregiverResponse
+	if err := cur.All(ctx, &caregivers); err != nil {
+		return model.CaregiverResponse{}, false, err
+	}
+
+	if len(caregivers) == 0 {
+		return model.CaregiverResponse{}, false, nil
+	}
+
+	return caregivers[0], true, nil
+}
+
+// GetCaregiverLink returns the pending or active link between a patient and the caregiver invited at email
+func (m *Mongo) GetCaregiverLink(ctx context.Context, patientID primitive.ObjectID, email string) (link model.CaregiverLink, found bool, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	lColl := db.Collection(constant.CaregiverLinkCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "patient_id", Value: patientID},
+		{Key: "email", Value: email},
+		{Key: "status", Value: bson.D{{Key: "$in", Value: bson.A{constant.InvitationPending, constant.InvitationActive}}}},
+	}
+
+	if err := lColl.FindOne(ctx, filter).Decode(&link); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return model.CaregiverLink{}, false, nil
+		}
+		return model.CaregiverLink{}, false, err
+	}
+
+	return link, true, nil
+}
+
+// SaveCaregiverLink inserts a link, or replaces it if it already exists
+func (m *Mongo) SaveCaregiverLink(ctx context.Context, link *model.CaregiverLink) error {
+	db := m.mongoclient.Database(constant.AppName)
+	lColl := db.Collection(constant.CaregiverLinkCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	_, err := lColl.ReplaceOne(ctx, bson.D{{Key: "_id", Value: link.ID}}, link, options.Replace().SetUpsert(true))
+	return err
+}
+
+// AcceptCaregiverInvitation activates the pending invitation with tokenHash if it was sent to email.
+// The token is removed so that the invitation cannot be accepted twice.
+func (m *Mongo) AcceptCaregiverInvitation(ctx context.Context, tokenHash string, caregiverID primitive.ObjectID, email string, now time.Time) (link model.CaregiverLink, found bool, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	lColl := db.Collection(constant.CaregiverLinkCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "token_hash", Value: tokenHash},
+		{Key: "email", Value: email},
+		{Key: "status", Value: constant.InvitationPending},
+		{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: now}}},
+	}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "status", Value: constant.InvitationActive},
+			{Key: "caregiver_id", Value: caregiverID},
+			{Key: "accepted_at", Value: now},
+		}},
+		{Key: "$unset", Value: bson.D{{Key: "token_hash", Value: ""}}},
+	}
+
+	err = lColl.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&link)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return model.CaregiverLink{}, false, nil
+		}
+		return model.CaregiverLink{}, false, err
+	}
+
+	return link, true, nil
+}
+
+// GetPatientCaregiverLinks returns the pending and active caregivers of a patient
+func (m *Mongo) GetPatientCaregiverLinks(ctx context.Context, patientID primitive.ObjectID) (links []model.CaregiverLinkResponse, err error) {
+	filter := bson.D{
+		{Key: "patient_id", Value: patientID},
+		{Key: "status", Value: bson.D{{Key: "$in", Value: bson.A{constant.InvitationPending, constant.InvitationActive}}}},
+	}
+
+	return m.getCaregiverLinks(ctx, filter)
+}
+
+// GetCaregiverPatientLinks returns the links to the patients a caregiver looks after
+func (m *Mongo) GetCaregiverPatientLinks(ctx context.Context, caregiverID primitive.ObjectID) (links []model.CaregiverLinkResponse, err error) {
+	filter := bson.D{
+		{Key: "caregiver_id", Value: caregiverID},
+		{Key: "status", Value: constant.InvitationActive},
+	}
+
+	return m.getCaregiverLinks(ctx, filter)
+}
+
+func (m *Mongo) getCaregiverLinks(ctx context.Context, filter bson.D) (links []model.CaregiverLinkResponse, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	lColl := db.Collection(constant.CaregiverLinkCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	matchStage := bson.D{{Key: "$match", Value: filter}}
+	patientLookupStage, patientUnwindStage := getPatientLookupAndUnwindStage()
+	caregiverLookupStage, caregiverUnwindStage := getCaregiverLookupAndUnwindStage()
+	sortStage := bson.D{{Key: "$sort", Value: bson.D{{Key: "created_at", Value: -1}}}}
+
+	pipeline := mongo.Pipeline{matchStage, patientLookupStage, patientUnwindStage, caregiverLookupStage, caregiverUnwindStage, sortStage}
+	cur, err := lColl.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	links = []model.CaregiverLinkResponse{}
+	if err := cur.All(ctx, &links); err != nil {
+		return nil, err
+	}
+
+	return links, nil
+}
+
+// UpdateCaregiverLink sets whether the caregiver of a link gets copies of the patient's reminders
+func (m *Mongo) UpdateCaregiverLink(ctx context.Context, id, patientID primitive.ObjectID, receiveReminders bool) (found bool, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	lColl := db.Collection(constant.CaregiverLinkCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "patient_id", Value: patientID},
+		{Key: "status", Value: bson.D{{Key: "$ne", Value: constant.InvitationRevoked}}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "receive_reminders", Value: receiveReminders}}}}
+
+	res, err := lColl.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return res.MatchedCount > 0, nil
+}
+
+// RevokeCaregiverLink ends the access of a caregiver, or cancels the invitation if it is still pending
+func (m *Mongo) RevokeCaregiverLink(ctx context.Context, id, patientID primitive.ObjectID, now time.Time) (found bool, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	lColl := db.Collection(constant.CaregiverLinkCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "patient_id", Value: patientID},
+		{Key: "status", Value: bson.D{{Key: "$ne", Value: constant.InvitationRevoked}}},
+	}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{{Key: "status", Value: constant.InvitationRevoked}, {Key: "revoked_at", Value: now}}},
+		{Key: "$unset", Value: bson.D{{Key: "token_hash", Value: ""}}},
+	}
+
+	res, err := lColl.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return res.MatchedCount > 0, nil
+}
+
+// GetPatientCaregiverIDs returns the ids of the caregivers with active access to a patient
+func (m *Mongo) GetPatientCaregiverIDs(ctx context.Context, patientID primitive.ObjectID) (ids []primitive.ObjectID, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	lColl := db.Collection(constant.CaregiverLinkCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{{Key: "patient_id", Value: patientID}, {Key: "status", Value: constant.InvitationActive}}
+	values, err := lColl.Distinct(ctx, "caregiver_id", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	ids = make([]primitive.ObjectID, 0, len(values))
+	for _, v := range values {
+		if id, ok := v.(primitive.ObjectID); ok {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
+// GetCaregiversToRemind returns the caregivers of a patient who asked for copies of the patient's reminders
+func (m *Mongo) GetCaregiversToRemind(ctx context.Context, patientID primitive.ObjectID) (caregivers []model.CaregiverResponse, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	lColl := db.Collection(constant.CaregiverLinkCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	matchStage := bson.D{{Key: "$match", Value: bson.D{
+		{Key: "patient_id", Value: patientID},
+		{Key: "status", Value: constant.InvitationActive},
+		{Key: "receive_reminders", Value: true},
+	}}}
+	caregiverLookupStage, caregiverUnwindStage := getCaregiverLookupAndUnwindStage()
+	replaceStage := bson.D{{Key: "$replaceWith", Value: "$caregiver"}}
+	userLookupStage, userUnwindStage := getUserLookupAndUnwindStage()
+
+	pipeline := mongo.Pipeline{matchStage, caregiverLookupStage, caregiverUnwindStage, replaceStage, userLookupStage, userUnwindStage}
+	cur, err := lColl.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	caregivers = []model.CaregiverResponse{}
+	if err := cur.All(ctx, &caregivers); err != nil {
+		return nil, err
+	}
+
+	return caregivers, nil
+}
+
+func getCaregiverLookupAndUnwindStage() (caregiverLookup bson.D, caregiverUnwind bson.D) {
+	caregiverLookup = bson.D{{
+		Key: "$lookup",
+		Value: bson.D{{
+			Key:   "from",
+			Value: constant.CaregiversCollection,
+		}, {
+			Key:   "localField",
+			Value: "caregiver_id",
+		}, {
+			Key:   "foreignField",
+			Value: "_id",
+		}, {
+			Key:   "as",
+			Value: "caregiver",
+		}},
+	}}
+
+	caregiverUnwind = bson.D{{
+		Key: "$unwind",
+		Value: bson.D{{
+			Key:   "path",
+			Value: "$caregiver",
+		}, {
+			Key:   "preserveNullAndEmptyArrays",
+			Value: true,
+		}},
+	}}
+
+	return
+}
+
+// getCaregiverIDsStages sets the caregiver_ids field of the documents to the ids of the caregivers
+// with active access to the patient referenced by patientField
+func getCaregiverIDsStages(patientField string) (caregiverLookup bson.D, caregiverIDs bson.D) {
+	caregiverLookup = bson.D{{
+		Key: "$lookup",
+		Value: bson.D{{
+			Key:   "from",
+			Value: constant.CaregiverLinkCollection,
+		}, {
+			Key:   "let",
+			Value: bson.D{{Key: "patient", Value: "$" + patientField}},
+		}, {
+			Key: "pipeline",
+			Value: bson.A{
+				bson.D{{Key: "$match", Value: bson.D{{Key: "$expr", Value: bson.D{{Key: "$and", Value: bson.A{
+					bson.D{{Key: "$eq", Value: bson.A{"$patient_id", "$$patient"}}},
+					bson.D{{Key: "$eq", Value: bson.A{"$status", constant.InvitationActive}}},
+				}}}}}}},
+				bson.D{{Key: "$project", Value: bson.D{{Key: "caregiver_id", Value: 1}}}},
+			},
+		}, {
+			Key:   "as",
+			Value: "caregiver_ids",
+		}},
+	}}
+
+	caregiverIDs = bson.D{{Key: "$addFields", Value: bson.D{{Key: "caregiver_ids", Value: "$caregiver_ids.caregiver_id"}}}}
+
+	return
+}
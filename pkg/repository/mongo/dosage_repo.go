@@ -5,6 +5,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"medbuddy-backend/internal/constant"
 	"medbuddy-backend/internal/model"
 	"time"
@@ -23,10 +24,11 @@ func (m *Mongo) SaveDosages(ctx context.Context, data []model.Dosage) error {
 		records = append(records, data[i])
 	}
 
-	_, err := dColl.InsertMany(ctx, records)
+	res, err := dColl.InsertMany(ctx, records)
 	if err != nil {
 		return err
 	}
+	recordInsert(ctx, dColl, res.InsertedIDs...)
 
 	return nil
 }
@@ -78,7 +80,8 @@ func (m *Mongo) GetPatientDosages(ctx context.Context, request *model.DosageFilt
 	return dosages, nil
 }
 
-func (m *Mongo) SetStatus(ctx context.Context, dosageId, patientId primitive.ObjectID, status string) (found bool, err error) {
+// SetStatus changes the status of a dosage if it still has the status from
+func (m *Mongo) SetStatus(ctx context.Context, dosageId, patientId primitive.ObjectID, from, status string, recordedBy *model.RecordedBy) (found bool, err error) {
 	db := m.mongoclient.Database(constant.AppName)
 	dColl := db.Collection(constant.DosageCollection)
 
@@ -86,14 +89,14 @@ func (m *Mongo) SetStatus(ctx context.Context, dosageId, patientId primitive.Obj
 	ctx, cancel = context.WithTimeout(ctx, m.timeout)
 	defer cancel()
 
-	updatesTemp := bson.D{{"is_active", false}, {"status", status}}
+	updatesTemp := bson.D{{Key: "is_active", Value: false}, {Key: "status", Value: status}, {Key: "recorded_by", Value: recordedBy}}
 	if status == constant.DosageSkipped {
 		updatesTemp = append(updatesTemp, bson.E{"time_skipped", time.Now()})
 	} else if status == constant.DosageTaken {
 		updatesTemp = append(updatesTemp, bson.E{"time_taken", time.Now()})
 	}
 
-	filter := bson.D{{Key: "patient_id", Value: patientId}, {Key: "_id", Value: dosageId}, {"is_active", true}}
+	filter := bson.D{{Key: "patient_id", Value: patientId}, {Key: "_id", Value: dosageId}, {Key: "status", Value: from}}
 	update := bson.D{{Key: "$set", Value: updatesTemp}}
 
 	res, err := dColl.UpdateOne(ctx, filter, update)
@@ -108,6 +111,158 @@ func (m *Mongo) SetStatus(ctx context.Context, dosageId, patientId primitive.Obj
 	return true, nil
 }
 
+// GetOverdueDosages returns the oldest dosages, up to limit, that are still waiting to be taken
+// although their reminder time is before before
+func (m *Mongo) GetOverdueDosages(ctx context.Context, before time.Time, limit int64) (dosages []model.Dosage, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	dColl := db.Collection(constant.DosageCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "status", Value: constant.DosageNotTaken},
+		{Key: "is_active", Value: true},
+		{Key: "reminder_time", Value: bson.D{{Key: "$lt", Value: before}}},
+		// snoozed dosages are overdue from the time they were snoozed until
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "snoozed_until", Value: bson.D{{Key: "$exists", Value: false}}}},
+			bson.D{{Key: "snoozed_until", Value: bson.D{{Key: "$lt", Value: before}}}},
+		}},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "reminder_time", Value: 1}}).SetLimit(limit)
+
+	cur, err := dColl.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	dosages = []model.Dosage{}
+	if err := cur.All(ctx, &dosages); err != nil {
+		return nil, err
+	}
+
+	return dosages, nil
+}
+
+// MarkDosageMissed sets the status of a dosage to missed if it has not been taken or skipped in
+// the meantime
+func (m *Mongo) MarkDosageMissed(ctx context.Context, id primitive.ObjectID, now time.Time) (found bool, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	dColl := db.Collection(constant.DosageCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "status", Value: constant.DosageNotTaken},
+		{Key: "is_active", Value: true},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: constant.DosageMissed},
+		{Key: "is_active", Value: false},
+		{Key: "time_missed", Value: now},
+	}}}
+
+	res, err := dColl.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return res.ModifiedCount > 0, nil
+}
+
+// SnoozeDosage records a snooze of a dosage that has not been taken or skipped in the meantime
+func (m *Mongo) SnoozeDosage(ctx context.Context, id primitive.ObjectID, snooze *model.Snooze) (found bool, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	dColl := db.Collection(constant.DosageCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "status", Value: constant.DosageNotTaken},
+		{Key: "is_active", Value: true},
+	}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{{Key: "snoozed_until", Value: snooze.Until}}},
+		{Key: "$push", Value: bson.D{{Key: "snoozes", Value: snooze}}},
+	}
+
+	if err := recordOverwrite(ctx, dColl, filter); err != nil {
+		return false, err
+	}
+
+	res, err := dColl.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return res.ModifiedCount > 0, nil
+}
+
+// GetNextDosage returns the first active scheduled dosage of a medication due after 'after'
+func (m *Mongo) GetNextDosage(ctx context.Context, medicationId primitive.ObjectID, after time.Time) (dosage model.Dosage, found bool, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	dColl := db.Collection(constant.DosageCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "medication_id", Value: medicationId},
+		{Key: "is_active", Value: true},
+		{Key: "as_needed", Value: bson.D{{Key: "$ne", Value: true}}},
+		{Key: "reminder_time", Value: bson.D{{Key: "$gt", Value: after}}},
+	}
+	opts := options.FindOne().SetSort(bson.D{{Key: "reminder_time", Value: 1}})
+
+	err = dColl.FindOne(ctx, filter, opts).Decode(&dosage)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return model.Dosage{}, false, nil
+		}
+		return model.Dosage{}, false, err
+	}
+
+	return dosage, true, nil
+}
+
+// GetActiveDosages returns the scheduled dosages of a medication still to be taken, in order
+func (m *Mongo) GetActiveDosages(ctx context.Context, medicationId primitive.ObjectID) (dosages []model.Dosage, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	dColl := db.Collection(constant.DosageCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "medication_id", Value: medicationId},
+		{Key: "is_active", Value: true},
+		{Key: "status", Value: constant.DosageNotTaken},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "reminder_time", Value: 1}})
+
+	cur, err := dColl.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	dosages = []model.Dosage{}
+	if err := cur.All(ctx, &dosages); err != nil {
+		return nil, err
+	}
+
+	return dosages, nil
+}
+
 func (m *Mongo) GetDosage(ctx context.Context, id primitive.ObjectID) (dosage model.DosageResponse, found bool, err error) {
 	db := m.mongoclient.Database(constant.AppName)
 	dColl := db.Collection(constant.DosageCollection)
@@ -120,9 +275,12 @@ func (m *Mongo) GetDosage(ctx context.Context, id primitive.ObjectID) (dosage mo
 	medicLookupStage, medicUnwindStage := getMedicationLookupAndUnwindStage()
 	medLookupStage, medUnwindStage := getDosageMedicineLookupAndUnwindStage()
 	patientLookupStage, patientUnwindStage := getDosagePatientLookupAndUnwindStage()
+	caregiverLookupStage, caregiverIDsStage := getCaregiverIDsStages("patient_id")
+	grantLookupStage, practitionerIDsStage := getGrantedPractitionerStages("patient_id", "medication_id", "medication.practitioner_ids")
 
 	pipeline := mongo.Pipeline{matchStage, medicLookupStage, medicUnwindStage, medLookupStage,
-		medUnwindStage, patientLookupStage, patientUnwindStage}
+		medUnwindStage, patientLookupStage, patientUnwindStage, caregiverLookupStage, caregiverIDsStage,
+		grantLookupStage, practitionerIDsStage}
 	cur, err := dColl.Aggregate(ctx, pipeline)
 	if err != nil {
 		return model.DosageResponse{}, false, err
@@ -148,7 +306,12 @@ func (m *Mongo) DeleteDosages(ctx context.Context, medicationId primitive.Object
 	ctx, cancel = context.WithTimeout(ctx, m.timeout)
 	defer cancel()
 
-	res, err := dColl.DeleteMany(ctx, bson.D{{Key: "medication_id", Value: medicationId}})
+	filter := bson.D{{Key: "medication_id", Value: medicationId}}
+	if err := recordOverwrite(ctx, dColl, filter); err != nil {
+		return -1, err
+	}
+
+	res, err := dColl.DeleteMany(ctx, filter)
 	if err != nil {
 		return -1, err
 	}
@@ -156,6 +319,105 @@ func (m *Mongo) DeleteDosages(ctx context.Context, medicationId primitive.Object
 	return res.DeletedCount, nil
 }
 
+func (m *Mongo) DeleteActiveDosages(ctx context.Context, medicationId primitive.ObjectID) (int64, error) {
+	db := m.mongoclient.Database(constant.AppName)
+	dColl := db.Collection(constant.DosageCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "medication_id", Value: medicationId},
+		{Key: "is_active", Value: true},
+	}
+
+	if err := recordOverwrite(ctx, dColl, filter); err != nil {
+		return -1, err
+	}
+
+	res, err := dColl.DeleteMany(ctx, filter)
+	if err != nil {
+		return -1, err
+	}
+
+	return res.DeletedCount, nil
+}
+
+// DeactivateDosages pauses the dosages of a medication due after 'after' that are still waiting to
+// be taken, they keep their status until they are deleted on resume
+func (m *Mongo) DeactivateDosages(ctx context.Context, medicationId primitive.ObjectID, after time.Time) (int64, error) {
+	db := m.mongoclient.Database(constant.AppName)
+	dColl := db.Collection(constant.DosageCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "medication_id", Value: medicationId},
+		{Key: "status", Value: constant.DosageNotTaken},
+		{Key: "is_active", Value: true},
+		{Key: "reminder_time", Value: bson.D{{Key: "$gt", Value: after}}},
+	}
+
+	if err := recordOverwrite(ctx, dColl, filter); err != nil {
+		return -1, err
+	}
+
+	res, err := dColl.UpdateMany(ctx, filter, bson.D{{Key: "$set", Value: bson.D{{Key: "is_active", Value: false}}}})
+	if err != nil {
+		return -1, err
+	}
+
+	return res.ModifiedCount, nil
+}
+
+// DeletePausedDosages deletes the dosages of a medication deactivated by DeactivateDosages
+func (m *Mongo) DeletePausedDosages(ctx context.Context, medicationId primitive.ObjectID) (int64, error) {
+	db := m.mongoclient.Database(constant.AppName)
+	dColl := db.Collection(constant.DosageCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "medication_id", Value: medicationId},
+		{Key: "status", Value: constant.DosageNotTaken},
+		{Key: "is_active", Value: false},
+	}
+
+	if err := recordOverwrite(ctx, dColl, filter); err != nil {
+		return -1, err
+	}
+
+	res, err := dColl.DeleteMany(ctx, filter)
+	if err != nil {
+		return -1, err
+	}
+
+	return res.DeletedCount, nil
+}
+
+// CountTakenDosages counts the dosages of a medication taken from 'from' up to, but not including, 'to'
+func (m *Mongo) CountTakenDosages(ctx context.Context, medicationId primitive.ObjectID, from, to time.Time) (int64, error) {
+	db := m.mongoclient.Database(constant.AppName)
+	dColl := db.Collection(constant.DosageCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "medication_id", Value: medicationId},
+		{Key: "status", Value: constant.DosageTaken},
+		{Key: "time_taken", Value: bson.D{{Key: "$gte", Value: from}, {Key: "$lt", Value: to}}},
+	}
+
+	return dColl.CountDocuments(ctx, filter)
+}
+
 func getMedicationLookupAndUnwindStage() (medicLookup bson.D, medicUnwind bson.D) {
 	medicLookup = bson.D{{
 		Key: "$lookup",
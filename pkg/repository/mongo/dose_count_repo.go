@@ -0,0 +1,85 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"medbuddy-backend/internal/constant"
+)
+
+// doseCountKey identifies the count of the dosages of a medication taken on day, the start of a
+// day in the medication's time zone
+func doseCountKey(medicationId primitive.ObjectID, day time.Time) string {
+	return medicationId.Hex() + "/" + day.Format("2006-01-02")
+}
+
+// SeedDoseCount starts the count of the dosages of a medication taken on day at count, unless it
+// has been started already
+func (m *Mongo) SeedDoseCount(ctx context.Context, medicationId primitive.ObjectID, day time.Time, count int64, expiresAt time.Time) error {
+	db := m.mongoclient.Database(constant.AppName)
+	cColl := db.Collection(constant.DoseCountCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	doc := bson.D{
+		{Key: "_id", Value: doseCountKey(medicationId, day)},
+		{Key: "count", Value: count},
+		{Key: "expires_at", Value: expiresAt},
+	}
+	if _, err := cColl.InsertOne(ctx, doc); err != nil && !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+
+	return nil
+}
+
+// TakeDoseSlot counts a dosage of a medication taken on day, unless max have been counted already.
+// Concurrent calls cannot count more than max between them.
+func (m *Mongo) TakeDoseSlot(ctx context.Context, medicationId primitive.ObjectID, day time.Time, max int) (taken bool, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	cColl := db.Collection(constant.DoseCountCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "_id", Value: doseCountKey(medicationId, day)},
+		{Key: "count", Value: bson.D{{Key: "$lt", Value: max}}},
+	}
+	update := bson.D{{Key: "$inc", Value: bson.D{{Key: "count", Value: 1}}}}
+
+	res, err := cColl.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return res.MatchedCount > 0, nil
+}
+
+// ReleaseDoseSlot gives back a dosage counted by TakeDoseSlot that was not recorded after all
+func (m *Mongo) ReleaseDoseSlot(ctx context.Context, medicationId primitive.ObjectID, day time.Time) error {
+	db := m.mongoclient.Database(constant.AppName)
+	cColl := db.Collection(constant.DoseCountCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "_id", Value: doseCountKey(medicationId, day)},
+		{Key: "count", Value: bson.D{{Key: "$gt", Value: 0}}},
+	}
+	update := bson.D{{Key: "$inc", Value: bson.D{{Key: "count", Value: -1}}}}
+
+	if _, err := cColl.UpdateOne(ctx, filter, update); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package mongo
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"medbuddy-backend/internal/constant"
+)
+
+// createIndexes makes sure the indexes used by the background jobs and token checks exist. Creating an
+// index that already exists is a no-op.
+func createIndexes(ctx context.Context, client *mongo.Client) error {
+	db := client.Database(constant.AppName)
+
+	indexes := map[string][]mongo.IndexModel{
+		constant.TaskCollection: {
+			{Keys: bson.D{{Key: "status", Value: 1}, {Key: "time", Value: 1}}},
+			{Keys: bson.D{{Key: "status", Value: 1}, {Key: "lease_expires_at", Value: 1}}},
+			{Keys: bson.D{{Key: "medication_id", Value: 1}}},
+			{Keys: bson.D{{Key: "dosage_id", Value: 1}}},
+		},
+		constant.LockCollection: {
+			// expired locks are only cleaned up by the TTL monitor, AcquireLock does not rely on it
+			{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		},
+		constant.RefreshTokenCollection: {
+			{Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "family_id", Value: 1}}},
+			{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		},
+		constant.RevokedTokenCollection: {
+			{Keys: bson.D{{Key: "jti", Value: 1}}, Options: options.Index().SetSparse(true)},
+			{Keys: bson.D{{Key: "family_id", Value: 1}}, Options: options.Index().SetSparse(true)},
+			{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		},
+		constant.UserTokenCollection: {
+			{Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "purpose", Value: 1}}},
+			{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		},
+		constant.LoginAttemptCollection: {
+			{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		},
+		constant.DoseCountCollection: {
+			{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		},
+		constant.CaregiverLinkCollection: {
+			{Keys: bson.D{{Key: "patient_id", Value: 1}, {Key: "status", Value: 1}}},
+			{Keys: bson.D{{Key: "caregiver_id", Value: 1}, {Key: "status", Value: 1}}},
+			{Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetSparse(true)},
+		},
+		constant.AccessGrantCollection: {
+			{Keys: bson.D{{Key: "patient_id", Value: 1}, {Key: "status", Value: 1}}},
+			{Keys: bson.D{{Key: "practitioner_id", Value: 1}, {Key: "status", Value: 1}}},
+		},
+		constant.PrescriptionCollection: {
+			{Keys: bson.D{{Key: "patient_id", Value: 1}, {Key: "status", Value: 1}}},
+			{Keys: bson.D{{Key: "practitioner_id", Value: 1}, {Key: "status", Value: 1}}},
+		},
+		constant.AlertRuleCollection: {
+			{Keys: bson.D{{Key: "practitioner_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		},
+		constant.AlertCollection: {
+			{Keys: bson.D{{Key: "practitioner_id", Value: 1}, {Key: "status", Value: 1}, {Key: "created_at", Value: -1}}},
+			{Keys: bson.D{{Key: "rule_id", Value: 1}, {Key: "patient_id", Value: 1}}},
+		},
+		constant.DosageCollection: {
+			{Keys: bson.D{{Key: "medication_id", Value: 1}}},
+			{Keys: bson.D{{Key: "patient_id", Value: 1}, {Key: "reminder_time", Value: 1}}},
+			{Keys: bson.D{{Key: "status", Value: 1}, {Key: "reminder_time", Value: 1}}},
+		},
+		constant.MedicationCollection: {
+			{Keys: bson.D{{Key: "resume_at", Value: 1}}, Options: options.Index().SetSparse(true)},
+		},
+	}
+
+	for collection, models := range indexes {
+		if _, err := db.Collection(collection).Indexes().CreateMany(ctx, models); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
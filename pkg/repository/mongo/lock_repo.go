@@ -0,0 +1,82 @@
+package mongo
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	options2 "go.mongodb.org/mongo-driver/mongo/options"
+	"medbuddy-backend/internal/constant"
+	"medbuddy-backend/internal/model"
+	"time"
+)
+
+// AcquireLock takes the named lock for owner, or renews it if owner already holds it, until
+// ttl from now. It returns false when another owner holds a lock that has not expired.
+func (m *Mongo) AcquireLock(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	db := m.mongoclient.Database(constant.AppName)
+	lColl := db.Collection(constant.LockCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	now := time.Now()
+	filter := bson.D{
+		{Key: "_id", Value: name},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "owner", Value: owner}},
+			bson.D{{Key: "expires_at", Value: bson.D{{Key: "$lt", Value: now}}}},
+		}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "owner", Value: owner},
+		{Key: "expires_at", Value: now.Add(ttl)},
+		{Key: "renewed_at", Value: now},
+	}}}
+
+	// If the lock is held by someone else the filter does not match and the upsert
+	// fails on the unique _id
+	_, err := lColl.UpdateOne(ctx, filter, update, options2.Update().SetUpsert(true))
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (m *Mongo) ReleaseLock(ctx context.Context, name, owner string) error {
+	db := m.mongoclient.Database(constant.AppName)
+	lColl := db.Collection(constant.LockCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	_, err := lColl.DeleteOne(ctx, bson.D{{Key: "_id", Value: name}, {Key: "owner", Value: owner}})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *Mongo) GetLock(ctx context.Context, name string) (lock model.Lock, found bool, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	lColl := db.Collection(constant.LockCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	if err := lColl.FindOne(ctx, bson.D{{Key: "_id", Value: name}}).Decode(&lock); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return model.Lock{}, false, nil
+		}
+		return model.Lock{}, false, err
+	}
+
+	return lock, true, nil
+}
@@ -0,0 +1,81 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"medbuddy-backend/internal/constant"
+	"medbuddy-backend/internal/model"
+)
+
+func (m *Mongo) GetLoginAttempts(ctx context.Context, keys ...string) (attempts []model.LoginAttempt, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	aColl := db.Collection(constant.LoginAttemptCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	// expired attempts may not have been removed by the TTL monitor yet
+	filter := bson.D{
+		{Key: "_id", Value: bson.D{{Key: "$in", Value: keys}}},
+		{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: time.Now()}}},
+	}
+
+	cur, err := aColl.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cur.All(ctx, &attempts); err != nil {
+		return nil, err
+	}
+
+	return attempts, nil
+}
+
+// RecordLoginFailure counts a failed login for key and returns the updated count. The count
+// starts over once it has expired.
+func (m *Mongo) RecordLoginFailure(ctx context.Context, key string, now, expiresAt time.Time) (attempt model.LoginAttempt, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	aColl := db.Collection(constant.LoginAttemptCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	// an update pipeline so that an expired count is reset in the same write
+	expired := bson.D{{Key: "$lte", Value: bson.A{bson.D{{Key: "$ifNull", Value: bson.A{"$expires_at", now}}}, now}}}
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.D{
+		{Key: "failures", Value: bson.D{{Key: "$add", Value: bson.A{
+			bson.D{{Key: "$cond", Value: bson.A{expired, 0, "$failures"}}}, 1,
+		}}}},
+		{Key: "last_failure_at", Value: now},
+		{Key: "expires_at", Value: expiresAt},
+	}}}}
+
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	if err := aColl.FindOneAndUpdate(ctx, bson.D{{Key: "_id", Value: key}}, update, opts).Decode(&attempt); err != nil {
+		return model.LoginAttempt{}, err
+	}
+
+	return attempt, nil
+}
+
+func (m *Mongo) ClearLoginAttempts(ctx context.Context, key string) error {
+	db := m.mongoclient.Database(constant.AppName)
+	aColl := db.Collection(constant.LoginAttemptCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	if _, err := aColl.DeleteOne(ctx, bson.D{{Key: "_id", Value: key}}); err != nil {
+		return err
+	}
+
+	return nil
+}
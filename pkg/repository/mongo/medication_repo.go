@@ -5,8 +5,10 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"medbuddy-backend/internal/constant"
 	"medbuddy-backend/internal/model"
+	"time"
 )
 
 func (m *Mongo) AddMedication(ctx context.Context, data *model.Medication) error {
@@ -20,11 +22,15 @@ func (m *Mongo) AddMedication(ctx context.Context, data *model.Medication) error
 	if _, err := mColl.InsertOne(ctx, data); err != nil {
 		return err
 	}
+	recordInsert(ctx, mColl, data.ID)
 
 	return nil
 }
 
-func (m *Mongo) UpdateMedication(ctx context.Context, id primitive.ObjectID, data *model.Medication) (found bool, err error) {
+// UpdateMedication writes the fields of an active medication that update sets. Nothing else is
+// written: the dosage counts, the stock and the state of the medication change as dosages are
+// taken and as it is paused, and a medication paused or discontinued in the meantime is not found.
+func (m *Mongo) UpdateMedication(ctx context.Context, id primitive.ObjectID, update *model.MedicationUpdate) (found bool, err error) {
 	db := m.mongoclient.Database(constant.AppName)
 	mColl := db.Collection(constant.MedicationCollection)
 
@@ -32,12 +38,43 @@ func (m *Mongo) UpdateMedication(ctx context.Context, id primitive.ObjectID, dat
 	ctx, cancel = context.WithTimeout(ctx, m.timeout)
 	defer cancel()
 
-	update := bson.D{{"$set", *data}}
-	res, err := mColl.UpdateByID(ctx, id, update)
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "is_active", Value: true},
+		{Key: "paused_at", Value: bson.D{{Key: "$exists", Value: false}}},
+	}
+	set := bson.D{{Key: "updated_at", Value: update.UpdatedAt}}
+	if update.Name != nil {
+		set = append(set, bson.E{Key: "name", Value: *update.Name})
+	}
+	if update.Treatment != nil {
+		set = append(set, bson.E{Key: "treatment", Value: *update.Treatment})
+	}
+	if update.Comment != nil {
+		set = append(set, bson.E{Key: "comment", Value: *update.Comment})
+	}
+	if s := update.Schedule; s != nil {
+		set = append(set,
+			bson.E{Key: "start_date", Value: s.StartDate},
+			bson.E{Key: "dosage_quantity", Value: s.DosageQuantity},
+			bson.E{Key: "daily_dosage", Value: s.DailyDosage},
+			bson.E{Key: "dosage_times", Value: s.DosageTimes},
+			bson.E{Key: "total_number_of_dosage", Value: s.TotalNumberOfDosage},
+			bson.E{Key: "schedule", Value: s.Schedule},
+			bson.E{Key: "phases", Value: s.Phases},
+			bson.E{Key: "time_zone", Value: s.TimeZone},
+		)
+	}
+	if update.Inventory != nil {
+		set = append(set, bson.E{Key: "inventory", Value: update.Inventory})
+	}
+
+	if err := recordOverwrite(ctx, mColl, filter); err != nil {
+		return false, err
+	}
+
+	res, err := mColl.UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: set}})
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return false, nil
-		}
 		return false, err
 	}
 
@@ -57,6 +94,10 @@ func (m *Mongo) DeleteMedication(ctx context.Context, id primitive.ObjectID) (fo
 	defer cancel()
 
 	filter := bson.D{{"_id", id}}
+	if err := recordOverwrite(ctx, mColl, filter); err != nil {
+		return false, err
+	}
+
 	res, err := mColl.DeleteOne(ctx, filter)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
@@ -83,8 +124,11 @@ func (m *Mongo) GetMedication(ctx context.Context, id primitive.ObjectID) (medic
 	medics := []model.MedicationResponse{}
 	matchStage := bson.D{{Key: "$match", Value: bson.D{{"_id", id}}}}
 	medLookupStage, medUnwindStage := getMedicineLookupAndUnwindStage()
+	caregiverLookupStage, caregiverIDsStage := getCaregiverIDsStages("patient_id")
+	grantLookupStage, practitionerIDsStage := getGrantedPractitionerStages("patient_id", "_id", "practitioner_ids")
 
-	pipeline := mongo.Pipeline{matchStage, medLookupStage, medUnwindStage}
+	pipeline := mongo.Pipeline{matchStage, medLookupStage, medUnwindStage, caregiverLookupStage, caregiverIDsStage,
+		grantLookupStage, practitionerIDsStage}
 	cur, err := mColl.Aggregate(ctx, pipeline)
 	if err != nil {
 		return model.MedicationResponse{}, false, err
@@ -112,9 +156,10 @@ func (m *Mongo) GetPatientsMedications(ctx context.Context, patientId primitive.
 	medics = []model.MedicationResponse{}
 	matchStage := bson.D{{Key: "$match", Value: bson.D{{"patient_id", patientId}}}}
 	medLookupStage, medUnwindStage := getMedicineLookupAndUnwindStage()
+	grantLookupStage, practitionerIDsStage := getGrantedPractitionerStages("patient_id", "_id", "practitioner_ids")
 	sortStage := bson.D{{Key: "$sort", Value: bson.D{{"created_at", -1}}}}
 
-	pipeline := mongo.Pipeline{matchStage, medLookupStage, medUnwindStage, sortStage}
+	pipeline := mongo.Pipeline{matchStage, medLookupStage, medUnwindStage, grantLookupStage, practitionerIDsStage, sortStage}
 	cur, err := mColl.Aggregate(ctx, pipeline)
 	if err != nil {
 		return nil, err
@@ -127,7 +172,69 @@ func (m *Mongo) GetPatientsMedications(ctx context.Context, patientId primitive.
 	return medics, nil
 }
 
-func (m *Mongo) AddPractitionerToMed(ctx context.Context, id primitive.ObjectID, practIds []primitive.ObjectID) (found bool, err error) {
+// dosageCounters are the fields of a medication counting its dosages in each status
+var dosageCounters = map[string]string{
+	constant.DosageTaken:   "dosages_taken",
+	constant.DosageSkipped: "dosages_skipped",
+	constant.DosageMissed:  "dosages_missed",
+}
+
+// IncrementDosageCounts adds to the counters of the dosages of a medication, counts is keyed by
+// dosage status and may hold negative values
+func (m *Mongo) IncrementDosageCounts(ctx context.Context, medicId primitive.ObjectID, counts map[string]int) error {
+	db := m.mongoclient.Database(constant.AppName)
+	mColl := db.Collection(constant.MedicationCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	inc := bson.D{}
+	for status, n := range counts {
+		if field, ok := dosageCounters[status]; ok && n != 0 {
+			inc = append(inc, bson.E{Key: field, Value: n})
+		}
+	}
+
+	if len(inc) == 0 {
+		return nil
+	}
+
+	_, err := mColl.UpdateByID(ctx, medicId, bson.D{{Key: "$inc", Value: inc}})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// IncrementDosagesTaken counts a dosage of an active medication as taken, unless total have been
+// taken already. A total of 0 does not limit the dosages.
+func (m *Mongo) IncrementDosagesTaken(ctx context.Context, medicId primitive.ObjectID, total int) (found bool, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	mColl := db.Collection(constant.MedicationCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{{Key: "_id", Value: medicId}, {Key: "is_active", Value: true}}
+	if total > 0 {
+		filter = append(filter, bson.E{Key: "dosages_taken", Value: bson.D{{Key: "$lt", Value: total}}})
+	}
+	update := bson.D{{Key: "$inc", Value: bson.D{{Key: "dosages_taken", Value: 1}}}}
+
+	res, err := mColl.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return res.MatchedCount > 0, nil
+}
+
+// DecrementStock takes units out of the stock of a medication, when it is tracked, and returns
+// what is left. The stock does not go below zero.
+func (m *Mongo) DecrementStock(ctx context.Context, medicId primitive.ObjectID, units float64) (inventory model.Inventory, found bool, err error) {
 	db := m.mongoclient.Database(constant.AppName)
 	mColl := db.Collection(constant.MedicationCollection)
 
@@ -135,23 +242,89 @@ func (m *Mongo) AddPractitionerToMed(ctx context.Context, id primitive.ObjectID,
 	ctx, cancel = context.WithTimeout(ctx, m.timeout)
 	defer cancel()
 
-	update := bson.D{{Key: "$set", Value: bson.D{{Key: "practitioner_ids", Value: practIds}}}}
-	res, err := mColl.UpdateByID(ctx, id, update)
+	filter := bson.D{
+		{Key: "_id", Value: medicId},
+		{Key: "inventory", Value: bson.D{{Key: "$type", Value: "object"}}},
+	}
+	update := mongo.Pipeline{bson.D{{Key: "$set", Value: bson.D{{Key: "inventory.stock", Value: bson.D{{Key: "$max", Value: bson.A{
+		0,
+		bson.D{{Key: "$subtract", Value: bson.A{"$inventory.stock", units}}},
+	}}}}}}}}
+
+	var medication model.Medication
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.D{{Key: "inventory", Value: 1}})
+	err = mColl.FindOneAndUpdate(ctx, filter, update, opts).Decode(&medication)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return false, nil
+			return model.Inventory{}, false, nil
 		}
+		return model.Inventory{}, false, err
+	}
+
+	return *medication.Inventory, true, nil
+}
+
+// MarkRefillNeeded records that the patient was told to refill a medication, it reports false when
+// they already were since the last refill
+func (m *Mongo) MarkRefillNeeded(ctx context.Context, medicId primitive.ObjectID, now time.Time) (bool, error) {
+	db := m.mongoclient.Database(constant.AppName)
+	mColl := db.Collection(constant.MedicationCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "_id", Value: medicId},
+		{Key: "inventory", Value: bson.D{{Key: "$type", Value: "object"}}},
+		{Key: "inventory.refill_notified_at", Value: bson.D{{Key: "$exists", Value: false}}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "inventory.refill_notified_at", Value: now}}}}
+
+	res, err := mColl.UpdateOne(ctx, filter, update)
+	if err != nil {
 		return false, err
 	}
 
-	if res.MatchedCount < 1 {
-		return false, nil
+	return res.ModifiedCount > 0, nil
+}
+
+// RefillStock adds quantity to the stock of a medication whose stock is tracked and returns the
+// new inventory. The patient can be told to refill it again afterwards.
+func (m *Mongo) RefillStock(ctx context.Context, medicId primitive.ObjectID, quantity float64, now time.Time) (inventory model.Inventory, found bool, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	mColl := db.Collection(constant.MedicationCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "_id", Value: medicId},
+		{Key: "inventory", Value: bson.D{{Key: "$type", Value: "object"}}},
+	}
+	update := bson.D{
+		{Key: "$inc", Value: bson.D{{Key: "inventory.stock", Value: quantity}}},
+		{Key: "$set", Value: bson.D{{Key: "inventory.last_refilled_at", Value: now}}},
+		{Key: "$unset", Value: bson.D{{Key: "inventory.refill_notified_at", Value: ""}}},
 	}
 
-	return true, nil
+	var medication model.Medication
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.D{{Key: "inventory", Value: 1}})
+	err = mColl.FindOneAndUpdate(ctx, filter, update, opts).Decode(&medication)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return model.Inventory{}, false, nil
+		}
+		return model.Inventory{}, false, err
+	}
+
+	return *medication.Inventory, true, nil
 }
 
-func (m *Mongo) IncrementDosageTaken(ctx context.Context, medicId primitive.ObjectID) error {
+// PauseMedication deactivates an active medication, resumeAt is when it is resumed automatically, if
+// ever. It reports false when the medication is not active.
+func (m *Mongo) PauseMedication(ctx context.Context, id primitive.ObjectID, now time.Time, resumeAt *time.Time) (found bool, err error) {
 	db := m.mongoclient.Database(constant.AppName)
 	mColl := db.Collection(constant.MedicationCollection)
 
@@ -159,19 +332,134 @@ func (m *Mongo) IncrementDosageTaken(ctx context.Context, medicId primitive.Obje
 	ctx, cancel = context.WithTimeout(ctx, m.timeout)
 	defer cancel()
 
-	update := bson.D{{
-		Key: "$inc", Value: bson.D{{
-			Key:   "dosages_taken",
-			Value: 1,
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "is_active", Value: true},
+	}
+	set := bson.D{
+		{Key: "is_active", Value: false},
+		{Key: "paused_at", Value: now},
+		{Key: "updated_at", Value: now},
+	}
+	if resumeAt != nil {
+		set = append(set, bson.E{Key: "resume_at", Value: *resumeAt})
+	}
+
+	if err := recordOverwrite(ctx, mColl, filter); err != nil {
+		return false, err
+	}
+
+	res, err := mColl.UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: set}})
+	if err != nil {
+		return false, err
+	}
+
+	return res.ModifiedCount > 0, nil
+}
+
+// ResumeMedication activates a paused medication again from startDate, which moves on when the
+// phases of a tapered medication carry on after the pause. It reports false when the medication
+// is not paused.
+func (m *Mongo) ResumeMedication(ctx context.Context, id primitive.ObjectID, startDate, now time.Time) (found bool, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	mColl := db.Collection(constant.MedicationCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "paused_at", Value: bson.D{{Key: "$exists", Value: true}}},
+	}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "is_active", Value: true},
+			{Key: "start_date", Value: startDate},
+			{Key: "updated_at", Value: now},
 		}},
-	}}
+		{Key: "$unset", Value: bson.D{{Key: "paused_at", Value: ""}, {Key: "resume_at", Value: ""}}},
+	}
 
-	_, err := mColl.UpdateByID(ctx, medicId, update)
+	if err := recordOverwrite(ctx, mColl, filter); err != nil {
+		return false, err
+	}
+
+	res, err := mColl.UpdateOne(ctx, filter, update)
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	return nil
+	return res.ModifiedCount > 0, nil
+}
+
+// DiscontinueMedication ends a medication for good, paused or not. It reports false when the
+// medication was already discontinued.
+func (m *Mongo) DiscontinueMedication(ctx context.Context, id primitive.ObjectID, reason string, now time.Time) (found bool, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	mColl := db.Collection(constant.MedicationCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "discontinued_at", Value: bson.D{{Key: "$exists", Value: false}}},
+	}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "is_active", Value: false},
+			{Key: "discontinued_at", Value: now},
+			{Key: "discontinued_reason", Value: reason},
+			{Key: "updated_at", Value: now},
+		}},
+		{Key: "$unset", Value: bson.D{{Key: "paused_at", Value: ""}, {Key: "resume_at", Value: ""}}},
+	}
+
+	if err := recordOverwrite(ctx, mColl, filter); err != nil {
+		return false, err
+	}
+
+	res, err := mColl.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return res.ModifiedCount > 0, nil
+}
+
+// GetMedicationsToResume returns the ids of the paused medications due to be resumed by now
+func (m *Mongo) GetMedicationsToResume(ctx context.Context, now time.Time) (ids []primitive.ObjectID, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	mColl := db.Collection(constant.MedicationCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "paused_at", Value: bson.D{{Key: "$exists", Value: true}}},
+		{Key: "resume_at", Value: bson.D{{Key: "$lte", Value: now}}},
+	}
+	opts := options.Find().SetProjection(bson.D{{Key: "_id", Value: 1}})
+
+	cur, err := mColl.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var medications []model.Medication
+	if err := cur.All(ctx, &medications); err != nil {
+		return nil, err
+	}
+
+	ids = []primitive.ObjectID{}
+	for _, medication := range medications {
+		ids = append(ids, medication.ID)
+	}
+
+	return ids, nil
 }
 
 func getMedicineLookupAndUnwindStage() (medicineLookup bson.D, medicineUnwind bson.D) {
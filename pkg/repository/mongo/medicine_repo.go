@@ -5,6 +5,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"medbuddy-backend/internal/constant"
 	"medbuddy-backend/internal/model"
 )
@@ -20,6 +21,7 @@ func (m *Mongo) AddMedicine(ctx context.Context, data *model.Medicine) error {
 	if _, err := mColl.InsertOne(ctx, data); err != nil {
 		return err
 	}
+	recordInsert(ctx, mColl, data.ID)
 
 	return nil
 }
@@ -118,3 +120,33 @@ func (m *Mongo) DeleteMedicine(ctx context.Context, id primitive.ObjectID) (foun
 
 	return true, nil
 }
+
+func (m *Mongo) ListMedicines(ctx context.Context, page, limit int) (medicines []model.Medicine, total int64, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	mColl := db.Collection(constant.MedicineCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	total, err = mColl.CountDocuments(ctx, bson.D{})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "name", Value: 1}}).
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit))
+
+	cur, err := mColl.Find(ctx, bson.D{}, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err := cur.All(ctx, &medicines); err != nil {
+		return nil, 0, err
+	}
+
+	return medicines, total, nil
+}
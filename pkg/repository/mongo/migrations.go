@@ -0,0 +1,90 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"medbuddy-backend/internal/constant"
+	"medbuddy-backend/internal/model"
+)
+
+// migrate backfills fields added to existing documents. Every step only touches documents that
+// have not been migrated yet, so it is safe to run on every start.
+func migrate(ctx context.Context, client *mongo.Client) error {
+	db := client.Database(constant.AppName)
+
+	// Users who signed up before email verification existed have been receiving reminders at
+	// their address all along, they are not asked to verify it
+	filter := bson.D{{Key: "email_verified", Value: bson.D{{Key: "$exists", Value: false}}}}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "email_verified", Value: true}}}}
+	res, err := db.Collection(constant.UsersCollection).UpdateMany(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if res.ModifiedCount > 0 {
+		logger.Infof("Marked the email of %v existing user(s) as verified", res.ModifiedCount)
+	}
+
+	return migratePractitionerIDs(ctx, db)
+}
+
+// migratePractitionerIDs turns the practitioners stored on medications before access grants existed
+// into active grants, these practitioners had access without being asked
+func migratePractitionerIDs(ctx context.Context, db *mongo.Database) error {
+	mColl := db.Collection(constant.MedicationCollection)
+
+	filter := bson.D{{Key: "practitioner_ids", Value: bson.D{{Key: "$exists", Value: true}}}}
+	cur, err := mColl.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	var medics []model.Medication
+	if err := cur.All(ctx, &medics); err != nil {
+		return err
+	}
+
+	if len(medics) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	grants := []interface{}{}
+	for _, medic := range medics {
+		seen := map[primitive.ObjectID]bool{}
+		for _, practitionerID := range medic.PractitionerIDs {
+			if seen[practitionerID] {
+				continue
+			}
+			seen[practitionerID] = true
+
+			grants = append(grants, model.AccessGrant{
+				ID:             primitive.NewObjectID(),
+				PatientID:      medic.PatientID,
+				PractitionerID: practitionerID,
+				MedicationID:   medic.ID,
+				Status:         constant.InvitationActive,
+				CreatedAt:      now,
+				RespondedAt:    &now,
+			})
+		}
+	}
+
+	if len(grants) > 0 {
+		if _, err := db.Collection(constant.AccessGrantCollection).InsertMany(ctx, grants); err != nil {
+			return err
+		}
+	}
+
+	update := bson.D{{Key: "$unset", Value: bson.D{{Key: "practitioner_ids", Value: ""}}}}
+	if _, err := mColl.UpdateMany(ctx, filter, update); err != nil {
+		return err
+	}
+
+	logger.Infof("Moved the practitioners of %v medication(s) to %v access grant(s)", len(medics), len(grants))
+	return nil
+}
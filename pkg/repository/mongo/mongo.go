@@ -51,6 +51,14 @@ func ConnectToDB() *mongo.Client {
 		log.Fatal("Error pinging mongoDB connection, error: ", err)
 	}
 
+	if err := createIndexes(ctx, mongoClient); err != nil {
+		log.Fatal("Error creating mongoDB indexes, error: ", err)
+	}
+
+	if err := migrate(ctx, mongoClient); err != nil {
+		log.Fatal("Error migrating mongoDB documents, error: ", err)
+	}
+
 	// IF EVERYTHING IS OKAY, THEN CONNECTION IS SETTLED
 	logger.Info("MONGO CONNECTION ESTABLISHED")
 
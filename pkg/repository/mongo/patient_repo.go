@@ -82,6 +82,44 @@ func (m *Mongo) GetPatientByEmail(ctx context.Context, email string) (patient mo
 	return patients[0], true, nil
 }
 
+func (m *Mongo) UpdateNotificationPreferences(ctx context.Context, id primitive.ObjectID, prefs *model.NotificationPreferences) (found bool, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	pColl := db.Collection(constant.PatientsCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{{Key: "_id", Value: id}}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "notification_preferences", Value: prefs}}}}
+
+	res, err := pColl.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return res.MatchedCount > 0, nil
+}
+
+func (m *Mongo) UpdatePatientTimeZone(ctx context.Context, id primitive.ObjectID, timeZone string) (found bool, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	pColl := db.Collection(constant.PatientsCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{{Key: "_id", Value: id}}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "time_zone", Value: timeZone}}}}
+
+	res, err := pColl.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return res.MatchedCount > 0, nil
+}
+
 func getUserLookupAndUnwindStage() (userLookup bson.D, userUnwind bson.D) {
 	userLookup = bson.D{{
 		Key: "$lookup",
@@ -7,6 +7,7 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"medbuddy-backend/internal/constant"
 	"medbuddy-backend/internal/model"
+	"time"
 )
 
 func (m *Mongo) CreatePractitioner(ctx context.Context, data *model.Practitioner) error {
@@ -140,25 +141,50 @@ func (m *Mongo) GetPractitionersByIds(ctx context.Context, ids []primitive.Objec
 	return practs, nil
 }
 
-func (m *Mongo) GetPractitionerMedications(ctx context.Context, practitionerId primitive.ObjectID) (medics []model.MedicationResponse, err error) {
+// GetPractitionerMedications returns the medications the practitioner has an active, unexpired grant
+// on, directly or through a grant on the whole profile of the patient
+func (m *Mongo) GetPractitionerMedications(ctx context.Context, practitionerId primitive.ObjectID, now time.Time) (medics []model.MedicationResponse, err error) {
 	db := m.mongoclient.Database(constant.AppName)
 	pColl := db.Collection(constant.MedicationCollection)
+	gColl := db.Collection(constant.AccessGrantCollection)
 
 	var cancel context.CancelFunc
 	ctx, cancel = context.WithTimeout(ctx, m.timeout)
 	defer cancel()
 
+	grantFilter := bson.D{
+		{Key: "practitioner_id", Value: practitionerId},
+		{Key: "status", Value: constant.InvitationActive},
+		{Key: "$or", Value: notExpiredFilter(now)},
+	}
+	gCur, err := gColl.Find(ctx, grantFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	var grants []model.AccessGrant
+	if err := gCur.All(ctx, &grants); err != nil {
+		return nil, err
+	}
+
 	medics = []model.MedicationResponse{}
-	matchStage := bson.D{{Key: "$match", Value: bson.D{{
-		Key: "practitioner_ids",
-		Value: bson.D{{
-			Key: "$elemMatch",
-			Value: bson.D{{
-				Key:   "$eq",
-				Value: practitionerId,
-			}},
-		}},
-	}}}}
+	if len(grants) == 0 {
+		return medics, nil
+	}
+
+	medicationIDs, patientIDs := bson.A{}, bson.A{}
+	for _, g := range grants {
+		if g.MedicationID.IsZero() {
+			patientIDs = append(patientIDs, g.PatientID)
+		} else {
+			medicationIDs = append(medicationIDs, g.MedicationID)
+		}
+	}
+
+	matchStage := bson.D{{Key: "$match", Value: bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: medicationIDs}}}},
+		bson.D{{Key: "patient_id", Value: bson.D{{Key: "$in", Value: patientIDs}}}},
+	}}}}}
 	medLookupStage, medUnwindStage := getMedicineLookupAndUnwindStage()
 	patientLookupStage, patientUnwindStage := getPatientLookupAndUnwindStage()
 
@@ -177,6 +203,233 @@ func (m *Mongo) GetPractitionerMedications(ctx context.Context, practitionerId p
 	return medics, nil
 }
 
+// rosterSortFields maps the roster sort options to the fields they sort on, in order
+var rosterSortFields = map[string][]string{
+	constant.RosterSortRisk:      {"at_risk", "consecutive_missed"},
+	constant.RosterSortName:      {"patient.full_name"},
+	constant.RosterSortAdherence: {"recent_adherence.rate"},
+	constant.RosterSortLastTaken: {"last_dosage_taken.time_taken"},
+}
+
+// GetPractitionerRoster returns a page of the patients the practitioner has an active, unexpired
+// grant on. Each patient comes with the medications and dosages the practitioner has access to, the
+// adherence since filter.Since and the risk flags raised on them.
+func (m *Mongo) GetPractitionerRoster(ctx context.Context, filter *model.RosterFilter, now time.Time) (patients []model.RosterPatient, total int64, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	gColl := db.Collection(constant.AccessGrantCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	matchStage := bson.D{{Key: "$match", Value: bson.D{
+		{Key: "practitioner_id", Value: filter.PractitionerID},
+		{Key: "status", Value: constant.InvitationActive},
+		{Key: "$or", Value: notExpiredFilter(now)},
+	}}}
+	groupStage := bson.D{{Key: "$group", Value: bson.D{
+		{Key: "_id", Value: "$patient_id"},
+		{Key: "patient_id", Value: bson.D{{Key: "$first", Value: "$patient_id"}}},
+		{Key: "whole_profile", Value: bson.D{{Key: "$max", Value: bson.D{{Key: "$eq", Value: bson.A{bson.D{{Key: "$type", Value: "$medication_id"}}, "missing"}}}}}},
+		{Key: "medication_ids", Value: bson.D{{Key: "$push", Value: "$medication_id"}}},
+	}}}
+	patientLookupStage, patientUnwindStage := getPatientLookupAndUnwindStage()
+
+	medicLookupStage := bson.D{{Key: "$lookup", Value: bson.D{
+		{Key: "from", Value: constant.MedicationCollection},
+		{Key: "let", Value: bson.D{
+			{Key: "patient", Value: "$_id"},
+			{Key: "whole_profile", Value: "$whole_profile"},
+			{Key: "medication_ids", Value: "$medication_ids"},
+		}},
+		{Key: "pipeline", Value: bson.A{
+			bson.D{{Key: "$match", Value: bson.D{{Key: "$expr", Value: bson.D{{Key: "$and", Value: bson.A{
+				bson.D{{Key: "$eq", Value: bson.A{"$patient_id", "$$patient"}}},
+				bson.D{{Key: "$or", Value: bson.A{"$$whole_profile", bson.D{{Key: "$in", Value: bson.A{"$_id", "$$medication_ids"}}}}}},
+			}}}}}}},
+			bson.D{{Key: "$project", Value: bson.D{
+				{Key: "name", Value: 1},
+				{Key: "dosage_quantity", Value: 1},
+				{Key: "daily_dosage", Value: 1},
+				{Key: "start_date", Value: 1},
+				{Key: "end_date", Value: 1},
+				{Key: "treatment", Value: 1},
+				{Key: "is_active", Value: 1},
+			}}},
+		}},
+		{Key: "as", Value: "medications"},
+	}}}
+
+	lastTakenLookupStage := getRosterDosageLookupStage("last_dosage_taken",
+		bson.D{{Key: "status", Value: constant.DosageTaken}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "time_taken", Value: -1}}}},
+		bson.D{{Key: "$limit", Value: 1}},
+		bson.D{{Key: "$project", Value: bson.D{
+			{Key: "medication_id", Value: 1},
+			{Key: "reminder_time", Value: 1},
+			{Key: "time_taken", Value: 1},
+		}}},
+	)
+	recentLookupStage := getRosterDosageLookupStage("recent_adherence",
+		bson.D{
+			{Key: "reminder_time", Value: bson.D{{Key: "$gte", Value: filter.Since}, {Key: "$lt", Value: now}}},
+			{Key: "as_needed", Value: bson.D{{Key: "$ne", Value: true}}},
+		},
+		bson.D{{Key: "$group", Value: append(bson.D{{Key: "_id", Value: nil}}, adherenceCounts()...)}},
+	)
+	lastSettledLookupStage := getRosterDosageLookupStage("last_settled",
+		bson.D{
+			{Key: "status", Value: bson.D{{Key: "$in", Value: bson.A{constant.DosageTaken, constant.DosageSkipped}}}},
+			{Key: "as_needed", Value: bson.D{{Key: "$ne", Value: true}}},
+		},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "reminder_time", Value: -1}}}},
+		bson.D{{Key: "$limit", Value: 1}},
+		bson.D{{Key: "$project", Value: bson.D{{Key: "reminder_time", Value: 1}}}},
+	)
+	// the missed dosages after the last one taken or skipped are missed in a row
+	lastSettledStage := bson.D{{Key: "$addFields", Value: bson.D{{Key: "last_settled", Value: bson.D{{Key: "$ifNull", Value: bson.A{
+		bson.D{{Key: "$first", Value: "$last_settled.reminder_time"}}, time.Time{},
+	}}}}}}}
+	missedLookupStage := bson.D{{Key: "$lookup", Value: bson.D{
+		{Key: "from", Value: constant.DosageCollection},
+		{Key: "let", Value: bson.D{
+			{Key: "patient", Value: "$_id"},
+			{Key: "medication_ids", Value: "$medications._id"},
+			{Key: "after", Value: "$last_settled"},
+		}},
+		{Key: "pipeline", Value: bson.A{
+			bson.D{{Key: "$match", Value: bson.D{
+				{Key: "status", Value: constant.DosageMissed},
+				{Key: "$expr", Value: bson.D{{Key: "$and", Value: bson.A{
+					bson.D{{Key: "$eq", Value: bson.A{"$patient_id", "$$patient"}}},
+					bson.D{{Key: "$in", Value: bson.A{"$medication_id", "$$medication_ids"}}},
+					bson.D{{Key: "$gt", Value: bson.A{"$reminder_time", "$$after"}}},
+				}}}},
+			}}},
+			bson.D{{Key: "$count", Value: "count"}},
+		}},
+		{Key: "as", Value: "consecutive_missed"},
+	}}}
+
+	summaryStage := bson.D{{Key: "$addFields", Value: bson.D{
+		{Key: "active_medications", Value: bson.D{{Key: "$filter", Value: bson.D{
+			{Key: "input", Value: "$medications"},
+			{Key: "cond", Value: "$$this.is_active"},
+		}}}},
+		{Key: "last_dosage_taken", Value: bson.D{{Key: "$first", Value: "$last_dosage_taken"}}},
+		{Key: "recent_adherence", Value: bson.D{{Key: "$ifNull", Value: bson.A{
+			bson.D{{Key: "$first", Value: "$recent_adherence"}},
+			bson.D{{Key: "taken", Value: 0}, {Key: "skipped", Value: 0}, {Key: "missed", Value: 0}},
+		}}}},
+		{Key: "consecutive_missed", Value: bson.D{{Key: "$ifNull", Value: bson.A{
+			bson.D{{Key: "$first", Value: "$consecutive_missed.count"}}, 0,
+		}}}},
+	}}}
+
+	due := bson.D{{Key: "$add", Value: bson.A{"$recent_adherence.taken", "$recent_adherence.skipped", "$recent_adherence.missed"}}}
+	rateStage := bson.D{{Key: "$addFields", Value: bson.D{{Key: "recent_adherence.rate", Value: bson.D{{Key: "$cond", Value: bson.A{
+		bson.D{{Key: "$gt", Value: bson.A{due, 0}}},
+		bson.D{{Key: "$round", Value: bson.A{
+			bson.D{{Key: "$multiply", Value: bson.A{bson.D{{Key: "$divide", Value: bson.A{"$recent_adherence.taken", due}}}, 100}}}, 1,
+		}}},
+		"$$REMOVE",
+	}}}}}}}
+
+	flag := func(cond bson.D, name string) bson.D {
+		return bson.D{{Key: "$cond", Value: bson.A{cond, bson.A{name}, bson.A{}}}}
+	}
+	riskStage := bson.D{{Key: "$addFields", Value: bson.D{{Key: "risk_flags", Value: bson.D{{Key: "$concatArrays", Value: bson.A{
+		flag(bson.D{{Key: "$gte", Value: bson.A{"$consecutive_missed", constant.RiskConsecutiveMissedThreshold}}}, constant.RiskConsecutiveMissed),
+		flag(bson.D{{Key: "$and", Value: bson.A{
+			bson.D{{Key: "$ne", Value: bson.A{bson.D{{Key: "$type", Value: "$recent_adherence.rate"}}, "missing"}}},
+			bson.D{{Key: "$lt", Value: bson.A{"$recent_adherence.rate", constant.RiskLowAdherenceThreshold}}},
+		}}}, constant.RiskLowAdherence),
+	}}}}}}}
+	atRiskStage := bson.D{{Key: "$addFields", Value: bson.D{{Key: "at_risk", Value: bson.D{{Key: "$gt", Value: bson.A{
+		bson.D{{Key: "$size", Value: "$risk_flags"}}, 0,
+	}}}}}}}
+	projectStage := bson.D{{Key: "$project", Value: bson.D{
+		{Key: "medications", Value: 0},
+		{Key: "medication_ids", Value: 0},
+		{Key: "last_settled", Value: 0},
+	}}}
+
+	order := 1
+	if filter.Descending {
+		order = -1
+	}
+	sort := bson.D{}
+	for _, field := range rosterSortFields[filter.Sort] {
+		sort = append(sort, bson.E{Key: field, Value: order})
+	}
+	sort = append(sort, bson.E{Key: "_id", Value: 1})
+	sortStage := bson.D{{Key: "$sort", Value: sort}}
+
+	facetStage := bson.D{{Key: "$facet", Value: bson.D{
+		{Key: "total", Value: bson.A{bson.D{{Key: "$count", Value: "count"}}}},
+		{Key: "patients", Value: bson.A{
+			bson.D{{Key: "$skip", Value: (filter.Page - 1) * filter.Limit}},
+			bson.D{{Key: "$limit", Value: filter.Limit}},
+		}},
+	}}}
+
+	pipeline := mongo.Pipeline{matchStage, groupStage, patientLookupStage, patientUnwindStage, medicLookupStage,
+		lastTakenLookupStage, recentLookupStage, lastSettledLookupStage, lastSettledStage, missedLookupStage,
+		summaryStage, rateStage, riskStage, atRiskStage, projectStage, sortStage, facetStage}
+	cur, err := gColl.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var results []struct {
+		Total []struct {
+			Count int64 `bson:"count"`
+		} `bson:"total"`
+		Patients []model.RosterPatient `bson:"patients"`
+	}
+	if err := cur.All(ctx, &results); err != nil {
+		return nil, 0, err
+	}
+
+	patients = []model.RosterPatient{}
+	if len(results) == 0 {
+		return patients, 0, nil
+	}
+
+	if len(results[0].Total) > 0 {
+		total = results[0].Total[0].Count
+	}
+	if len(results[0].Patients) > 0 {
+		patients = results[0].Patients
+	}
+
+	return patients, total, nil
+}
+
+// getRosterDosageLookupStage looks up, as the field as, the dosages of the medications of a roster
+// patient that match filter, running stages on them
+func getRosterDosageLookupStage(as string, filter bson.D, stages ...bson.D) bson.D {
+	match := append(filter, bson.E{Key: "$expr", Value: bson.D{{Key: "$and", Value: bson.A{
+		bson.D{{Key: "$eq", Value: bson.A{"$patient_id", "$$patient"}}},
+		bson.D{{Key: "$in", Value: bson.A{"$medication_id", "$$medication_ids"}}},
+	}}}})
+
+	pipeline := bson.A{bson.D{{Key: "$match", Value: match}}}
+	for _, stage := range stages {
+		pipeline = append(pipeline, stage)
+	}
+
+	return bson.D{{Key: "$lookup", Value: bson.D{
+		{Key: "from", Value: constant.DosageCollection},
+		{Key: "let", Value: bson.D{
+			{Key: "patient", Value: "$_id"},
+			{Key: "medication_ids", Value: "$medications._id"},
+		}},
+		{Key: "pipeline", Value: pipeline},
+		{Key: "as", Value: as},
+	}}}
+}
+
 func getPatientLookupAndUnwindStage() (patientLookup bson.D, patientUnwind bson.D) {
 	patientLookup = bson.D{{
 		Key: "$lookup",
@@ -0,0 +1,135 @@
+package mongo
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"medbuddy-backend/internal/constant"
+	"medbuddy-backend/internal/model"
+	"time"
+)
+
+func (m *Mongo) CreatePrescription(ctx context.Context, prescription *model.Prescription) error {
+	db := m.mongoclient.Database(constant.AppName)
+	pColl := db.Collection(constant.PrescriptionCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	if _, err := pColl.InsertOne(ctx, prescription); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *Mongo) GetPrescription(ctx context.Context, id primitive.ObjectID) (prescription model.PrescriptionResponse, found bool, err error) {
+	prescriptions, err := m.getPrescriptions(ctx, bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		return model.PrescriptionResponse{}, false, err
+	}
+
+	if len(prescriptions) == 0 {
+		return model.PrescriptionResponse{}, false, nil
+	}
+
+	return prescriptions[0], true, nil
+}
+
+// GetPrescriptions returns the prescriptions of a patient or of a practitioner
+func (m *Mongo) GetPrescriptions(ctx context.Context, request *model.PrescriptionFilter) (prescriptions []model.PrescriptionResponse, err error) {
+	filter := bson.D{}
+	if !request.PatientID.IsZero() {
+		filter = append(filter, bson.E{Key: "patient_id", Value: request.PatientID})
+	}
+
+	if !request.PractitionerID.IsZero() {
+		filter = append(filter, bson.E{Key: "practitioner_id", Value: request.PractitionerID})
+	}
+
+	if request.Status != "" {
+		filter = append(filter, bson.E{Key: "status", Value: request.Status})
+	}
+
+	return m.getPrescriptions(ctx, filter)
+}
+
+func (m *Mongo) getPrescriptions(ctx context.Context, filter bson.D) (prescriptions []model.PrescriptionResponse, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	pColl := db.Collection(constant.PrescriptionCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	matchStage := bson.D{{Key: "$match", Value: filter}}
+	patientLookupStage, patientUnwindStage := getPatientLookupAndUnwindStage()
+	practitionerLookupStage, practitionerUnwindStage := getPractitionerLookupAndUnwindStage()
+	sortStage := bson.D{{Key: "$sort", Value: bson.D{{Key: "created_at", Value: -1}}}}
+
+	pipeline := mongo.Pipeline{matchStage, patientLookupStage, patientUnwindStage, practitionerLookupStage,
+		practitionerUnwindStage, sortStage}
+	cur, err := pColl.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	prescriptions = []model.PrescriptionResponse{}
+	if err := cur.All(ctx, &prescriptions); err != nil {
+		return nil, err
+	}
+
+	return prescriptions, nil
+}
+
+// SetPrescriptionStatus moves a prescription from the from status to status. Moving it back to
+// pending clears the time it was responded to.
+func (m *Mongo) SetPrescriptionStatus(ctx context.Context, id primitive.ObjectID, from, status string, now time.Time) (found bool, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	pColl := db.Collection(constant.PrescriptionCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "status", Value: from},
+	}
+
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: status},
+		{Key: "responded_at", Value: now},
+	}}}
+	if status == constant.PrescriptionPending {
+		update = bson.D{
+			{Key: "$set", Value: bson.D{{Key: "status", Value: status}}},
+			{Key: "$unset", Value: bson.D{{Key: "responded_at", Value: ""}}},
+		}
+	}
+
+	res, err := pColl.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return res.MatchedCount > 0, nil
+}
+
+func (m *Mongo) SetPrescriptionMedication(ctx context.Context, id, medicationID primitive.ObjectID) error {
+	db := m.mongoclient.Database(constant.AppName)
+	pColl := db.Collection(constant.PrescriptionCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "medication_id", Value: medicationID}}}}
+	if _, err := pColl.UpdateByID(ctx, id, update); err != nil {
+		return err
+	}
+
+	return nil
+}
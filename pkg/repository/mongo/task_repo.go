@@ -28,6 +28,7 @@ func (m *Mongo) AddTasks(ctx context.Context, tasks []model.Task) (int64, error)
 	if err != nil {
 		return -1, err
 	}
+	recordInsert(ctx, tColl, res.InsertedIDs...)
 
 	return int64(len(res.InsertedIDs)), nil
 }
@@ -73,7 +74,7 @@ func (m *Mongo) DeleteTasks(ctx context.Context, taskIDs []primitive.ObjectID) (
 	return res.DeletedCount, nil
 }
 
-func (m *Mongo) GetLatestTasks(ctx context.Context, startTime time.Time) (tasks []model.LatestTaskResponse, err error) {
+func (m *Mongo) DeleteUndoneTasks(ctx context.Context, medicationId primitive.ObjectID) (int64, error) {
 	db := m.mongoclient.Database(constant.AppName)
 	tColl := db.Collection(constant.TaskCollection)
 
@@ -82,33 +83,247 @@ func (m *Mongo) GetLatestTasks(ctx context.Context, startTime time.Time) (tasks
 	defer cancel()
 
 	filter := bson.D{
+		{Key: "medication_id", Value: medicationId},
 		{Key: "status", Value: constant.TaskUndone},
-		{Key: "time", Value: bson.D{{Key: "$gt", Value: startTime}}},
-		{Key: "time", Value: bson.D{{Key: "$lte", Value: startTime.Add(constant.TimeLapseForJobs)}}},
 	}
 
-	tasks = []model.LatestTaskResponse{}
-	matchStage := bson.D{{Key: "$match", Value: filter}}
-	medicLookupStage, medicUnwindStage := getMedicationLookupAndUnwindStage()
-	patientLookupStage, patientUnwindStage := getTaskPatientLookupAndUnwindStage()
-	medLookupStage, medUnwindStage := getDosageMedicineLookupAndUnwindStage()
-	sortStage := bson.D{{Key: "$sort", Value: bson.D{{"reminder_time", 1}}}}
+	if err := recordOverwrite(ctx, tColl, filter); err != nil {
+		return -1, err
+	}
 
-	pipeline := mongo.Pipeline{matchStage, medicLookupStage, medicUnwindStage, patientLookupStage, patientUnwindStage,
-		medLookupStage, medUnwindStage, sortStage}
+	res, err := tColl.DeleteMany(ctx, filter)
+	if err != nil {
+		return -1, err
+	}
+
+	return res.DeletedCount, nil
+}
+
+// DeleteUndoneDosageTasks deletes the reminders of a dosage that have not been sent yet
+func (m *Mongo) DeleteUndoneDosageTasks(ctx context.Context, dosageId primitive.ObjectID) (int64, error) {
+	db := m.mongoclient.Database(constant.AppName)
+	tColl := db.Collection(constant.TaskCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "dosage_id", Value: dosageId},
+		{Key: "status", Value: constant.TaskUndone},
+	}
+
+	if err := recordOverwrite(ctx, tColl, filter); err != nil {
+		return -1, err
+	}
 
-	options := options2.Aggregate().SetAllowDiskUse(true)
-	cur, err := tColl.Aggregate(ctx, pipeline, options)
+	res, err := tColl.DeleteMany(ctx, filter)
 	if err != nil {
-		return nil, err
+		return -1, err
 	}
 
-	err = cur.All(ctx, &tasks)
+	return res.DeletedCount, nil
+}
+
+func (m *Mongo) DeleteMedicationTasks(ctx context.Context, medicationId primitive.ObjectID) (int64, error) {
+	db := m.mongoclient.Database(constant.AppName)
+	tColl := db.Collection(constant.TaskCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{{Key: "medication_id", Value: medicationId}}
+	if err := recordOverwrite(ctx, tColl, filter); err != nil {
+		return -1, err
+	}
+
+	res, err := tColl.DeleteMany(ctx, filter)
 	if err != nil {
-		return nil, err
+		return -1, err
+	}
+
+	return res.DeletedCount, nil
+}
+
+// ClaimDueTask atomically leases the earliest task whose time is between notBefore and now to
+// owner until leaseUntil. Undone tasks and claimed tasks whose lease has expired, because the
+// instance dispatching them stopped, can be claimed.
+func (m *Mongo) ClaimDueTask(ctx context.Context, owner string, now, notBefore, leaseUntil time.Time) (task model.Task, found bool, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	tColl := db.Collection(constant.TaskCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "time", Value: bson.D{
+			{Key: "$gte", Value: notBefore},
+			{Key: "$lte", Value: now},
+		}},
+		{Key: "$or", Value: claimableTaskFilter(now)},
+	}
+
+	update := bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "status", Value: constant.TaskClaimed},
+			{Key: "claimed_at", Value: now},
+			{Key: "lease_owner", Value: owner},
+			{Key: "lease_expires_at", Value: leaseUntil},
+		}},
+		{Key: "$inc", Value: bson.D{{Key: "attempts", Value: 1}}},
 	}
 
-	return tasks, nil
+	options := options2.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "time", Value: 1}}).
+		SetReturnDocument(options2.After)
+
+	if err := tColl.FindOneAndUpdate(ctx, filter, update, options).Decode(&task); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return model.Task{}, false, nil
+		}
+		return model.Task{}, false, err
+	}
+
+	return task, true, nil
+}
+
+// RenewTaskLease extends the lease owner holds on a task. It returns false if the lease has
+// been lost to another instance.
+func (m *Mongo) RenewTaskLease(ctx context.Context, taskID primitive.ObjectID, owner string, leaseUntil time.Time) (bool, error) {
+	db := m.mongoclient.Database(constant.AppName)
+	tColl := db.Collection(constant.TaskCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "_id", Value: taskID},
+		{Key: "status", Value: constant.TaskClaimed},
+		{Key: "lease_owner", Value: owner},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "lease_expires_at", Value: leaseUntil}}}}
+
+	res, err := tColl.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return res.MatchedCount > 0, nil
+}
+
+// CompleteTask sets the final status of a task leased by owner. Setting the status back to
+// undone releases the task so it can be claimed again.
+func (m *Mongo) CompleteTask(ctx context.Context, taskID primitive.ObjectID, owner string, status string, lastError string) error {
+	db := m.mongoclient.Database(constant.AppName)
+	tColl := db.Collection(constant.TaskCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	updates := bson.D{
+		{Key: "status", Value: status},
+		{Key: "last_error", Value: lastError},
+	}
+	if status != constant.TaskUndone {
+		updates = append(updates, bson.E{Key: "completed_at", Value: time.Now()})
+	}
+
+	filter := bson.D{
+		{Key: "_id", Value: taskID},
+		{Key: "status", Value: constant.TaskClaimed},
+		{Key: "lease_owner", Value: owner},
+	}
+	update := bson.D{
+		{Key: "$set", Value: updates},
+		{Key: "$unset", Value: bson.D{{Key: "lease_owner", Value: ""}, {Key: "lease_expires_at", Value: ""}}},
+	}
+
+	_, err := tColl.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ReleaseTask gives up the lease owner holds on a task that failed, it can be claimed again once
+// nextAttemptAt has passed
+func (m *Mongo) ReleaseTask(ctx context.Context, taskID primitive.ObjectID, owner string, nextAttemptAt time.Time, lastError string) error {
+	db := m.mongoclient.Database(constant.AppName)
+	tColl := db.Collection(constant.TaskCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "_id", Value: taskID},
+		{Key: "status", Value: constant.TaskClaimed},
+		{Key: "lease_owner", Value: owner},
+	}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "status", Value: constant.TaskUndone},
+			{Key: "last_error", Value: lastError},
+			{Key: "next_attempt_at", Value: nextAttemptAt},
+		}},
+		{Key: "$unset", Value: bson.D{{Key: "lease_owner", Value: ""}, {Key: "lease_expires_at", Value: ""}}},
+	}
+
+	_, err := tColl.UpdateOne(ctx, filter, update)
+	return err
+}
+
+// ExpireTasks marks tasks scheduled before the given time that were never sent as expired
+func (m *Mongo) ExpireTasks(ctx context.Context, before time.Time) (int64, error) {
+	db := m.mongoclient.Database(constant.AppName)
+	tColl := db.Collection(constant.TaskCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	now := time.Now()
+	filter := bson.D{
+		{Key: "time", Value: bson.D{{Key: "$lt", Value: before}}},
+		{Key: "$or", Value: claimableTaskFilter(now)},
+	}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "status", Value: constant.TaskExpired},
+			{Key: "completed_at", Value: now},
+		}},
+		{Key: "$unset", Value: bson.D{{Key: "lease_owner", Value: ""}, {Key: "lease_expires_at", Value: ""}}},
+	}
+
+	res, err := tColl.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return -1, err
+	}
+
+	return res.ModifiedCount, nil
+}
+
+// claimableTaskFilter matches undone tasks that are not waiting to be tried again and claimed tasks
+// whose lease has expired
+func claimableTaskFilter(now time.Time) bson.A {
+	return bson.A{
+		bson.D{
+			{Key: "status", Value: constant.TaskUndone},
+			{Key: "$or", Value: bson.A{
+				bson.D{{Key: "next_attempt_at", Value: bson.D{{Key: "$exists", Value: false}}}},
+				bson.D{{Key: "next_attempt_at", Value: bson.D{{Key: "$lte", Value: now}}}},
+			}},
+		},
+		bson.D{
+			{Key: "status", Value: constant.TaskClaimed},
+			{Key: "lease_expires_at", Value: bson.D{{Key: "$lt", Value: now}}},
+		},
+	}
 }
 
 func (m *Mongo) GetTask(ctx context.Context, taskID primitive.ObjectID) (task model.LatestTaskResponse, found bool, err error) {
@@ -126,10 +341,11 @@ func (m *Mongo) GetTask(ctx context.Context, taskID primitive.ObjectID) (task mo
 	medicLookupStage, medicUnwindStage := getMedicationLookupAndUnwindStage()
 	patientLookupStage, patientUnwindStage := getTaskPatientLookupAndUnwindStage()
 	medLookupStage, medUnwindStage := getDosageMedicineLookupAndUnwindStage()
+	dosageLookupStage, dosageStage := getTaskDosageStages()
 	sortStage := bson.D{{Key: "$sort", Value: bson.D{{"reminder_time", 1}}}}
 
 	pipeline := mongo.Pipeline{matchStage, medicLookupStage, medicUnwindStage, patientLookupStage, patientUnwindStage,
-		medLookupStage, medUnwindStage, sortStage}
+		medLookupStage, medUnwindStage, dosageLookupStage, dosageStage, sortStage}
 
 	cur, err := tColl.Aggregate(ctx, pipeline)
 	if err != nil {
@@ -179,3 +395,102 @@ func getTaskPatientLookupAndUnwindStage() (patientLookup bson.D, patientUnwind b
 
 	return
 }
+
+// getTaskDosageStages sets the status and the is_active flag of the dosage of a task and replaces the dosage quantity of
+// its medication with the quantity of the dosage, which differs from phase to phase for tapered
+// medications. Dosages scheduled before dosages had a quantity keep the one of the medication.
+func getTaskDosageStages() (dosageLookup bson.D, dosage bson.D) {
+	dosageLookup = bson.D{{Key: "$lookup", Value: bson.D{
+		{Key: "from", Value: constant.DosageCollection},
+		{Key: "let", Value: bson.D{{Key: "dosage_id", Value: "$dosage_id"}}},
+		{Key: "pipeline", Value: bson.A{
+			bson.D{{Key: "$match", Value: bson.D{{Key: "$expr", Value: bson.D{{Key: "$eq", Value: bson.A{"$_id", "$$dosage_id"}}}}}}},
+			bson.D{{Key: "$project", Value: bson.D{{Key: "quantity", Value: 1}, {Key: "status", Value: 1}, {Key: "is_active", Value: 1}}}},
+		}},
+		{Key: "as", Value: "dosage"},
+	}}}
+
+	dosage = bson.D{{Key: "$set", Value: bson.D{
+		{Key: "medication.dosage_quantity", Value: bson.D{{Key: "$ifNull", Value: bson.A{
+			bson.D{{Key: "$first", Value: "$dosage.quantity"}},
+			"$medication.dosage_quantity",
+		}}}},
+		{Key: "dosage_status", Value: bson.D{{Key: "$first", Value: "$dosage.status"}}},
+		{Key: "dosage_active", Value: bson.D{{Key: "$first", Value: "$dosage.is_active"}}},
+	}}}
+
+	return
+}
+
+func (m *Mongo) CountTasksByStatus(ctx context.Context) (map[string]int64, error) {
+	db := m.mongoclient.Database(constant.AppName)
+	tColl := db.Collection(constant.TaskCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	groupStage := bson.D{{Key: "$group", Value: bson.D{
+		{Key: "_id", Value: "$status"},
+		{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+	}}}
+
+	cur, err := tColl.Aggregate(ctx, mongo.Pipeline{groupStage})
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []struct {
+		Status string `bson:"_id"`
+		Count  int64  `bson:"count"`
+	}
+	if err := cur.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(groups))
+	for _, group := range groups {
+		counts[group.Status] = group.Count
+	}
+
+	return counts, nil
+}
+
+// CountOverdueTasks counts the tasks due before a time that have not been sent
+func (m *Mongo) CountOverdueTasks(ctx context.Context, before time.Time) (int64, error) {
+	db := m.mongoclient.Database(constant.AppName)
+	tColl := db.Collection(constant.TaskCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "time", Value: bson.D{{Key: "$lt", Value: before}}},
+		{Key: "status", Value: bson.D{{Key: "$in", Value: bson.A{constant.TaskUndone, constant.TaskClaimed}}}},
+	}
+
+	return tColl.CountDocuments(ctx, filter)
+}
+
+// GetNextTask returns the earliest task that has not been sent yet
+func (m *Mongo) GetNextTask(ctx context.Context) (task model.Task, found bool, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	tColl := db.Collection(constant.TaskCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{{Key: "status", Value: constant.TaskUndone}}
+	opts := options2.FindOne().SetSort(bson.D{{Key: "time", Value: 1}})
+
+	if err := tColl.FindOne(ctx, filter, opts).Decode(&task); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return model.Task{}, false, nil
+		}
+		return model.Task{}, false, err
+	}
+
+	return task, true, nil
+}
@@ -0,0 +1,294 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"medbuddy-backend/internal/constant"
+	"medbuddy-backend/internal/model"
+)
+
+func (m *Mongo) SaveRefreshToken(ctx context.Context, token *model.RefreshToken) error {
+	db := m.mongoclient.Database(constant.AppName)
+	rColl := db.Collection(constant.RefreshTokenCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	if _, err := rColl.InsertOne(ctx, token); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *Mongo) GetRefreshToken(ctx context.Context, tokenHash string) (token model.RefreshToken, found bool, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	rColl := db.Collection(constant.RefreshTokenCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	err = rColl.FindOne(ctx, bson.D{{Key: "token_hash", Value: tokenHash}}).Decode(&token)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return model.RefreshToken{}, false, nil
+		}
+		return model.RefreshToken{}, false, err
+	}
+
+	return token, true, nil
+}
+
+// UseRefreshToken marks an unused, unrevoked and unexpired refresh token as used and returns it.
+// It is atomic, a token can only be used once even if it is presented twice at the same time.
+func (m *Mongo) UseRefreshToken(ctx context.Context, tokenHash string, now time.Time) (token model.RefreshToken, found bool, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	rColl := db.Collection(constant.RefreshTokenCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "token_hash", Value: tokenHash},
+		{Key: "used_at", Value: bson.D{{Key: "$exists", Value: false}}},
+		{Key: "revoked_at", Value: bson.D{{Key: "$exists", Value: false}}},
+		{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: now}}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "used_at", Value: now}}}}
+
+	err = rColl.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&token)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return model.RefreshToken{}, false, nil
+		}
+		return model.RefreshToken{}, false, err
+	}
+
+	return token, true, nil
+}
+
+// RevokeTokenFamily revokes every refresh token of a family along with the access tokens issued
+// from them, which all expire before until
+func (m *Mongo) RevokeTokenFamily(ctx context.Context, familyID string, until time.Time) error {
+	db := m.mongoclient.Database(constant.AppName)
+	rColl := db.Collection(constant.RefreshTokenCollection)
+	revColl := db.Collection(constant.RevokedTokenCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "family_id", Value: familyID},
+		{Key: "revoked_at", Value: bson.D{{Key: "$exists", Value: false}}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "revoked_at", Value: time.Now()}}}}
+
+	if _, err := rColl.UpdateMany(ctx, filter, update); err != nil {
+		return err
+	}
+
+	revoked := model.RevokedToken{ID: primitive.NewObjectID(), FamilyID: familyID, ExpiresAt: until}
+	if _, err := revColl.InsertOne(ctx, revoked); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RevokeToken revokes a single access token until it expires
+func (m *Mongo) RevokeToken(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	db := m.mongoclient.Database(constant.AppName)
+	revColl := db.Collection(constant.RevokedTokenCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	revoked := model.RevokedToken{ID: primitive.NewObjectID(), TokenID: tokenID, ExpiresAt: expiresAt}
+	if _, err := revColl.InsertOne(ctx, revoked); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *Mongo) IsTokenRevoked(ctx context.Context, tokenID, familyID string) (bool, error) {
+	db := m.mongoclient.Database(constant.AppName)
+	revColl := db.Collection(constant.RevokedTokenCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	conditions := bson.A{bson.D{{Key: "jti", Value: tokenID}}}
+	if familyID != "" {
+		conditions = append(conditions, bson.D{{Key: "family_id", Value: familyID}})
+	}
+
+	count, err := revColl.CountDocuments(ctx, bson.D{{Key: "$or", Value: conditions}}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// RevokeUserTokens revokes every refresh token of a user along with the access tokens issued
+// from them, which all expire before until
+func (m *Mongo) RevokeUserTokens(ctx context.Context, userID primitive.ObjectID, until time.Time) error {
+	db := m.mongoclient.Database(constant.AppName)
+	rColl := db.Collection(constant.RefreshTokenCollection)
+	revColl := db.Collection(constant.RevokedTokenCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "user_id", Value: userID},
+		{Key: "revoked_at", Value: bson.D{{Key: "$exists", Value: false}}},
+	}
+
+	families, err := rColl.Distinct(ctx, "family_id", filter)
+	if err != nil {
+		return err
+	}
+
+	if len(families) == 0 {
+		return nil
+	}
+
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "revoked_at", Value: time.Now()}}}}
+	if _, err := rColl.UpdateMany(ctx, filter, update); err != nil {
+		return err
+	}
+
+	revoked := make([]interface{}, 0, len(families))
+	for _, family := range families {
+		familyID, _ := family.(string)
+		revoked = append(revoked, model.RevokedToken{ID: primitive.NewObjectID(), FamilyID: familyID, ExpiresAt: until})
+	}
+
+	if _, err := revColl.InsertMany(ctx, revoked); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *Mongo) SaveUserToken(ctx context.Context, token *model.UserToken) error {
+	db := m.mongoclient.Database(constant.AppName)
+	tColl := db.Collection(constant.UserTokenCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	if _, err := tColl.InsertOne(ctx, token); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UseUserToken marks an unused and unexpired token issued for purpose as used and returns it
+func (m *Mongo) UseUserToken(ctx context.Context, tokenHash, purpose string, now time.Time) (token model.UserToken, found bool, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	tColl := db.Collection(constant.UserTokenCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "token_hash", Value: tokenHash},
+		{Key: "purpose", Value: purpose},
+		{Key: "used_at", Value: bson.D{{Key: "$exists", Value: false}}},
+		{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: now}}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "used_at", Value: now}}}}
+
+	err = tColl.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&token)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return model.UserToken{}, false, nil
+		}
+		return model.UserToken{}, false, err
+	}
+
+	return token, true, nil
+}
+
+// RecordTokenUse stores a used token that is not issued from the database, e.g. a signed link. It
+// reports false when the token has been used before.
+func (m *Mongo) RecordTokenUse(ctx context.Context, token *model.UserToken) (firstUse bool, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	tColl := db.Collection(constant.UserTokenCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	if _, err := tColl.InsertOne(ctx, token); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// IsTokenUsed reports whether a token issued for purpose has been used
+func (m *Mongo) IsTokenUsed(ctx context.Context, tokenHash, purpose string) (bool, error) {
+	db := m.mongoclient.Database(constant.AppName)
+	tColl := db.Collection(constant.UserTokenCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "token_hash", Value: tokenHash},
+		{Key: "purpose", Value: purpose},
+		{Key: "used_at", Value: bson.D{{Key: "$exists", Value: true}}},
+	}
+
+	count, err := tColl.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// DeleteUserTokens deletes the unused tokens issued to a user for purpose
+func (m *Mongo) DeleteUserTokens(ctx context.Context, userID primitive.ObjectID, purpose string) error {
+	db := m.mongoclient.Database(constant.AppName)
+	tColl := db.Collection(constant.UserTokenCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "user_id", Value: userID},
+		{Key: "purpose", Value: purpose},
+		{Key: "used_at", Value: bson.D{{Key: "$exists", Value: false}}},
+	}
+
+	if _, err := tColl.DeleteMany(ctx, filter); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,172 @@
+package mongo
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"medbuddy-backend/internal/config"
+)
+
+const (
+	// transactionModeAuto uses transactions when the deployment supports them and
+	// falls back to compensating deletes otherwise
+	transactionModeAuto       = "auto"
+	transactionModeNative     = "transaction"
+	transactionModeCompensate = "compensate"
+)
+
+var (
+	supportsTransactions     bool
+	supportsTransactionsOnce sync.Once
+)
+
+type undoLogKey struct{}
+
+// undoLog records how to revert the writes made during a unit of work on deployments
+// without transaction support
+type undoLog struct {
+	mu    sync.Mutex
+	steps []func(ctx context.Context) error
+}
+
+// WithTransaction runs fn as a single unit of work. Repository calls made with the context
+// passed to fn either all take effect or none of them do. On replica sets and sharded clusters
+// fn runs inside a MongoDB transaction, on standalone servers every write is recorded and
+// reverted in reverse order if fn returns an error.
+func (m *Mongo) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !m.useNativeTransactions(ctx) {
+		return m.withCompensation(ctx, fn)
+	}
+
+	session, err := m.mongoclient.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+
+	return err
+}
+
+func (m *Mongo) withCompensation(ctx context.Context, fn func(ctx context.Context) error) error {
+	log := &undoLog{}
+	err := fn(context.WithValue(ctx, undoLogKey{}, log))
+	if err == nil {
+		return nil
+	}
+
+	log.mu.Lock()
+	defer log.mu.Unlock()
+
+	// Use a fresh context so the writes are reverted even if ctx has been cancelled
+	undoCtx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	for i := len(log.steps) - 1; i >= 0; i-- {
+		if undoErr := log.steps[i](undoCtx); undoErr != nil {
+			logger.Error("Error reverting write of failed unit of work, error: ", undoErr.Error())
+		}
+	}
+
+	return err
+}
+
+func (m *Mongo) useNativeTransactions(ctx context.Context) bool {
+	switch strings.ToLower(config.GetConfig().MongoTransactionMode) {
+	case transactionModeNative:
+		return true
+	case transactionModeCompensate:
+		return false
+	}
+
+	supportsTransactionsOnce.Do(func() {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+
+		var res bson.M
+		err := m.mongoclient.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&res)
+		if err != nil {
+			logger.Error("Error checking mongoDB topology, using compensating writes, error: ", err.Error())
+			return
+		}
+
+		// replica set members report their set name, mongos routers report "isdbgrid"
+		_, isReplicaSet := res["setName"]
+		supportsTransactions = isReplicaSet || res["msg"] == "isdbgrid"
+		if !supportsTransactions {
+			logger.Info("MongoDB deployment does not support transactions, using compensating writes")
+		}
+	})
+
+	return supportsTransactions
+}
+
+func addUndoStep(ctx context.Context, step func(ctx context.Context) error) {
+	log, ok := ctx.Value(undoLogKey{}).(*undoLog)
+	if !ok {
+		return
+	}
+
+	log.mu.Lock()
+	log.steps = append(log.steps, step)
+	log.mu.Unlock()
+}
+
+func inCompensatedUnit(ctx context.Context) bool {
+	_, ok := ctx.Value(undoLogKey{}).(*undoLog)
+	return ok
+}
+
+// recordInsert registers the deletion of newly inserted documents as the undo step
+func recordInsert(ctx context.Context, coll *mongo.Collection, ids ...interface{}) {
+	if len(ids) == 0 {
+		return
+	}
+
+	addUndoStep(ctx, func(ctx context.Context) error {
+		_, err := coll.DeleteMany(ctx, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}})
+		return err
+	})
+}
+
+// recordOverwrite saves the documents matching filter before they are updated or deleted and
+// registers their restoration as the undo step. It is a no-op outside a compensated unit of work.
+func recordOverwrite(ctx context.Context, coll *mongo.Collection, filter interface{}) error {
+	if !inCompensatedUnit(ctx) {
+		return nil
+	}
+
+	cur, err := coll.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	var docs []bson.Raw
+	if err := cur.All(ctx, &docs); err != nil {
+		return err
+	}
+
+	if len(docs) == 0 {
+		return nil
+	}
+
+	addUndoStep(ctx, func(ctx context.Context) error {
+		for _, doc := range docs {
+			_, err := coll.ReplaceOne(ctx, bson.D{{Key: "_id", Value: doc.Lookup("_id")}}, doc, options.Replace().SetUpsert(true))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return nil
+}
@@ -2,8 +2,14 @@ package mongo
 
 import (
 	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"medbuddy-backend/internal/constant"
 	"medbuddy-backend/internal/model"
+	"time"
 )
 
 func (m *Mongo) CreateUser(ctx context.Context, data *model.User) error {
@@ -20,3 +26,200 @@ func (m *Mongo) CreateUser(ctx context.Context, data *model.User) error {
 
 	return nil
 }
+
+func (m *Mongo) GetUserByID(ctx context.Context, id primitive.ObjectID) (user model.User, found bool, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	uColl := db.Collection(constant.UsersCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	err = uColl.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return model.User{}, false, nil
+		}
+		return model.User{}, false, err
+	}
+
+	return user, true, nil
+}
+
+func (m *Mongo) UpdateUserPassword(ctx context.Context, id primitive.ObjectID, password, salt string) (found bool, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	uColl := db.Collection(constant.UsersCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	update := bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "password", Value: password},
+			{Key: "salt", Value: salt},
+			{Key: "updated_at", Value: time.Now()},
+		}},
+		{Key: "$unset", Value: bson.D{{Key: "password_reset_required", Value: ""}}},
+	}
+
+	res, err := uColl.UpdateOne(ctx, bson.D{{Key: "_id", Value: id}}, update)
+	if err != nil {
+		return false, err
+	}
+
+	return res.MatchedCount > 0, nil
+}
+
+func (m *Mongo) SetEmailVerified(ctx context.Context, id primitive.ObjectID) (found bool, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	uColl := db.Collection(constant.UsersCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "email_verified", Value: true},
+		{Key: "updated_at", Value: time.Now()},
+	}}}
+
+	res, err := uColl.UpdateOne(ctx, bson.D{{Key: "_id", Value: id}}, update)
+	if err != nil {
+		return false, err
+	}
+
+	return res.MatchedCount > 0, nil
+}
+
+// SetUserLock locks or unlocks a user account. lockedBy records what locked it.
+func (m *Mongo) SetUserLock(ctx context.Context, id primitive.ObjectID, locked bool, lockedBy string) (found bool, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	uColl := db.Collection(constant.UsersCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "is_locked", Value: true},
+		{Key: "locked_by", Value: lockedBy},
+		{Key: "updated_at", Value: time.Now()},
+	}}}
+
+	if !locked {
+		update = bson.D{
+			{Key: "$set", Value: bson.D{{Key: "is_locked", Value: false}, {Key: "updated_at", Value: time.Now()}}},
+			{Key: "$unset", Value: bson.D{{Key: "locked_by", Value: ""}}},
+		}
+	}
+
+	res, err := uColl.UpdateOne(ctx, bson.D{{Key: "_id", Value: id}}, update)
+	if err != nil {
+		return false, err
+	}
+
+	return res.MatchedCount > 0, nil
+}
+
+func (m *Mongo) GetUserByEmail(ctx context.Context, email string) (user model.User, found bool, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	uColl := db.Collection(constant.UsersCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	err = uColl.FindOne(ctx, bson.D{{Key: "email", Value: email}}).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return model.User{}, false, nil
+		}
+		return model.User{}, false, err
+	}
+
+	return user, true, nil
+}
+
+func (m *Mongo) ListUsers(ctx context.Context, filter *model.UserFilter) (users []model.User, total int64, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	uColl := db.Collection(constant.UsersCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	query := bson.D{}
+	if filter.Role != 0 {
+		query = append(query, bson.E{Key: "role", Value: filter.Role})
+	}
+	if filter.Locked != nil {
+		query = append(query, bson.E{Key: "is_locked", Value: *filter.Locked})
+	}
+	if filter.Email != "" {
+		query = append(query, bson.E{Key: "email", Value: filter.Email})
+	}
+
+	total, err = uColl.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(int64((filter.Page - 1) * filter.Limit)).
+		SetLimit(int64(filter.Limit))
+
+	cur, err := uColl.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err := cur.All(ctx, &users); err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+// CreateFirstUserWithRole creates user unless a user with the same role already exists
+func (m *Mongo) CreateFirstUserWithRole(ctx context.Context, user *model.User) (created bool, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	uColl := db.Collection(constant.UsersCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	filter := bson.D{{Key: "role", Value: user.Role}}
+	update := bson.D{{Key: "$setOnInsert", Value: user}}
+
+	res, err := uColl.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return false, err
+	}
+
+	return res.UpsertedCount > 0, nil
+}
+
+// RequirePasswordReset stops a user from logging in until they reset their password
+func (m *Mongo) RequirePasswordReset(ctx context.Context, id primitive.ObjectID) (found bool, err error) {
+	db := m.mongoclient.Database(constant.AppName)
+	uColl := db.Collection(constant.UsersCollection)
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "password_reset_required", Value: true},
+		{Key: "updated_at", Value: time.Now()},
+	}}}
+
+	res, err := uColl.UpdateOne(ctx, bson.D{{Key: "_id", Value: id}}, update)
+	if err != nil {
+		return false, err
+	}
+
+	return res.MatchedCount > 0, nil
+}
@@ -17,29 +17,70 @@ type RedisRepository interface {
 
 // repositories
 type StorageRepository interface {
+	// WithTransaction runs fn as a single unit of work, the repository calls made with
+	// the context passed to fn are either all applied or all reverted
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+
 	// Patient
 	CreatePatient(ctx context.Context, user *model.Patient) error
 	GetPatientByEmail(ctx context.Context, email string) (patient model.PatientResponse, found bool, err error)
 	GetPatientByID(ctx context.Context, id primitive.ObjectID) (patient model.PatientResponse, found bool, err error)
+	UpdateNotificationPreferences(ctx context.Context, id primitive.ObjectID, prefs *model.NotificationPreferences) (found bool, err error)
+	UpdatePatientTimeZone(ctx context.Context, id primitive.ObjectID, timeZone string) (found bool, err error)
 
 	// User
 	CreateUser(ctx context.Context, data *model.User) error
+	GetUserByID(ctx context.Context, id primitive.ObjectID) (user model.User, found bool, err error)
+	GetUserByEmail(ctx context.Context, email string) (user model.User, found bool, err error)
+	ListUsers(ctx context.Context, filter *model.UserFilter) (users []model.User, total int64, err error)
+	CreateFirstUserWithRole(ctx context.Context, user *model.User) (created bool, err error)
+	UpdateUserPassword(ctx context.Context, id primitive.ObjectID, password, salt string) (found bool, err error)
+	SetEmailVerified(ctx context.Context, id primitive.ObjectID) (found bool, err error)
+	SetUserLock(ctx context.Context, id primitive.ObjectID, locked bool, lockedBy string) (found bool, err error)
+	RequirePasswordReset(ctx context.Context, id primitive.ObjectID) (found bool, err error)
+
+	// Login attempt
+	GetLoginAttempts(ctx context.Context, keys ...string) (attempts []model.LoginAttempt, err error)
+	RecordLoginFailure(ctx context.Context, key string, now, expiresAt time.Time) (attempt model.LoginAttempt, err error)
+	ClearLoginAttempts(ctx context.Context, key string) error
+
+	// Token
+	SaveRefreshToken(ctx context.Context, token *model.RefreshToken) error
+	GetRefreshToken(ctx context.Context, tokenHash string) (token model.RefreshToken, found bool, err error)
+	UseRefreshToken(ctx context.Context, tokenHash string, now time.Time) (token model.RefreshToken, found bool, err error)
+	RevokeTokenFamily(ctx context.Context, familyID string, until time.Time) error
+	RevokeToken(ctx context.Context, tokenID string, expiresAt time.Time) error
+	IsTokenRevoked(ctx context.Context, tokenID, familyID string) (bool, error)
+	RevokeUserTokens(ctx context.Context, userID primitive.ObjectID, until time.Time) error
+	SaveUserToken(ctx context.Context, token *model.UserToken) error
+	UseUserToken(ctx context.Context, tokenHash, purpose string, now time.Time) (token model.UserToken, found bool, err error)
+	RecordTokenUse(ctx context.Context, token *model.UserToken) (firstUse bool, err error)
+	IsTokenUsed(ctx context.Context, tokenHash, purpose string) (bool, error)
+	DeleteUserTokens(ctx context.Context, userID primitive.ObjectID, purpose string) error
 
 	// Medicine
 	AddMedicine(ctx context.Context, data *model.Medicine) error
 	GetMedicineByID(ctx context.Context, id primitive.ObjectID) (medicine model.Medicine, found bool, err error)
 	UpdateMedicine(ctx context.Context, id primitive.ObjectID, data *model.Medicine) (found bool, err error)
 	DeleteMedicine(ctx context.Context, id primitive.ObjectID) (found bool, err error)
+	ListMedicines(ctx context.Context, page, limit int) (medicines []model.Medicine, total int64, err error)
 	GetMedicineFilter(ctx context.Context, req *model.MedicineFilter) (medicine model.Medicine, found bool, err error)
 
 	// Medication
 	AddMedication(ctx context.Context, data *model.Medication) error
-	UpdateMedication(ctx context.Context, id primitive.ObjectID, data *model.Medication) (found bool, err error)
+	UpdateMedication(ctx context.Context, id primitive.ObjectID, update *model.MedicationUpdate) (found bool, err error)
 	DeleteMedication(ctx context.Context, id primitive.ObjectID) (found bool, err error)
 	GetMedication(ctx context.Context, id primitive.ObjectID) (medic model.MedicationResponse, found bool, err error)
 	GetPatientsMedications(ctx context.Context, patientId primitive.ObjectID) (medics []model.MedicationResponse, err error)
-	AddPractitionerToMed(ctx context.Context, id primitive.ObjectID, practIds []primitive.ObjectID) (found bool, err error)
-	IncrementDosageTaken(ctx context.Context, medicId primitive.ObjectID) error
+	IncrementDosageCounts(ctx context.Context, medicId primitive.ObjectID, counts map[string]int) error
+	IncrementDosagesTaken(ctx context.Context, medicId primitive.ObjectID, total int) (found bool, err error)
+	PauseMedication(ctx context.Context, id primitive.ObjectID, now time.Time, resumeAt *time.Time) (found bool, err error)
+	ResumeMedication(ctx context.Context, id primitive.ObjectID, startDate, now time.Time) (found bool, err error)
+	DiscontinueMedication(ctx context.Context, id primitive.ObjectID, reason string, now time.Time) (found bool, err error)
+	GetMedicationsToResume(ctx context.Context, now time.Time) (ids []primitive.ObjectID, err error)
+	DecrementStock(ctx context.Context, medicId primitive.ObjectID, units float64) (inventory model.Inventory, found bool, err error)
+	MarkRefillNeeded(ctx context.Context, medicId primitive.ObjectID, now time.Time) (bool, error)
+	RefillStock(ctx context.Context, medicId primitive.ObjectID, quantity float64, now time.Time) (inventory model.Inventory, found bool, err error)
 
 	// Practitioner
 	CreatePractitioner(ctx context.Context, data *model.Practitioner) error
@@ -47,19 +88,94 @@ type StorageRepository interface {
 	GetPractitionersByEmail(ctx context.Context, emails []string) (practs []model.PractitionerResponse, err error)
 	GetPractitionersByIds(ctx context.Context, ids []primitive.ObjectID) (practs []model.PractitionerResponse, err error)
 	GetPractitionerByEmail(ctx context.Context, email string) (pract model.PractitionerResponse, found bool, err error)
-	GetPractitionerMedications(ctx context.Context, practitionerId primitive.ObjectID) (medics []model.MedicationResponse, err error)
+	GetPractitionerMedications(ctx context.Context, practitionerId primitive.ObjectID, now time.Time) (medics []model.MedicationResponse, err error)
+	GetPractitionerRoster(ctx context.Context, filter *model.RosterFilter, now time.Time) (patients []model.RosterPatient, total int64, err error)
+
+	// Access grant
+	CreateAccessGrant(ctx context.Context, grant *model.AccessGrant) error
+	GetOpenAccessGrant(ctx context.Context, patientID, practitionerID, medicationID primitive.ObjectID, now time.Time) (grant model.AccessGrant, found bool, err error)
+	GetAccessGrant(ctx context.Context, id primitive.ObjectID) (grant model.AccessGrantResponse, found bool, err error)
+	GetAccessGrants(ctx context.Context, request *model.AccessGrantFilter, now time.Time) (grants []model.AccessGrantResponse, err error)
+	RespondToAccessGrant(ctx context.Context, id primitive.ObjectID, status string, now time.Time) (found bool, err error)
+	RevokeAccessGrant(ctx context.Context, id primitive.ObjectID, revokedBy string, now time.Time) (found bool, err error)
+	GetProfilePractitionerIDs(ctx context.Context, patientID primitive.ObjectID, now time.Time) (ids []primitive.ObjectID, err error)
+
+	// Prescription
+	CreatePrescription(ctx context.Context, prescription *model.Prescription) error
+	GetPrescription(ctx context.Context, id primitive.ObjectID) (prescription model.PrescriptionResponse, found bool, err error)
+	GetPrescriptions(ctx context.Context, request *model.PrescriptionFilter) (prescriptions []model.PrescriptionResponse, err error)
+	SetPrescriptionStatus(ctx context.Context, id primitive.ObjectID, from, status string, now time.Time) (found bool, err error)
+	SetPrescriptionMedication(ctx context.Context, id, medicationID primitive.ObjectID) error
+
+	// Alert
+	CreateAlertRule(ctx context.Context, rule *model.AlertRule) error
+	GetAlertRule(ctx context.Context, id primitive.ObjectID) (rule model.AlertRule, found bool, err error)
+	GetAlertRules(ctx context.Context, practitionerID primitive.ObjectID) (rules []model.AlertRule, err error)
+	GetEnabledAlertRules(ctx context.Context, filter *model.AlertRuleFilter) (rules []model.AlertRule, err error)
+	UpdateAlertRule(ctx context.Context, rule *model.AlertRule) (found bool, err error)
+	DeleteAlertRule(ctx context.Context, id primitive.ObjectID) (found bool, err error)
+	RaiseAlert(ctx context.Context, alert *model.Alert, since time.Time) (created bool, err error)
+	GetAlert(ctx context.Context, id primitive.ObjectID) (alert model.Alert, found bool, err error)
+	GetAlerts(ctx context.Context, filter *model.AlertFilter) (alerts []model.Alert, total int64, err error)
+	SetAlertStatus(ctx context.Context, id primitive.ObjectID, from []string, status string, now time.Time) (found bool, err error)
+
+	// Adherence
+	GetAdherence(ctx context.Context, filter *model.AdherenceFilter) (report model.AdherenceReport, err error)
+
+	// Caregiver
+	CreateCaregiver(ctx context.Context, data *model.Caregiver) error
+	GetCaregiverByID(ctx context.Context, id primitive.ObjectID) (caregiver model.CaregiverResponse, found bool, err error)
+	GetCaregiverByEmail(ctx context.Context, email string) (caregiver model.CaregiverResponse, found bool, err error)
+	GetCaregiverLink(ctx context.Context, patientID primitive.ObjectID, email string) (link model.CaregiverLink, found bool, err error)
+	SaveCaregiverLink(ctx context.Context, link *model.CaregiverLink) error
+	AcceptCaregiverInvitation(ctx context.Context, tokenHash string, caregiverID primitive.ObjectID, email string, now time.Time) (link model.CaregiverLink, found bool, err error)
+	GetPatientCaregiverLinks(ctx context.Context, patientID primitive.ObjectID) (links []model.CaregiverLinkResponse, err error)
+	GetCaregiverPatientLinks(ctx context.Context, caregiverID primitive.ObjectID) (links []model.CaregiverLinkResponse, err error)
+	UpdateCaregiverLink(ctx context.Context, id, patientID primitive.ObjectID, receiveReminders bool) (found bool, err error)
+	RevokeCaregiverLink(ctx context.Context, id, patientID primitive.ObjectID, now time.Time) (found bool, err error)
+	GetPatientCaregiverIDs(ctx context.Context, patientID primitive.ObjectID) (ids []primitive.ObjectID, err error)
+	GetCaregiversToRemind(ctx context.Context, patientID primitive.ObjectID) (caregivers []model.CaregiverResponse, err error)
 
 	// Dosage
 	SaveDosages(ctx context.Context, data []model.Dosage) error
 	GetPatientDosages(ctx context.Context, request *model.DosageFilter) (dosages []model.DosageResponse, err error)
-	SetStatus(ctx context.Context, dosageId, patientId primitive.ObjectID, status string) (found bool, err error)
+	SetStatus(ctx context.Context, dosageId, patientId primitive.ObjectID, from, status string, recordedBy *model.RecordedBy) (found bool, err error)
+	GetOverdueDosages(ctx context.Context, before time.Time, limit int64) (dosages []model.Dosage, err error)
+	MarkDosageMissed(ctx context.Context, id primitive.ObjectID, now time.Time) (found bool, err error)
 	GetDosage(ctx context.Context, id primitive.ObjectID) (dosage model.DosageResponse, found bool, err error)
 	DeleteDosages(ctx context.Context, medicationId primitive.ObjectID) (int64, error)
+	DeleteActiveDosages(ctx context.Context, medicationId primitive.ObjectID) (int64, error)
+	CountTakenDosages(ctx context.Context, medicationId primitive.ObjectID, from, to time.Time) (int64, error)
+	SnoozeDosage(ctx context.Context, id primitive.ObjectID, snooze *model.Snooze) (found bool, err error)
+	GetNextDosage(ctx context.Context, medicationId primitive.ObjectID, after time.Time) (dosage model.Dosage, found bool, err error)
+	GetActiveDosages(ctx context.Context, medicationId primitive.ObjectID) (dosages []model.Dosage, err error)
+	DeactivateDosages(ctx context.Context, medicationId primitive.ObjectID, after time.Time) (int64, error)
+	DeletePausedDosages(ctx context.Context, medicationId primitive.ObjectID) (int64, error)
+
+	// Dose count
+	SeedDoseCount(ctx context.Context, medicationId primitive.ObjectID, day time.Time, count int64, expiresAt time.Time) error
+	TakeDoseSlot(ctx context.Context, medicationId primitive.ObjectID, day time.Time, max int) (taken bool, err error)
+	ReleaseDoseSlot(ctx context.Context, medicationId primitive.ObjectID, day time.Time) error
 
 	// Task
 	AddTasks(ctx context.Context, tasks []model.Task) (int64, error)
 	UpdateTask(ctx context.Context, taskID primitive.ObjectID, status string) error
 	DeleteTasks(ctx context.Context, taskIDs []primitive.ObjectID) (int64, error)
-	GetLatestTasks(ctx context.Context, startTime time.Time) (tasks []model.LatestTaskResponse, err error)
+	DeleteUndoneTasks(ctx context.Context, medicationId primitive.ObjectID) (int64, error)
+	DeleteUndoneDosageTasks(ctx context.Context, dosageId primitive.ObjectID) (int64, error)
+	DeleteMedicationTasks(ctx context.Context, medicationId primitive.ObjectID) (int64, error)
+	ClaimDueTask(ctx context.Context, owner string, now, notBefore, leaseUntil time.Time) (task model.Task, found bool, err error)
+	RenewTaskLease(ctx context.Context, taskID primitive.ObjectID, owner string, leaseUntil time.Time) (bool, error)
+	CompleteTask(ctx context.Context, taskID primitive.ObjectID, owner string, status string, lastError string) error
+	ReleaseTask(ctx context.Context, taskID primitive.ObjectID, owner string, nextAttemptAt time.Time, lastError string) error
+	ExpireTasks(ctx context.Context, before time.Time) (int64, error)
+	CountTasksByStatus(ctx context.Context) (map[string]int64, error)
+	CountOverdueTasks(ctx context.Context, before time.Time) (int64, error)
+	GetNextTask(ctx context.Context) (task model.Task, found bool, err error)
+
+	// Lock
+	AcquireLock(ctx context.Context, name, owner string, ttl time.Duration) (bool, error)
+	ReleaseLock(ctx context.Context, name, owner string) error
+	GetLock(ctx context.Context, name string) (lock model.Lock, found bool, err error)
 	GetTask(ctx context.Context, taskID primitive.ObjectID) (task model.LatestTaskResponse, found bool, err error)
 }
@@ -0,0 +1,28 @@
+package router
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	log "github.com/sirupsen/logrus"
+	"medbuddy-backend/internal/policy"
+	"medbuddy-backend/pkg/handler/adherence"
+	"medbuddy-backend/pkg/middleware"
+	"medbuddy-backend/pkg/repository/mongo"
+	adherenceService "medbuddy-backend/service/adherence"
+)
+
+func Adherence(r *gin.Engine, validate *validator.Validate, ApiVersion string, logger *log.Logger) *gin.Engine {
+
+	dbRepo := mongo.GetDB()
+	adherenceCtrl := adherence.NewController(validate, logger, adherenceService.NewAdherenceService(dbRepo))
+
+	adherenceUrl := r.Group(fmt.Sprintf("/api/%v", ApiVersion))
+	{
+		adherenceUrl.GET("/patient/adherence", middleware.Authorize(policy.AdherenceRead), adherenceCtrl.GetOwnAdherence)
+		adherenceUrl.GET("/medication/:id/adherence", middleware.Authorize(policy.AdherenceRead), adherenceCtrl.GetMedicationAdherence)
+		adherenceUrl.GET("/practitioner/patients/:id/adherence", middleware.Authorize(policy.AdherenceRead), adherenceCtrl.GetPatientAdherence)
+		adherenceUrl.GET("/practitioner/medications/:id/adherence", middleware.Authorize(policy.AdherenceRead), adherenceCtrl.GetMedicationAdherence)
+	}
+	return r
+}
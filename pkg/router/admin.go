@@ -0,0 +1,37 @@
+package router
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	log "github.com/sirupsen/logrus"
+	"medbuddy-backend/internal/policy"
+	"medbuddy-backend/pkg/handler/admin"
+	"medbuddy-backend/pkg/handler/medicine"
+	"medbuddy-backend/pkg/middleware"
+	"medbuddy-backend/pkg/repository/mongo"
+	adminService "medbuddy-backend/service/admin"
+	medService "medbuddy-backend/service/medicine"
+)
+
+func Admin(r *gin.Engine, validate *validator.Validate, ApiVersion string, logger *log.Logger) *gin.Engine {
+
+	dbRepo := mongo.GetDB()
+	adminCtrl := admin.NewController(validate, logger, adminService.NewAdminService(dbRepo))
+	medicineService := medService.NewMedicineService(dbRepo)
+	medicineCtrl := medicine.NewController(validate, logger, medicineService)
+
+	adminUrl := r.Group(fmt.Sprintf("/api/%v/admin", ApiVersion))
+	{
+		adminUrl.POST("/login", adminCtrl.LoginAdmin)
+		adminUrl.GET("/users", middleware.Authorize(policy.UserManage), adminCtrl.ListUsers)
+		adminUrl.POST("/users/:id/lock", middleware.Authorize(policy.UserManage), adminCtrl.LockUser)
+		adminUrl.POST("/users/:id/unlock", middleware.Authorize(policy.UserManage), adminCtrl.UnlockUser)
+		adminUrl.POST("/users/:id/reset-password", middleware.Authorize(policy.UserManage), adminCtrl.ForcePasswordReset)
+		adminUrl.GET("/medicines", middleware.Authorize(policy.MedicineRead), medicineCtrl.ListMedicines)
+		adminUrl.PUT("/medicines/:id", middleware.Authorize(policy.MedicineUpdate), medicineCtrl.UpdateMedicine)
+		adminUrl.DELETE("/medicines/:id", middleware.Authorize(policy.MedicineDelete), medicineCtrl.DeleteMedicine)
+		adminUrl.GET("/jobs", middleware.Authorize(policy.JobRead), adminCtrl.GetJobStatus)
+	}
+	return r
+}
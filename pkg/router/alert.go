@@ -0,0 +1,31 @@
+package router
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	log "github.com/sirupsen/logrus"
+	"medbuddy-backend/internal/policy"
+	"medbuddy-backend/pkg/handler/alert"
+	"medbuddy-backend/pkg/middleware"
+	"medbuddy-backend/pkg/repository/mongo"
+	alertService "medbuddy-backend/service/alert"
+)
+
+func Alert(r *gin.Engine, validate *validator.Validate, ApiVersion string, logger *log.Logger) *gin.Engine {
+
+	dbRepo := mongo.GetDB()
+	alertCtrl := alert.NewController(validate, logger, alertService.NewAlertService(dbRepo))
+
+	alertUrl := r.Group(fmt.Sprintf("/api/%v", ApiVersion))
+	{
+		alertUrl.POST("/practitioner/alert-rules", middleware.Authorize(policy.AlertRuleManage), alertCtrl.CreateAlertRule)
+		alertUrl.GET("/practitioner/alert-rules", middleware.Authorize(policy.AlertRuleManage), alertCtrl.GetAlertRules)
+		alertUrl.PATCH("/practitioner/alert-rules/:id", middleware.Authorize(policy.AlertRuleManage), alertCtrl.UpdateAlertRule)
+		alertUrl.DELETE("/practitioner/alert-rules/:id", middleware.Authorize(policy.AlertRuleManage), alertCtrl.DeleteAlertRule)
+		alertUrl.GET("/practitioner/alerts", middleware.Authorize(policy.AlertList), alertCtrl.GetAlerts)
+		alertUrl.POST("/practitioner/alerts/:id/acknowledge", middleware.Authorize(policy.AlertUpdate), alertCtrl.AcknowledgeAlert)
+		alertUrl.POST("/practitioner/alerts/:id/resolve", middleware.Authorize(policy.AlertUpdate), alertCtrl.ResolveAlert)
+	}
+	return r
+}
@@ -5,9 +5,15 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	log "github.com/sirupsen/logrus"
+	"medbuddy-backend/internal/policy"
+	"medbuddy-backend/pkg/handler/auth"
+	"medbuddy-backend/pkg/handler/caregiver"
 	"medbuddy-backend/pkg/handler/patient"
 	"medbuddy-backend/pkg/handler/practitioner"
+	"medbuddy-backend/pkg/middleware"
 	"medbuddy-backend/pkg/repository/mongo"
+	authService "medbuddy-backend/service/auth"
+	careService "medbuddy-backend/service/caregiver"
 	patService "medbuddy-backend/service/patient"
 	practService "medbuddy-backend/service/practitioner"
 )
@@ -21,10 +27,24 @@ func Auth(r *gin.Engine, validate *validator.Validate, ApiVersion string, logger
 	practitionerService := practService.NewPractitionerService(dbRepo)
 	practitionerCtrl := practitioner.Controller{Validate: validate, Logger: logger, PractitionerService: practitionerService}
 
+	caregiverCtrl := caregiver.NewController(validate, logger, careService.NewCaregiverService(dbRepo))
+
+	authCtrl := auth.NewController(validate, logger, authService.NewAuthService(dbRepo))
+
 	authUrl := r.Group(fmt.Sprintf("/api/%v", ApiVersion))
 	{
 		authUrl.POST("/practitioner/login", practitionerCtrl.LoginPractitioner)
 		authUrl.POST("/patient/login", patientCtrl.LoginPatient)
+		authUrl.POST("/caregiver/login", caregiverCtrl.LoginCaregiver)
+		authUrl.POST("/auth/refresh", authCtrl.RefreshToken)
+		authUrl.POST("/auth/logout", middleware.Authorize(policy.AccountManage), authCtrl.Logout)
+		authUrl.POST("/patient/forgot-password", authCtrl.ForgotPatientPassword)
+		authUrl.POST("/practitioner/forgot-password", authCtrl.ForgotPractitionerPassword)
+		authUrl.POST("/caregiver/forgot-password", authCtrl.ForgotCaregiverPassword)
+		authUrl.POST("/reset-password", authCtrl.ResetPassword)
+		authUrl.POST("/verify-email", authCtrl.VerifyEmail)
+		authUrl.POST("/verify-email/resend", middleware.Authorize(policy.AccountManage), authCtrl.ResendVerificationEmail)
+		authUrl.POST("/unlock-account", authCtrl.UnlockAccount)
 	}
 	return r
 }
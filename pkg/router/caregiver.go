@@ -0,0 +1,36 @@
+package router
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	log "github.com/sirupsen/logrus"
+	"medbuddy-backend/internal/policy"
+	"medbuddy-backend/pkg/handler/caregiver"
+	"medbuddy-backend/pkg/middleware"
+	"medbuddy-backend/pkg/repository/mongo"
+	careService "medbuddy-backend/service/caregiver"
+)
+
+func Caregiver(r *gin.Engine, validate *validator.Validate, ApiVersion string, logger *log.Logger) *gin.Engine {
+
+	dbRepo := mongo.GetDB()
+	caregiverService := careService.NewCaregiverService(dbRepo)
+	caregiverCtrl := caregiver.NewController(validate, logger, caregiverService)
+
+	caregiverUrl := r.Group(fmt.Sprintf("/api/%v", ApiVersion))
+	{
+		caregiverUrl.POST("/caregiver", caregiverCtrl.CreateCaregiver)
+		caregiverUrl.GET("/caregiver", middleware.Authorize(policy.CaregiverRead), caregiverCtrl.GetCaregiver)
+		caregiverUrl.POST("/caregiver/invitations/accept", middleware.Authorize(policy.CaregiverRead), caregiverCtrl.AcceptInvitation)
+		caregiverUrl.GET("/caregiver/patients", middleware.Authorize(policy.CaregiverRead), caregiverCtrl.GetCaregiverPatients)
+		caregiverUrl.GET("/caregiver/patients/:id/medications", middleware.Authorize(policy.MedicationList), caregiverCtrl.GetPatientMedications)
+		caregiverUrl.GET("/caregiver/patients/:id/dosages", middleware.Authorize(policy.DosageList), caregiverCtrl.GetPatientDosages)
+
+		caregiverUrl.POST("/patient/caregivers", middleware.Authorize(policy.CaregiverManage), caregiverCtrl.InviteCaregiver)
+		caregiverUrl.GET("/patient/caregivers", middleware.Authorize(policy.CaregiverManage), caregiverCtrl.GetPatientCaregivers)
+		caregiverUrl.PATCH("/patient/caregivers/:id", middleware.Authorize(policy.CaregiverManage), caregiverCtrl.UpdateCaregiverLink)
+		caregiverUrl.DELETE("/patient/caregivers/:id", middleware.Authorize(policy.CaregiverManage), caregiverCtrl.RevokeCaregiver)
+	}
+	return r
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	log "github.com/sirupsen/logrus"
+	"medbuddy-backend/internal/policy"
 	"medbuddy-backend/pkg/handler/dosage"
 	"medbuddy-backend/pkg/middleware"
 	"medbuddy-backend/pkg/repository/mongo"
@@ -19,8 +20,13 @@ func Dosage(r *gin.Engine, validate *validator.Validate, ApiVersion string, logg
 
 	dosageUrl := r.Group(fmt.Sprintf("/api/%v", ApiVersion))
 	{
-		dosageUrl.PATCH("/dosage-status/:id", middleware.Patient(), dosageCtrl.UpdateDosageStatus)
-		dosageUrl.GET("/dosage/:id", middleware.Patient(), dosageCtrl.GetDosage)
+		dosageUrl.PATCH("/dosage-status/:id", middleware.Authorize(policy.DosageSetStatus), dosageCtrl.UpdateDosageStatus)
+		dosageUrl.GET("/dosage/:id", middleware.Authorize(policy.DosageRead), dosageCtrl.GetDosage)
+		dosageUrl.POST("/dosage/:id/snooze", middleware.Authorize(policy.DosageSnooze), dosageCtrl.SnoozeDosage)
+		// one-click links of reminder emails, the token in the link authorizes them
+		dosageUrl.GET("/dosage-actions/:token", dosageCtrl.GetDosageAction)
+		dosageUrl.POST("/dosage-actions/:token", dosageCtrl.ApplyDosageAction)
+		dosageUrl.POST("/medication/:id/doses", middleware.Authorize(policy.DosageRecord), dosageCtrl.RecordDose)
 	}
 	return r
 }
@@ -0,0 +1,29 @@
+package router
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	log "github.com/sirupsen/logrus"
+	"medbuddy-backend/internal/policy"
+	"medbuddy-backend/pkg/handler/grant"
+	"medbuddy-backend/pkg/middleware"
+	"medbuddy-backend/pkg/repository/mongo"
+	grantService "medbuddy-backend/service/grant"
+)
+
+func AccessGrant(r *gin.Engine, validate *validator.Validate, ApiVersion string, logger *log.Logger) *gin.Engine {
+
+	dbRepo := mongo.GetDB()
+	grantCtrl := grant.NewController(validate, logger, grantService.NewGrantService(dbRepo))
+
+	grantUrl := r.Group(fmt.Sprintf("/api/%v", ApiVersion))
+	{
+		grantUrl.POST("/patient/access-grants", middleware.Authorize(policy.MedicationShare), grantCtrl.ShareWithPractitioners)
+		grantUrl.GET("/access-grants", middleware.Authorize(policy.AccessGrantList), grantCtrl.GetAccessGrants)
+		grantUrl.POST("/access-grants/:id/accept", middleware.Authorize(policy.AccessGrantRespond), grantCtrl.AcceptAccessGrant)
+		grantUrl.POST("/access-grants/:id/decline", middleware.Authorize(policy.AccessGrantRespond), grantCtrl.DeclineAccessGrant)
+		grantUrl.DELETE("/access-grants/:id", middleware.Authorize(policy.AccessGrantRevoke), grantCtrl.RevokeAccessGrant)
+	}
+	return r
+}
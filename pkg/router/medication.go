@@ -5,6 +5,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	log "github.com/sirupsen/logrus"
+	"medbuddy-backend/internal/policy"
 	"medbuddy-backend/pkg/handler/dosage"
 	"medbuddy-backend/pkg/handler/medication"
 	"medbuddy-backend/pkg/middleware"
@@ -23,12 +24,17 @@ func Medication(r *gin.Engine, validate *validator.Validate, ApiVersion string,
 
 	medicationUrl := r.Group(fmt.Sprintf("/api/%v", ApiVersion))
 	{
-		medicationUrl.POST("/medication", middleware.Patient(), medicationCtrl.AddMedication)
-		medicationUrl.GET("/medication/:id", middleware.Patient(), medicationCtrl.GetMedication)
-		medicationUrl.GET("/medication/dosages", middleware.Patient(), dosageCtrl.GetMedicationDosages)
-		medicationUrl.GET("/medication", middleware.Patient(), medicationCtrl.GetPatientMedications)
-		medicationUrl.DELETE("/medication/:id", middleware.Patient(), medicationCtrl.DeleteMedication)
-		medicationUrl.PATCH("/medication/:medication-id/practitioners", middleware.Patient(), medicationCtrl.AddPractitionerToMeds)
+		medicationUrl.POST("/medication", middleware.Authorize(policy.MedicationCreate), medicationCtrl.AddMedication)
+		medicationUrl.GET("/medication/:id", middleware.Authorize(policy.MedicationRead), medicationCtrl.GetMedication)
+		medicationUrl.GET("/medication/dosages", middleware.Authorize(policy.DosageList), dosageCtrl.GetMedicationDosages)
+		medicationUrl.GET("/medication", middleware.Authorize(policy.MedicationList), medicationCtrl.GetPatientMedications)
+		medicationUrl.PATCH("/medication/:id", middleware.Authorize(policy.MedicationUpdate), medicationCtrl.UpdateMedication)
+		medicationUrl.DELETE("/medication/:id", middleware.Authorize(policy.MedicationDelete), medicationCtrl.DeleteMedication)
+		medicationUrl.PATCH("/medication/:id/practitioners", middleware.Authorize(policy.MedicationShare), medicationCtrl.AddPractitionerToMeds)
+		medicationUrl.POST("/medication/:id/refills", middleware.Authorize(policy.MedicationRefill), medicationCtrl.RefillMedication)
+		medicationUrl.POST("/medication/:id/pause", middleware.Authorize(policy.MedicationUpdate), medicationCtrl.PauseMedication)
+		medicationUrl.POST("/medication/:id/resume", middleware.Authorize(policy.MedicationUpdate), medicationCtrl.ResumeMedication)
+		medicationUrl.POST("/medication/:id/discontinue", middleware.Authorize(policy.MedicationUpdate), medicationCtrl.DiscontinueMedication)
 	}
 	return r
 }
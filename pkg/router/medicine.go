@@ -5,6 +5,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	log "github.com/sirupsen/logrus"
+	"medbuddy-backend/internal/policy"
 	"medbuddy-backend/pkg/handler/medicine"
 	"medbuddy-backend/pkg/middleware"
 	"medbuddy-backend/pkg/repository/mongo"
@@ -19,12 +20,11 @@ func Medicine(r *gin.Engine, validate *validator.Validate, ApiVersion string, lo
 
 	medicineUrl := r.Group(fmt.Sprintf("/api/%v", ApiVersion))
 	{
-		medicineUrl.POST("/medicine", middleware.Generic(), medicineCtrl.AddMedicine)
-		medicineUrl.GET("/medicine/:id", middleware.Generic(), medicineCtrl.GetMedicine)
-		medicineUrl.GET("/medicine", middleware.Generic(), medicineCtrl.GetMedicineFilter)
-		//medicineUrl.GET("/list_medicine", middleware.Generic(), medicineCtrl.ListMedicines)
-		medicineUrl.PUT("/medicine/:id", middleware.Generic(), medicineCtrl.UpdateMedicine)
-		medicineUrl.DELETE("/medicine/:id", middleware.Generic(), medicineCtrl.DeleteMedicine)
+		medicineUrl.POST("/medicine", middleware.Authorize(policy.MedicineCreate), medicineCtrl.AddMedicine)
+		medicineUrl.GET("/medicine/:id", middleware.Authorize(policy.MedicineRead), medicineCtrl.GetMedicine)
+		medicineUrl.GET("/medicine", middleware.Authorize(policy.MedicineRead), medicineCtrl.GetMedicineFilter)
+		//medicineUrl.GET("/list_medicine", middleware.Authorize(policy.MedicineRead), medicineCtrl.ListMedicines)
+		// The catalogue is shared, it is moderated through the admin routes
 	}
 	return r
 }
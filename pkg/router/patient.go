@@ -5,6 +5,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	log "github.com/sirupsen/logrus"
+	"medbuddy-backend/internal/policy"
 	"medbuddy-backend/pkg/handler/dosage"
 	"medbuddy-backend/pkg/handler/patient"
 	"medbuddy-backend/pkg/middleware"
@@ -24,8 +25,10 @@ func Patient(r *gin.Engine, validate *validator.Validate, ApiVersion string, log
 	patientUrl := r.Group(fmt.Sprintf("/api/%v", ApiVersion))
 	{
 		patientUrl.POST("/patient", patientCtrl.CreatePatient)
-		patientUrl.GET("/patient", middleware.Patient(), patientCtrl.GetPatient)
-		patientUrl.GET("/patient/dosages", middleware.Patient(), dosageCtrl.GetPatientDosages)
+		patientUrl.GET("/patient", middleware.Authorize(policy.PatientRead), patientCtrl.GetPatient)
+		patientUrl.GET("/patient/dosages", middleware.Authorize(policy.DosageList), dosageCtrl.GetPatientDosages)
+		patientUrl.PATCH("/patient/notification-preferences", middleware.Authorize(policy.PatientUpdate), patientCtrl.UpdateNotificationPreferences)
+		patientUrl.PATCH("/patient/time-zone", middleware.Authorize(policy.PatientUpdate), patientCtrl.UpdateTimeZone)
 		//patientUrl.GET("/patient/:id", patientCtrl.GetPatientByID)
 		//patientUrl.PATCH("/patient", patientCtrl.UpdatePatient)
 	}
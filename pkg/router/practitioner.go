@@ -5,6 +5,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	log "github.com/sirupsen/logrus"
+	"medbuddy-backend/internal/policy"
 	"medbuddy-backend/pkg/handler/practitioner"
 	"medbuddy-backend/pkg/middleware"
 	"medbuddy-backend/pkg/repository/mongo"
@@ -20,10 +21,11 @@ func Practitioner(r *gin.Engine, validate *validator.Validate, ApiVersion string
 	practitionerUrl := r.Group(fmt.Sprintf("/api/%v", ApiVersion))
 	{
 		practitionerUrl.POST("/practitioner", practitionerCtrl.CreatePractitioner)
-		practitionerUrl.GET("/practitioner", middleware.Practitioner(), practitionerCtrl.GetPractitioner)
-		practitionerUrl.GET("/practitioner/email/:email", middleware.Practitioner(), practitionerCtrl.GetPractitionerByEmail)
-		practitionerUrl.GET("/practitioner/ids", middleware.Practitioner(), practitionerCtrl.GetPractitionersByIds)
-		practitionerUrl.GET("/practitioner/medications", middleware.Practitioner(), practitionerCtrl.GetPractitionerMedications)
+		practitionerUrl.GET("/practitioner", middleware.Authorize(policy.PractitionerRead), practitionerCtrl.GetPractitioner)
+		practitionerUrl.GET("/practitioner/email/:email", middleware.Authorize(policy.PractitionerRead), practitionerCtrl.GetPractitionerByEmail)
+		practitionerUrl.GET("/practitioner/ids", middleware.Authorize(policy.PractitionerRead), practitionerCtrl.GetPractitionersByIds)
+		practitionerUrl.GET("/practitioner/medications", middleware.Authorize(policy.PractitionerMedicationsList), practitionerCtrl.GetPractitionerMedications)
+		practitionerUrl.GET("/practitioner/patients", middleware.Authorize(policy.PractitionerPatientsList), practitionerCtrl.GetPatientRoster)
 	}
 	return r
 }
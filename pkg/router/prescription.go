@@ -0,0 +1,29 @@
+package router
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	log "github.com/sirupsen/logrus"
+	"medbuddy-backend/internal/policy"
+	"medbuddy-backend/pkg/handler/prescription"
+	"medbuddy-backend/pkg/middleware"
+	"medbuddy-backend/pkg/repository/mongo"
+	prescriptionService "medbuddy-backend/service/prescription"
+)
+
+func Prescription(r *gin.Engine, validate *validator.Validate, ApiVersion string, logger *log.Logger) *gin.Engine {
+
+	dbRepo := mongo.GetDB()
+	prescriptionCtrl := prescription.NewController(validate, logger, prescriptionService.NewPrescriptionService(dbRepo))
+
+	prescriptionUrl := r.Group(fmt.Sprintf("/api/%v", ApiVersion))
+	{
+		prescriptionUrl.POST("/practitioner/prescriptions", middleware.Authorize(policy.PrescriptionCreate), prescriptionCtrl.Prescribe)
+		prescriptionUrl.GET("/prescriptions", middleware.Authorize(policy.PrescriptionList), prescriptionCtrl.GetPrescriptions)
+		prescriptionUrl.POST("/prescriptions/:id/accept", middleware.Authorize(policy.PrescriptionRespond), prescriptionCtrl.AcceptPrescription)
+		prescriptionUrl.POST("/prescriptions/:id/decline", middleware.Authorize(policy.PrescriptionRespond), prescriptionCtrl.DeclinePrescription)
+		prescriptionUrl.DELETE("/prescriptions/:id", middleware.Authorize(policy.PrescriptionCancel), prescriptionCtrl.CancelPrescription)
+	}
+	return r
+}
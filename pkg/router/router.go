@@ -28,6 +28,12 @@ func Setup(validate *validator.Validate, logger *log.Logger) *gin.Engine {
 	Medication(r, validate, ApiVersion, logger)
 	Dosage(r, validate, ApiVersion, logger)
 	Practitioner(r, validate, ApiVersion, logger)
+	Admin(r, validate, ApiVersion, logger)
+	Caregiver(r, validate, ApiVersion, logger)
+	AccessGrant(r, validate, ApiVersion, logger)
+	Prescription(r, validate, ApiVersion, logger)
+	Adherence(r, validate, ApiVersion, logger)
+	Alert(r, validate, ApiVersion, logger)
 
 	r.NoRoute(func(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{
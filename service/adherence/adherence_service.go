@@ -0,0 +1,206 @@
+package adherence
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"math"
+	"medbuddy-backend/internal/constant"
+	"medbuddy-backend/internal/errors"
+	"medbuddy-backend/internal/model"
+	"medbuddy-backend/internal/policy"
+	"medbuddy-backend/pkg/repository/storage"
+	"medbuddy-backend/utility"
+	"time"
+)
+
+type AdherenceService interface {
+	GetPatientAdherence(uInfo *model.ContextInfo, patientId string, query *model.AdherenceQuery) (model.AdherenceReport, errors.InternalError)
+	GetMedicationAdherence(uInfo *model.ContextInfo, medicationId string, query *model.AdherenceQuery) (model.AdherenceReport, errors.InternalError)
+}
+
+type adherenceService struct {
+	dbRepo storage.StorageRepository
+}
+
+func NewAdherenceService(dbRepo storage.StorageRepository) AdherenceService {
+	return &adherenceService{dbRepo: dbRepo}
+}
+
+var (
+	logger = utility.NewLogger()
+)
+
+// GetPatientAdherence reports on all the medications of a patient. Practitioners need access to
+// the whole profile of the patient.
+func (a *adherenceService) GetPatientAdherence(uInfo *model.ContextInfo, patientId string, query *model.AdherenceQuery) (model.AdherenceReport, errors.InternalError) {
+	ctx := context.Background()
+
+	patientID, err := primitive.ObjectIDFromHex(patientId)
+	if err != nil {
+		return model.AdherenceReport{}, errors.BadRequestError("invalid patient id")
+	}
+
+	practitionerIDs, err := a.dbRepo.GetProfilePractitionerIDs(ctx, patientID, time.Now())
+	if err != nil {
+		logger.Error("Error fetching patient's practitioners, error: ", err.Error())
+		return model.AdherenceReport{}, errors.InternalServerError
+	}
+
+	if err := policy.Authorize(uInfo, policy.AdherenceRead, policy.Patient(patientID, nil, practitionerIDs)); err != nil {
+		return model.AdherenceReport{}, err
+	}
+
+	patient, _, err := a.dbRepo.GetPatientByID(ctx, patientID)
+	if err != nil {
+		logger.Error("Error fetching patient by id, error: ", err.Error())
+		return model.AdherenceReport{}, errors.InternalServerError
+	}
+
+	filter, iErr := parseQuery(query, patient.TimeZone)
+	if iErr != nil {
+		return model.AdherenceReport{}, iErr
+	}
+	filter.PatientID = patientID
+
+	return a.report(ctx, filter)
+}
+
+func (a *adherenceService) GetMedicationAdherence(uInfo *model.ContextInfo, medicationId string, query *model.AdherenceQuery) (model.AdherenceReport, errors.InternalError) {
+	ctx := context.Background()
+
+	medId, err := primitive.ObjectIDFromHex(medicationId)
+	if err != nil {
+		return model.AdherenceReport{}, errors.BadRequestError("invalid medication id")
+	}
+
+	medication, found, err := a.dbRepo.GetMedication(ctx, medId)
+	if err != nil {
+		logger.Error("Error fetching medication, error: ", err.Error())
+		return model.AdherenceReport{}, errors.InternalServerError
+	}
+
+	if !found {
+		return model.AdherenceReport{}, errors.ResourceNotFoundError("medication not found")
+	}
+
+	if err := policy.Authorize(uInfo, policy.AdherenceRead, policy.Medication(&medication)); err != nil {
+		return model.AdherenceReport{}, err
+	}
+
+	filter, iErr := parseQuery(query, medication.TimeZone)
+	if iErr != nil {
+		return model.AdherenceReport{}, iErr
+	}
+	filter.PatientID = medication.PatientID
+	filter.MedicationID = medId
+
+	return a.report(ctx, filter)
+}
+
+func (a *adherenceService) report(ctx context.Context, filter *model.AdherenceFilter) (model.AdherenceReport, errors.InternalError) {
+	report, err := a.dbRepo.GetAdherence(ctx, filter)
+	if err != nil {
+		logger.Error("Error aggregating adherence, error: ", err.Error())
+		return model.AdherenceReport{}, errors.InternalServerError
+	}
+
+	report.PatientID = filter.PatientID
+	if !filter.MedicationID.IsZero() {
+		report.MedicationID = &filter.MedicationID
+	}
+	report.From = filter.From
+	report.To = filter.To
+	report.Interval = filter.Interval
+	report.OnTimeWindowMinutes = int(filter.OnTimeWindow.Minutes())
+
+	setRates(&report.Summary)
+	for i := range report.Series {
+		setRates(&report.Series[i].AdherenceStats)
+	}
+
+	return report, nil
+}
+
+// parseQuery validates the query and fills in the defaults. The period ends now at the latest, so
+// that dosages that are not due yet are left out. Days are the ones of the time zone, or of UTC
+// when it is not set.
+func parseQuery(query *model.AdherenceQuery, timeZone string) (*model.AdherenceFilter, errors.InternalError) {
+	loc := time.UTC
+	if timeZone != "" {
+		var err error
+		if loc, err = utility.LoadTimeZone(timeZone); err != nil {
+			logger.Error("Error loading time zone of adherence report, error: ", err.Error())
+			return nil, errors.InternalServerError
+		}
+	}
+
+	now := time.Now()
+	filter := &model.AdherenceFilter{
+		Interval:     constant.AdherenceIntervalDay,
+		OnTimeWindow: constant.DefaultOnTimeWindow,
+		To:           now,
+		TimeZone:     timeZone,
+	}
+
+	switch query.Interval {
+	case "":
+	case constant.AdherenceIntervalDay, constant.AdherenceIntervalWeek:
+		filter.Interval = query.Interval
+	default:
+		return nil, errors.BadRequestError(fmt.Sprintf("interval must be %s or %s", constant.AdherenceIntervalDay, constant.AdherenceIntervalWeek))
+	}
+
+	if query.OnTimeMinutes < 0 || query.OnTimeMinutes > 24*60 {
+		return nil, errors.BadRequestError(fmt.Sprintf("on_time_minutes must be between 1 and 1440, or 0 for the default of %d", int(constant.DefaultOnTimeWindow.Minutes())))
+	}
+	if query.OnTimeMinutes > 0 {
+		filter.OnTimeWindow = time.Duration(query.OnTimeMinutes) * time.Minute
+	}
+
+	if query.To != "" {
+		to, err := utility.FormatTime(query.To)
+		if err != nil {
+			return nil, errors.BadRequestError(fmt.Sprint("to: ", err.Error()))
+		}
+
+		// the day given is included
+		if to = utility.DateIn(to, loc).AddDate(0, 0, 1); to.Before(now) {
+			filter.To = to
+		}
+	}
+
+	filter.From = filter.To.Add(-constant.DefaultAdherencePeriod)
+	if query.From != "" {
+		from, err := utility.FormatTime(query.From)
+		if err != nil {
+			return nil, errors.BadRequestError(fmt.Sprint("from: ", err.Error()))
+		}
+		filter.From = utility.DateIn(from, loc)
+	}
+
+	if !filter.From.Before(filter.To) {
+		return nil, errors.BadRequestError("from must be before to")
+	}
+
+	if filter.To.Sub(filter.From) > constant.MaxAdherencePeriod {
+		return nil, errors.BadRequestError("the period cannot be longer than a year")
+	}
+
+	return filter, nil
+}
+
+// setRates computes the percentages of stats from its counts, rounded to one decimal
+func setRates(stats *model.AdherenceStats) {
+	if due := stats.Taken + stats.Skipped + stats.Missed; due > 0 {
+		stats.AdherenceRate = percentage(stats.Taken, due)
+	}
+
+	if stats.Taken > 0 {
+		stats.OnTimeRate = percentage(stats.OnTime, stats.Taken)
+	}
+}
+
+func percentage(n, total int) float64 {
+	return math.Round(float64(n)*1000/float64(total)) / 10
+}
@@ -0,0 +1,200 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"medbuddy-backend/internal/config"
+	"medbuddy-backend/internal/constant"
+	"medbuddy-backend/internal/errors"
+	"medbuddy-backend/internal/model"
+	"medbuddy-backend/pkg/repository/storage"
+	"medbuddy-backend/service/auth"
+	"medbuddy-backend/service/jobs"
+	"medbuddy-backend/utility"
+	"time"
+)
+
+type AdminService interface {
+	BootstrapAdmin() error
+	LoginAdmin(data *model.UserLogin) (model.AdminResponse, errors.InternalError)
+	ListUsers(filter *model.UserFilter) (model.UserListResponse, errors.InternalError)
+	SetUserLock(userID string, locked bool) errors.InternalError
+	ForcePasswordReset(userID string) errors.InternalError
+	GetJobStatus() (model.JobStatus, errors.InternalError)
+}
+
+type adminService struct {
+	dbRepo      storage.StorageRepository
+	authService auth.AuthService
+}
+
+func NewAdminService(dbRepo storage.StorageRepository) AdminService {
+	return &adminService{dbRepo: dbRepo, authService: auth.NewAuthService(dbRepo)}
+}
+
+var (
+	logger = utility.NewLogger()
+)
+
+// BootstrapAdmin creates the first administrator from ADMIN_EMAIL and ADMIN_PASSWORD. Nothing is
+// done once an administrator exists, further ones are not created this way.
+func (a *adminService) BootstrapAdmin() error {
+	ctx := context.Background()
+
+	conf := config.GetConfig()
+	if conf.AdminEmail == "" || conf.AdminPassword == "" {
+		return nil
+	}
+
+	if len(conf.AdminPassword) < 8 {
+		return fmt.Errorf("ADMIN_PASSWORD must be at least 8 characters")
+	}
+
+	hashedPassword, salt, err := utility.HashPassword(conf.AdminPassword)
+	if err != nil {
+		return err
+	}
+
+	user := model.User{
+		ID:        primitive.NewObjectID(),
+		Firstname: "MedBuddy",
+		Lastname:  "Admin",
+		Email:     conf.AdminEmail,
+		Role:      constant.Roles[constant.Admin],
+		Verified:  true,
+		Salt:      salt,
+		Password:  hashedPassword,
+		CreatedAt: utility.ReturnCurrentTime(),
+		UpdatedAt: utility.ReturnCurrentTime(),
+	}
+
+	created, err := a.dbRepo.CreateFirstUserWithRole(ctx, &user)
+	if err != nil {
+		return err
+	}
+
+	if created {
+		logger.Infof("Created administrator '%s'", user.Email)
+	}
+
+	return nil
+}
+
+func (a *adminService) LoginAdmin(data *model.UserLogin) (model.AdminResponse, errors.InternalError) {
+	ctx := context.Background()
+
+	if err := a.authService.CheckLogin(data); err != nil {
+		return model.AdminResponse{}, err
+	}
+
+	user, found, err := a.dbRepo.GetUserByEmail(ctx, data.Email)
+	if err != nil {
+		logger.Error("Error fetching user by email, error: ", err.Error())
+		return model.AdminResponse{}, errors.InternalServerError
+	}
+
+	// Unknown emails and wrong passwords get the same response
+	if !found || user.Role != constant.Roles[constant.Admin] {
+		return model.AdminResponse{}, a.authService.LoginFailed(data, nil, "")
+	}
+
+	if !utility.PasswordIsValid(data.Password, user.Salt, user.Password) {
+		return model.AdminResponse{}, a.authService.LoginFailed(data, &user, user.Firstname+" "+user.Lastname)
+	}
+
+	// Ensure that user is not locked
+	if user.IsLocked {
+		return model.AdminResponse{}, errors.ForbiddenError("cannot login, user is currently blocked")
+	}
+
+	if user.MustReset {
+		return model.AdminResponse{}, errors.ForbiddenError("password reset required, check your email for a reset link")
+	}
+
+	a.authService.LoginSucceeded(data)
+
+	// Administrators have no profile, their tokens are issued for the user itself
+	tokens, tErr := a.authService.IssueTokens(user.ID.Hex(), &user, "")
+	if tErr != nil {
+		return model.AdminResponse{}, tErr
+	}
+
+	// Omit password and salt from response
+	user.Password = ""
+	user.Salt = ""
+
+	return model.AdminResponse{User: user, Token: tokens.Token, RefreshToken: tokens.RefreshToken}, nil
+}
+
+func (a *adminService) ListUsers(filter *model.UserFilter) (model.UserListResponse, errors.InternalError) {
+	ctx := context.Background()
+
+	users, total, err := a.dbRepo.ListUsers(ctx, filter)
+	if err != nil {
+		logger.Error("Error listing users, error: ", err.Error())
+		return model.UserListResponse{}, errors.InternalServerError
+	}
+
+	// Omit passwords and salts from response
+	for i := range users {
+		users[i].Password = ""
+		users[i].Salt = ""
+	}
+
+	if users == nil {
+		users = []model.User{}
+	}
+
+	return model.UserListResponse{Users: users, Total: total, Page: filter.Page, Limit: filter.Limit}, nil
+}
+
+func (a *adminService) SetUserLock(userID string, locked bool) errors.InternalError {
+	return a.authService.SetUserLock(userID, locked)
+}
+
+func (a *adminService) ForcePasswordReset(userID string) errors.InternalError {
+	return a.authService.ForcePasswordReset(userID)
+}
+
+func (a *adminService) GetJobStatus() (model.JobStatus, errors.InternalError) {
+	ctx := context.Background()
+
+	counts, err := a.dbRepo.CountTasksByStatus(ctx)
+	if err != nil {
+		logger.Error("Error counting tasks by status, error: ", err.Error())
+		return model.JobStatus{}, errors.InternalServerError
+	}
+
+	// tasks are picked up within a poll interval of being due, allow for a slow run
+	pollInterval := jobs.PollInterval()
+	overdue, err := a.dbRepo.CountOverdueTasks(ctx, time.Now().Add(-2*pollInterval))
+	if err != nil {
+		logger.Error("Error counting overdue tasks, error: ", err.Error())
+		return model.JobStatus{}, errors.InternalServerError
+	}
+
+	status := model.JobStatus{Tasks: counts, OverdueTasks: overdue, PollInterval: pollInterval.String()}
+
+	next, found, err := a.dbRepo.GetNextTask(ctx)
+	if err != nil {
+		logger.Error("Error fetching next task, error: ", err.Error())
+		return model.JobStatus{}, errors.InternalServerError
+	}
+
+	if found {
+		status.NextTaskAt = &next.Time
+	}
+
+	lock, found, err := a.dbRepo.GetLock(ctx, constant.ReminderMaintenanceLock)
+	if err != nil {
+		logger.Error("Error fetching job lock, error: ", err.Error())
+		return model.JobStatus{}, errors.InternalServerError
+	}
+
+	if found && lock.ExpiresAt.After(time.Now()) {
+		status.MaintenanceLock = &lock
+	}
+
+	return status, nil
+}
@@ -0,0 +1,258 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"math"
+	"medbuddy-backend/internal/constant"
+	"medbuddy-backend/internal/errors"
+	"medbuddy-backend/internal/model"
+	"medbuddy-backend/internal/policy"
+	"medbuddy-backend/pkg/repository/storage"
+	"medbuddy-backend/utility"
+	"time"
+)
+
+type AlertService interface {
+	CreateAlertRule(uInfo *model.ContextInfo, data *model.AlertRuleRequest) (model.AlertRule, errors.InternalError)
+	GetAlertRules(uInfo *model.ContextInfo) ([]model.AlertRule, errors.InternalError)
+	UpdateAlertRule(uInfo *model.ContextInfo, id string, data *model.UpdateAlertRuleRequest) (model.AlertRule, errors.InternalError)
+	DeleteAlertRule(uInfo *model.ContextInfo, id string) errors.InternalError
+	GetAlerts(uInfo *model.ContextInfo, filter *model.AlertFilter) (model.AlertListResponse, errors.InternalError)
+	AcknowledgeAlert(uInfo *model.ContextInfo, id string) errors.InternalError
+	ResolveAlert(uInfo *model.ContextInfo, id string) errors.InternalError
+}
+
+type alertService struct {
+	dbRepo storage.StorageRepository
+}
+
+func NewAlertService(dbRepo storage.StorageRepository) AlertService {
+	return &alertService{dbRepo: dbRepo}
+}
+
+var (
+	logger = utility.NewLogger()
+)
+
+func (a *alertService) CreateAlertRule(uInfo *model.ContextInfo, data *model.AlertRuleRequest) (model.AlertRule, errors.InternalError) {
+	ctx := context.Background()
+
+	practitionerID, err := primitive.ObjectIDFromHex(uInfo.ID)
+	if err != nil {
+		logger.Error("Error converting hex Id to objectId at CreateAlertRule, error: ", err.Error())
+		return model.AlertRule{}, errors.InternalServerError
+	}
+
+	now := time.Now()
+	rule := model.AlertRule{
+		ID:             primitive.NewObjectID(),
+		PractitionerID: practitionerID,
+		Type:           data.Type,
+		Threshold:      data.Threshold,
+		Enabled:        data.Enabled == nil || *data.Enabled,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	if data.PatientID != "" {
+		patientID, err := primitive.ObjectIDFromHex(data.PatientID)
+		if err != nil {
+			return model.AlertRule{}, errors.BadRequestError("invalid patient id")
+		}
+		rule.PatientID = patientID
+	}
+
+	if err := validateThreshold(&rule); err != nil {
+		return model.AlertRule{}, err
+	}
+
+	if err := a.dbRepo.CreateAlertRule(ctx, &rule); err != nil {
+		logger.Error("Error creating alert rule, error: ", err.Error())
+		return model.AlertRule{}, errors.InternalServerError
+	}
+
+	return rule, nil
+}
+
+func (a *alertService) GetAlertRules(uInfo *model.ContextInfo) ([]model.AlertRule, errors.InternalError) {
+	ctx := context.Background()
+
+	practitionerID, err := primitive.ObjectIDFromHex(uInfo.ID)
+	if err != nil {
+		logger.Error("Error converting hex Id to objectId at GetAlertRules, error: ", err.Error())
+		return nil, errors.InternalServerError
+	}
+
+	rules, err := a.dbRepo.GetAlertRules(ctx, practitionerID)
+	if err != nil {
+		logger.Error("Error fetching alert rules, error: ", err.Error())
+		return nil, errors.InternalServerError
+	}
+
+	return rules, nil
+}
+
+func (a *alertService) UpdateAlertRule(uInfo *model.ContextInfo, id string, data *model.UpdateAlertRuleRequest) (model.AlertRule, errors.InternalError) {
+	ctx := context.Background()
+
+	rule, iErr := a.getAuthorizedRule(ctx, uInfo, id)
+	if iErr != nil {
+		return model.AlertRule{}, iErr
+	}
+
+	if data.Threshold != nil {
+		rule.Threshold = *data.Threshold
+	}
+	if data.Enabled != nil {
+		rule.Enabled = *data.Enabled
+	}
+
+	if err := validateThreshold(&rule); err != nil {
+		return model.AlertRule{}, err
+	}
+
+	rule.UpdatedAt = time.Now()
+	found, err := a.dbRepo.UpdateAlertRule(ctx, &rule)
+	if err != nil {
+		logger.Error("Error updating alert rule, error: ", err.Error())
+		return model.AlertRule{}, errors.InternalServerError
+	}
+
+	if !found {
+		return model.AlertRule{}, errors.ResourceNotFoundError("alert rule not found")
+	}
+
+	return rule, nil
+}
+
+// DeleteAlertRule deletes a rule, the alerts it raised are kept
+func (a *alertService) DeleteAlertRule(uInfo *model.ContextInfo, id string) errors.InternalError {
+	ctx := context.Background()
+
+	rule, iErr := a.getAuthorizedRule(ctx, uInfo, id)
+	if iErr != nil {
+		return iErr
+	}
+
+	found, err := a.dbRepo.DeleteAlertRule(ctx, rule.ID)
+	if err != nil {
+		logger.Error("Error deleting alert rule, error: ", err.Error())
+		return errors.InternalServerError
+	}
+
+	if !found {
+		return errors.ResourceNotFoundError("alert rule not found")
+	}
+
+	return nil
+}
+
+func (a *alertService) GetAlerts(uInfo *model.ContextInfo, filter *model.AlertFilter) (model.AlertListResponse, errors.InternalError) {
+	ctx := context.Background()
+
+	switch filter.Status {
+	case "", constant.AlertOpen, constant.AlertAcknowledged, constant.AlertResolved:
+	default:
+		return model.AlertListResponse{}, errors.BadRequestError("invalid status")
+	}
+
+	practitionerID, err := primitive.ObjectIDFromHex(uInfo.ID)
+	if err != nil {
+		logger.Error("Error converting hex Id to objectId at GetAlerts, error: ", err.Error())
+		return model.AlertListResponse{}, errors.InternalServerError
+	}
+	filter.PractitionerID = practitionerID
+
+	alerts, total, err := a.dbRepo.GetAlerts(ctx, filter)
+	if err != nil {
+		logger.Error("Error fetching alerts, error: ", err.Error())
+		return model.AlertListResponse{}, errors.InternalServerError
+	}
+
+	return model.AlertListResponse{Alerts: alerts, Total: total, Page: filter.Page, Limit: filter.Limit}, nil
+}
+
+func (a *alertService) AcknowledgeAlert(uInfo *model.ContextInfo, id string) errors.InternalError {
+	return a.setStatus(uInfo, id, []string{constant.AlertOpen}, constant.AlertAcknowledged)
+}
+
+func (a *alertService) ResolveAlert(uInfo *model.ContextInfo, id string) errors.InternalError {
+	return a.setStatus(uInfo, id, []string{constant.AlertOpen, constant.AlertAcknowledged}, constant.AlertResolved)
+}
+
+func (a *alertService) setStatus(uInfo *model.ContextInfo, id string, from []string, status string) errors.InternalError {
+	ctx := context.Background()
+
+	alertID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.BadRequestError("invalid id")
+	}
+
+	alert, found, err := a.dbRepo.GetAlert(ctx, alertID)
+	if err != nil {
+		logger.Error("Error fetching alert, error: ", err.Error())
+		return errors.InternalServerError
+	}
+
+	if !found {
+		return errors.ResourceNotFoundError("alert not found")
+	}
+
+	if err := policy.Authorize(uInfo, policy.AlertUpdate, policy.Alert(&alert)); err != nil {
+		return err
+	}
+
+	found, err = a.dbRepo.SetAlertStatus(ctx, alertID, from, status, time.Now())
+	if err != nil {
+		logger.Error("Error updating alert status, error: ", err.Error())
+		return errors.InternalServerError
+	}
+
+	if !found {
+		return errors.BadRequestError(fmt.Sprintf("alert is already %s", alert.Status))
+	}
+
+	return nil
+}
+
+func (a *alertService) getAuthorizedRule(ctx context.Context, uInfo *model.ContextInfo, id string) (model.AlertRule, errors.InternalError) {
+	ruleID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return model.AlertRule{}, errors.BadRequestError("invalid id")
+	}
+
+	rule, found, err := a.dbRepo.GetAlertRule(ctx, ruleID)
+	if err != nil {
+		logger.Error("Error fetching alert rule, error: ", err.Error())
+		return model.AlertRule{}, errors.InternalServerError
+	}
+
+	if !found {
+		return model.AlertRule{}, errors.ResourceNotFoundError("alert rule not found")
+	}
+
+	if err := policy.Authorize(uInfo, policy.AlertRuleManage, policy.AlertRule(&rule)); err != nil {
+		return model.AlertRule{}, err
+	}
+
+	return rule, nil
+}
+
+func validateThreshold(rule *model.AlertRule) errors.InternalError {
+	switch rule.Type {
+	case constant.AlertConsecutiveMissed:
+		if rule.Threshold < 1 || rule.Threshold != math.Trunc(rule.Threshold) {
+			return errors.BadRequestError("threshold must be a number of doses of at least 1")
+		}
+	case constant.AlertLowAdherence:
+		if rule.Threshold <= 0 || rule.Threshold > 100 {
+			return errors.BadRequestError("threshold must be a percentage between 0 and 100")
+		}
+	default:
+		rule.Threshold = 0
+	}
+
+	return nil
+}
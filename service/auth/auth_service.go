@@ -0,0 +1,425 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"medbuddy-backend/internal/config"
+	"medbuddy-backend/internal/constant"
+	"medbuddy-backend/internal/errors"
+	"medbuddy-backend/internal/model"
+	"medbuddy-backend/pkg/middleware"
+	"medbuddy-backend/pkg/notification"
+	"medbuddy-backend/pkg/repository/storage"
+	"medbuddy-backend/utility"
+	"strings"
+	"time"
+)
+
+type AuthService interface {
+	IssueTokens(subject string, user *model.User, familyID string) (model.TokenResponse, errors.InternalError)
+	RefreshToken(data *model.RefreshTokenRequest) (model.TokenResponse, errors.InternalError)
+	Logout(uInfo *model.ContextInfo) errors.InternalError
+	ForgotPassword(role string, data *model.ForgotPassword) errors.InternalError
+	ForcePasswordReset(userID string) errors.InternalError
+	ResetPassword(data *model.ResetPassword) errors.InternalError
+	SendVerificationEmail(name string, user *model.User) errors.InternalError
+	ResendVerificationEmail(uInfo *model.ContextInfo) errors.InternalError
+	VerifyEmail(data *model.VerifyEmail) errors.InternalError
+	CheckLogin(data *model.UserLogin) errors.InternalError
+	LoginFailed(data *model.UserLogin, user *model.User, name string) errors.InternalError
+	LoginSucceeded(data *model.UserLogin)
+	UnlockAccount(data *model.UnlockAccount) errors.InternalError
+	SetUserLock(userID string, locked bool) errors.InternalError
+}
+
+type authService struct {
+	dbRepo   storage.StorageRepository
+	notifier *notification.Dispatcher
+}
+
+func NewAuthService(dbRepo storage.StorageRepository) AuthService {
+	return &authService{dbRepo: dbRepo, notifier: notification.NewDispatcherFromConfig(config.GetConfig())}
+}
+
+var (
+	logger = utility.NewLogger()
+
+	resetPasswordSubject  = "Reset your MedBuddy password"
+	resetPasswordTemplate = "utility/template/reset_password.html"
+	verifyEmailSubject    = "Verify your MedBuddy email address"
+	verifyEmailTemplate   = "utility/template/verify_email.html"
+)
+
+// IssueTokens creates an access token and a refresh token for the patient or practitioner with
+// id subject. An empty familyID starts a new family, which is what happens on login.
+func (a *authService) IssueTokens(subject string, user *model.User, familyID string) (model.TokenResponse, errors.InternalError) {
+	ctx := context.Background()
+
+	if familyID == "" {
+		familyID = primitive.NewObjectID().Hex()
+	}
+
+	token, expiresAt, err := middleware.CreateToken(subject, user.Email, user.Role, familyID)
+	if err != nil {
+		logger.Error("Error creating token for user, error: ", err.Error())
+		return model.TokenResponse{}, errors.InternalServerError
+	}
+
+	refreshToken, err := utility.GenerateToken()
+	if err != nil {
+		logger.Error("Error generating refresh token, error: ", err.Error())
+		return model.TokenResponse{}, errors.InternalServerError
+	}
+
+	now := time.Now()
+	stored := model.RefreshToken{
+		ID:        primitive.NewObjectID(),
+		TokenHash: utility.HashToken(refreshToken),
+		FamilyID:  familyID,
+		Subject:   subject,
+		UserID:    user.ID,
+		Email:     user.Email,
+		Role:      user.Role,
+		ExpiresAt: now.Add(middleware.RefreshTokenTTL()),
+		CreatedAt: now,
+	}
+
+	if err := a.dbRepo.SaveRefreshToken(ctx, &stored); err != nil {
+		logger.Error("Error saving refresh token, error: ", err.Error())
+		return model.TokenResponse{}, errors.InternalServerError
+	}
+
+	return model.TokenResponse{Token: token, RefreshToken: refreshToken, ExpiresAt: expiresAt}, nil
+}
+
+// RefreshToken exchanges a refresh token for a new pair of tokens. Refresh tokens are single use,
+// presenting one that was already used means it leaked so its whole family is revoked.
+func (a *authService) RefreshToken(data *model.RefreshTokenRequest) (model.TokenResponse, errors.InternalError) {
+	ctx := context.Background()
+
+	now := time.Now()
+	tokenHash := utility.HashToken(data.RefreshToken)
+
+	stored, found, err := a.dbRepo.UseRefreshToken(ctx, tokenHash, now)
+	if err != nil {
+		logger.Error("Error using refresh token, error: ", err.Error())
+		return model.TokenResponse{}, errors.InternalServerError
+	}
+
+	if !found {
+		existing, found, err := a.dbRepo.GetRefreshToken(ctx, tokenHash)
+		if err != nil {
+			logger.Error("Error fetching refresh token, error: ", err.Error())
+			return model.TokenResponse{}, errors.InternalServerError
+		}
+
+		if found && existing.UsedAt != nil && existing.RevokedAt == nil {
+			logger.Warnf("Refresh token reuse detected for '%s', revoking token family %s", existing.Email, existing.FamilyID)
+			if err := a.revokeFamily(ctx, existing.FamilyID); err != nil {
+				return model.TokenResponse{}, err
+			}
+		}
+
+		return model.TokenResponse{}, errors.UnauthorizedError("invalid refresh token")
+	}
+
+	user, found, err := a.dbRepo.GetUserByID(ctx, stored.UserID)
+	if err != nil {
+		logger.Error("Error fetching user by id, error: ", err.Error())
+		return model.TokenResponse{}, errors.InternalServerError
+	}
+
+	if !found {
+		return model.TokenResponse{}, errors.UnauthorizedError("invalid refresh token")
+	}
+
+	// Ensure that user is not locked
+	if user.IsLocked {
+		return model.TokenResponse{}, errors.ForbiddenError("cannot refresh token, user is currently blocked")
+	}
+
+	return a.IssueTokens(stored.Subject, &user, stored.FamilyID)
+}
+
+// Logout revokes the access token of the request and every token of its family, so the refresh
+// token issued alongside it can no longer be used
+func (a *authService) Logout(uInfo *model.ContextInfo) errors.InternalError {
+	ctx := context.Background()
+
+	if err := a.dbRepo.RevokeToken(ctx, uInfo.TokenID, uInfo.TokenExpiresAt); err != nil {
+		logger.Error("Error revoking access token, error: ", err.Error())
+		return errors.InternalServerError
+	}
+
+	if uInfo.TokenFamilyID == "" {
+		return nil
+	}
+
+	return a.revokeFamily(ctx, uInfo.TokenFamilyID)
+}
+
+func (a *authService) revokeFamily(ctx context.Context, familyID string) errors.InternalError {
+	// access tokens of the family issued until now expire within their ttl
+	until := time.Now().Add(middleware.AccessTokenTTL())
+	if err := a.dbRepo.RevokeTokenFamily(ctx, familyID, until); err != nil {
+		logger.Error("Error revoking token family, error: ", err.Error())
+		return errors.InternalServerError
+	}
+
+	return nil
+}
+
+// ForgotPassword emails a password reset link to the patient or practitioner with the given
+// email. It succeeds whether or not the account exists so that it cannot be used to find accounts.
+func (a *authService) ForgotPassword(role string, data *model.ForgotPassword) errors.InternalError {
+	ctx := context.Background()
+
+	user, name, found, err := a.getUserByEmail(ctx, role, data.Email)
+	if err != nil {
+		logger.Error("Error fetching user by email, error: ", err.Error())
+		return errors.InternalServerError
+	}
+
+	if !found {
+		return nil
+	}
+
+	return a.sendResetEmail(ctx, name, &user)
+}
+
+// ForcePasswordReset signs a user out everywhere and makes them choose a new password through
+// an emailed reset link before they can log in again
+func (a *authService) ForcePasswordReset(userID string) errors.InternalError {
+	ctx := context.Background()
+
+	oId, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return errors.BadRequestError("invalid user id")
+	}
+
+	user, found, err := a.dbRepo.GetUserByID(ctx, oId)
+	if err != nil {
+		logger.Error("Error fetching user by id, error: ", err.Error())
+		return errors.InternalServerError
+	}
+
+	if !found {
+		return errors.ResourceNotFoundError("user not found")
+	}
+
+	if _, err := a.dbRepo.RequirePasswordReset(ctx, oId); err != nil {
+		logger.Error("Error requiring password reset, error: ", err.Error())
+		return errors.InternalServerError
+	}
+
+	if err := a.dbRepo.RevokeUserTokens(ctx, oId, time.Now().Add(middleware.AccessTokenTTL())); err != nil {
+		logger.Error("Error revoking user's sessions, error: ", err.Error())
+		return errors.InternalServerError
+	}
+
+	return a.sendResetEmail(ctx, user.Firstname+" "+user.Lastname, &user)
+}
+
+func (a *authService) sendResetEmail(ctx context.Context, name string, user *model.User) errors.InternalError {
+	// Only the latest reset link works
+	if err := a.dbRepo.DeleteUserTokens(ctx, user.ID, constant.TokenPurposeResetPassword); err != nil {
+		logger.Error("Error deleting previous reset tokens, error: ", err.Error())
+		return errors.InternalServerError
+	}
+
+	token, err := a.saveUserToken(ctx, user, constant.TokenPurposeResetPassword, constant.ResetPasswordTokenTTL)
+	if err != nil {
+		logger.Error("Error saving reset token, error: ", err.Error())
+		return errors.InternalServerError
+	}
+
+	emailData := map[string]string{
+		"Name":      name,
+		"Link":      appLink("reset-password", token),
+		"ExpiresIn": fmt.Sprintf("%v minutes", constant.ResetPasswordTokenTTL.Minutes()),
+	}
+
+	if err := a.sendEmail(ctx, name, user.Email, resetPasswordSubject, resetPasswordTemplate, emailData); err != nil {
+		logger.Errorf("Error sending password reset email to '%s', error: %s", user.Email, err.Error())
+		return errors.InternalServerErrorWithMsg("could not send password reset email")
+	}
+
+	return nil
+}
+
+// ResetPassword sets a new password using an emailed reset token and signs the user out of
+// every session
+func (a *authService) ResetPassword(data *model.ResetPassword) errors.InternalError {
+	ctx := context.Background()
+
+	token, found, err := a.dbRepo.UseUserToken(ctx, utility.HashToken(data.Token), constant.TokenPurposeResetPassword, time.Now())
+	if err != nil {
+		logger.Error("Error using reset token, error: ", err.Error())
+		return errors.InternalServerError
+	}
+
+	if !found {
+		return errors.BadRequestError("invalid or expired reset token")
+	}
+
+	hashedPassword, salt, err := utility.HashPassword(data.Password)
+	if err != nil {
+		logger.Error("Error hashing user's password, error: ", err.Error())
+		return errors.InternalServerError
+	}
+
+	found, err = a.dbRepo.UpdateUserPassword(ctx, token.UserID, hashedPassword, salt)
+	if err != nil {
+		logger.Error("Error updating user's password, error: ", err.Error())
+		return errors.InternalServerError
+	}
+
+	if !found {
+		return errors.BadRequestError("invalid or expired reset token")
+	}
+
+	if err := a.dbRepo.RevokeUserTokens(ctx, token.UserID, time.Now().Add(middleware.AccessTokenTTL())); err != nil {
+		logger.Error("Error revoking user's sessions, error: ", err.Error())
+		return errors.InternalServerError
+	}
+
+	return nil
+}
+
+// SendVerificationEmail emails a link to verify the address of a new account
+func (a *authService) SendVerificationEmail(name string, user *model.User) errors.InternalError {
+	ctx := context.Background()
+
+	// Only the latest verification link works
+	if err := a.dbRepo.DeleteUserTokens(ctx, user.ID, constant.TokenPurposeVerifyEmail); err != nil {
+		logger.Error("Error deleting previous verification tokens, error: ", err.Error())
+		return errors.InternalServerError
+	}
+
+	token, err := a.saveUserToken(ctx, user, constant.TokenPurposeVerifyEmail, constant.VerifyEmailTokenTTL)
+	if err != nil {
+		logger.Error("Error saving verification token, error: ", err.Error())
+		return errors.InternalServerError
+	}
+
+	emailData := map[string]string{
+		"Name":      name,
+		"Link":      appLink("verify-email", token),
+		"ExpiresIn": fmt.Sprintf("%v hours", constant.VerifyEmailTokenTTL.Hours()),
+	}
+
+	if err := a.sendEmail(ctx, name, user.Email, verifyEmailSubject, verifyEmailTemplate, emailData); err != nil {
+		logger.Errorf("Error sending verification email to '%s', error: %s", user.Email, err.Error())
+		return errors.InternalServerErrorWithMsg("could not send verification email")
+	}
+
+	return nil
+}
+
+func (a *authService) ResendVerificationEmail(uInfo *model.ContextInfo) errors.InternalError {
+	ctx := context.Background()
+
+	user, name, found, err := a.getUserByEmail(ctx, constant.RoleName(uInfo.Role), uInfo.Email)
+	if err != nil {
+		logger.Error("Error fetching user by email, error: ", err.Error())
+		return errors.InternalServerError
+	}
+
+	if !found {
+		return errors.ResourceNotFoundError("user not found")
+	}
+
+	if user.Verified {
+		return errors.BadRequestError("email is already verified")
+	}
+
+	return a.SendVerificationEmail(name, &user)
+}
+
+func (a *authService) VerifyEmail(data *model.VerifyEmail) errors.InternalError {
+	ctx := context.Background()
+
+	token, found, err := a.dbRepo.UseUserToken(ctx, utility.HashToken(data.Token), constant.TokenPurposeVerifyEmail, time.Now())
+	if err != nil {
+		logger.Error("Error using verification token, error: ", err.Error())
+		return errors.InternalServerError
+	}
+
+	if !found {
+		return errors.BadRequestError("invalid or expired verification token")
+	}
+
+	found, err = a.dbRepo.SetEmailVerified(ctx, token.UserID)
+	if err != nil {
+		logger.Error("Error marking user's email as verified, error: ", err.Error())
+		return errors.InternalServerError
+	}
+
+	if !found {
+		return errors.BadRequestError("invalid or expired verification token")
+	}
+
+	return nil
+}
+
+// getUserByEmail returns the user account and name of the user with email and role
+func (a *authService) getUserByEmail(ctx context.Context, role, email string) (user model.User, name string, found bool, err error) {
+	switch role {
+	case constant.Patient:
+		patient, found, err := a.dbRepo.GetPatientByEmail(ctx, email)
+		return patient.User, patient.FullName, found && !patient.User.ID.IsZero(), err
+	case constant.Practitioner:
+		pract, found, err := a.dbRepo.GetPractitionerByEmail(ctx, email)
+		return pract.User, pract.FullName, found && !pract.User.ID.IsZero(), err
+	case constant.Caregiver:
+		caregiver, found, err := a.dbRepo.GetCaregiverByEmail(ctx, email)
+		return caregiver.User, caregiver.FullName, found && !caregiver.User.ID.IsZero(), err
+	case constant.Admin:
+		user, found, err := a.dbRepo.GetUserByEmail(ctx, email)
+		return user, user.Firstname + " " + user.Lastname, found && user.Role == constant.Roles[constant.Admin], err
+	}
+
+	return model.User{}, "", false, fmt.Errorf("unknown role '%s'", role)
+}
+
+// saveUserToken stores a new single use token for purpose and returns it
+func (a *authService) saveUserToken(ctx context.Context, user *model.User, purpose string, ttl time.Duration) (string, error) {
+	token, err := utility.GenerateToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	userToken := model.UserToken{
+		ID:        primitive.NewObjectID(),
+		TokenHash: utility.HashToken(token),
+		Purpose:   purpose,
+		UserID:    user.ID,
+		Email:     user.Email,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+
+	if err := a.dbRepo.SaveUserToken(ctx, &userToken); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func (a *authService) sendEmail(ctx context.Context, name, email, subject, template string, data interface{}) error {
+	html, err := utility.RenderTemplate(template, data)
+	if err != nil {
+		return err
+	}
+
+	to := notification.Recipient{Name: name, Email: email}
+	_, err = a.notifier.Send(ctx, []string{constant.ChannelEmail}, to, &notification.Message{Subject: subject, HTML: html})
+	return err
+}
+
+// appLink returns the link to a page of the web app that takes an emailed token
+func appLink(page, token string) string {
+	return fmt.Sprintf("%s/%s?token=%s", strings.TrimRight(config.GetConfig().AppBaseURL, "/"), page, token)
+}
@@ -0,0 +1,247 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"math"
+	"medbuddy-backend/internal/constant"
+	"medbuddy-backend/internal/errors"
+	"medbuddy-backend/internal/model"
+	"medbuddy-backend/pkg/middleware"
+	"medbuddy-backend/utility"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	unlockAccountSubject  = "Your MedBuddy account has been locked"
+	unlockAccountTemplate = "utility/template/unlock_account.html"
+
+	// errInvalidLogin is returned for unknown emails and wrong passwords alike
+	errInvalidLogin = errors.UnauthorizedError("invalid email or password")
+
+	dummyPassword     struct{ hash, salt string }
+	dummyPasswordOnce sync.Once
+)
+
+// CheckLogin refuses a login while the account or client IP is backing off after failed logins
+func (a *authService) CheckLogin(data *model.UserLogin) errors.InternalError {
+	ctx := context.Background()
+
+	attempts, err := a.dbRepo.GetLoginAttempts(ctx, emailKey(data.Email), ipKey(data.IP))
+	if err != nil {
+		logger.Error("Error fetching login attempts, error: ", err.Error())
+		return errors.InternalServerError
+	}
+
+	var wait time.Duration
+	for _, attempt := range attempts {
+		threshold := constant.LoginBackoffThreshold
+		if strings.HasPrefix(attempt.Key, "ip:") {
+			threshold = constant.LoginBackoffThresholdPerIP
+		}
+
+		if w := time.Until(attempt.LastFailureAt.Add(backoff(attempt.Failures, threshold))); w > wait {
+			wait = w
+		}
+	}
+
+	if wait > 0 {
+		return errors.TooManyRequestsError(fmt.Sprintf("too many failed login attempts, try again in %v seconds", math.Ceil(wait.Seconds())))
+	}
+
+	return nil
+}
+
+// LoginFailed records a failed login and returns the error to respond with. user is nil when no
+// account exists for the email. The account is locked once it reaches MaxFailedLogins and the
+// owner is emailed a link to unlock it.
+func (a *authService) LoginFailed(data *model.UserLogin, user *model.User, name string) errors.InternalError {
+	ctx := context.Background()
+
+	if user == nil {
+		// take as long as checking a real password so response times do not reveal accounts
+		hash, salt := dummyPasswordHash()
+		utility.PasswordIsValid(data.Password, salt, hash)
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(constant.LoginAttemptWindow)
+
+	if _, err := a.dbRepo.RecordLoginFailure(ctx, ipKey(data.IP), now, expiresAt); err != nil {
+		logger.Error("Error recording failed login, error: ", err.Error())
+		return errors.InternalServerError
+	}
+
+	attempt, err := a.dbRepo.RecordLoginFailure(ctx, emailKey(data.Email), now, expiresAt)
+	if err != nil {
+		logger.Error("Error recording failed login, error: ", err.Error())
+		return errors.InternalServerError
+	}
+
+	if user == nil || user.IsLocked || attempt.Failures < constant.MaxFailedLogins {
+		return errInvalidLogin
+	}
+
+	if _, err := a.dbRepo.SetUserLock(ctx, user.ID, true, constant.LockedByFailedLogins); err != nil {
+		logger.Error("Error locking user, error: ", err.Error())
+		return errors.InternalServerError
+	}
+
+	logger.Warnf("Locked account of '%s' after %v failed logins", user.Email, attempt.Failures)
+	if err := a.sendUnlockEmail(ctx, name, user); err != nil {
+		logger.Errorf("Error sending unlock email to '%s', error: %s", user.Email, err.Error())
+	}
+
+	return errInvalidLogin
+}
+
+// LoginSucceeded forgets the failed logins of the account
+func (a *authService) LoginSucceeded(data *model.UserLogin) {
+	if err := a.dbRepo.ClearLoginAttempts(context.Background(), emailKey(data.Email)); err != nil {
+		logger.Error("Error clearing login attempts, error: ", err.Error())
+	}
+}
+
+// UnlockAccount unlocks an account locked after failed logins using the emailed unlock token.
+// Accounts locked by an administrator stay locked.
+func (a *authService) UnlockAccount(data *model.UnlockAccount) errors.InternalError {
+	ctx := context.Background()
+
+	token, found, err := a.dbRepo.UseUserToken(ctx, utility.HashToken(data.Token), constant.TokenPurposeUnlockAccount, time.Now())
+	if err != nil {
+		logger.Error("Error using unlock token, error: ", err.Error())
+		return errors.InternalServerError
+	}
+
+	if !found {
+		return errors.BadRequestError("invalid or expired unlock token")
+	}
+
+	user, found, err := a.dbRepo.GetUserByID(ctx, token.UserID)
+	if err != nil {
+		logger.Error("Error fetching user by id, error: ", err.Error())
+		return errors.InternalServerError
+	}
+
+	if !found {
+		return errors.BadRequestError("invalid or expired unlock token")
+	}
+
+	if user.IsLocked && user.LockedBy != constant.LockedByFailedLogins {
+		return errors.ForbiddenError("account was locked by an administrator")
+	}
+
+	return a.unlock(ctx, &user)
+}
+
+// SetUserLock lets an administrator lock or unlock the account of a user
+func (a *authService) SetUserLock(userID string, locked bool) errors.InternalError {
+	ctx := context.Background()
+
+	oId, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return errors.BadRequestError("invalid user id")
+	}
+
+	user, found, err := a.dbRepo.GetUserByID(ctx, oId)
+	if err != nil {
+		logger.Error("Error fetching user by id, error: ", err.Error())
+		return errors.InternalServerError
+	}
+
+	if !found {
+		return errors.ResourceNotFoundError("user not found")
+	}
+
+	if !locked {
+		return a.unlock(ctx, &user)
+	}
+
+	if _, err := a.dbRepo.SetUserLock(ctx, oId, true, constant.LockedByAdmin); err != nil {
+		logger.Error("Error locking user, error: ", err.Error())
+		return errors.InternalServerError
+	}
+
+	// a locked user is signed out everywhere
+	if err := a.dbRepo.RevokeUserTokens(ctx, oId, time.Now().Add(middleware.AccessTokenTTL())); err != nil {
+		logger.Error("Error revoking user's sessions, error: ", err.Error())
+		return errors.InternalServerError
+	}
+
+	return nil
+}
+
+func (a *authService) unlock(ctx context.Context, user *model.User) errors.InternalError {
+	if _, err := a.dbRepo.SetUserLock(ctx, user.ID, false, ""); err != nil {
+		logger.Error("Error unlocking user, error: ", err.Error())
+		return errors.InternalServerError
+	}
+
+	if err := a.dbRepo.ClearLoginAttempts(ctx, emailKey(user.Email)); err != nil {
+		logger.Error("Error clearing login attempts, error: ", err.Error())
+		return errors.InternalServerError
+	}
+
+	return nil
+}
+
+func (a *authService) sendUnlockEmail(ctx context.Context, name string, user *model.User) error {
+	if err := a.dbRepo.DeleteUserTokens(ctx, user.ID, constant.TokenPurposeUnlockAccount); err != nil {
+		return err
+	}
+
+	token, err := a.saveUserToken(ctx, user, constant.TokenPurposeUnlockAccount, constant.UnlockAccountTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	emailData := map[string]string{
+		"Name":      name,
+		"Link":      appLink("unlock-account", token),
+		"ExpiresIn": fmt.Sprintf("%v hours", constant.UnlockAccountTokenTTL.Hours()),
+	}
+
+	return a.sendEmail(ctx, name, user.Email, unlockAccountSubject, unlockAccountTemplate, emailData)
+}
+
+// backoff returns how long to wait after the last of failures failed logins
+func backoff(failures, threshold int) time.Duration {
+	if failures < threshold {
+		return 0
+	}
+
+	exp := failures - threshold
+	if exp > 20 {
+		return constant.LoginBackoffMax
+	}
+
+	wait := constant.LoginBackoffBase << exp
+	if wait > constant.LoginBackoffMax {
+		return constant.LoginBackoffMax
+	}
+
+	return wait
+}
+
+func emailKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+func dummyPasswordHash() (hash, salt string) {
+	dummyPasswordOnce.Do(func() {
+		hash, salt, err := utility.HashPassword(primitive.NewObjectID().Hex())
+		if err != nil {
+			logger.Error("Error hashing dummy password, error: ", err.Error())
+		}
+		dummyPassword.hash, dummyPassword.salt = hash, salt
+	})
+
+	return dummyPassword.hash, dummyPassword.salt
+}
@@ -0,0 +1,474 @@
+package caregiver
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"medbuddy-backend/internal/config"
+	"medbuddy-backend/internal/constant"
+	"medbuddy-backend/internal/errors"
+	"medbuddy-backend/internal/model"
+	"medbuddy-backend/internal/policy"
+	"medbuddy-backend/pkg/notification"
+	"medbuddy-backend/pkg/repository/storage"
+	"medbuddy-backend/service/auth"
+	"medbuddy-backend/utility"
+	"strings"
+	"time"
+)
+
+type CaregiverService interface {
+	CreateCaregiver(data *model.CaregiverRequest) (model.CaregiverResponse, errors.InternalError)
+	LoginCaregiver(data *model.UserLogin) (model.CaregiverResponse, errors.InternalError)
+	GetCaregiver(uInfo *model.ContextInfo) (model.CaregiverResponse, errors.InternalError)
+	InviteCaregiver(uInfo *model.ContextInfo, data *model.InviteCaregiverRequest) (model.CaregiverLinkResponse, errors.InternalError)
+	GetPatientCaregivers(uInfo *model.ContextInfo) ([]model.CaregiverLinkResponse, errors.InternalError)
+	UpdateCaregiverLink(uInfo *model.ContextInfo, id string, data *model.UpdateCaregiverLinkRequest) errors.InternalError
+	RevokeCaregiver(uInfo *model.ContextInfo, id string) errors.InternalError
+	AcceptInvitation(uInfo *model.ContextInfo, data *model.AcceptInvitationRequest) (model.CaregiverLinkResponse, errors.InternalError)
+	GetCaregiverPatients(uInfo *model.ContextInfo) ([]model.CaregiverLinkResponse, errors.InternalError)
+	GetPatientMedications(uInfo *model.ContextInfo, patientId string) ([]model.MedicationResponse, errors.InternalError)
+	GetPatientDosages(uInfo *model.ContextInfo, patientId string, isActive *bool, medicationId string) ([]model.DosageResponse, errors.InternalError)
+}
+
+type caregiverService struct {
+	dbRepo      storage.StorageRepository
+	authService auth.AuthService
+	notifier    *notification.Dispatcher
+}
+
+func NewCaregiverService(dbRepo storage.StorageRepository) CaregiverService {
+	return &caregiverService{
+		dbRepo:      dbRepo,
+		authService: auth.NewAuthService(dbRepo),
+		notifier:    notification.NewDispatcherFromConfig(config.GetConfig()),
+	}
+}
+
+var (
+	logger = utility.NewLogger()
+
+	invitationSubject  = "%s invited you to be their caregiver on MedBuddy"
+	invitationTemplate = "utility/template/caregiver_invitation.html"
+)
+
+func (cs *caregiverService) CreateCaregiver(data *model.CaregiverRequest) (model.CaregiverResponse, errors.InternalError) {
+	ctx := context.Background()
+
+	formatedTime, err := utility.FormatTime(data.DOB)
+	if err != nil {
+		return model.CaregiverResponse{}, errors.BadRequestError(err.Error())
+	}
+
+	hashedPassword, salt, err := utility.HashPassword(data.Password)
+	if err != nil {
+		logger.Error("Error hashing user's password, error: ", err.Error())
+		return model.CaregiverResponse{}, errors.InternalServerError
+	}
+
+	_, found, err := cs.dbRepo.GetCaregiverByEmail(ctx, data.Email)
+	if err != nil {
+		logger.Error("Error fetching caregiver by email, error: ", err.Error())
+		return model.CaregiverResponse{}, errors.InternalServerError
+	}
+
+	if found {
+		return model.CaregiverResponse{}, errors.BadRequestError("caregiver already exists")
+	}
+
+	user := model.User{
+		ID:        primitive.NewObjectID(),
+		Firstname: data.Firstname,
+		Lastname:  data.Lastname,
+		Gender:    data.Gender,
+		Email:     data.Email,
+		DOB:       formatedTime,
+		Role:      constant.Roles[constant.Caregiver],
+		Salt:      salt,
+		Password:  hashedPassword,
+		CreatedAt: utility.ReturnCurrentTime(),
+		UpdatedAt: utility.ReturnCurrentTime(),
+	}
+
+	if err := cs.dbRepo.CreateUser(ctx, &user); err != nil {
+		logger.Error("Error creating user document, error: ", err.Error())
+		return model.CaregiverResponse{}, errors.InternalServerError
+	}
+
+	caregiver := model.Caregiver{
+		ID:       primitive.NewObjectID(),
+		FullName: data.Firstname + " " + data.Lastname,
+		Email:    data.Email,
+		Phone:    data.Phone,
+		UserID:   user.ID,
+	}
+
+	if err := cs.dbRepo.CreateCaregiver(ctx, &caregiver); err != nil {
+		logger.Error("Error creating caregiver's document, error: ", err.Error())
+		return model.CaregiverResponse{}, errors.InternalServerError
+	}
+
+	// Reminder copies are only emailed once the address is verified
+	if vErr := cs.authService.SendVerificationEmail(caregiver.FullName, &user); vErr != nil {
+		logger.Errorf("Could not send verification email to '%s', it can be resent, error: %s", user.Email, vErr.Error())
+	}
+
+	tokens, tErr := cs.authService.IssueTokens(caregiver.ID.Hex(), &user, "")
+	if tErr != nil {
+		return model.CaregiverResponse{}, tErr
+	}
+
+	response := utility.RequestsToCaregiverResponse(&caregiver, &user)
+	response.Token = tokens.Token
+	response.RefreshToken = tokens.RefreshToken
+	return response, nil
+}
+
+func (cs *caregiverService) LoginCaregiver(data *model.UserLogin) (model.CaregiverResponse, errors.InternalError) {
+	ctx := context.Background()
+
+	if err := cs.authService.CheckLogin(data); err != nil {
+		return model.CaregiverResponse{}, err
+	}
+
+	caregiver, found, err := cs.dbRepo.GetCaregiverByEmail(ctx, data.Email)
+	if err != nil {
+		logger.Error("Error fetching caregiver by email, error: ", err.Error())
+		return model.CaregiverResponse{}, errors.InternalServerError
+	}
+
+	// Unknown emails and wrong passwords get the same response
+	if !found || caregiver.User.ID.IsZero() {
+		return model.CaregiverResponse{}, cs.authService.LoginFailed(data, nil, "")
+	}
+
+	if !utility.PasswordIsValid(data.Password, caregiver.User.Salt, caregiver.User.Password) {
+		return model.CaregiverResponse{}, cs.authService.LoginFailed(data, &caregiver.User, caregiver.FullName)
+	}
+
+	if caregiver.User.IsLocked {
+		return model.CaregiverResponse{}, errors.ForbiddenError("cannot login, user is currently blocked")
+	}
+
+	if caregiver.User.MustReset {
+		return model.CaregiverResponse{}, errors.ForbiddenError("password reset required, check your email for a reset link")
+	}
+
+	cs.authService.LoginSucceeded(data)
+
+	tokens, tErr := cs.authService.IssueTokens(caregiver.ID.Hex(), &caregiver.User, "")
+	if tErr != nil {
+		return model.CaregiverResponse{}, tErr
+	}
+
+	// Omit password and salt from response, then set tokens
+	caregiver.User.Password = ""
+	caregiver.User.Salt = ""
+	caregiver.Token = tokens.Token
+	caregiver.RefreshToken = tokens.RefreshToken
+
+	return caregiver, nil
+}
+
+func (cs *caregiverService) GetCaregiver(uInfo *model.ContextInfo) (model.CaregiverResponse, errors.InternalError) {
+	ctx := context.Background()
+
+	oId, err := primitive.ObjectIDFromHex(uInfo.ID)
+	if err != nil {
+		logger.Error("Error converting hex Id to objectId, error: ", err.Error())
+		return model.CaregiverResponse{}, errors.InternalServerError
+	}
+
+	caregiver, found, err := cs.dbRepo.GetCaregiverByID(ctx, oId)
+	if err != nil {
+		logger.Error("Error fetching caregiver by id, error: ", err.Error())
+		return model.CaregiverResponse{}, errors.InternalServerError
+	}
+
+	if !found {
+		return model.CaregiverResponse{}, errors.ResourceNotFoundError("caregiver not found")
+	}
+
+	caregiver.User.Password = ""
+	caregiver.User.Salt = ""
+	return caregiver, nil
+}
+
+// InviteCaregiver emails an invitation to become the caregiver of the patient. Inviting an address
+// again while the invitation is pending sends a new link and invalidates the previous one.
+func (cs *caregiverService) InviteCaregiver(uInfo *model.ContextInfo, data *model.InviteCaregiverRequest) (model.CaregiverLinkResponse, errors.InternalError) {
+	ctx := context.Background()
+
+	patientID, err := primitive.ObjectIDFromHex(uInfo.ID)
+	if err != nil {
+		logger.Error("Error converting hex Id to objectId at InviteCaregiver, error: ", err.Error())
+		return model.CaregiverLinkResponse{}, errors.InternalServerError
+	}
+
+	email := strings.ToLower(strings.TrimSpace(data.Email))
+	if email == strings.ToLower(uInfo.Email) {
+		return model.CaregiverLinkResponse{}, errors.BadRequestError("you cannot be your own caregiver")
+	}
+
+	patient, found, err := cs.dbRepo.GetPatientByID(ctx, patientID)
+	if err != nil {
+		logger.Error("Error fetching patient by id, error: ", err.Error())
+		return model.CaregiverLinkResponse{}, errors.InternalServerError
+	}
+
+	if !found {
+		return model.CaregiverLinkResponse{}, errors.ResourceNotFoundError("patient not found")
+	}
+
+	link, found, err := cs.dbRepo.GetCaregiverLink(ctx, patientID, email)
+	if err != nil {
+		logger.Error("Error fetching caregiver link, error: ", err.Error())
+		return model.CaregiverLinkResponse{}, errors.InternalServerError
+	}
+
+	if found && link.Status == constant.InvitationActive {
+		return model.CaregiverLinkResponse{}, errors.BadRequestError("this caregiver already has access to your schedule")
+	}
+
+	token, err := utility.GenerateToken()
+	if err != nil {
+		logger.Error("Error generating invitation token, error: ", err.Error())
+		return model.CaregiverLinkResponse{}, errors.InternalServerError
+	}
+
+	now := time.Now()
+	if !found {
+		link = model.CaregiverLink{
+			ID:        primitive.NewObjectID(),
+			PatientID: patientID,
+			Email:     email,
+			Status:    constant.InvitationPending,
+			CreatedAt: now,
+		}
+	}
+	link.ReceiveReminders = data.ReceiveReminders
+	link.TokenHash = utility.HashToken(token)
+	link.ExpiresAt = now.Add(constant.InvitationTTL)
+
+	if err := cs.dbRepo.SaveCaregiverLink(ctx, &link); err != nil {
+		logger.Error("Error saving caregiver invitation, error: ", err.Error())
+		return model.CaregiverLinkResponse{}, errors.InternalServerError
+	}
+
+	if err := cs.sendInvitation(ctx, patient.FullName, email, token); err != nil {
+		logger.Errorf("Error sending caregiver invitation to '%s', error: %s", email, err.Error())
+		return model.CaregiverLinkResponse{}, errors.InternalServerErrorWithMsg("could not send invitation email")
+	}
+
+	return linkResponse(&link), nil
+}
+
+func (cs *caregiverService) GetPatientCaregivers(uInfo *model.ContextInfo) ([]model.CaregiverLinkResponse, errors.InternalError) {
+	ctx := context.Background()
+
+	patientID, err := primitive.ObjectIDFromHex(uInfo.ID)
+	if err != nil {
+		logger.Error("Error converting hex Id to objectId at GetPatientCaregivers, error: ", err.Error())
+		return nil, errors.InternalServerError
+	}
+
+	links, err := cs.dbRepo.GetPatientCaregiverLinks(ctx, patientID)
+	if err != nil {
+		logger.Error("Error fetching patient's caregivers, error: ", err.Error())
+		return nil, errors.InternalServerError
+	}
+
+	return links, nil
+}
+
+func (cs *caregiverService) UpdateCaregiverLink(uInfo *model.ContextInfo, id string, data *model.UpdateCaregiverLinkRequest) errors.InternalError {
+	ctx := context.Background()
+
+	patientID, err := primitive.ObjectIDFromHex(uInfo.ID)
+	if err != nil {
+		logger.Error("Error converting hex Id to objectId at UpdateCaregiverLink, error: ", err.Error())
+		return errors.InternalServerError
+	}
+
+	linkID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.BadRequestError("invalid id")
+	}
+
+	found, err := cs.dbRepo.UpdateCaregiverLink(ctx, linkID, patientID, *data.ReceiveReminders)
+	if err != nil {
+		logger.Error("Error updating caregiver link, error: ", err.Error())
+		return errors.InternalServerError
+	}
+
+	if !found {
+		return errors.ResourceNotFoundError("caregiver not found")
+	}
+
+	return nil
+}
+
+// RevokeCaregiver removes the access of a caregiver, or cancels the invitation if it is still pending
+func (cs *caregiverService) RevokeCaregiver(uInfo *model.ContextInfo, id string) errors.InternalError {
+	ctx := context.Background()
+
+	patientID, err := primitive.ObjectIDFromHex(uInfo.ID)
+	if err != nil {
+		logger.Error("Error converting hex Id to objectId at RevokeCaregiver, error: ", err.Error())
+		return errors.InternalServerError
+	}
+
+	linkID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.BadRequestError("invalid id")
+	}
+
+	found, err := cs.dbRepo.RevokeCaregiverLink(ctx, linkID, patientID, time.Now())
+	if err != nil {
+		logger.Error("Error revoking caregiver link, error: ", err.Error())
+		return errors.InternalServerError
+	}
+
+	if !found {
+		return errors.ResourceNotFoundError("caregiver not found")
+	}
+
+	return nil
+}
+
+// AcceptInvitation gives the caregiver access to the schedule of the patient who invited them. The
+// invitation can only be accepted from the account of the address it was sent to.
+func (cs *caregiverService) AcceptInvitation(uInfo *model.ContextInfo, data *model.AcceptInvitationRequest) (model.CaregiverLinkResponse, errors.InternalError) {
+	ctx := context.Background()
+
+	caregiverID, err := primitive.ObjectIDFromHex(uInfo.ID)
+	if err != nil {
+		logger.Error("Error converting hex Id to objectId at AcceptInvitation, error: ", err.Error())
+		return model.CaregiverLinkResponse{}, errors.InternalServerError
+	}
+
+	email := strings.ToLower(uInfo.Email)
+	link, found, err := cs.dbRepo.AcceptCaregiverInvitation(ctx, utility.HashToken(data.Token), caregiverID, email, time.Now())
+	if err != nil {
+		logger.Error("Error accepting caregiver invitation, error: ", err.Error())
+		return model.CaregiverLinkResponse{}, errors.InternalServerError
+	}
+
+	if !found {
+		return model.CaregiverLinkResponse{}, errors.BadRequestError("invalid or expired invitation, or it was sent to another email address")
+	}
+
+	return linkResponse(&link), nil
+}
+
+func (cs *caregiverService) GetCaregiverPatients(uInfo *model.ContextInfo) ([]model.CaregiverLinkResponse, errors.InternalError) {
+	ctx := context.Background()
+
+	caregiverID, err := primitive.ObjectIDFromHex(uInfo.ID)
+	if err != nil {
+		logger.Error("Error converting hex Id to objectId at GetCaregiverPatients, error: ", err.Error())
+		return nil, errors.InternalServerError
+	}
+
+	links, err := cs.dbRepo.GetCaregiverPatientLinks(ctx, caregiverID)
+	if err != nil {
+		logger.Error("Error fetching caregiver's patients, error: ", err.Error())
+		return nil, errors.InternalServerError
+	}
+
+	return links, nil
+}
+
+func (cs *caregiverService) GetPatientMedications(uInfo *model.ContextInfo, patientId string) ([]model.MedicationResponse, errors.InternalError) {
+	ctx := context.Background()
+
+	patientID, iErr := cs.authorizePatient(ctx, uInfo, policy.MedicationList, patientId)
+	if iErr != nil {
+		return nil, iErr
+	}
+
+	medics, err := cs.dbRepo.GetPatientsMedications(ctx, patientID)
+	if err != nil {
+		logger.Error("Error getting patients medications, error: ", err.Error())
+		return nil, errors.InternalServerError
+	}
+
+	return medics, nil
+}
+
+func (cs *caregiverService) GetPatientDosages(uInfo *model.ContextInfo, patientId string, isActive *bool, medicationId string) ([]model.DosageResponse, errors.InternalError) {
+	ctx := context.Background()
+
+	patientID, iErr := cs.authorizePatient(ctx, uInfo, policy.DosageList, patientId)
+	if iErr != nil {
+		return nil, iErr
+	}
+
+	filter := model.DosageFilter{PatiendID: patientID, IsActive: isActive}
+	if medicationId != "" {
+		medId, err := primitive.ObjectIDFromHex(medicationId)
+		if err != nil {
+			return nil, errors.BadRequestError("invalid medication id")
+		}
+		filter.MedicationID = medId
+	}
+
+	dosages, err := cs.dbRepo.GetPatientDosages(ctx, &filter)
+	if err != nil {
+		logger.Error("Error getting dosages, error: ", err.Error())
+		return nil, errors.InternalServerError
+	}
+
+	return dosages, nil
+}
+
+// authorizePatient checks that the user may perform action on the schedule of the patient with id patientId
+func (cs *caregiverService) authorizePatient(ctx context.Context, uInfo *model.ContextInfo, action policy.Action, patientId string) (primitive.ObjectID, errors.InternalError) {
+	patientID, err := primitive.ObjectIDFromHex(patientId)
+	if err != nil {
+		return primitive.NilObjectID, errors.BadRequestError("invalid patient id")
+	}
+
+	caregiverIDs, err := cs.dbRepo.GetPatientCaregiverIDs(ctx, patientID)
+	if err != nil {
+		logger.Error("Error fetching patient's caregivers, error: ", err.Error())
+		return primitive.NilObjectID, errors.InternalServerError
+	}
+
+	if err := policy.Authorize(uInfo, action, policy.Patient(patientID, caregiverIDs, nil)); err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	return patientID, nil
+}
+
+func (cs *caregiverService) sendInvitation(ctx context.Context, patientName, email, token string) error {
+	emailData := map[string]string{
+		"PatientName": patientName,
+		"Link":        fmt.Sprintf("%s/caregiver-invitation?token=%s", strings.TrimRight(config.GetConfig().AppBaseURL, "/"), token),
+		"ExpiresIn":   fmt.Sprintf("%v days", constant.InvitationTTL.Hours()/24),
+	}
+
+	html, err := utility.RenderTemplate(invitationTemplate, emailData)
+	if err != nil {
+		return err
+	}
+
+	msg := &notification.Message{Subject: fmt.Sprintf(invitationSubject, patientName), HTML: html}
+	_, err = cs.notifier.Send(ctx, []string{constant.ChannelEmail}, notification.Recipient{Email: email}, msg)
+	return err
+}
+
+func linkResponse(link *model.CaregiverLink) model.CaregiverLinkResponse {
+	return model.CaregiverLinkResponse{
+		ID:               link.ID,
+		PatientID:        link.PatientID,
+		CaregiverID:      link.CaregiverID,
+		Email:            link.Email,
+		Status:           link.Status,
+		ReceiveReminders: link.ReceiveReminders,
+		ExpiresAt:        link.ExpiresAt,
+		CreatedAt:        link.CreatedAt,
+		AcceptedAt:       link.AcceptedAt,
+	}
+}
@@ -2,19 +2,28 @@ package dosage
 
 import (
 	"context"
+	"fmt"
 	log "github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"medbuddy-backend/internal/config"
 	"medbuddy-backend/internal/constant"
 	"medbuddy-backend/internal/errors"
 	"medbuddy-backend/internal/model"
+	"medbuddy-backend/internal/policy"
+	"medbuddy-backend/pkg/events"
 	"medbuddy-backend/pkg/repository/storage"
 	"medbuddy-backend/utility"
+	"time"
 )
 
 type DosageService interface {
 	GetPatientsDosages(uInfo model.ContextInfo, isActive *bool, medicationId string) ([]model.DosageResponse, errors.InternalError)
 	SetDosageStatus(uInfo *model.ContextInfo, status string, dosageId string) errors.InternalError
-	GetDosage(id string) (model.DosageResponse, errors.InternalError)
+	GetDosage(uInfo *model.ContextInfo, id string) (model.DosageResponse, errors.InternalError)
+	RecordDose(uInfo *model.ContextInfo, medicationId string) (model.Dosage, errors.InternalError)
+	SnoozeDosage(uInfo *model.ContextInfo, dosageId string, minutes int) (model.DosageResponse, errors.InternalError)
+	GetDosageAction(token string) (model.DosageAction, errors.InternalError)
+	ApplyDosageAction(token string) (model.DosageAction, errors.InternalError)
 }
 
 type dosageService struct {
@@ -27,6 +36,14 @@ func NewDosageService(dbRepo storage.StorageRepository) DosageService {
 
 var (
 	logger = utility.NewLogger()
+
+	// statusTransitions lists the statuses a dosage can be set to from its current status. A missed
+	// dosage can still be recorded as taken or skipped, when the patient took it late or forgot to
+	// record it, within constant.MissedDoseCorrectionWindow of its reminder time.
+	statusTransitions = map[string][]string{
+		constant.DosageNotTaken: {constant.DosageTaken, constant.DosageSkipped},
+		constant.DosageMissed:   {constant.DosageTaken, constant.DosageSkipped},
+	}
 )
 
 func (d *dosageService) GetPatientsDosages(uInfo model.ContextInfo, isActive *bool, medicationId string) ([]model.DosageResponse, errors.InternalError) {
@@ -92,35 +109,73 @@ func (d *dosageService) SetDosageStatus(uInfo *model.ContextInfo, status string,
 		return errors.ResourceNotFoundError("dosage not found")
 	}
 
-	if dosage.Status == constant.DosageSkipped || dosage.Status == constant.DosageTaken || !dosage.IsActive {
-		return errors.BadRequestError("status of dosage cannot be set again")
+	if err := policy.Authorize(uInfo, policy.DosageSetStatus, policy.Dosage(&dosage)); err != nil {
+		return err
 	}
 
 	if status != constant.DosageSkipped && status != constant.DosageTaken {
 		return errors.BadRequestError("invalid status")
 	}
 
-	found, err = d.dbRepo.SetStatus(ctx, dId, oId, status)
+	if !canTransition(&dosage, status, time.Now()) {
+		return errors.BadRequestError(fmt.Sprintf("status of %s dosage cannot be set to %s", dosage.Status, status))
+	}
+
+	// caregivers set the status on the patient's behalf, who set it is kept on the dosage
+	recordedBy := &model.RecordedBy{ID: oId, Role: constant.RoleName(uInfo.Role)}
+	found, err = d.dbRepo.SetStatus(ctx, dId, dosage.PatientID, dosage.Status, status, recordedBy)
 	if err != nil {
 		logger.Error("Error setting status of dosage, error: ", err.Error())
 		return errors.InternalServerError
 	}
 
 	if !found {
-		return errors.BadRequestError("dosage not found / you don't have access to dosage")
+		return errors.BadRequestError("status of dosage was changed in the meantime, try again")
+	}
+
+	counts := map[string]int{status: 1}
+	if dosage.Status == constant.DosageMissed {
+		counts[constant.DosageMissed] = -1
+	}
+
+	if err := d.dbRepo.IncrementDosageCounts(ctx, dosage.MedicationID, counts); err != nil {
+		log.Error("Error when updating dosage counts, error: ", err.Error())
 	}
 
 	if status == constant.DosageTaken {
-		err := d.dbRepo.IncrementDosageTaken(ctx, dosage.MedicationID)
-		if err != nil {
-			log.Error("Error when incrementing dosage taken, error: ", err.Error())
+		quantity := dosage.Quantity
+		if quantity == "" {
+			quantity = dosage.Medication.DosageQuantity
 		}
+		d.consumeStock(ctx, dosage.MedicationID, dosage.PatientID, quantity, dosage.Medication.DosageQuantity, 0)
 	}
 
 	return nil
 }
 
-func (d *dosageService) GetDosage(id string) (model.DosageResponse, errors.InternalError) {
+// canTransition reports whether the status of dosage can be set to status at now
+func canTransition(dosage *model.DosageResponse, status string, now time.Time) bool {
+	switch dosage.Status {
+	case constant.DosageNotTaken:
+		if !dosage.IsActive {
+			return false
+		}
+	case constant.DosageMissed:
+		if now.After(dosage.ReminderTime.Add(constant.MissedDoseCorrectionWindow)) {
+			return false
+		}
+	}
+
+	for _, s := range statusTransitions[dosage.Status] {
+		if s == status {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (d *dosageService) GetDosage(uInfo *model.ContextInfo, id string) (model.DosageResponse, errors.InternalError) {
 	ctx := context.Background()
 
 	oId, err := primitive.ObjectIDFromHex(id)
@@ -139,5 +194,429 @@ func (d *dosageService) GetDosage(id string) (model.DosageResponse, errors.Inter
 		return model.DosageResponse{}, errors.ResourceNotFoundError("dosage not found")
 	}
 
+	if err := policy.Authorize(uInfo, policy.DosageRead, policy.Dosage(&dosage)); err != nil {
+		return model.DosageResponse{}, err
+	}
+
+	return dosage, nil
+}
+
+// RecordDose records a dosage of an as needed medication as taken now, as long as the maximum per
+// day and the total number of dosages of the medication have not been reached. Both are counted
+// with conditional writes before the dosage is saved, so doses recorded at the same time cannot
+// go over them, and given back when it cannot be saved.
+func (d *dosageService) RecordDose(uInfo *model.ContextInfo, medicationId string) (model.Dosage, errors.InternalError) {
+	ctx := context.Background()
+
+	oId, err := primitive.ObjectIDFromHex(uInfo.ID)
+	if err != nil {
+		logger.Error("Error converting hex Id to objectId at RecordDose, error: ", err.Error())
+		return model.Dosage{}, errors.InternalServerError
+	}
+
+	medId, err := primitive.ObjectIDFromHex(medicationId)
+	if err != nil {
+		return model.Dosage{}, errors.BadRequestError("invalid medication id")
+	}
+
+	medic, found, err := d.dbRepo.GetMedication(ctx, medId)
+	if err != nil {
+		logger.Error("Error fetching medication by id in RecordDose, error: ", err.Error())
+		return model.Dosage{}, errors.InternalServerError
+	}
+
+	if !found {
+		return model.Dosage{}, errors.ResourceNotFoundError("medication not found")
+	}
+
+	if err := policy.Authorize(uInfo, policy.DosageRecord, policy.Medication(&medic)); err != nil {
+		return model.Dosage{}, err
+	}
+
+	if !utility.IsAsNeeded(medic.Schedule) {
+		return model.Dosage{}, errors.BadRequestError("only dosages of as needed medications are recorded when taken, set the status of a scheduled dosage instead")
+	}
+
+	if !medic.IsActive {
+		return model.Dosage{}, errors.BadRequestError("medication is not active")
+	}
+
+	// the day is the one of the medication's time zone
+	loc, err := utility.LoadTimeZone(medic.TimeZone)
+	if err != nil {
+		logger.Error("Error loading time zone of medication in RecordDose, error: ", err.Error())
+		return model.Dosage{}, errors.InternalServerError
+	}
+
+	now := time.Now()
+	day := utility.DateIn(now.In(loc), loc)
+	tomorrow := day.AddDate(0, 0, 1)
+	taken, err := d.dbRepo.CountTakenDosages(ctx, medId, day, tomorrow)
+	if err != nil {
+		logger.Error("Error counting dosages taken today in RecordDose, error: ", err.Error())
+		return model.Dosage{}, errors.InternalServerError
+	}
+
+	// the count of the day starts from the dosages taken before it was kept, it is only seeded once
+	if err := d.dbRepo.SeedDoseCount(ctx, medId, day, taken, tomorrow.AddDate(0, 0, 1)); err != nil {
+		logger.Error("Error starting count of dosages taken today in RecordDose, error: ", err.Error())
+		return model.Dosage{}, errors.InternalServerError
+	}
+
+	slot, err := d.dbRepo.TakeDoseSlot(ctx, medId, day, medic.Schedule.MaxPerDay)
+	if err != nil {
+		logger.Error("Error counting dosage taken today in RecordDose, error: ", err.Error())
+		return model.Dosage{}, errors.InternalServerError
+	}
+
+	if !slot {
+		return model.Dosage{}, errors.BadRequestError(fmt.Sprintf("the maximum of %v dosage(s) a day has already been taken today", medic.Schedule.MaxPerDay))
+	}
+
+	releaseSlot := func() {
+		if err := d.dbRepo.ReleaseDoseSlot(ctx, medId, day); err != nil {
+			logger.Error("Error giving back dosage taken today in RecordDose, error: ", err.Error())
+		}
+	}
+
+	found, err = d.dbRepo.IncrementDosagesTaken(ctx, medId, medic.TotalNumberOfDosage)
+	if err != nil {
+		logger.Error("Error when updating dosage counts, error: ", err.Error())
+		releaseSlot()
+		return model.Dosage{}, errors.InternalServerError
+	}
+
+	if !found {
+		releaseSlot()
+		return model.Dosage{}, errors.BadRequestError(fmt.Sprintf("all %v dosage(s) of the medication have been taken", medic.TotalNumberOfDosage))
+	}
+
+	dosage := model.Dosage{
+		ID:           primitive.NewObjectID(),
+		ReminderTime: now,
+		Status:       constant.DosageTaken,
+		TimeTaken:    now,
+		MedicationID: medId,
+		PatientID:    medic.PatientID,
+		RecordedBy:   &model.RecordedBy{ID: oId, Role: constant.RoleName(uInfo.Role)},
+		AsNeeded:     true,
+	}
+
+	if err := d.dbRepo.SaveDosages(ctx, []model.Dosage{dosage}); err != nil {
+		logger.Error("Error saving dosage in RecordDose, error: ", err.Error())
+		if err := d.dbRepo.IncrementDosageCounts(ctx, medId, map[string]int{constant.DosageTaken: -1}); err != nil {
+			logger.Error("Error when updating dosage counts, error: ", err.Error())
+		}
+		releaseSlot()
+		return model.Dosage{}, errors.InternalServerError
+	}
+
+	d.consumeStock(ctx, medId, medic.PatientID, medic.DosageQuantity, medic.DosageQuantity, medic.Schedule.MaxPerDay)
+
+	return dosage, nil
+}
+
+// consumeStock takes a dosage taken at quantity out of the stock of its medication, when the stock
+// is tracked. The first time what is left lasts fewer days than the refill threshold, the patient
+// is told to refill it. Upcoming dosages without a quantity of their own are taken at
+// medicationQuantity; as needed medications, which have none, are counted at maxPerDay a day.
+func (d *dosageService) consumeStock(ctx context.Context, medicationID, patientID primitive.ObjectID, quantity, medicationQuantity string, maxPerDay int) {
+	units, unit, err := utility.DosageUnits(quantity)
+	if err != nil {
+		// the stock of medications with such quantities is not tracked
+		return
+	}
+
+	inventory, found, err := d.dbRepo.DecrementStock(ctx, medicationID, units)
+	if err != nil {
+		logger.Error("Error decrementing stock of medication, error: ", err.Error())
+		return
+	}
+
+	if !found || inventory.RefillNotifiedAt != nil {
+		return
+	}
+
+	now := time.Now()
+	var runsOutAt time.Time
+	if maxPerDay > 0 {
+		days := inventory.Stock / (units * float64(maxPerDay))
+		runsOutAt = now.Add(time.Duration(days * float64(24*time.Hour)))
+	} else {
+		dosages, err := d.dbRepo.GetActiveDosages(ctx, medicationID)
+		if err != nil {
+			logger.Error("Error fetching upcoming dosages of medication, error: ", err.Error())
+			return
+		}
+
+		var runsOut bool
+		runsOutAt, runsOut = utility.SupplyRunsOut(inventory.Stock, dosages, medicationQuantity)
+		if !runsOut {
+			// the stock lasts until the end of the treatment
+			return
+		}
+	}
+
+	threshold := inventory.RefillThresholdDays
+	if threshold <= 0 {
+		threshold = constant.DefaultRefillThresholdDays
+	}
+
+	if runsOutAt.After(now.AddDate(0, 0, threshold)) {
+		return
+	}
+
+	marked, err := d.dbRepo.MarkRefillNeeded(ctx, medicationID, now)
+	if err != nil {
+		logger.Error("Error marking medication as needing a refill, error: ", err.Error())
+		return
+	}
+
+	if !marked {
+		return
+	}
+
+	events.Publish(ctx, constant.EventRefillNeeded, model.RefillNeeded{
+		MedicationID: medicationID,
+		PatientID:    patientID,
+		Stock:        inventory.Stock,
+		Unit:         unit,
+		RunsOutAt:    runsOutAt,
+	})
+}
+
+// SnoozeDosage puts off the reminder of a due dosage by 'minutes', the reminder is sent again then.
+// A dosage cannot be snoozed past the next dosage of its medication, and it only becomes overdue
+// once the missed dose grace period has passed since it was snoozed until.
+func (d *dosageService) SnoozeDosage(uInfo *model.ContextInfo, dosageId string, minutes int) (model.DosageResponse, errors.InternalError) {
+	ctx := context.Background()
+
+	oId, err := primitive.ObjectIDFromHex(uInfo.ID)
+	if err != nil {
+		logger.Error("Error converting hex Id to objectId at SnoozeDosage, error: ", err.Error())
+		return model.DosageResponse{}, errors.InternalServerError
+	}
+
+	dId, err := primitive.ObjectIDFromHex(dosageId)
+	if err != nil {
+		return model.DosageResponse{}, errors.BadRequestError("invalid dosage id")
+	}
+
+	dosage, found, err := d.dbRepo.GetDosage(ctx, dId)
+	if err != nil {
+		logger.Error("Error fetching dosage by id in SnoozeDosage, error: ", err.Error())
+		return model.DosageResponse{}, errors.InternalServerError
+	}
+
+	if !found {
+		return model.DosageResponse{}, errors.ResourceNotFoundError("dosage not found")
+	}
+
+	if err := policy.Authorize(uInfo, policy.DosageSnooze, policy.Dosage(&dosage)); err != nil {
+		return model.DosageResponse{}, err
+	}
+
+	if dosage.AsNeeded {
+		return model.DosageResponse{}, errors.BadRequestError("dosages of as needed medications have no reminder to snooze")
+	}
+
+	if dosage.Status != constant.DosageNotTaken || !dosage.IsActive {
+		return model.DosageResponse{}, errors.BadRequestError(fmt.Sprintf("%s dosage cannot be snoozed", dosage.Status))
+	}
+
+	if minutes > constant.MaxSnoozeMinutes {
+		return model.DosageResponse{}, errors.BadRequestError(fmt.Sprintf("a reminder can be snoozed for at most %v minutes", constant.MaxSnoozeMinutes))
+	}
+
+	if len(dosage.Snoozes) >= constant.MaxSnoozesPerDosage {
+		return model.DosageResponse{}, errors.BadRequestError(fmt.Sprintf("the reminder of the dosage has already been snoozed %v times", constant.MaxSnoozesPerDosage))
+	}
+
+	now := time.Now()
+	if now.Before(dosage.ReminderTime) {
+		return model.DosageResponse{}, errors.BadRequestError(fmt.Sprintf("the dosage is not due until %s", dosage.ReminderTime.Format(time.RFC3339)))
+	}
+
+	until := now.Add(time.Duration(minutes) * time.Minute)
+	next, found, err := d.dbRepo.GetNextDosage(ctx, dosage.MedicationID, dosage.ReminderTime)
+	if err != nil {
+		logger.Error("Error fetching next dosage in SnoozeDosage, error: ", err.Error())
+		return model.DosageResponse{}, errors.InternalServerError
+	}
+
+	if found && !until.Before(next.ReminderTime) {
+		return model.DosageResponse{}, errors.BadRequestError(fmt.Sprintf("the reminder can only be snoozed until the next dosage, due at %s", next.ReminderTime.Format(time.RFC3339)))
+	}
+
+	snooze := model.Snooze{
+		SnoozedAt: now,
+		Until:     until,
+		SnoozedBy: &model.RecordedBy{ID: oId, Role: constant.RoleName(uInfo.Role)},
+	}
+
+	err = d.dbRepo.WithTransaction(ctx, func(ctx context.Context) error {
+		found, err := d.dbRepo.SnoozeDosage(ctx, dId, &snooze)
+		if err != nil {
+			logger.Error("Error snoozing dosage, error: ", err.Error())
+			return err
+		}
+
+		if !found {
+			return constant.ErrDosageChanged
+		}
+
+		// reminders still pending for the dosage, e.g. of an earlier snooze, are replaced by this one
+		if _, err := d.dbRepo.DeleteUndoneDosageTasks(ctx, dId); err != nil {
+			logger.Error("Error deleting pending tasks of dosage in SnoozeDosage, error: ", err.Error())
+			return err
+		}
+
+		task := model.Task{
+			ID:           primitive.NewObjectID(),
+			Time:         until,
+			Status:       constant.TaskUndone,
+			MedicationID: dosage.MedicationID,
+			DosageID:     dId,
+			Kind:         constant.TaskKindSnooze,
+		}
+
+		if _, err := d.dbRepo.AddTasks(ctx, []model.Task{task}); err != nil {
+			logger.Error("Error adding task in SnoozeDosage, error: ", err.Error())
+			return err
+		}
+
+		return nil
+	})
+	if err == constant.ErrDosageChanged {
+		return model.DosageResponse{}, errors.BadRequestError("status of dosage was changed in the meantime, try again")
+	}
+
+	if err != nil {
+		return model.DosageResponse{}, errors.InternalServerError
+	}
+
+	dosage.Snoozes = append(dosage.Snoozes, snooze)
+	dosage.SnoozedUntil = &until
+
 	return dosage, nil
 }
+
+// GetDosageAction returns what the one-click link of a reminder email does, without doing it, so
+// that the patient confirms it first. Link scanners that open links in emails change nothing.
+func (d *dosageService) GetDosageAction(token string) (model.DosageAction, errors.InternalError) {
+	ctx := context.Background()
+
+	action, dosage, _, err := d.dosageAction(ctx, token)
+	if err != nil {
+		return model.DosageAction{}, err
+	}
+
+	if !canTransition(&dosage, action.Status, time.Now()) {
+		return model.DosageAction{}, errors.BadRequestError(fmt.Sprintf("status of %s dosage cannot be set to %s", dosage.Status, action.Status))
+	}
+
+	return action, nil
+}
+
+// ApplyDosageAction sets the status of the dosage of a one-click link on the patient's behalf,
+// following the same rules as SetDosageStatus. A link works once: its use is recorded once the
+// status is set, so a link whose status change failed can be tried again, and a used link is
+// refused by dosageAction. Two uses at the same time cannot both set the status, as SetStatus
+// only changes a dosage that still has the status it was read with.
+func (d *dosageService) ApplyDosageAction(token string) (model.DosageAction, errors.InternalError) {
+	ctx := context.Background()
+
+	action, dosage, expiresAt, err := d.dosageAction(ctx, token)
+	if err != nil {
+		return model.DosageAction{}, err
+	}
+
+	now := time.Now()
+	if !canTransition(&dosage, action.Status, now) {
+		return model.DosageAction{}, errors.BadRequestError(fmt.Sprintf("status of %s dosage cannot be set to %s", dosage.Status, action.Status))
+	}
+
+	patient := &model.ContextInfo{
+		ID:    dosage.PatientID.Hex(),
+		Role:  constant.Roles[constant.Patient],
+		Email: dosage.Medication.Patient.Email,
+	}
+
+	if err := d.SetDosageStatus(patient, action.Status, dosage.ID.Hex()); err != nil {
+		return model.DosageAction{}, err
+	}
+
+	used := model.UserToken{
+		ID:        primitive.NewObjectID(),
+		TokenHash: utility.HashToken(token),
+		Purpose:   constant.TokenPurposeDosageAction,
+		UserID:    dosage.Medication.Patient.UserID,
+		Email:     dosage.Medication.Patient.Email,
+		ExpiresAt: expiresAt,
+		CreatedAt: now,
+		UsedAt:    &now,
+		DosageID:  dosage.ID,
+	}
+
+	// the status is already set, so a failure to record the use is only logged. The link cannot
+	// set it again anyway, a dosage does not leave taken or skipped.
+	if _, e := d.dbRepo.RecordTokenUse(ctx, &used); e != nil {
+		logger.Error("Error recording use of dosage action link, error: ", e.Error())
+	}
+
+	return action, nil
+}
+
+// dosageAction verifies the token of a one-click link that has not been used yet and returns its
+// action and dosage
+func (d *dosageService) dosageAction(ctx context.Context, token string) (model.DosageAction, model.DosageResponse, time.Time, errors.InternalError) {
+	dId, status, expiresAt, err := utility.VerifyDosageAction(config.GetConfig().SecretKey, token, time.Now())
+	if err != nil {
+		return model.DosageAction{}, model.DosageResponse{}, time.Time{}, errors.BadRequestError(err.Error())
+	}
+
+	if status != constant.DosageSkipped && status != constant.DosageTaken {
+		return model.DosageAction{}, model.DosageResponse{}, time.Time{}, errors.BadRequestError("invalid status")
+	}
+
+	used, err := d.dbRepo.IsTokenUsed(ctx, utility.HashToken(token), constant.TokenPurposeDosageAction)
+	if err != nil {
+		logger.Error("Error checking use of dosage action link, error: ", err.Error())
+		return model.DosageAction{}, model.DosageResponse{}, time.Time{}, errors.InternalServerError
+	}
+
+	if used {
+		return model.DosageAction{}, model.DosageResponse{}, time.Time{}, errors.BadRequestError("this link has already been used")
+	}
+
+	dosage, found, err := d.dbRepo.GetDosage(ctx, dId)
+	if err != nil {
+		logger.Error("Error fetching dosage of dosage action link, error: ", err.Error())
+		return model.DosageAction{}, model.DosageResponse{}, time.Time{}, errors.InternalServerError
+	}
+
+	if !found {
+		return model.DosageAction{}, model.DosageResponse{}, time.Time{}, errors.ResourceNotFoundError("dosage not found")
+	}
+
+	loc, err := utility.LoadTimeZone(dosage.Medication.TimeZone)
+	if err != nil {
+		loc = time.Local
+	}
+
+	quantity := dosage.Quantity
+	if quantity == "" {
+		quantity = dosage.Medication.DosageQuantity
+	}
+
+	action := model.DosageAction{
+		DosageID:     dosage.ID,
+		Status:       status,
+		PatientName:  dosage.Medication.Patient.FullName,
+		Medicine:     dosage.Medication.Medicine.Name,
+		Quantity:     quantity,
+		ReminderTime: dosage.ReminderTime.In(loc),
+	}
+
+	return action, dosage, expiresAt, nil
+}
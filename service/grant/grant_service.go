@@ -0,0 +1,308 @@
+package grant
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"medbuddy-backend/internal/config"
+	"medbuddy-backend/internal/constant"
+	"medbuddy-backend/internal/errors"
+	"medbuddy-backend/internal/model"
+	"medbuddy-backend/internal/policy"
+	"medbuddy-backend/pkg/notification"
+	"medbuddy-backend/pkg/repository/storage"
+	"medbuddy-backend/utility"
+	"strings"
+	"time"
+)
+
+type GrantService interface {
+	ShareWithPractitioners(uInfo *model.ContextInfo, data *model.ShareRequest) ([]model.AccessGrantResponse, errors.InternalError)
+	GetAccessGrants(uInfo *model.ContextInfo, status string) ([]model.AccessGrantResponse, errors.InternalError)
+	AcceptAccessGrant(uInfo *model.ContextInfo, id string) (model.AccessGrantResponse, errors.InternalError)
+	DeclineAccessGrant(uInfo *model.ContextInfo, id string) (model.AccessGrantResponse, errors.InternalError)
+	RevokeAccessGrant(uInfo *model.ContextInfo, id string) errors.InternalError
+}
+
+type grantService struct {
+	dbRepo   storage.StorageRepository
+	notifier *notification.Dispatcher
+}
+
+func NewGrantService(dbRepo storage.StorageRepository) GrantService {
+	return &grantService{
+		dbRepo:   dbRepo,
+		notifier: notification.NewDispatcherFromConfig(config.GetConfig()),
+	}
+}
+
+var (
+	logger = utility.NewLogger()
+
+	grantSubject  = "%s would like to share their medications with you on MedBuddy"
+	grantTemplate = "utility/template/access_grant.html"
+)
+
+// ShareWithPractitioners asks the practitioners to accept access to a medication of the patient, or to
+// all of their medications when no medication is given. Practitioners who already have, or have been
+// asked for, that access are skipped.
+func (g *grantService) ShareWithPractitioners(uInfo *model.ContextInfo, data *model.ShareRequest) ([]model.AccessGrantResponse, errors.InternalError) {
+	ctx := context.Background()
+
+	patientID, err := primitive.ObjectIDFromHex(uInfo.ID)
+	if err != nil {
+		logger.Error("Error converting hex Id to objectId at ShareWithPractitioners, error: ", err.Error())
+		return nil, errors.InternalServerError
+	}
+
+	now := time.Now()
+	grant := model.AccessGrant{PatientID: patientID, Status: constant.InvitationPending, CreatedAt: now}
+	scope := "all their medications"
+
+	if data.MedicationID != "" {
+		medId, err := primitive.ObjectIDFromHex(data.MedicationID)
+		if err != nil {
+			return nil, errors.BadRequestError("invalid medication id")
+		}
+
+		medication, found, err := g.dbRepo.GetMedication(ctx, medId)
+		if err != nil {
+			logger.Error("Error fetching medication, error: ", err.Error())
+			return nil, errors.InternalServerError
+		}
+
+		if !found {
+			return nil, errors.ResourceNotFoundError("medication not found")
+		}
+
+		if err := policy.Authorize(uInfo, policy.MedicationShare, policy.Medication(&medication)); err != nil {
+			return nil, err
+		}
+
+		grant.MedicationID = medId
+		scope = fmt.Sprintf("their %s medication", medication.Name)
+	}
+
+	if data.ExpiresAt != "" {
+		expiresAt, err := utility.FormatTime(data.ExpiresAt)
+		if err != nil {
+			return nil, errors.BadRequestError(fmt.Sprint("ExpiresAt: ", err.Error()))
+		}
+
+		if !expiresAt.After(now) {
+			return nil, errors.BadRequestError("expiry date must be in the future")
+		}
+		grant.ExpiresAt = &expiresAt
+	}
+
+	emails := make([]string, len(data.PractitionerEmails))
+	for i, email := range data.PractitionerEmails {
+		emails[i] = strings.ToLower(strings.TrimSpace(email))
+	}
+
+	practitioners, err := g.dbRepo.GetPractitionersByEmail(ctx, emails)
+	if err != nil {
+		logger.Error("Error fetching specified practioner(s), error: ", err.Error())
+		return nil, errors.InternalServerError
+	}
+
+	if len(practitioners) <= 0 {
+		return nil, errors.BadRequestError("invalid practitioner email(s)")
+	}
+
+	patient, _, err := g.dbRepo.GetPatientByID(ctx, patientID)
+	if err != nil {
+		logger.Error("Error fetching patient by id, error: ", err.Error())
+		return nil, errors.InternalServerError
+	}
+
+	grants := []model.AccessGrantResponse{}
+	for _, practitioner := range practitioners {
+		_, found, err := g.dbRepo.GetOpenAccessGrant(ctx, patientID, practitioner.ID, grant.MedicationID, now)
+		if err != nil {
+			logger.Error("Error checking existing access grants, error: ", err.Error())
+			return nil, errors.InternalServerError
+		}
+
+		if found {
+			continue
+		}
+
+		grant.ID = primitive.NewObjectID()
+		grant.PractitionerID = practitioner.ID
+		if err := g.dbRepo.CreateAccessGrant(ctx, &grant); err != nil {
+			logger.Error("Error creating access grant, error: ", err.Error())
+			return nil, errors.InternalServerError
+		}
+
+		// The practitioner also finds the request in their account, a failed email is not fatal
+		if err := g.sendGrantRequest(ctx, patient.FullName, &practitioner, scope, grant.ExpiresAt); err != nil {
+			logger.Errorf("Error emailing access request to '%s', error: %s", practitioner.Email, err.Error())
+		}
+
+		grants = append(grants, grantResponse(&grant))
+	}
+	logger.Infof("Shared with %v out of %v practitioner(s)\n", len(grants), len(data.PractitionerEmails))
+
+	return grants, nil
+}
+
+// GetAccessGrants returns the grants given by a patient, or given to a practitioner
+func (g *grantService) GetAccessGrants(uInfo *model.ContextInfo, status string) ([]model.AccessGrantResponse, errors.InternalError) {
+	ctx := context.Background()
+
+	switch status {
+	case "", constant.InvitationPending, constant.InvitationActive, constant.InvitationDeclined,
+		constant.InvitationRevoked, constant.InvitationExpired:
+	default:
+		return nil, errors.BadRequestError("invalid status")
+	}
+
+	oId, err := primitive.ObjectIDFromHex(uInfo.ID)
+	if err != nil {
+		logger.Error("Error converting hex Id to objectId at GetAccessGrants, error: ", err.Error())
+		return nil, errors.InternalServerError
+	}
+
+	filter := model.AccessGrantFilter{Status: status}
+	if constant.RoleName(uInfo.Role) == constant.Practitioner {
+		filter.PractitionerID = oId
+	} else {
+		filter.PatientID = oId
+	}
+
+	now := time.Now()
+	grants, err := g.dbRepo.GetAccessGrants(ctx, &filter, now)
+	if err != nil {
+		logger.Error("Error fetching access grants, error: ", err.Error())
+		return nil, errors.InternalServerError
+	}
+
+	for i := range grants {
+		grants[i].Status = grantStatus(&grants[i], now)
+	}
+
+	return grants, nil
+}
+
+func (g *grantService) AcceptAccessGrant(uInfo *model.ContextInfo, id string) (model.AccessGrantResponse, errors.InternalError) {
+	return g.respond(uInfo, id, constant.InvitationActive)
+}
+
+func (g *grantService) DeclineAccessGrant(uInfo *model.ContextInfo, id string) (model.AccessGrantResponse, errors.InternalError) {
+	return g.respond(uInfo, id, constant.InvitationDeclined)
+}
+
+// RevokeAccessGrant ends a grant, or withdraws it while it is still pending. Both the patient and the
+// practitioner can revoke it.
+func (g *grantService) RevokeAccessGrant(uInfo *model.ContextInfo, id string) errors.InternalError {
+	ctx := context.Background()
+
+	grant, iErr := g.getAuthorizedGrant(ctx, uInfo, policy.AccessGrantRevoke, id)
+	if iErr != nil {
+		return iErr
+	}
+
+	found, err := g.dbRepo.RevokeAccessGrant(ctx, grant.ID, constant.RoleName(uInfo.Role), time.Now())
+	if err != nil {
+		logger.Error("Error revoking access grant, error: ", err.Error())
+		return errors.InternalServerError
+	}
+
+	if !found {
+		return errors.BadRequestError(fmt.Sprintf("access grant is already %s", grant.Status))
+	}
+
+	return nil
+}
+
+// respond accepts or declines a pending grant on behalf of the practitioner it was given to
+func (g *grantService) respond(uInfo *model.ContextInfo, id, status string) (model.AccessGrantResponse, errors.InternalError) {
+	ctx := context.Background()
+
+	grant, iErr := g.getAuthorizedGrant(ctx, uInfo, policy.AccessGrantRespond, id)
+	if iErr != nil {
+		return model.AccessGrantResponse{}, iErr
+	}
+
+	now := time.Now()
+	found, err := g.dbRepo.RespondToAccessGrant(ctx, grant.ID, status, now)
+	if err != nil {
+		logger.Error("Error responding to access grant, error: ", err.Error())
+		return model.AccessGrantResponse{}, errors.InternalServerError
+	}
+
+	if !found {
+		return model.AccessGrantResponse{}, errors.BadRequestError(fmt.Sprintf("access grant is already %s", grantStatus(&grant, now)))
+	}
+
+	grant.Status = status
+	grant.RespondedAt = &now
+	return grant, nil
+}
+
+func (g *grantService) getAuthorizedGrant(ctx context.Context, uInfo *model.ContextInfo, action policy.Action, id string) (model.AccessGrantResponse, errors.InternalError) {
+	grantID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return model.AccessGrantResponse{}, errors.BadRequestError("invalid id")
+	}
+
+	grant, found, err := g.dbRepo.GetAccessGrant(ctx, grantID)
+	if err != nil {
+		logger.Error("Error fetching access grant, error: ", err.Error())
+		return model.AccessGrantResponse{}, errors.InternalServerError
+	}
+
+	if !found {
+		return model.AccessGrantResponse{}, errors.ResourceNotFoundError("access grant not found")
+	}
+
+	if err := policy.Authorize(uInfo, action, policy.AccessGrant(&grant)); err != nil {
+		return model.AccessGrantResponse{}, err
+	}
+
+	return grant, nil
+}
+
+func (g *grantService) sendGrantRequest(ctx context.Context, patientName string, practitioner *model.PractitionerResponse, scope string, expiresAt *time.Time) error {
+	emailData := map[string]string{
+		"Name":        practitioner.FullName,
+		"PatientName": patientName,
+		"Scope":       scope,
+		"Link":        fmt.Sprintf("%s/practitioner/access-grants", strings.TrimRight(config.GetConfig().AppBaseURL, "/")),
+	}
+	if expiresAt != nil {
+		emailData["ExpiresAt"] = expiresAt.Format("January 2, 2006")
+	}
+
+	html, err := utility.RenderTemplate(grantTemplate, emailData)
+	if err != nil {
+		return err
+	}
+
+	msg := &notification.Message{Subject: fmt.Sprintf(grantSubject, patientName), HTML: html}
+	_, err = g.notifier.Send(ctx, []string{constant.ChannelEmail}, notification.Recipient{Email: practitioner.Email}, msg)
+	return err
+}
+
+// grantStatus reports pending and active grants past their expiry date as expired
+func grantStatus(grant *model.AccessGrantResponse, now time.Time) string {
+	if grant.ExpiresAt != nil && !grant.ExpiresAt.After(now) &&
+		(grant.Status == constant.InvitationPending || grant.Status == constant.InvitationActive) {
+		return constant.InvitationExpired
+	}
+
+	return grant.Status
+}
+
+func grantResponse(grant *model.AccessGrant) model.AccessGrantResponse {
+	return model.AccessGrantResponse{
+		ID:             grant.ID,
+		PatientID:      grant.PatientID,
+		PractitionerID: grant.PractitionerID,
+		MedicationID:   grant.MedicationID,
+		Status:         grant.Status,
+		ExpiresAt:      grant.ExpiresAt,
+		CreatedAt:      grant.CreatedAt,
+	}
+}
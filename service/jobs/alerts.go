@@ -0,0 +1,226 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"medbuddy-backend/internal/config"
+	"medbuddy-backend/internal/constant"
+	"medbuddy-backend/internal/model"
+	"medbuddy-backend/pkg/events"
+	"medbuddy-backend/pkg/notification"
+	"medbuddy-backend/utility"
+	"strings"
+	"time"
+)
+
+var (
+	consecutiveMissedMessage = "%s has missed %d doses in a row"
+	lowAdherenceMessage      = "%s has taken %.1f%% of their doses over the last 7 days, below your %.0f%% threshold"
+	medicationDeletedMessage = "%s deleted their %s medication"
+
+	alertSubject  = "MedBuddy alert about %s"
+	alertText     = "Hi %s, %s. See your alerts at %s."
+	alertTemplate = "utility/template/practitioner_alert.html"
+)
+
+// evaluateAlertRules checks the adherence of the patients of every practitioner with an enabled
+// consecutive_missed or low_adherence rule, and raises the alerts of the rules they break
+func (d *reminderDispatcher) evaluateAlertRules() {
+	if !d.begin() {
+		return
+	}
+	defer d.wg.Done()
+
+	ctx := context.Background()
+
+	filter := model.AlertRuleFilter{Types: []string{constant.AlertConsecutiveMissed, constant.AlertLowAdherence}}
+	rules, err := d.dbRepo.GetEnabledAlertRules(ctx, &filter)
+	if err != nil {
+		logger.Error("Could not fetch alert rules, got error: ", err.Error())
+		return
+	}
+
+	// rules are sorted by practitioner
+	var raised int
+	for start := 0; start < len(rules); {
+		end := start
+		for end < len(rules) && rules[end].PractitionerID == rules[start].PractitionerID {
+			end++
+		}
+
+		raised += d.evaluatePractitionerRules(ctx, rules[start:end])
+		start = end
+	}
+
+	if raised > 0 {
+		logger.Infof("Raised %v alert(s) on %v rule(s)", raised, len(rules))
+	}
+}
+
+// evaluatePractitionerRules evaluates the rules of a practitioner on every patient of their roster
+func (d *reminderDispatcher) evaluatePractitionerRules(ctx context.Context, rules []model.AlertRule) (raised int) {
+	now := time.Now()
+	filter := model.RosterFilter{
+		PractitionerID: rules[0].PractitionerID,
+		Since:          now.Add(-constant.RosterAdherencePeriod),
+		Sort:           constant.RosterSortName,
+		Page:           1,
+		Limit:          constant.MaxPageLimit,
+	}
+
+	for {
+		patients, total, err := d.dbRepo.GetPractitionerRoster(ctx, &filter, now)
+		if err != nil {
+			logger.Error("Could not fetch practitioner's patients, got error: ", err.Error())
+			return
+		}
+
+		for i := range patients {
+			for j := range rules {
+				alert, ok := ruleAlert(&rules[j], &patients[i], now)
+				if ok && d.raiseAlert(ctx, alert, patients[i].Patient.FullName) {
+					raised++
+				}
+			}
+		}
+
+		if int64(filter.Page*filter.Limit) >= total {
+			return
+		}
+		filter.Page++
+	}
+}
+
+// ruleAlert returns the alert the rule raises on the patient, if the patient breaks it
+func ruleAlert(rule *model.AlertRule, patient *model.RosterPatient, now time.Time) (*model.Alert, bool) {
+	if !rule.PatientID.IsZero() && rule.PatientID != patient.PatientID {
+		return nil, false
+	}
+
+	alert := &model.Alert{
+		ID:             primitive.NewObjectID(),
+		RuleID:         rule.ID,
+		PractitionerID: rule.PractitionerID,
+		PatientID:      patient.PatientID,
+		Type:           rule.Type,
+		Status:         constant.AlertOpen,
+		CreatedAt:      now,
+	}
+
+	switch rule.Type {
+	case constant.AlertConsecutiveMissed:
+		if float64(patient.ConsecutiveMissed) < rule.Threshold {
+			return nil, false
+		}
+		alert.Value = float64(patient.ConsecutiveMissed)
+		alert.Message = fmt.Sprintf(consecutiveMissedMessage, patient.Patient.FullName, patient.ConsecutiveMissed)
+	case constant.AlertLowAdherence:
+		rate := patient.RecentAdherence.Rate
+		if rate == nil || *rate >= rule.Threshold {
+			return nil, false
+		}
+		alert.Value = *rate
+		alert.Message = fmt.Sprintf(lowAdherenceMessage, patient.Patient.FullName, *rate, rule.Threshold)
+	default:
+		return nil, false
+	}
+
+	return alert, true
+}
+
+// alertOnDeletedMedication raises the alerts of the medication_deleted rules of the practitioners
+// who had access to a deleted medication
+func (d *reminderDispatcher) alertOnDeletedMedication(ctx context.Context, event events.Event) {
+	deleted, ok := event.Data.(model.DeletedMedication)
+	if !ok || len(deleted.PractitionerIDs) == 0 {
+		return
+	}
+
+	// the practitioners are emailed away from the request that deleted the medication
+	if !d.begin() {
+		return
+	}
+
+	go func() {
+		defer d.wg.Done()
+
+		ctx := context.Background()
+		filter := model.AlertRuleFilter{PractitionerIDs: deleted.PractitionerIDs, Types: []string{constant.AlertMedicationDeleted}}
+		rules, err := d.dbRepo.GetEnabledAlertRules(ctx, &filter)
+		if err != nil {
+			logger.Error("Could not fetch alert rules, got error: ", err.Error())
+			return
+		}
+
+		for _, rule := range rules {
+			if !rule.PatientID.IsZero() && rule.PatientID != deleted.PatientID {
+				continue
+			}
+
+			alert := &model.Alert{
+				ID:             primitive.NewObjectID(),
+				RuleID:         rule.ID,
+				PractitionerID: rule.PractitionerID,
+				PatientID:      deleted.PatientID,
+				MedicationID:   deleted.MedicationID,
+				Type:           rule.Type,
+				Message:        fmt.Sprintf(medicationDeletedMessage, deleted.PatientName, deleted.Name),
+				Status:         constant.AlertOpen,
+				CreatedAt:      deleted.DeletedAt,
+			}
+			d.raiseAlert(ctx, alert, deleted.PatientName)
+		}
+	}()
+}
+
+// raiseAlert stores the alert and emails it to the practitioner, unless the rule already alerted
+// them about the patient recently. It reports whether the alert was stored.
+func (d *reminderDispatcher) raiseAlert(ctx context.Context, alert *model.Alert, patientName string) bool {
+	created, err := d.dbRepo.RaiseAlert(ctx, alert, alert.CreatedAt.Add(-constant.AlertCooldown))
+	if err != nil {
+		logger.Error("Could not store alert, got error: ", err.Error())
+		return false
+	}
+
+	if !created {
+		return false
+	}
+
+	practitioner, found, err := d.dbRepo.GetPractitionerByID(ctx, alert.PractitionerID)
+	if err != nil || !found {
+		logger.Errorf("Error fetching practitioner %s, error: %v", alert.PractitionerID.Hex(), err)
+		return true
+	}
+
+	// like reminders, alerts are not emailed to unverified addresses
+	if !practitioner.User.Verified {
+		return true
+	}
+
+	link := fmt.Sprintf("%s/practitioner/alerts", strings.TrimRight(config.GetConfig().AppBaseURL, "/"))
+	data := map[string]string{
+		"Name":    practitioner.FullName,
+		"Message": alert.Message,
+		"Link":    link,
+	}
+
+	html, err := utility.RenderTemplate(alertTemplate, data)
+	if err != nil {
+		logger.Error("Error rendering alert email, error: ", err.Error())
+		return true
+	}
+
+	msg := &notification.Message{
+		Subject: fmt.Sprintf(alertSubject, patientName),
+		HTML:    html,
+		Text:    fmt.Sprintf(alertText, practitioner.FullName, alert.Message, link),
+	}
+
+	to := notification.Recipient{Name: practitioner.FullName, Email: practitioner.Email}
+	if _, err := d.notifier.Send(ctx, []string{constant.ChannelEmail}, to, msg); err != nil {
+		logger.Errorf("Could not send alert email to practitioner '%s', error: %s", practitioner.Email, err.Error())
+	}
+
+	return true
+}
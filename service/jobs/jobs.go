@@ -4,19 +4,44 @@ import (
 	"context"
 	"fmt"
 	"github.com/go-co-op/gocron"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"medbuddy-backend/internal/config"
 	"medbuddy-backend/internal/constant"
 	"medbuddy-backend/internal/model"
+	"medbuddy-backend/pkg/events"
+	"medbuddy-backend/pkg/notification"
 	"medbuddy-backend/pkg/repository/mongo"
+	"medbuddy-backend/pkg/repository/storage"
+	"medbuddy-backend/service/medication"
 	"medbuddy-backend/utility"
+	"os"
+	"strings"
+	"sync"
 	"time"
 )
 
 var (
-	emailSubject = "Reminder to take %s"
+	reminderSubject  = "Reminder to take %s"
+	reminderText     = "Hi %s, it's time to take %s of %s."
+	reminderTemplate = "utility/template/reminder.html"
+
+	caregiverReminderSubject  = "Reminder for %s to take %s"
+	caregiverReminderText     = "Hi %s, it's time for %s to take %s of %s."
+	caregiverReminderTemplate = "utility/template/caregiver_reminder.html"
 
 	CronScheduler *gocron.Scheduler
+	dispatcher    *reminderDispatcher
 	logger        = utility.NewLogger()
+
+	// instanceID identifies this server among the instances sharing the database
+	instanceID = newInstanceID()
+)
+
+const (
+	// maxConcurrentReminders is the number of reminders sent at the same time
+	maxConcurrentReminders = 10
+
+	maintenanceInterval = time.Minute
 )
 
 type Cron struct {
@@ -27,74 +52,499 @@ func NewCronJob() *Cron {
 	s := gocron.NewScheduler(time.UTC)
 
 	CronScheduler = s
+	dispatcher = newReminderDispatcher(mongo.GetDB())
+	events.Subscribe(constant.EventDoseMissed, dispatcher.notifyCaregiversOfMissedDose)
+	events.Subscribe(constant.EventMedicationDeleted, dispatcher.alertOnDeletedMedication)
+	events.Subscribe(constant.EventRefillNeeded, dispatcher.notifyRefillNeeded)
 	return &Cron{s}
 }
 
 func (c *Cron) StartJobs() {
-	// 4
-	c.scheduler.Every(constant.TimeLapseInMinutes).Minute().Do(fetchTasks)
+	// Housekeeping runs on a single instance while every instance dispatches reminders
+	c.scheduler.Every(maintenanceInterval).SingletonMode().
+		Do(leaderOnly(constant.ReminderMaintenanceLock, maintenanceInterval, dispatcher.expireMissedTasks))
+	c.scheduler.Every(maintenanceInterval).SingletonMode().
+		Do(leaderOnly(constant.ReminderMaintenanceLock, maintenanceInterval, dispatcher.detectMissedDoses))
+	c.scheduler.Every(maintenanceInterval).SingletonMode().
+		Do(leaderOnly(constant.ReminderMaintenanceLock, maintenanceInterval, dispatcher.resumePausedMedications))
+	c.scheduler.Every(constant.AlertEvaluationInterval).SingletonMode().
+		Do(leaderOnly(constant.AlertEvaluationLock, constant.AlertEvaluationInterval, dispatcher.evaluateAlertRules))
+
+	c.scheduler.Every(dispatcher.pollInterval).SingletonMode().Do(dispatcher.dispatchDueTasks)
 
-	// 5
 	c.scheduler.StartAsync()
-	logger.Info("Background cron jobs started...")
+	logger.Infof("Background cron jobs started on instance %s...", instanceID)
 }
 
-func StopJobs() {
+// StopJobs stops scheduling new work and waits for the reminders being sent to complete, or
+// for ctx to be done. Tasks that could not complete are taken over once their lease expires.
+func StopJobs(ctx context.Context) {
 	CronScheduler.Stop()
+
+	if err := dispatcher.stop(ctx); err != nil {
+		logger.Error("Error waiting for reminders to be sent, error: ", err.Error())
+		return
+	}
+
+	// Let another instance take over the housekeeping jobs right away
+	for _, lock := range []string{constant.ReminderMaintenanceLock, constant.AlertEvaluationLock} {
+		if err := mongo.GetDB().ReleaseLock(ctx, lock, instanceID); err != nil {
+			logger.Error("Error releasing job lock, error: ", err.Error())
+		}
+	}
 	logger.Info("SUCCESSFULLY STOPPED CRON JOBS")
 }
 
-func fetchTasks() {
-	ctx := context.Background()
+// leaderOnly wraps a job so that it only runs on the instance holding the named lock. The lock
+// outlives a couple of runs, so it stays with the same instance until that instance stops.
+func leaderOnly(name string, interval time.Duration, job func()) func() {
+	return func() {
+		acquired, err := mongo.GetDB().AcquireLock(context.Background(), name, instanceID, 3*interval)
+		if err != nil {
+			logger.Errorf("Error acquiring lock '%s', error: %s", name, err.Error())
+			return
+		}
+
+		if !acquired {
+			return
+		}
 
-	dbRepo := mongo.GetDB()
-	tasks, err := dbRepo.GetLatestTasks(ctx, time.Now())
+		job()
+	}
+}
+
+func newInstanceID() string {
+	hostname, err := os.Hostname()
 	if err != nil {
-		logger.Error("Could not fetch latest tasks, got error: ", err.Error())
+		hostname = constant.AppName
+	}
+
+	return fmt.Sprintf("%s-%s", hostname, primitive.NewObjectID().Hex())
+}
+
+// reminderDispatcher sends the reminders of due tasks. Every instance runs a dispatcher, a task
+// is leased by one of them before it is sent so that a reminder is not sent twice. The lease is
+// renewed while the reminder is being sent and tasks of an instance that died are taken over
+// once their lease expires.
+type reminderDispatcher struct {
+	dbRepo       storage.StorageRepository
+	notifier     *notification.Dispatcher
+	medications  medication.MedicationService
+	pollInterval time.Duration
+	gracePeriod  time.Duration
+	lease        time.Duration
+
+	// missedDoseGracePeriod is how long after its reminder time a dosage is marked as missed
+	missedDoseGracePeriod time.Duration
+
+	mu       sync.Mutex
+	stopping bool
+	wg       sync.WaitGroup
+}
+
+func newReminderDispatcher(dbRepo storage.StorageRepository) *reminderDispatcher {
+	conf := config.GetConfig()
+
+	d := &reminderDispatcher{
+		dbRepo:       dbRepo,
+		notifier:     notification.NewDispatcherFromConfig(conf),
+		medications:  medication.NewMedicationService(dbRepo),
+		pollInterval: PollInterval(),
+		gracePeriod:  constant.DefaultReminderGracePeriod,
+		lease:        constant.DefaultReminderLease,
+
+		missedDoseGracePeriod: constant.DefaultMissedDoseGracePeriod,
+	}
+
+	if conf.ReminderGracePeriodMinutes > 0 {
+		d.gracePeriod = time.Duration(conf.ReminderGracePeriodMinutes) * time.Minute
+	}
+	if conf.ReminderLeaseSeconds > 0 {
+		d.lease = time.Duration(conf.ReminderLeaseSeconds) * time.Second
+	}
+	if conf.MissedDoseGracePeriodMins > 0 {
+		d.missedDoseGracePeriod = time.Duration(conf.MissedDoseGracePeriodMins) * time.Minute
+	}
+
+	return d
+}
+
+// PollInterval returns how often due tasks are looked up
+func PollInterval() time.Duration {
+	if secs := config.GetConfig().ReminderPollIntervalSeconds; secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+
+	return constant.DefaultReminderPollInterval
+}
+
+// begin registers a unit of work, it returns false once the dispatcher is stopping
+func (d *reminderDispatcher) begin() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.stopping {
+		return false
+	}
+
+	d.wg.Add(1)
+	return true
+}
+
+func (d *reminderDispatcher) stop(ctx context.Context) error {
+	d.mu.Lock()
+	d.stopping = true
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *reminderDispatcher) expireMissedTasks() {
+	if !d.begin() {
 		return
 	}
+	defer d.wg.Done()
+
+	expired, err := d.dbRepo.ExpireTasks(context.Background(), time.Now().Add(-d.gracePeriod))
+	if err != nil {
+		logger.Error("Could not expire missed tasks, got error: ", err.Error())
+	} else if expired > 0 {
+		logger.Infof("Expired %v task(s) older than the %v grace period", expired, d.gracePeriod)
+	}
+}
+
+// dispatchDueTasks claims every task that is due and sends its reminder. Tasks whose time
+// passed while the server was down are caught up if they are within the grace period.
+func (d *reminderDispatcher) dispatchDueTasks() {
+	if !d.begin() {
+		return
+	}
+	defer d.wg.Done()
+
+	ctx := context.Background()
+	sem := make(chan struct{}, maxConcurrentReminders)
+
+	var claimed int
+	for {
+		// a task is only claimed once there is room to send it, its lease would otherwise run out
+		// while it waits for the reminders being sent and another instance would send it as well
+		sem <- struct{}{}
 
-	logger.Infof("Successfully fetched %v tasks to be executed \n", len(tasks))
+		now := time.Now()
+		task, found, err := d.dbRepo.ClaimDueTask(ctx, instanceID, now, now.Add(-d.gracePeriod), now.Add(d.lease))
+		if err != nil {
+			<-sem
+			logger.Error("Could not claim due task, got error: ", err.Error())
+			break
+		}
 
-	var timeIntervals []time.Duration
-	for idx, task := range tasks {
-		var interval time.Duration
-		if idx == 0 {
-			interval = task.Time.Sub(time.Now())
+		if !found {
+			<-sem
+			break
 		}
-		if idx > 0 {
-			interval = task.Time.Sub(tasks[idx-1].Time)
 
+		if !d.begin() {
+			<-sem
+			// the dispatcher was stopped after the task was claimed, release it for the next run
+			d.complete(task, constant.TaskUndone, "dispatcher stopped")
+			break
 		}
 
-		timeIntervals = append(timeIntervals, interval)
+		claimed++
+		go func(task model.Task) {
+			defer func() {
+				<-sem
+				d.wg.Done()
+			}()
+
+			d.runTask(ctx, task)
+		}(task)
 	}
 
-	runTasks(timeIntervals, tasks)
+	if claimed > 0 {
+		logger.Infof("Claimed %v task(s) to be executed", claimed)
+	}
 }
 
-func runTasks(intervals []time.Duration, tasks []model.LatestTaskResponse) {
-	for i := 0; i < len(tasks); i++ {
-		time.Sleep(intervals[i])
-		go func(task model.LatestTaskResponse) {
-			config := config.GetConfig()
-			emailEntity := utility.NewEmail(config.EmailDomain, fmt.Sprintf(emailSubject, task.Medication.Medicine.Name),
-				task.Medication.Patient.Email, config.MailgunEmailKey)
+func (d *reminderDispatcher) runTask(ctx context.Context, claimed model.Task) {
+	stopRenewal := d.renewLease(claimed)
+	defer stopRenewal()
 
-			err := emailEntity.SendReminderEmail(logger, &task.Medication)
-			if err != nil {
-				logger.Errorf("Got error while sending email to '%s', error: %s", task.Medication.Patient.Email, err.Error())
-				return
-			}
+	task, found, err := d.dbRepo.GetTask(ctx, claimed.ID)
+	if err != nil {
+		logger.Error("Error fetching task details, error: ", err.Error())
+		d.retryOrFail(claimed, err)
+		return
+	}
+
+	if !found || task.Medication.Patient.Email == "" {
+		d.complete(claimed, constant.TaskFailed, "medication or patient of task not found")
+		return
+	}
+
+	// the patient took or skipped the dosage before it was reminded again, or it was missed
+	if task.DosageStatus != "" && task.DosageStatus != constant.DosageNotTaken {
+		d.complete(claimed, constant.TaskDone, fmt.Sprintf("dosage is already %s", task.DosageStatus))
+		return
+	}
+
+	// the medication was paused or discontinued after the task was claimed
+	if task.DosageStatus == constant.DosageNotTaken && !task.DosageActive {
+		d.complete(claimed, constant.TaskDone, "dosage is no longer active")
+		return
+	}
+
+	channels, err := d.reminderChannels(ctx, &task.Medication.Patient)
+	if err != nil {
+		logger.Error("Error fetching patient's account, error: ", err.Error())
+		d.retryOrFail(claimed, err)
+		return
+	}
+
+	if len(channels) == 0 {
+		d.complete(claimed, constant.TaskFailed, "patient has not verified their account or has no channel to be reminded on")
+		return
+	}
+
+	delivered, err := d.sendReminder(ctx, &task, channels)
+	if len(delivered) == 0 {
+		logger.Errorf("Got error while sending reminder to '%s', error: %s", task.Medication.Patient.Email, err.Error())
+		d.retryOrFail(claimed, err)
+		return
+	}
+
+	if err != nil {
+		// the reminder reached the patient, retrying would send it again on the other channels
+		logger.Warnf("Could not send reminder to '%s' on every channel, error: %s", task.Medication.Patient.Email, err.Error())
+	}
+
+	logger.Infof("Successfully sent reminder to '%s' via %v", task.Medication.Patient.Email, delivered)
+	d.complete(claimed, constant.TaskDone, "")
+
+	d.sendCaregiverCopies(ctx, &task)
+	d.scheduleReRemind(ctx, &task)
+}
+
+// scheduleReRemind adds a task to send the reminder of a dosage again, when the patient asked to
+// be reminded again and has not set the status of the dosage by then. Only the scheduled reminder
+// of a dosage is sent again, not the ones of a snooze nor the ones sent again themselves.
+func (d *reminderDispatcher) scheduleReRemind(ctx context.Context, task *model.LatestTaskResponse) {
+	minutes := task.Medication.Patient.NotificationPreferences.ReRemindMinutes
+	if minutes <= 0 || task.Kind != "" {
+		return
+	}
+
+	reRemind := model.Task{
+		ID:           primitive.NewObjectID(),
+		Time:         time.Now().Add(time.Duration(minutes) * time.Minute),
+		Status:       constant.TaskUndone,
+		MedicationID: task.MedicationID,
+		DosageID:     task.DosageID,
+		Kind:         constant.TaskKindReRemind,
+	}
+
+	if _, err := d.dbRepo.AddTasks(ctx, []model.Task{reRemind}); err != nil {
+		logger.Error("Error adding task to remind the patient again, error: ", err.Error())
+	}
+}
 
-			logger.Infof("Successfully sent reminder email to '%s'", task.Medication.Patient.Email)
+// renewLease keeps extending the lease on a task until the returned function is called
+func (d *reminderDispatcher) renewLease(task model.Task) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(d.lease / 3)
+		defer ticker.Stop()
 
-			db := mongo.GetDB()
-			if err := db.UpdateTask(context.Background(), task.ID, constant.TaskDone); err != nil {
-				logger.Error("Error updating task to `done`, error: ", err.Error())
+		for {
+			select {
+			case <-done:
 				return
+			case <-ticker.C:
+				renewed, err := d.dbRepo.RenewTaskLease(context.Background(), task.ID, instanceID, time.Now().Add(d.lease))
+				if err != nil {
+					logger.Error("Error renewing task lease, error: ", err.Error())
+					continue
+				}
+
+				if !renewed {
+					logger.Warnf("Lease on task %s was lost to another instance", task.ID.Hex())
+					return
+				}
 			}
-			logger.Infof("Successfully updated task to send reminder email to '%s'\n", task.Medication.Patient.Email)
-		}(tasks[i])
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+func (d *reminderDispatcher) retryOrFail(task model.Task, err error) {
+	if task.Attempts < constant.MaxTaskAttempts {
+		nextAttemptAt := time.Now().Add(retryBackoff(task.Attempts))
+		if rErr := d.dbRepo.ReleaseTask(context.Background(), task.ID, instanceID, nextAttemptAt, err.Error()); rErr != nil {
+			logger.Error("Error releasing task to be tried again, error: ", rErr.Error())
+		}
+		return
+	}
+
+	d.complete(task, constant.TaskFailed, err.Error())
+}
+
+// retryBackoff returns how long a task waits before it is tried again after its attempts-th attempt
+// failed, so that an outage of a channel does not use up its attempts at once
+func retryBackoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	if attempts > 10 {
+		return constant.TaskRetryBackoffMax
+	}
+
+	wait := constant.TaskRetryBackoffBase << (attempts - 1)
+	if wait > constant.TaskRetryBackoffMax {
+		return constant.TaskRetryBackoffMax
+	}
+
+	return wait
+}
+
+func (d *reminderDispatcher) complete(task model.Task, status string, reason string) {
+	if err := d.dbRepo.CompleteTask(context.Background(), task.ID, instanceID, status, reason); err != nil {
+		logger.Errorf("Error updating task to `%s`, error: %s", status, err.Error())
+	}
+}
+
+// reminderChannels returns the channels a patient may be reminded on. Reminders name the patient's
+// medication and carry links that set the status of its dosages, so patients who have not verified
+// their email address are reminded on no channel: their phone number, push tokens and webhook URL
+// are as unconfirmed as the address.
+func (d *reminderDispatcher) reminderChannels(ctx context.Context, patient *model.PatientForDosage) ([]string, error) {
+	// patients who have not set their preferences are reminded by email
+	channels := patient.NotificationPreferences.Channels
+	if len(channels) == 0 {
+		channels = []string{constant.ChannelEmail}
+	}
+
+	user, found, err := d.dbRepo.GetUserByID(ctx, patient.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !found || !user.Verified {
+		return nil, nil
+	}
+
+	return channels, nil
+}
+
+// sendReminder notifies the patient of a task on the given channels and returns the channels the
+// reminder was delivered on
+func (d *reminderDispatcher) sendReminder(ctx context.Context, task *model.LatestTaskResponse, channels []string) ([]string, error) {
+	medication := &task.Medication
+	patient := medication.Patient
+
+	html, err := utility.RenderTemplate(reminderTemplate, reminderEmail{
+		MedicationForDosage: medication,
+		TakenLink:           dosageActionLink(task.DosageID, constant.DosageTaken),
+		SkipLink:            dosageActionLink(task.DosageID, constant.DosageSkipped),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &notification.Message{
+		Subject: fmt.Sprintf(reminderSubject, medication.Medicine.Name),
+		HTML:    html,
+		Text:    fmt.Sprintf(reminderText, patient.FullName, medication.DosageQuantity, medication.Medicine.Name),
+		Event:   "reminder.due",
+		Data: map[string]interface{}{
+			"task_id":       task.ID.Hex(),
+			"medication_id": task.MedicationID.Hex(),
+			"dosage_id":     task.DosageID.Hex(),
+			"medicine":      medication.Medicine.Name,
+		},
+	}
+
+	to := notification.Recipient{
+		Name:       patient.FullName,
+		Email:      patient.Email,
+		Phone:      patient.NotificationPreferences.Phone,
+		PushToken:  patient.NotificationPreferences.PushToken,
+		WebhookURL: patient.NotificationPreferences.WebhookURL,
+	}
+
+	return d.notifier.Send(ctx, channels, to, msg)
+}
+
+// reminderEmail is what reminder emails are rendered with, the links are empty when the public url
+// of the server is not configured
+type reminderEmail struct {
+	*model.MedicationForDosage
+	TakenLink string
+	SkipLink  string
+}
+
+// dosageActionLink returns a signed link that sets the status of a dosage without logging in
+func dosageActionLink(dosageID primitive.ObjectID, status string) string {
+	cfg := config.GetConfig()
+	if cfg.APIBaseURL == "" {
+		return ""
+	}
+
+	token := utility.SignDosageAction(cfg.SecretKey, dosageID, status, time.Now().Add(constant.DosageActionLinkTTL))
+	return fmt.Sprintf("%s/api/v1/dosage-actions/%s", strings.TrimRight(cfg.APIBaseURL, "/"), token)
+}
+
+// sendCaregiverCopies emails a copy of a reminder to the caregivers of the patient who asked for
+// one. The reminder has already been sent to the patient, so failures are only logged.
+func (d *reminderDispatcher) sendCaregiverCopies(ctx context.Context, task *model.LatestTaskResponse) {
+	medication := &task.Medication
+
+	caregivers, err := d.dbRepo.GetCaregiversToRemind(ctx, medication.PatientID)
+	if err != nil {
+		logger.Error("Error fetching patient's caregivers, error: ", err.Error())
+		return
+	}
+
+	for _, caregiver := range caregivers {
+		// the copy names the patient's medication, like the reminder it only goes to verified addresses
+		if !caregiver.User.Verified {
+			continue
+		}
+
+		data := map[string]interface{}{
+			"Name":           caregiver.FullName,
+			"PatientName":    medication.Patient.FullName,
+			"DosageQuantity": medication.DosageQuantity,
+			"Medicine":       medication.Medicine,
+		}
+
+		html, err := utility.RenderTemplate(caregiverReminderTemplate, data)
+		if err != nil {
+			logger.Error("Error rendering caregiver reminder, error: ", err.Error())
+			return
+		}
+
+		msg := &notification.Message{
+			Subject: fmt.Sprintf(caregiverReminderSubject, medication.Patient.FullName, medication.Medicine.Name),
+			HTML:    html,
+			Text: fmt.Sprintf(caregiverReminderText, caregiver.FullName, medication.Patient.FullName,
+				medication.DosageQuantity, medication.Medicine.Name),
+		}
+
+		to := notification.Recipient{Name: caregiver.FullName, Email: caregiver.Email}
+		if _, err := d.notifier.Send(ctx, []string{constant.ChannelEmail}, to, msg); err != nil {
+			logger.Errorf("Could not send reminder copy to caregiver '%s', error: %s", caregiver.Email, err.Error())
+		}
 	}
 }
@@ -0,0 +1,144 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"medbuddy-backend/internal/constant"
+	"medbuddy-backend/internal/model"
+	"medbuddy-backend/pkg/events"
+	"medbuddy-backend/pkg/notification"
+	"medbuddy-backend/utility"
+	"time"
+)
+
+var (
+	missedDoseSubject  = "%s missed a dose of %s"
+	missedDoseText     = "Hi %s, %s has not recorded taking %s of %s that was due at %s."
+	missedDoseTemplate = "utility/template/caregiver_missed_dose.html"
+)
+
+// detectMissedDoses marks the dosages that are still not taken once the missed dose grace period
+// has passed since their reminder time, and publishes a dose.missed event for each of them
+func (d *reminderDispatcher) detectMissedDoses() {
+	if !d.begin() {
+		return
+	}
+	defer d.wg.Done()
+
+	ctx := context.Background()
+
+	var missed int
+	for {
+		var failed int
+		dosages, err := d.dbRepo.GetOverdueDosages(ctx, time.Now().Add(-d.missedDoseGracePeriod), constant.MissedDoseBatchSize)
+		if err != nil {
+			logger.Error("Could not fetch overdue dosages, got error: ", err.Error())
+			break
+		}
+
+		for _, dosage := range dosages {
+			now := time.Now()
+			found, err := d.dbRepo.MarkDosageMissed(ctx, dosage.ID, now)
+			if err != nil {
+				logger.Error("Could not mark dosage as missed, got error: ", err.Error())
+				failed++
+				continue
+			}
+
+			// the dosage was taken or skipped since it was fetched
+			if !found {
+				continue
+			}
+			missed++
+
+			if err := d.dbRepo.IncrementDosageCounts(ctx, dosage.MedicationID, map[string]int{constant.DosageMissed: 1}); err != nil {
+				logger.Error("Error when updating dosage counts, error: ", err.Error())
+			}
+
+			events.Publish(ctx, constant.EventDoseMissed, model.MissedDose{
+				DosageID:     dosage.ID,
+				MedicationID: dosage.MedicationID,
+				PatientID:    dosage.PatientID,
+				ReminderTime: dosage.ReminderTime,
+				MissedAt:     now,
+			})
+		}
+
+		// dosages that failed are tried again on the next run
+		if len(dosages) < constant.MissedDoseBatchSize || failed > 0 {
+			break
+		}
+	}
+
+	if missed > 0 {
+		logger.Infof("Marked %v dosage(s) as missed after the %v grace period", missed, d.missedDoseGracePeriod)
+	}
+}
+
+// notifyCaregiversOfMissedDose emails the caregivers who get copies of the patient's reminders
+// when one of their doses is missed
+func (d *reminderDispatcher) notifyCaregiversOfMissedDose(ctx context.Context, event events.Event) {
+	missed, ok := event.Data.(model.MissedDose)
+	if !ok {
+		return
+	}
+
+	caregivers, err := d.dbRepo.GetCaregiversToRemind(ctx, missed.PatientID)
+	if err != nil {
+		logger.Error("Error fetching patient's caregivers, error: ", err.Error())
+		return
+	}
+
+	if len(caregivers) == 0 {
+		return
+	}
+
+	dosage, found, err := d.dbRepo.GetDosage(ctx, missed.DosageID)
+	if err != nil || !found {
+		logger.Errorf("Error fetching missed dosage %s, error: %v", missed.DosageID.Hex(), err)
+		return
+	}
+
+	medication := &dosage.Medication
+	if dosage.Quantity != "" {
+		medication.DosageQuantity = dosage.Quantity
+	}
+	loc, err := utility.LoadTimeZone(medication.TimeZone)
+	if err != nil {
+		loc = time.Local
+	}
+
+	reminderTime := missed.ReminderTime.In(loc).Format("15:04 MST on January 2")
+	for _, caregiver := range caregivers {
+		// like reminder copies, the email names the patient's medication
+		if !caregiver.User.Verified {
+			continue
+		}
+
+		data := map[string]interface{}{
+			"Name":           caregiver.FullName,
+			"PatientName":    medication.Patient.FullName,
+			"DosageQuantity": medication.DosageQuantity,
+			"Medicine":       medication.Medicine,
+			"ReminderTime":   reminderTime,
+		}
+
+		html, err := utility.RenderTemplate(missedDoseTemplate, data)
+		if err != nil {
+			logger.Error("Error rendering missed dose email, error: ", err.Error())
+			return
+		}
+
+		msg := &notification.Message{
+			Subject: fmt.Sprintf(missedDoseSubject, medication.Patient.FullName, medication.Medicine.Name),
+			HTML:    html,
+			Text: fmt.Sprintf(missedDoseText, caregiver.FullName, medication.Patient.FullName,
+				medication.DosageQuantity, medication.Medicine.Name, reminderTime),
+		}
+
+		to := notification.Recipient{Name: caregiver.FullName, Email: caregiver.Email}
+		if _, err := d.notifier.Send(ctx, []string{constant.ChannelEmail}, to, msg); err != nil {
+			logger.Errorf("Could not send missed dose email to caregiver '%s', error: %s", caregiver.Email, err.Error())
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package jobs
+
+import (
+	"context"
+	"time"
+)
+
+// resumePausedMedications resumes the paused medications whose resume date has come, their
+// dosages and reminders are scheduled again from now on
+func (d *reminderDispatcher) resumePausedMedications() {
+	if !d.begin() {
+		return
+	}
+	defer d.wg.Done()
+
+	resumed, err := d.medications.ResumePausedMedications(context.Background(), time.Now())
+	if err != nil {
+		logger.Error("Could not resume paused medications, got error: ", err.Error())
+	} else if resumed > 0 {
+		logger.Infof("Resumed %v paused medication(s)", resumed)
+	}
+}
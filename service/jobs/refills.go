@@ -0,0 +1,106 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"medbuddy-backend/internal/model"
+	"medbuddy-backend/pkg/events"
+	"medbuddy-backend/pkg/notification"
+	"medbuddy-backend/utility"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	refillSubject  = "Time to refill your %s"
+	refillText     = "Hi %s, you have %s of %s left, which lasts until %s. Refill it so that you do not run out."
+	refillTemplate = "utility/template/refill.html"
+)
+
+// notifyRefillNeeded tells the patient, on the channels they are reminded on, that the stock of a
+// medication is running low
+func (d *reminderDispatcher) notifyRefillNeeded(ctx context.Context, event events.Event) {
+	refill, ok := event.Data.(model.RefillNeeded)
+	if !ok {
+		return
+	}
+
+	// the patient is notified away from the request that took the dosage
+	if !d.begin() {
+		return
+	}
+
+	go func() {
+		defer d.wg.Done()
+
+		ctx := context.Background()
+		medication, found, err := d.dbRepo.GetMedication(ctx, refill.MedicationID)
+		if err != nil || !found {
+			logger.Errorf("Error fetching medication %s to refill, error: %v", refill.MedicationID.Hex(), err)
+			return
+		}
+
+		patient := &model.PatientForDosage{
+			FullName:                medication.Patient.FullName,
+			Email:                   medication.Patient.Email,
+			UserID:                  medication.Patient.UserID,
+			NotificationPreferences: medication.Patient.NotificationPreferences,
+		}
+
+		channels, err := d.reminderChannels(ctx, patient)
+		if err != nil {
+			logger.Error("Error fetching patient's account, error: ", err.Error())
+			return
+		}
+
+		if len(channels) == 0 {
+			return
+		}
+
+		loc, err := utility.LoadTimeZone(medication.TimeZone)
+		if err != nil {
+			loc = time.Local
+		}
+
+		stock := strings.TrimSpace(strconv.FormatFloat(refill.Stock, 'f', -1, 64) + " " + refill.Unit)
+		runsOutAt := refill.RunsOutAt.In(loc).Format("Monday, January 2")
+		data := map[string]interface{}{
+			"Name":      patient.FullName,
+			"Stock":     stock,
+			"Medicine":  medication.Medicine,
+			"RunsOutAt": runsOutAt,
+		}
+
+		html, err := utility.RenderTemplate(refillTemplate, data)
+		if err != nil {
+			logger.Error("Error rendering refill email, error: ", err.Error())
+			return
+		}
+
+		msg := &notification.Message{
+			Subject: fmt.Sprintf(refillSubject, medication.Medicine.Name),
+			HTML:    html,
+			Text:    fmt.Sprintf(refillText, patient.FullName, stock, medication.Medicine.Name, runsOutAt),
+			Event:   "refill.needed",
+			Data: map[string]interface{}{
+				"medication_id": refill.MedicationID.Hex(),
+				"medicine":      medication.Medicine.Name,
+				"stock":         refill.Stock,
+				"runs_out_at":   refill.RunsOutAt,
+			},
+		}
+
+		to := notification.Recipient{
+			Name:       patient.FullName,
+			Email:      patient.Email,
+			Phone:      patient.NotificationPreferences.Phone,
+			PushToken:  patient.NotificationPreferences.PushToken,
+			WebhookURL: patient.NotificationPreferences.WebhookURL,
+		}
+
+		if _, err := d.notifier.Send(ctx, channels, to, msg); err != nil {
+			logger.Errorf("Could not send refill reminder to '%s', error: %s", patient.Email, err.Error())
+		}
+	}()
+}
@@ -7,25 +7,37 @@ import (
 	"medbuddy-backend/internal/constant"
 	"medbuddy-backend/internal/errors"
 	"medbuddy-backend/internal/model"
+	"medbuddy-backend/internal/policy"
+	"medbuddy-backend/pkg/events"
 	"medbuddy-backend/pkg/repository/storage"
+	"medbuddy-backend/service/grant"
 	"medbuddy-backend/utility"
+	"strings"
+	"time"
 )
 
 type MedicationService interface {
 	AddMedication(userInfo *model.ContextInfo, data *model.MedicationRequest) (model.MedicationResponse, errors.InternalError)
-	GetMedication(id string) (model.MedicationResponse, errors.InternalError)
+	GetMedication(userInfo *model.ContextInfo, id string) (model.MedicationResponse, errors.InternalError)
 	GetPatientMedications(userInfo model.ContextInfo) ([]model.MedicationResponse, errors.InternalError)
-	UpdateMedication(userInfo *model.ContextInfo, id string, data *model.MedicationRequest) (model.MedicationResponse, errors.InternalError)
+	UpdateMedication(userInfo *model.ContextInfo, id string, data *model.UpdateMedicationRequest) (model.MedicationResponse, errors.InternalError)
 	DeleteMedication(userInfo *model.ContextInfo, id string) errors.InternalError
 	AddPractitionersToMedication(userInfo *model.ContextInfo, medicId string, practEmails []string) (string, errors.InternalError)
+	CreateMedication(ctx context.Context, patientID primitive.ObjectID, data *model.MedicationRequest, prescribedBy primitive.ObjectID) (model.MedicationResponse, errors.InternalError)
+	RefillMedication(userInfo *model.ContextInfo, id string, data *model.RefillRequest) (model.Inventory, errors.InternalError)
+	PauseMedication(userInfo *model.ContextInfo, id string, data *model.PauseMedicationRequest) (model.MedicationResponse, errors.InternalError)
+	ResumeMedication(userInfo *model.ContextInfo, id string) (model.MedicationResponse, errors.InternalError)
+	ResumePausedMedications(ctx context.Context, now time.Time) (int, errors.InternalError)
+	DiscontinueMedication(userInfo *model.ContextInfo, id string, data *model.DiscontinueMedicationRequest) (model.MedicationResponse, errors.InternalError)
 }
 
 type medicationService struct {
-	dbRepo storage.StorageRepository
+	dbRepo       storage.StorageRepository
+	grantService grant.GrantService
 }
 
 func NewMedicationService(dbRepo storage.StorageRepository) MedicationService {
-	return &medicationService{dbRepo: dbRepo}
+	return &medicationService{dbRepo: dbRepo, grantService: grant.NewGrantService(dbRepo)}
 }
 
 var (
@@ -41,12 +53,39 @@ func (m *medicationService) AddMedication(userInfo *model.ContextInfo, data *mod
 		return model.MedicationResponse{}, errors.InternalServerError
 	}
 
+	response, iErr := m.CreateMedication(ctx, patientID, data, primitive.NilObjectID)
+	if iErr != nil {
+		return model.MedicationResponse{}, iErr
+	}
+	response.Patient.Email = userInfo.Email
+
+	return response, nil
+}
+
+// CreateMedication creates the medication of a patient with its dosages and reminder tasks.
+// prescribedBy is the practitioner who prescribed it, or the zero id when the patient added it.
+func (m *medicationService) CreateMedication(ctx context.Context, patientID primitive.ObjectID, data *model.MedicationRequest, prescribedBy primitive.ObjectID) (model.MedicationResponse, errors.InternalError) {
 	startDate, err := utility.FormatTime(data.StartDate)
 	if err != nil {
 		logger.Error("Error converting startDate in AddMedication, error: ", err.Error())
 		return model.MedicationResponse{}, errors.BadRequestError(fmt.Sprint("StartDate: ", err.Error()))
 	}
 
+	// medications follow the time zone of the patient unless they are given one
+	if data.TimeZone == "" {
+		patient, _, err := m.dbRepo.GetPatientByID(ctx, patientID)
+		if err != nil {
+			logger.Error("Error fetching patient in AddMedication, error: ", err.Error())
+			return model.MedicationResponse{}, errors.InternalServerError
+		}
+		data.TimeZone = patient.TimeZone
+	}
+
+	// the dosages of a medication taken in phases are counted from its phases
+	if len(data.Phases) > 0 {
+		data.TotalNumberOfDosage = utility.PhaseDosageCount(data.Phases)
+	}
+
 	medication := model.Medication{
 		ID:                  primitive.NewObjectID(),
 		Name:                data.Name,
@@ -54,18 +93,31 @@ func (m *medicationService) AddMedication(userInfo *model.ContextInfo, data *mod
 		StartDate:           startDate,
 		DosageQuantity:      data.DosageQuantity,
 		DailyDosage:         data.DailyDosage,
+		DosageTimes:         data.DosageTimes,
+		Schedule:            data.Schedule,
+		Phases:              data.Phases,
+		TimeZone:            data.TimeZone,
 		Treatment:           data.Treatment,
 		CreatedAt:           utility.ReturnCurrentTime(),
 		UpdatedAt:           utility.ReturnCurrentTime(),
 		IsActive:            true,
 		Comment:             data.Comment,
 		TotalNumberOfDosage: data.TotalNumberOfDosage,
+		PrescribedBy:        prescribedBy,
 	}
 
-	if medication.TotalNumberOfDosage <= 0 {
+	// as needed medications may be taken without limit
+	if medication.TotalNumberOfDosage < 0 || (medication.TotalNumberOfDosage == 0 && !utility.IsAsNeeded(data.Schedule)) {
 		return model.MedicationResponse{}, errors.BadRequestError("invalid value for total number of dosage")
 	}
 
+	if data.Inventory != nil {
+		if err := utility.ValidateInventory(data.DosageQuantity, data.Phases); err != nil {
+			return model.MedicationResponse{}, err
+		}
+		medication.Inventory = newInventory(data.Inventory, nil)
+	}
+
 	med, found, err := m.dbRepo.GetMedicineFilter(ctx, &model.MedicineFilter{
 		Name:         data.Medicine.Name,
 		Manufacturer: data.Medicine.Manufacturer,
@@ -78,18 +130,13 @@ func (m *medicationService) AddMedication(userInfo *model.ContextInfo, data *mod
 		return model.MedicationResponse{}, errors.InternalServerError
 	}
 
+	newMedicine := !found
 	if found {
 		medication.MedicineID = med.ID
 	} else {
 		data.Medicine.ID = primitive.NewObjectID()
 		data.Medicine.CreatedAt = utility.ReturnCurrentTime()
 		data.Medicine.UpdatedAt = utility.ReturnCurrentTime()
-
-		if err := m.dbRepo.AddMedicine(ctx, &data.Medicine); err != nil {
-			logger.Error("Error adding new medicine in AddMedication, error: ", err.Error())
-			return model.MedicationResponse{}, errors.InternalServerError
-		}
-
 		medication.MedicineID = data.Medicine.ID
 	}
 
@@ -99,37 +146,54 @@ func (m *medicationService) AddMedication(userInfo *model.ContextInfo, data *mod
 		return model.MedicationResponse{}, errors.BadRequestError(err.Error())
 	}
 
+	var tasks []model.Task
 	for i := range dosages {
 		dosages[i].ID = primitive.NewObjectID()
 		dosages[i].MedicationID = medication.ID
 		dosages[i].PatientID = patientID
-	}
-
-	if err := m.dbRepo.SaveDosages(ctx, dosages); err != nil {
-		logger.Error("Error saving dosages in AddMedication, error: ", err.Error())
-		return model.MedicationResponse{}, errors.InternalServerError
-	}
-
-	if err := m.dbRepo.AddMedication(ctx, &medication); err != nil {
-		logger.Error("Error adding medication in AddMedication, error: ", err.Error())
-		return model.MedicationResponse{}, errors.InternalServerError
-	}
 
-	var tasks []model.Task
-	for _, dosage := range dosages {
-		t := model.Task{
+		tasks = append(tasks, model.Task{
 			ID:           primitive.NewObjectID(),
-			Time:         dosage.ReminderTime,
+			Time:         dosages[i].ReminderTime,
 			Status:       constant.TaskUndone,
 			MedicationID: medication.ID,
+			DosageID:     dosages[i].ID,
+		})
+	}
+
+	var count int64
+	err = m.dbRepo.WithTransaction(ctx, func(ctx context.Context) error {
+		if newMedicine {
+			if err := m.dbRepo.AddMedicine(ctx, &data.Medicine); err != nil {
+				logger.Error("Error adding new medicine in AddMedication, error: ", err.Error())
+				return err
+			}
 		}
 
-		tasks = append(tasks, t)
-	}
+		// as needed medications have no scheduled dosages
+		if len(dosages) > 0 {
+			if err := m.dbRepo.SaveDosages(ctx, dosages); err != nil {
+				logger.Error("Error saving dosages in AddMedication, error: ", err.Error())
+				return err
+			}
+		}
+
+		if err := m.dbRepo.AddMedication(ctx, &medication); err != nil {
+			logger.Error("Error adding medication in AddMedication, error: ", err.Error())
+			return err
+		}
+
+		if len(tasks) > 0 {
+			count, err = m.dbRepo.AddTasks(ctx, tasks)
+			if err != nil {
+				logger.Error("Error adding tasks in AddMedication, error: ", err.Error())
+				return err
+			}
+		}
 
-	count, err := m.dbRepo.AddTasks(ctx, tasks)
+		return nil
+	})
 	if err != nil {
-		logger.Error("Error adding tasks in AddMedication, error: ", err.Error())
 		return model.MedicationResponse{}, errors.InternalServerError
 	}
 
@@ -139,12 +203,12 @@ func (m *medicationService) AddMedication(userInfo *model.ContextInfo, data *mod
 	response.Dosages = dosages
 	response.Medicine = data.Medicine
 	response.Medicine.ID = medication.MedicineID
-	response.Patient = model.Patient{ID: patientID, Email: userInfo.Email}
+	response.Patient = model.Patient{ID: patientID}
 
 	return response, nil
 }
 
-func (m *medicationService) GetMedication(id string) (model.MedicationResponse, errors.InternalError) {
+func (m *medicationService) GetMedication(userInfo *model.ContextInfo, id string) (model.MedicationResponse, errors.InternalError) {
 	ctx := context.Background()
 
 	oID, err := primitive.ObjectIDFromHex(id)
@@ -163,6 +227,10 @@ func (m *medicationService) GetMedication(id string) (model.MedicationResponse,
 		return model.MedicationResponse{}, errors.ResourceNotFoundError("medication does not exist")
 	}
 
+	if err := policy.Authorize(userInfo, policy.MedicationRead, policy.Medication(&medic)); err != nil {
+		return model.MedicationResponse{}, err
+	}
+
 	return medic, nil
 }
 
@@ -184,10 +252,326 @@ func (m *medicationService) GetPatientMedications(userInfo model.ContextInfo) ([
 	return medics, nil
 }
 
-func (m *medicationService) UpdateMedication(userInfo *model.ContextInfo, id string, data *model.MedicationRequest) (model.MedicationResponse, errors.InternalError) {
-	//ctx := context.Background()
+func (m *medicationService) UpdateMedication(userInfo *model.ContextInfo, id string, data *model.UpdateMedicationRequest) (model.MedicationResponse, errors.InternalError) {
+	ctx := context.Background()
+
+	patientID, err := primitive.ObjectIDFromHex(userInfo.ID)
+	if err != nil {
+		logger.Error("Error converting hex Id to objectId at UpdateMedication, error: ", err.Error())
+		return model.MedicationResponse{}, errors.InternalServerError
+	}
+
+	medId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		logger.Error("Error converting medication id to objectId at UpdateMedication, error: ", err.Error())
+		return model.MedicationResponse{}, errors.BadRequestError("invalid id")
+	}
+
+	medic, found, err := m.dbRepo.GetMedication(ctx, medId)
+	if err != nil {
+		logger.Error("Error fetching medication by id in UpdateMedication, error: ", err.Error())
+		return model.MedicationResponse{}, errors.InternalServerError
+	}
+
+	if !found {
+		return model.MedicationResponse{}, errors.ResourceNotFoundError("medication not found")
+	}
+
+	if err := policy.Authorize(userInfo, policy.MedicationUpdate, policy.Medication(&medic)); err != nil {
+		return model.MedicationResponse{}, err
+	}
+
+	if medic.DiscontinuedAt != nil {
+		return model.MedicationResponse{}, errors.BadRequestError("a discontinued medication cannot be changed")
+	}
+
+	if medic.PausedAt != nil {
+		return model.MedicationResponse{}, errors.BadRequestError("medication is paused, resume it before changing it")
+	}
+
+	dosages, err := m.dbRepo.GetPatientDosages(ctx, &model.DosageFilter{PatiendID: patientID, MedicationID: medId})
+	if err != nil {
+		logger.Error("Error fetching medication dosages in UpdateMedication, error: ", err.Error())
+		return model.MedicationResponse{}, errors.InternalServerError
+	}
+
+	// dosages that have been taken or skipped are kept as they are
+	var completed int
+	for _, dosage := range dosages {
+		if !dosage.IsActive {
+			completed++
+		}
+	}
+
+	medication := utility.MedicationResponseToMedication(&medic)
+	if len(medication.DosageTimes) == 0 {
+		medication.DosageTimes = utility.GetDosageTimes(dosages, medication.DailyDosage)
+	}
+
+	reschedule := data.StartDate != nil || data.DailyDosage != nil || data.TotalNumberOfDosage != nil || len(data.DosageTimes) > 0 ||
+		data.Schedule != nil || len(data.Phases) > 0 || data.DosageQuantity != nil || data.TimeZone != nil
+	if data.Name != nil {
+		medication.Name = *data.Name
+	}
+	if data.DosageQuantity != nil {
+		medication.DosageQuantity = *data.DosageQuantity
+	}
+	if data.Treatment != nil {
+		medication.Treatment = *data.Treatment
+	}
+	if data.Comment != nil {
+		medication.Comment = *data.Comment
+	}
+	if data.DailyDosage != nil {
+		medication.DailyDosage = *data.DailyDosage
+	}
+	if data.TotalNumberOfDosage != nil {
+		medication.TotalNumberOfDosage = *data.TotalNumberOfDosage
+	}
+	if len(data.DosageTimes) > 0 {
+		medication.DosageTimes = data.DosageTimes
+		medication.Phases = nil
+	}
+	if len(data.Phases) > 0 {
+		medication.Phases = data.Phases
+		if data.DailyDosage == nil && len(data.DosageTimes) == 0 {
+			medication.DailyDosage = 0
+			medication.DosageTimes = nil
+		}
+	}
+	if len(medication.Phases) > 0 {
+		medication.TotalNumberOfDosage = utility.PhaseDosageCount(medication.Phases)
+	}
+	if data.TimeZone != nil {
+		medication.TimeZone = *data.TimeZone
+	}
+	if data.Schedule != nil {
+		medication.Schedule = data.Schedule
+		// the times of the previous schedule do not carry over to an as needed one
+		if utility.IsAsNeeded(data.Schedule) && data.DailyDosage == nil && len(data.DosageTimes) == 0 {
+			medication.DailyDosage = 0
+			medication.DosageTimes = nil
+		}
+	}
 
-	return model.MedicationResponse{}, nil
+	loc, err := utility.LoadTimeZone(medication.TimeZone)
+	if err != nil {
+		return model.MedicationResponse{}, errors.BadRequestError(err.Error())
+	}
+
+	from := time.Now()
+	if data.StartDate != nil {
+		startDate, err := utility.FormatTime(*data.StartDate)
+		if err != nil {
+			return model.MedicationResponse{}, errors.BadRequestError(fmt.Sprint("StartDate: ", err.Error()))
+		}
+
+		if utility.DateIn(startDate, loc).Before(utility.DateIn(from.In(loc), loc)) {
+			return model.MedicationResponse{}, errors.BadRequestError("invalid startDate")
+		}
+
+		medication.StartDate = startDate
+	}
+
+	if startDay := utility.DateIn(medication.StartDate, loc); startDay.After(from) {
+		from = startDay
+	}
+
+	// the stock changes as dosages are taken, it is only written when it is replaced
+	medication.Inventory = nil
+	if data.Inventory != nil || medic.Inventory != nil {
+		if err := utility.ValidateInventory(medication.DosageQuantity, medication.Phases); err != nil {
+			return model.MedicationResponse{}, err
+		}
+	}
+	if data.Inventory != nil {
+		medication.Inventory = newInventory(data.Inventory, medic.Inventory)
+	}
+
+	asNeeded := utility.IsAsNeeded(medication.Schedule)
+	if medication.TotalNumberOfDosage < 0 || (medication.TotalNumberOfDosage == 0 && !asNeeded) {
+		return model.MedicationResponse{}, errors.BadRequestError("invalid value for total number of dosage")
+	}
+
+	// as needed medications without a total are not limited by the dosages already taken
+	remaining := medication.TotalNumberOfDosage - completed
+	if remaining < 0 && medication.TotalNumberOfDosage > 0 && len(medication.Phases) == 0 {
+		return model.MedicationResponse{}, errors.BadRequestError(fmt.Sprintf("total number of dosage cannot be less than the %v dosage(s) already taken or skipped", completed))
+	}
+
+	var upcoming []model.Dosage
+	var tasks []model.Task
+	if reschedule {
+		upcoming, err = utility.GetUpcomingDosages(medication.StartDate, from, &model.MedicationRequest{
+			DailyDosage:         medication.DailyDosage,
+			DosageTimes:         medication.DosageTimes,
+			Schedule:            medication.Schedule,
+			Phases:              medication.Phases,
+			DosageQuantity:      medication.DosageQuantity,
+			TimeZone:            medication.TimeZone,
+			TotalNumberOfDosage: remaining,
+		})
+		if err != nil {
+			logger.Error("Error getting dosage times in UpdateMedication, error: ", err.Error())
+			return model.MedicationResponse{}, errors.BadRequestError(err.Error())
+		}
+
+		for i := range upcoming {
+			upcoming[i].ID = primitive.NewObjectID()
+			upcoming[i].MedicationID = medication.ID
+			upcoming[i].PatientID = patientID
+
+			tasks = append(tasks, model.Task{
+				ID:           primitive.NewObjectID(),
+				Time:         upcoming[i].ReminderTime,
+				Status:       constant.TaskUndone,
+				MedicationID: medication.ID,
+				DosageID:     upcoming[i].ID,
+			})
+		}
+	}
+
+	medication.UpdatedAt = utility.ReturnCurrentTime()
+	update := model.MedicationUpdate{
+		Name:      data.Name,
+		Treatment: data.Treatment,
+		Comment:   data.Comment,
+		Inventory: medication.Inventory,
+		UpdatedAt: medication.UpdatedAt,
+	}
+	if reschedule {
+		update.Schedule = &model.MedicationSchedule{
+			StartDate:           medication.StartDate,
+			DosageQuantity:      medication.DosageQuantity,
+			DailyDosage:         medication.DailyDosage,
+			DosageTimes:         medication.DosageTimes,
+			TotalNumberOfDosage: medication.TotalNumberOfDosage,
+			Schedule:            medication.Schedule,
+			Phases:              medication.Phases,
+			TimeZone:            medication.TimeZone,
+		}
+	}
+
+	err = m.dbRepo.WithTransaction(ctx, func(ctx context.Context) error {
+		found, err := m.dbRepo.UpdateMedication(ctx, medication.ID, &update)
+		if err != nil {
+			logger.Error("Error updating medication in UpdateMedication, error: ", err.Error())
+			return err
+		}
+
+		// the medication was paused, discontinued or deleted since it was fetched
+		if !found {
+			return constant.ErrMedicationChanged
+		}
+
+		if reschedule {
+			deleted, err := m.dbRepo.DeleteActiveDosages(ctx, medication.ID)
+			if err != nil {
+				logger.Error("Error deleting upcoming dosages in UpdateMedication, error: ", err.Error())
+				return err
+			}
+
+			deletedTasks, err := m.dbRepo.DeleteUndoneTasks(ctx, medication.ID)
+			if err != nil {
+				logger.Error("Error deleting pending tasks in UpdateMedication, error: ", err.Error())
+				return err
+			}
+
+			logger.Infof("Replacing %v dosage(s) and %v task(s) of medication %v", deleted, deletedTasks, medication.ID.Hex())
+
+			if len(upcoming) > 0 {
+				if err := m.dbRepo.SaveDosages(ctx, upcoming); err != nil {
+					logger.Error("Error saving dosages in UpdateMedication, error: ", err.Error())
+					return err
+				}
+
+				if _, err := m.dbRepo.AddTasks(ctx, tasks); err != nil {
+					logger.Error("Error adding tasks in UpdateMedication, error: ", err.Error())
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+	if err == constant.ErrMedicationChanged {
+		return model.MedicationResponse{}, errors.BadRequestError("medication was changed in the meantime, try again")
+	}
+
+	if err != nil {
+		return model.MedicationResponse{}, errors.InternalServerError
+	}
+
+	response := utility.MedicationToMedicationResponse(&medication)
+	response.IsActive = medication.IsActive
+	response.PractitionerIDs = medic.PractitionerIDs
+	response.Medicine = medic.Medicine
+	response.Dosages = upcoming
+	if medication.Inventory == nil {
+		response.Inventory = medic.Inventory
+	}
+
+	return response, nil
+}
+
+// newInventory returns the inventory a request sets, the date of the last refill is kept from the
+// current inventory, if any
+func newInventory(data *model.InventoryRequest, current *model.Inventory) *model.Inventory {
+	inventory := &model.Inventory{
+		Stock:               data.Stock,
+		RefillThresholdDays: data.RefillThresholdDays,
+	}
+
+	if inventory.RefillThresholdDays == 0 {
+		inventory.RefillThresholdDays = constant.DefaultRefillThresholdDays
+	}
+
+	if current != nil {
+		inventory.LastRefilledAt = current.LastRefilledAt
+	}
+
+	return inventory
+}
+
+// RefillMedication adds a refill to the stock of a medication, the patient is told to refill it
+// again once the stock runs low
+func (m *medicationService) RefillMedication(userInfo *model.ContextInfo, id string, data *model.RefillRequest) (model.Inventory, errors.InternalError) {
+	ctx := context.Background()
+
+	medId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return model.Inventory{}, errors.BadRequestError("invalid id")
+	}
+
+	medic, found, err := m.dbRepo.GetMedication(ctx, medId)
+	if err != nil {
+		logger.Error("Error fetching medication by id in RefillMedication, error: ", err.Error())
+		return model.Inventory{}, errors.InternalServerError
+	}
+
+	if !found {
+		return model.Inventory{}, errors.ResourceNotFoundError("medication not found")
+	}
+
+	if err := policy.Authorize(userInfo, policy.MedicationRefill, policy.Medication(&medic)); err != nil {
+		return model.Inventory{}, err
+	}
+
+	if medic.Inventory == nil {
+		return model.Inventory{}, errors.BadRequestError("the stock of the medication is not tracked, set its 'inventory' first")
+	}
+
+	inventory, found, err := m.dbRepo.RefillStock(ctx, medId, data.Quantity, time.Now())
+	if err != nil {
+		logger.Error("Error refilling stock of medication, error: ", err.Error())
+		return model.Inventory{}, errors.InternalServerError
+	}
+
+	if !found {
+		return model.Inventory{}, errors.ResourceNotFoundError("medication not found")
+	}
+
+	return inventory, nil
 }
 
 func (m *medicationService) DeleteMedication(userInfo *model.ContextInfo, id string) errors.InternalError {
@@ -196,20 +580,50 @@ func (m *medicationService) DeleteMedication(userInfo *model.ContextInfo, id str
 	medId, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		logger.Error("Error converting hex Id to objectId, error: ", err.Error())
-		return errors.InternalServerError
+		return errors.BadRequestError("invalid id")
 	}
 
-	count, err := m.dbRepo.DeleteDosages(ctx, medId)
+	medic, found, err := m.dbRepo.GetMedication(ctx, medId)
 	if err != nil {
-		logger.Error("Error deleting dosages, error: ", err.Error())
+		logger.Error("Error fetching medication by id in DeleteMedication, error: ", err.Error())
 		return errors.InternalServerError
 	}
 
-	logger.Infof("Matched and deleted %v dosage(s)", count)
+	if !found {
+		return errors.ResourceNotFoundError("medication not found")
+	}
+
+	if err := policy.Authorize(userInfo, policy.MedicationDelete, policy.Medication(&medic)); err != nil {
+		return err
+	}
+
+	err = m.dbRepo.WithTransaction(ctx, func(ctx context.Context) error {
+		found, err = m.dbRepo.DeleteMedication(ctx, medId)
+		if err != nil {
+			logger.Error("Error deleting medication by id, error: ", err.Error())
+			return err
+		}
+
+		if !found {
+			return nil
+		}
+
+		count, err := m.dbRepo.DeleteDosages(ctx, medId)
+		if err != nil {
+			logger.Error("Error deleting dosages, error: ", err.Error())
+			return err
+		}
+
+		taskCount, err := m.dbRepo.DeleteMedicationTasks(ctx, medId)
+		if err != nil {
+			logger.Error("Error deleting tasks, error: ", err.Error())
+			return err
+		}
 
-	found, err := m.dbRepo.DeleteMedication(ctx, medId)
+		logger.Infof("Matched and deleted %v dosage(s) and %v task(s)", count, taskCount)
+		return nil
+	})
 	if err != nil {
-		logger.Error("Error deleting medication by id, error: ", err.Error())
 		return errors.InternalServerError
 	}
 
@@ -217,49 +631,403 @@ func (m *medicationService) DeleteMedication(userInfo *model.ContextInfo, id str
 		return errors.ResourceNotFoundError("medication not found")
 	}
 
+	events.Publish(ctx, constant.EventMedicationDeleted, model.DeletedMedication{
+		MedicationID:    medId,
+		PatientID:       medic.PatientID,
+		PatientName:     medic.Patient.FullName,
+		Name:            medic.Name,
+		PractitionerIDs: medic.PractitionerIDs,
+		DeletedAt:       time.Now(),
+	})
+
 	return nil
 }
 
-func (m *medicationService) AddPractitionersToMedication(userInfo *model.ContextInfo, medicId string, practEmails []string) (string, errors.InternalError) {
+// PauseMedication stops the reminders of a medication until it is resumed, by the patient or on
+// the day they asked for. The dosages still to come are kept, deactivated, until then.
+func (m *medicationService) PauseMedication(userInfo *model.ContextInfo, id string, data *model.PauseMedicationRequest) (model.MedicationResponse, errors.InternalError) {
 	ctx := context.Background()
 
-	medId, err := primitive.ObjectIDFromHex(medicId)
+	medId, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		logger.Error("Error converting hex Id to objectId, error: ", err.Error())
-		return "", errors.InternalServerError
+		return model.MedicationResponse{}, errors.BadRequestError("invalid id")
 	}
 
-	medication, found, err := m.dbRepo.GetMedication(ctx, medId)
+	medic, found, err := m.dbRepo.GetMedication(ctx, medId)
 	if err != nil {
-		logger.Error("Error fetching medication, error: ", err.Error())
-		return "", errors.InternalServerError
+		logger.Error("Error fetching medication by id in PauseMedication, error: ", err.Error())
+		return model.MedicationResponse{}, errors.InternalServerError
 	}
 
 	if !found {
-		return "", errors.ResourceNotFoundError("medication not found")
+		return model.MedicationResponse{}, errors.ResourceNotFoundError("medication not found")
+	}
+
+	if err := policy.Authorize(userInfo, policy.MedicationUpdate, policy.Medication(&medic)); err != nil {
+		return model.MedicationResponse{}, err
+	}
+
+	if medic.DiscontinuedAt != nil {
+		return model.MedicationResponse{}, errors.BadRequestError("medication is discontinued")
+	}
+
+	if medic.PausedAt != nil {
+		return model.MedicationResponse{}, errors.BadRequestError("medication is already paused")
+	}
+
+	now := time.Now()
+
+	var resumeAt *time.Time
+	if data.ResumeOn != "" {
+		resumeOn, err := utility.FormatTime(data.ResumeOn)
+		if err != nil {
+			return model.MedicationResponse{}, errors.BadRequestError(fmt.Sprint("ResumeOn: ", err.Error()))
+		}
+
+		loc, err := utility.LoadTimeZone(medic.TimeZone)
+		if err != nil {
+			return model.MedicationResponse{}, errors.BadRequestError(err.Error())
+		}
+
+		// the medication is resumed at the start of the day, in its time zone
+		day := utility.DateIn(resumeOn, loc)
+		if !day.After(now) {
+			return model.MedicationResponse{}, errors.BadRequestError("'resume_on' should be a day after today")
+		}
+		resumeAt = &day
+	}
+
+	var deactivated, deletedTasks int64
+	err = m.dbRepo.WithTransaction(ctx, func(ctx context.Context) error {
+		found, err := m.dbRepo.PauseMedication(ctx, medId, now, resumeAt)
+		if err != nil {
+			logger.Error("Error pausing medication in PauseMedication, error: ", err.Error())
+			return err
+		}
+
+		if !found {
+			return constant.ErrMedicationChanged
+		}
+
+		deactivated, err = m.dbRepo.DeactivateDosages(ctx, medId, now)
+		if err != nil {
+			logger.Error("Error deactivating upcoming dosages in PauseMedication, error: ", err.Error())
+			return err
+		}
+
+		deletedTasks, err = m.dbRepo.DeleteUndoneTasks(ctx, medId)
+		if err != nil {
+			logger.Error("Error deleting pending tasks in PauseMedication, error: ", err.Error())
+			return err
+		}
+
+		return nil
+	})
+	if err == constant.ErrMedicationChanged {
+		return model.MedicationResponse{}, errors.BadRequestError("medication was changed in the meantime, try again")
+	}
+
+	if err != nil {
+		return model.MedicationResponse{}, errors.InternalServerError
+	}
+
+	logger.Infof("Paused %v dosage(s) and deleted %v task(s) of medication %v", deactivated, deletedTasks, medId.Hex())
+
+	medic.IsActive = false
+	medic.PausedAt = &now
+	medic.ResumeAt = resumeAt
+	medic.UpdatedAt = now
+	medic.Dosages = nil
+
+	return medic, nil
+}
+
+// ResumeMedication resumes a paused medication, its dosages are scheduled again from now on
+func (m *medicationService) ResumeMedication(userInfo *model.ContextInfo, id string) (model.MedicationResponse, errors.InternalError) {
+	ctx := context.Background()
+
+	medId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return model.MedicationResponse{}, errors.BadRequestError("invalid id")
+	}
+
+	medic, found, err := m.dbRepo.GetMedication(ctx, medId)
+	if err != nil {
+		logger.Error("Error fetching medication by id in ResumeMedication, error: ", err.Error())
+		return model.MedicationResponse{}, errors.InternalServerError
+	}
+
+	if !found {
+		return model.MedicationResponse{}, errors.ResourceNotFoundError("medication not found")
+	}
+
+	if err := policy.Authorize(userInfo, policy.MedicationUpdate, policy.Medication(&medic)); err != nil {
+		return model.MedicationResponse{}, err
+	}
+
+	if medic.PausedAt == nil {
+		return model.MedicationResponse{}, errors.BadRequestError("medication is not paused")
+	}
+
+	return m.resume(ctx, &medic, time.Now())
+}
+
+// ResumePausedMedications resumes the paused medications whose resume date has come, it returns
+// how many were resumed
+func (m *medicationService) ResumePausedMedications(ctx context.Context, now time.Time) (int, errors.InternalError) {
+	ids, err := m.dbRepo.GetMedicationsToResume(ctx, now)
+	if err != nil {
+		logger.Error("Error fetching medications to resume, error: ", err.Error())
+		return 0, errors.InternalServerError
+	}
+
+	var resumed int
+	for _, id := range ids {
+		medic, found, err := m.dbRepo.GetMedication(ctx, id)
+		if err != nil {
+			logger.Error("Error fetching medication to resume, error: ", err.Error())
+			continue
+		}
+
+		// the medication was deleted, discontinued or resumed since it was fetched
+		if !found || medic.PausedAt == nil {
+			continue
+		}
+
+		if _, err := m.resume(ctx, &medic, now); err != nil {
+			logger.Errorf("Could not resume medication %v, error: %s", id.Hex(), err.Error())
+			continue
+		}
+		resumed++
+	}
+
+	return resumed, nil
+}
+
+// resume schedules the dosages left of a paused medication from now on and activates it again.
+// The dosages that were taken, skipped, missed or still pending when it was paused count towards
+// its total, the paused ones are replaced.
+func (m *medicationService) resume(ctx context.Context, medic *model.MedicationResponse, now time.Time) (model.MedicationResponse, errors.InternalError) {
+	dosages, err := m.dbRepo.GetPatientDosages(ctx, &model.DosageFilter{PatiendID: medic.PatientID, MedicationID: medic.ID})
+	if err != nil {
+		logger.Error("Error fetching medication dosages in ResumeMedication, error: ", err.Error())
+		return model.MedicationResponse{}, errors.InternalServerError
+	}
+
+	var kept int
+	for _, dosage := range dosages {
+		if dosage.Status != constant.DosageNotTaken || dosage.IsActive {
+			kept++
+		}
 	}
 
-	practitioners, err := m.dbRepo.GetPractitionersByEmail(ctx, practEmails)
+	dosageTimes := medic.DosageTimes
+	if len(dosageTimes) == 0 {
+		dosageTimes = utility.GetDosageTimes(dosages, medic.DailyDosage)
+	}
+
+	loc, err := utility.LoadTimeZone(medic.TimeZone)
 	if err != nil {
-		logger.Error("Error fetching specified practioner(s), error: ", err.Error())
-		return "", errors.InternalServerError
+		return model.MedicationResponse{}, errors.BadRequestError(err.Error())
+	}
+
+	// phases follow each other from the start date, which moves on by the days of the pause
+	startDate, from := medic.StartDate, now
+	if len(medic.Phases) > 0 {
+		startDate, from = utility.ResumePhases(medic.StartDate, medic.Phases, *medic.PausedAt, now, loc)
+	}
+
+	if startDay := utility.DateIn(startDate, loc); startDay.After(from) {
+		from = startDay
+	}
+
+	var upcoming []model.Dosage
+	var tasks []model.Task
+	if remaining := medic.TotalNumberOfDosage - kept; remaining > 0 || len(medic.Phases) > 0 {
+		upcoming, err = utility.GetUpcomingDosages(startDate, from, &model.MedicationRequest{
+			DailyDosage:         medic.DailyDosage,
+			DosageTimes:         dosageTimes,
+			Schedule:            medic.Schedule,
+			Phases:              medic.Phases,
+			DosageQuantity:      medic.DosageQuantity,
+			TimeZone:            medic.TimeZone,
+			TotalNumberOfDosage: remaining,
+		})
+		if err != nil {
+			logger.Error("Error getting dosage times in ResumeMedication, error: ", err.Error())
+			return model.MedicationResponse{}, errors.BadRequestError(err.Error())
+		}
+	}
+
+	for i := range upcoming {
+		upcoming[i].ID = primitive.NewObjectID()
+		upcoming[i].MedicationID = medic.ID
+		upcoming[i].PatientID = medic.PatientID
+
+		tasks = append(tasks, model.Task{
+			ID:           primitive.NewObjectID(),
+			Time:         upcoming[i].ReminderTime,
+			Status:       constant.TaskUndone,
+			MedicationID: medic.ID,
+			DosageID:     upcoming[i].ID,
+		})
 	}
 
-	if len(practitioners) <= 0 {
-		return "", errors.BadRequestError("invalid practitioner email(s)")
+	err = m.dbRepo.WithTransaction(ctx, func(ctx context.Context) error {
+		found, err := m.dbRepo.ResumeMedication(ctx, medic.ID, startDate, now)
+		if err != nil {
+			logger.Error("Error resuming medication in ResumeMedication, error: ", err.Error())
+			return err
+		}
+
+		if !found {
+			return constant.ErrMedicationChanged
+		}
+
+		if _, err := m.dbRepo.DeletePausedDosages(ctx, medic.ID); err != nil {
+			logger.Error("Error deleting paused dosages in ResumeMedication, error: ", err.Error())
+			return err
+		}
+
+		if _, err := m.dbRepo.DeleteUndoneTasks(ctx, medic.ID); err != nil {
+			logger.Error("Error deleting pending tasks in ResumeMedication, error: ", err.Error())
+			return err
+		}
+
+		if len(upcoming) > 0 {
+			if err := m.dbRepo.SaveDosages(ctx, upcoming); err != nil {
+				logger.Error("Error saving dosages in ResumeMedication, error: ", err.Error())
+				return err
+			}
+
+			if _, err := m.dbRepo.AddTasks(ctx, tasks); err != nil {
+				logger.Error("Error adding tasks in ResumeMedication, error: ", err.Error())
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err == constant.ErrMedicationChanged {
+		return model.MedicationResponse{}, errors.BadRequestError("medication was changed in the meantime, try again")
 	}
-	logger.Infof("Successfully fetched %v out of %v practitioners\n", len(practitioners), len(practEmails))
 
-	practitionerIds := make([]primitive.ObjectID, len(practitioners))
-	for i, p := range practitioners {
-		practitionerIds[i] = p.ID
+	if err != nil {
+		return model.MedicationResponse{}, errors.InternalServerError
 	}
-	medication.PractitionerIDs = append(medication.PractitionerIDs, practitionerIds...)
 
-	if _, err := m.dbRepo.AddPractitionerToMed(ctx, medId, medication.PractitionerIDs); err != nil {
-		logger.Error("Error adding practioner(s) to medication, error: ", err.Error())
-		return "", errors.InternalServerError
+	logger.Infof("Resumed medication %v with %v dosage(s)", medic.ID.Hex(), len(upcoming))
+
+	response := *medic
+	response.IsActive = true
+	response.StartDate = startDate
+	response.PausedAt = nil
+	response.ResumeAt = nil
+	response.UpdatedAt = now
+	response.Dosages = upcoming
+
+	return response, nil
+}
+
+// DiscontinueMedication ends a medication with the reason it was stopped. Unlike DeleteMedication
+// it keeps the medication and the dosages taken, skipped or missed, only the dosages and reminders
+// still to come are deleted.
+func (m *medicationService) DiscontinueMedication(userInfo *model.ContextInfo, id string, data *model.DiscontinueMedicationRequest) (model.MedicationResponse, errors.InternalError) {
+	ctx := context.Background()
+
+	medId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return model.MedicationResponse{}, errors.BadRequestError("invalid id")
+	}
+
+	medic, found, err := m.dbRepo.GetMedication(ctx, medId)
+	if err != nil {
+		logger.Error("Error fetching medication by id in DiscontinueMedication, error: ", err.Error())
+		return model.MedicationResponse{}, errors.InternalServerError
+	}
+
+	if !found {
+		return model.MedicationResponse{}, errors.ResourceNotFoundError("medication not found")
+	}
+
+	if err := policy.Authorize(userInfo, policy.MedicationUpdate, policy.Medication(&medic)); err != nil {
+		return model.MedicationResponse{}, err
+	}
+
+	if medic.DiscontinuedAt != nil {
+		return model.MedicationResponse{}, errors.BadRequestError("medication is already discontinued")
+	}
+
+	now := time.Now()
+	reason := strings.TrimSpace(data.Reason)
+	if reason == "" {
+		return model.MedicationResponse{}, errors.BadRequestError("'reason' is required")
+	}
+
+	var deleted, deletedTasks int64
+	err = m.dbRepo.WithTransaction(ctx, func(ctx context.Context) error {
+		found, err := m.dbRepo.DiscontinueMedication(ctx, medId, reason, now)
+		if err != nil {
+			logger.Error("Error discontinuing medication in DiscontinueMedication, error: ", err.Error())
+			return err
+		}
+
+		if !found {
+			return constant.ErrMedicationChanged
+		}
+
+		// the dosages to come are deactivated like on a pause, then deleted with the paused ones
+		if _, err := m.dbRepo.DeactivateDosages(ctx, medId, now); err != nil {
+			logger.Error("Error deactivating upcoming dosages in DiscontinueMedication, error: ", err.Error())
+			return err
+		}
+
+		deleted, err = m.dbRepo.DeletePausedDosages(ctx, medId)
+		if err != nil {
+			logger.Error("Error deleting upcoming dosages in DiscontinueMedication, error: ", err.Error())
+			return err
+		}
+
+		deletedTasks, err = m.dbRepo.DeleteUndoneTasks(ctx, medId)
+		if err != nil {
+			logger.Error("Error deleting pending tasks in DiscontinueMedication, error: ", err.Error())
+			return err
+		}
+
+		return nil
+	})
+	if err == constant.ErrMedicationChanged {
+		return model.MedicationResponse{}, errors.BadRequestError("medication was changed in the meantime, try again")
+	}
+
+	if err != nil {
+		return model.MedicationResponse{}, errors.InternalServerError
+	}
+
+	logger.Infof("Discontinued medication %v, deleted %v dosage(s) and %v task(s)", medId.Hex(), deleted, deletedTasks)
+
+	medic.IsActive = false
+	medic.DiscontinuedAt = &now
+	medic.DiscontinuedReason = reason
+	medic.PausedAt = nil
+	medic.ResumeAt = nil
+	medic.UpdatedAt = now
+	medic.Dosages = nil
+
+	return medic, nil
+}
+
+// AddPractitionersToMedication asks the practitioners to accept access to the medication, they only
+// get access once they have accepted
+func (m *medicationService) AddPractitionersToMedication(userInfo *model.ContextInfo, medicId string, practEmails []string) (string, errors.InternalError) {
+	if _, err := primitive.ObjectIDFromHex(medicId); err != nil {
+		return "", errors.BadRequestError("invalid medication id")
+	}
+
+	grants, err := m.grantService.ShareWithPractitioners(userInfo, &model.ShareRequest{PractitionerEmails: practEmails, MedicationID: medicId})
+	if err != nil {
+		return "", err
 	}
 
-	return fmt.Sprintf("successfully added %v out of %v practitioner(s) to medication", len(practitionerIds), len(practEmails)), nil
+	return fmt.Sprintf("asked %v out of %v practitioner(s) to accept access to medication", len(grants), len(practEmails)), nil
 }
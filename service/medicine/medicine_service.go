@@ -15,6 +15,7 @@ type MedicineService interface {
 	GetMedicineFilter(req *model.MedicineFilter) (model.Medicine, errors.InternalError)
 	UpdateMedicine(id string, data *model.MedicineRequest) (model.Medicine, errors.InternalError)
 	DeleteMedicine(id string) errors.InternalError
+	ListMedicines(page, limit int) (model.MedicineListResponse, errors.InternalError)
 }
 
 type medicineService struct {
@@ -147,3 +148,19 @@ func (m *medicineService) DeleteMedicine(id string) errors.InternalError {
 
 	return nil
 }
+
+func (m *medicineService) ListMedicines(page, limit int) (model.MedicineListResponse, errors.InternalError) {
+	ctx := context.Background()
+
+	medicines, total, err := m.dbRepo.ListMedicines(ctx, page, limit)
+	if err != nil {
+		logger.Error("Error listing medicines, error: ", err.Error())
+		return model.MedicineListResponse{}, errors.InternalServerError
+	}
+
+	if medicines == nil {
+		medicines = []model.Medicine{}
+	}
+
+	return model.MedicineListResponse{Medicines: medicines, Total: total, Page: page, Limit: limit}, nil
+}
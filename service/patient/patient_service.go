@@ -2,12 +2,15 @@ package patient
 
 import (
 	"context"
+	"fmt"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"medbuddy-backend/internal/constant"
 	"medbuddy-backend/internal/errors"
 	"medbuddy-backend/internal/model"
-	"medbuddy-backend/pkg/middleware"
+	"medbuddy-backend/pkg/notification"
 	"medbuddy-backend/pkg/repository/storage"
+	"medbuddy-backend/service/auth"
+	"medbuddy-backend/service/medication"
 	"medbuddy-backend/utility"
 )
 
@@ -16,14 +19,22 @@ type PatientService interface {
 	LoginPatient(data *model.UserLogin) (model.PatientResponse, errors.InternalError)
 	GetPatient(id string) (model.PatientResponse, errors.InternalError)
 	GetPatientByEmail(email string) (model.PatientResponse, errors.InternalError)
+	UpdateNotificationPreferences(id string, data *model.UpdateNotificationPreferencesRequest) (model.NotificationPreferences, errors.InternalError)
+	UpdateTimeZone(uInfo *model.ContextInfo, data *model.UpdateTimeZoneRequest) (model.UpdateTimeZoneResponse, errors.InternalError)
 }
 
 type patientService struct {
-	dbRepo storage.StorageRepository
+	dbRepo            storage.StorageRepository
+	authService       auth.AuthService
+	medicationService medication.MedicationService
 }
 
 func NewPatientService(dbRepo storage.StorageRepository) PatientService {
-	return &patientService{dbRepo: dbRepo}
+	return &patientService{
+		dbRepo:            dbRepo,
+		authService:       auth.NewAuthService(dbRepo),
+		medicationService: medication.NewMedicationService(dbRepo),
+	}
 }
 
 var (
@@ -36,6 +47,10 @@ func (p *patientService) CreatePatient(data *model.CreatePatientReq) (model.Pati
 		return model.PatientResponse{}, errors.BadRequestError(err.Error())
 	}
 
+	if _, err := utility.LoadTimeZone(data.TimeZone); err != nil {
+		return model.PatientResponse{}, errors.BadRequestError(err.Error())
+	}
+
 	hashedPassword, salt, err := utility.HashPassword(data.Password)
 	if err != nil {
 		logger.Error("Error hashing user's password, error: ", err.Error())
@@ -77,6 +92,9 @@ func (p *patientService) CreatePatient(data *model.CreatePatientReq) (model.Pati
 		FullName: data.Firstname + " " + data.Lastname,
 		Email:    data.Email,
 		UserID:   user.ID,
+		TimeZone: data.TimeZone,
+
+		NotificationPreferences: model.NotificationPreferences{Channels: []string{constant.ChannelEmail}},
 	}
 
 	if err := p.dbRepo.CreatePatient(ctx, &patient); err != nil {
@@ -84,14 +102,19 @@ func (p *patientService) CreatePatient(data *model.CreatePatientReq) (model.Pati
 		return model.PatientResponse{}, errors.InternalServerError
 	}
 
-	token, err := middleware.CreateToken(patient.ID.Hex(), patient.Email, user.Role)
-	if err != nil {
-		logger.Error("Error creating token for user, error: ", err.Error())
-		return model.PatientResponse{}, errors.InternalServerError
+	// The account is usable right away, reminders are only emailed once the address is verified
+	if vErr := p.authService.SendVerificationEmail(patient.FullName, &user); vErr != nil {
+		logger.Errorf("Could not send verification email to '%s', it can be resent, error: %s", user.Email, vErr.Error())
+	}
+
+	tokens, tErr := p.authService.IssueTokens(patient.ID.Hex(), &user, "")
+	if tErr != nil {
+		return model.PatientResponse{}, tErr
 	}
 
 	response := utility.RequestsToPatientResponse(&patient, &user)
-	response.Token = token
+	response.Token = tokens.Token
+	response.RefreshToken = tokens.RefreshToken
 	return response, nil
 }
 
@@ -99,18 +122,23 @@ func (p *patientService) LoginPatient(data *model.UserLogin) (model.PatientRespo
 	// Get database details
 	ctx := context.Background()
 
+	if err := p.authService.CheckLogin(data); err != nil {
+		return model.PatientResponse{}, err
+	}
+
 	patient, found, err := p.dbRepo.GetPatientByEmail(ctx, data.Email)
 	if err != nil {
 		logger.Error("Error fetching patient by email, error: ", err.Error())
 		return model.PatientResponse{}, errors.InternalServerError
 	}
 
-	if !found {
-		return model.PatientResponse{}, errors.ResourceNotFoundError("patient not found")
+	// Unknown emails and wrong passwords get the same response
+	if !found || patient.User.ID.IsZero() {
+		return model.PatientResponse{}, p.authService.LoginFailed(data, nil, "")
 	}
 
 	if !utility.PasswordIsValid(data.Password, patient.User.Salt, patient.User.Password) {
-		return model.PatientResponse{}, errors.BadRequestError("invalid password")
+		return model.PatientResponse{}, p.authService.LoginFailed(data, &patient.User, patient.FullName)
 	}
 
 	// Ensure that user is not locked
@@ -118,16 +146,22 @@ func (p *patientService) LoginPatient(data *model.UserLogin) (model.PatientRespo
 		return model.PatientResponse{}, errors.ForbiddenError("cannot login, user is currently blocked")
 	}
 
-	token, err := middleware.CreateToken(patient.ID.Hex(), patient.Email, patient.User.Role)
-	if err != nil {
-		logger.Error("Error creating token for user, error: ", err.Error())
-		return model.PatientResponse{}, errors.InternalServerError
+	if patient.User.MustReset {
+		return model.PatientResponse{}, errors.ForbiddenError("password reset required, check your email for a reset link")
 	}
 
-	// Omit password and salt from response, then set token
+	p.authService.LoginSucceeded(data)
+
+	tokens, tErr := p.authService.IssueTokens(patient.ID.Hex(), &patient.User, "")
+	if tErr != nil {
+		return model.PatientResponse{}, tErr
+	}
+
+	// Omit password and salt from response, then set tokens
 	patient.User.Password = ""
 	patient.User.Salt = ""
-	patient.Token = token
+	patient.Token = tokens.Token
+	patient.RefreshToken = tokens.RefreshToken
 
 	return patient, nil
 }
@@ -169,3 +203,111 @@ func (p *patientService) GetPatientByEmail(email string) (model.PatientResponse,
 
 	return patient, nil
 }
+
+func (p *patientService) UpdateNotificationPreferences(id string, data *model.UpdateNotificationPreferencesRequest) (model.NotificationPreferences, errors.InternalError) {
+	ctx := context.Background()
+
+	oId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		logger.Error("Error converting hex Id to objectId, error: ", err.Error())
+		return model.NotificationPreferences{}, errors.InternalServerError
+	}
+
+	// Every channel other than email needs an address to deliver to
+	for _, channel := range data.Channels {
+		switch {
+		case channel == constant.ChannelSMS && data.Phone == "":
+			return model.NotificationPreferences{}, errors.BadRequestError("phone is required to receive sms reminders")
+		case channel == constant.ChannelPush && data.PushToken == "":
+			return model.NotificationPreferences{}, errors.BadRequestError("push_token is required to receive push reminders")
+		case channel == constant.ChannelWebhook && data.WebhookURL == "":
+			return model.NotificationPreferences{}, errors.BadRequestError("webhook_url is required to receive webhook reminders")
+		}
+	}
+
+	// the webhook is checked again when it is sent, the host may resolve elsewhere by then
+	if data.WebhookURL != "" {
+		if err := notification.CheckWebhookURL(ctx, data.WebhookURL); err != nil {
+			return model.NotificationPreferences{}, errors.BadRequestError(fmt.Sprint("webhook_url: ", err.Error()))
+		}
+	}
+
+	prefs := model.NotificationPreferences{
+		Channels:   data.Channels,
+		Phone:      data.Phone,
+		PushToken:  data.PushToken,
+		WebhookURL: data.WebhookURL,
+
+		ReRemindMinutes: data.ReRemindMinutes,
+	}
+
+	found, err := p.dbRepo.UpdateNotificationPreferences(ctx, oId, &prefs)
+	if err != nil {
+		logger.Error("Error updating patient's notification preferences, error: ", err.Error())
+		return model.NotificationPreferences{}, errors.InternalServerError
+	}
+
+	if !found {
+		return model.NotificationPreferences{}, errors.ResourceNotFoundError("patient not found")
+	}
+
+	return prefs, nil
+}
+
+// UpdateTimeZone sets the time zone of the patient. When asked to, the upcoming dosages of their
+// active medications that follow their previous time zone are moved to the same time of day in
+// the new one, e.g. while they travel.
+func (p *patientService) UpdateTimeZone(uInfo *model.ContextInfo, data *model.UpdateTimeZoneRequest) (model.UpdateTimeZoneResponse, errors.InternalError) {
+	ctx := context.Background()
+
+	oId, err := primitive.ObjectIDFromHex(uInfo.ID)
+	if err != nil {
+		logger.Error("Error converting hex Id to objectId at UpdateTimeZone, error: ", err.Error())
+		return model.UpdateTimeZoneResponse{}, errors.InternalServerError
+	}
+
+	if _, err := utility.LoadTimeZone(data.TimeZone); err != nil {
+		return model.UpdateTimeZoneResponse{}, errors.BadRequestError(err.Error())
+	}
+
+	patient, found, err := p.dbRepo.GetPatientByID(ctx, oId)
+	if err != nil {
+		logger.Error("Error fetching patient by id, error: ", err.Error())
+		return model.UpdateTimeZoneResponse{}, errors.InternalServerError
+	}
+
+	if !found {
+		return model.UpdateTimeZoneResponse{}, errors.ResourceNotFoundError("patient not found")
+	}
+
+	response := model.UpdateTimeZoneResponse{TimeZone: data.TimeZone}
+
+	// medications are shifted before the patient so that a failed attempt can be retried, the
+	// medications already shifted no longer follow the previous time zone
+	if data.ShiftDosages && data.TimeZone != patient.TimeZone {
+		medics, err := p.dbRepo.GetPatientsMedications(ctx, oId)
+		if err != nil {
+			logger.Error("Error fetching patient's medications, error: ", err.Error())
+			return model.UpdateTimeZoneResponse{}, errors.InternalServerError
+		}
+
+		for _, medic := range medics {
+			if !medic.IsActive || medic.TimeZone != patient.TimeZone {
+				continue
+			}
+
+			update := model.UpdateMedicationRequest{TimeZone: &data.TimeZone}
+			if _, iErr := p.medicationService.UpdateMedication(uInfo, medic.ID.Hex(), &update); iErr != nil {
+				return model.UpdateTimeZoneResponse{}, iErr
+			}
+			response.ShiftedMedications++
+		}
+	}
+
+	if _, err := p.dbRepo.UpdatePatientTimeZone(ctx, oId, data.TimeZone); err != nil {
+		logger.Error("Error updating patient's time zone, error: ", err.Error())
+		return model.UpdateTimeZoneResponse{}, errors.InternalServerError
+	}
+
+	return response, nil
+}
@@ -7,9 +7,10 @@ import (
 	"medbuddy-backend/internal/constant"
 	"medbuddy-backend/internal/errors"
 	"medbuddy-backend/internal/model"
-	"medbuddy-backend/pkg/middleware"
 	"medbuddy-backend/pkg/repository/storage"
+	"medbuddy-backend/service/auth"
 	"medbuddy-backend/utility"
+	"time"
 )
 
 type PractitionerService interface {
@@ -19,14 +20,16 @@ type PractitionerService interface {
 	GetPractitionerByEmail(email string) (model.PractitionerResponse, errors.InternalError)
 	GetPractitionersByIDs(uInfo *model.ContextInfo, ids []string) ([]model.PractitionerResponse, errors.InternalError)
 	GetPractitionerMedications(uInfo *model.ContextInfo) ([]model.MedicationResponse, errors.InternalError)
+	GetPatientRoster(uInfo *model.ContextInfo, query *model.RosterQuery) (model.RosterResponse, errors.InternalError)
 }
 
 type practitionerService struct {
-	dbRepo storage.StorageRepository
+	dbRepo      storage.StorageRepository
+	authService auth.AuthService
 }
 
 func NewPractitionerService(dbRepo storage.StorageRepository) PractitionerService {
-	return &practitionerService{dbRepo: dbRepo}
+	return &practitionerService{dbRepo: dbRepo, authService: auth.NewAuthService(dbRepo)}
 }
 
 var (
@@ -90,32 +93,42 @@ func (p *practitionerService) CreatePractitioner(data *model.PractitionerRequest
 		return model.PractitionerResponse{}, errors.InternalServerError
 	}
 
-	token, err := middleware.CreateToken(pract.ID.Hex(), pract.Email, user.Role)
-	if err != nil {
-		logger.Error("Error creating token for user, error: ", err.Error())
-		return model.PractitionerResponse{}, errors.InternalServerError
+	// The account is usable right away, reminders are only emailed once the address is verified
+	if vErr := p.authService.SendVerificationEmail(pract.FullName, &user); vErr != nil {
+		logger.Errorf("Could not send verification email to '%s', it can be resent, error: %s", user.Email, vErr.Error())
+	}
+
+	tokens, tErr := p.authService.IssueTokens(pract.ID.Hex(), &user, "")
+	if tErr != nil {
+		return model.PractitionerResponse{}, tErr
 	}
 
 	response := utility.RequestsToPractitionerResponse(&pract, &user)
-	response.Token = token
+	response.Token = tokens.Token
+	response.RefreshToken = tokens.RefreshToken
 	return response, nil
 }
 
 func (p *practitionerService) LoginPractitioner(data *model.UserLogin) (model.PractitionerResponse, errors.InternalError) {
 	ctx := context.Background()
 
+	if err := p.authService.CheckLogin(data); err != nil {
+		return model.PractitionerResponse{}, err
+	}
+
 	practitioner, found, err := p.dbRepo.GetPractitionerByEmail(ctx, data.Email)
 	if err != nil {
 		logger.Error("Error fetching practitioner by email, error: ", err.Error())
 		return model.PractitionerResponse{}, errors.InternalServerError
 	}
 
-	if !found {
-		return model.PractitionerResponse{}, errors.ResourceNotFoundError("practitioner not found")
+	// Unknown emails and wrong passwords get the same response
+	if !found || practitioner.User.ID.IsZero() {
+		return model.PractitionerResponse{}, p.authService.LoginFailed(data, nil, "")
 	}
 
 	if !utility.PasswordIsValid(data.Password, practitioner.User.Salt, practitioner.User.Password) {
-		return model.PractitionerResponse{}, errors.BadRequestError("invalid password")
+		return model.PractitionerResponse{}, p.authService.LoginFailed(data, &practitioner.User, practitioner.FullName)
 	}
 
 	// Ensure that user is not locked
@@ -123,16 +136,22 @@ func (p *practitionerService) LoginPractitioner(data *model.UserLogin) (model.Pr
 		return model.PractitionerResponse{}, errors.ForbiddenError("cannot login, user is currently blocked")
 	}
 
-	token, err := middleware.CreateToken(practitioner.ID.Hex(), practitioner.Email, practitioner.User.Role)
-	if err != nil {
-		logger.Error("Error creating token for user, error: ", err.Error())
-		return model.PractitionerResponse{}, errors.InternalServerError
+	if practitioner.User.MustReset {
+		return model.PractitionerResponse{}, errors.ForbiddenError("password reset required, check your email for a reset link")
 	}
 
-	// Omit password and salt from response, then set token
+	p.authService.LoginSucceeded(data)
+
+	tokens, tErr := p.authService.IssueTokens(practitioner.ID.Hex(), &practitioner.User, "")
+	if tErr != nil {
+		return model.PractitionerResponse{}, tErr
+	}
+
+	// Omit password and salt from response, then set tokens
 	practitioner.User.Password = ""
 	practitioner.User.Salt = ""
-	practitioner.Token = token
+	practitioner.Token = tokens.Token
+	practitioner.RefreshToken = tokens.RefreshToken
 
 	return practitioner, nil
 }
@@ -207,7 +226,7 @@ func (p *practitionerService) GetPractitionerMedications(userInfo *model.Context
 		return nil, errors.InternalServerError
 	}
 
-	medications, err := p.dbRepo.GetPractitionerMedications(ctx, practitionersId)
+	medications, err := p.dbRepo.GetPractitionerMedications(ctx, practitionersId, time.Now())
 	if err != nil {
 		logger.Error("Error fetching medications for practitioner, error: ", err.Error())
 		return nil, errors.InternalServerError
@@ -215,3 +234,46 @@ func (p *practitionerService) GetPractitionerMedications(userInfo *model.Context
 
 	return medications, nil
 }
+
+// GetPatientRoster returns the patients shared with the practitioner, at risk first unless another
+// sort is asked for
+func (p *practitionerService) GetPatientRoster(uInfo *model.ContextInfo, query *model.RosterQuery) (model.RosterResponse, errors.InternalError) {
+	ctx := context.Background()
+
+	practitionerId, err := primitive.ObjectIDFromHex(uInfo.ID)
+	if err != nil {
+		logger.Error("Error converting hex Id to objectId at GetPatientRoster, error: ", err.Error())
+		return model.RosterResponse{}, errors.InternalServerError
+	}
+
+	filter := model.RosterFilter{PractitionerID: practitionerId, Sort: query.Sort, Page: query.Page, Limit: query.Limit}
+	switch filter.Sort {
+	case "":
+		filter.Sort = constant.RosterSortRisk
+	case constant.RosterSortRisk, constant.RosterSortName, constant.RosterSortAdherence, constant.RosterSortLastTaken:
+	default:
+		return model.RosterResponse{}, errors.BadRequestError(fmt.Sprintf("sort must be one of %s, %s, %s or %s",
+			constant.RosterSortRisk, constant.RosterSortName, constant.RosterSortAdherence, constant.RosterSortLastTaken))
+	}
+
+	switch query.Order {
+	case "":
+		filter.Descending = filter.Sort == constant.RosterSortRisk
+	case "asc":
+	case "desc":
+		filter.Descending = true
+	default:
+		return model.RosterResponse{}, errors.BadRequestError("order must be asc or desc")
+	}
+
+	now := time.Now()
+	filter.Since = now.Add(-constant.RosterAdherencePeriod)
+
+	patients, total, err := p.dbRepo.GetPractitionerRoster(ctx, &filter, now)
+	if err != nil {
+		logger.Error("Error fetching practitioner's patients, error: ", err.Error())
+		return model.RosterResponse{}, errors.InternalServerError
+	}
+
+	return model.RosterResponse{Patients: patients, Total: total, Page: filter.Page, Limit: filter.Limit}, nil
+}
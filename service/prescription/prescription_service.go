@@ -0,0 +1,315 @@
+package prescription
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"medbuddy-backend/internal/config"
+	"medbuddy-backend/internal/constant"
+	"medbuddy-backend/internal/errors"
+	"medbuddy-backend/internal/model"
+	"medbuddy-backend/internal/policy"
+	"medbuddy-backend/pkg/notification"
+	"medbuddy-backend/pkg/repository/storage"
+	"medbuddy-backend/service/medication"
+	"medbuddy-backend/utility"
+	"strings"
+	"time"
+)
+
+type PrescriptionService interface {
+	Prescribe(uInfo *model.ContextInfo, data *model.PrescriptionRequest) (model.PrescriptionResponse, errors.InternalError)
+	GetPrescriptions(uInfo *model.ContextInfo, status string) ([]model.PrescriptionResponse, errors.InternalError)
+	AcceptPrescription(uInfo *model.ContextInfo, id string, data *model.AcceptPrescriptionRequest) (model.MedicationResponse, errors.InternalError)
+	DeclinePrescription(uInfo *model.ContextInfo, id string) errors.InternalError
+	CancelPrescription(uInfo *model.ContextInfo, id string) errors.InternalError
+}
+
+type prescriptionService struct {
+	dbRepo            storage.StorageRepository
+	medicationService medication.MedicationService
+	notifier          *notification.Dispatcher
+}
+
+func NewPrescriptionService(dbRepo storage.StorageRepository) PrescriptionService {
+	return &prescriptionService{
+		dbRepo:            dbRepo,
+		medicationService: medication.NewMedicationService(dbRepo),
+		notifier:          notification.NewDispatcherFromConfig(config.GetConfig()),
+	}
+}
+
+var (
+	logger = utility.NewLogger()
+
+	prescriptionSubject  = "%s prescribed %s for you on MedBuddy"
+	prescriptionTemplate = "utility/template/prescription.html"
+)
+
+// Prescribe proposes a medication plan to a patient who shares their whole profile with the
+// practitioner. The plan is checked like a medication the patient adds, but nothing is scheduled
+// until the patient accepts it.
+func (p *prescriptionService) Prescribe(uInfo *model.ContextInfo, data *model.PrescriptionRequest) (model.PrescriptionResponse, errors.InternalError) {
+	ctx := context.Background()
+
+	practitionerID, err := primitive.ObjectIDFromHex(uInfo.ID)
+	if err != nil {
+		logger.Error("Error converting hex Id to objectId at Prescribe, error: ", err.Error())
+		return model.PrescriptionResponse{}, errors.InternalServerError
+	}
+
+	patientID, err := primitive.ObjectIDFromHex(data.PatientID)
+	if err != nil {
+		return model.PrescriptionResponse{}, errors.BadRequestError("invalid patient id")
+	}
+
+	practitionerIDs, err := p.dbRepo.GetProfilePractitionerIDs(ctx, patientID, time.Now())
+	if err != nil {
+		logger.Error("Error fetching patient's practitioners, error: ", err.Error())
+		return model.PrescriptionResponse{}, errors.InternalServerError
+	}
+
+	if err := policy.Authorize(uInfo, policy.PrescriptionCreate, policy.Patient(patientID, nil, practitionerIDs)); err != nil {
+		return model.PrescriptionResponse{}, err
+	}
+
+	patient, found, err := p.dbRepo.GetPatientByID(ctx, patientID)
+	if err != nil {
+		logger.Error("Error fetching patient by id, error: ", err.Error())
+		return model.PrescriptionResponse{}, errors.InternalServerError
+	}
+
+	if !found {
+		return model.PrescriptionResponse{}, errors.ResourceNotFoundError("patient not found")
+	}
+
+	// the plan is checked in the time zone it will follow once accepted
+	plan := data.Medication
+	if plan.TimeZone == "" {
+		plan.TimeZone = patient.TimeZone
+	}
+
+	if iErr := checkPlan(&plan); iErr != nil {
+		return model.PrescriptionResponse{}, iErr
+	}
+
+	practitioner, _, err := p.dbRepo.GetPractitionerByID(ctx, practitionerID)
+	if err != nil {
+		logger.Error("Error fetching practitioner by id, error: ", err.Error())
+		return model.PrescriptionResponse{}, errors.InternalServerError
+	}
+
+	prescription := model.Prescription{
+		ID:             primitive.NewObjectID(),
+		PatientID:      patientID,
+		PractitionerID: practitionerID,
+		Medication:     data.Medication,
+		Status:         constant.PrescriptionPending,
+		CreatedAt:      time.Now(),
+	}
+	if err := p.dbRepo.CreatePrescription(ctx, &prescription); err != nil {
+		logger.Error("Error creating prescription, error: ", err.Error())
+		return model.PrescriptionResponse{}, errors.InternalServerError
+	}
+
+	// The patient also finds the prescription in their account, a failed email is not fatal
+	if err := p.sendPrescription(ctx, &patient, practitioner.FullName, data.Medication.Name); err != nil {
+		logger.Errorf("Error emailing prescription to '%s', error: %s", patient.Email, err.Error())
+	}
+
+	return model.PrescriptionResponse{
+		ID:             prescription.ID,
+		PatientID:      prescription.PatientID,
+		PractitionerID: prescription.PractitionerID,
+		Medication:     prescription.Medication,
+		Status:         prescription.Status,
+		CreatedAt:      prescription.CreatedAt,
+		Patient:        model.PatientForDosage{FullName: patient.FullName, Email: patient.Email, UserID: patient.UserID},
+		Practitioner:   model.Practitioner{ID: practitioner.ID, FullName: practitioner.FullName, Title: practitioner.Title, Email: practitioner.Email},
+	}, nil
+}
+
+// GetPrescriptions returns the prescriptions of a patient, or written by a practitioner
+func (p *prescriptionService) GetPrescriptions(uInfo *model.ContextInfo, status string) ([]model.PrescriptionResponse, errors.InternalError) {
+	ctx := context.Background()
+
+	switch status {
+	case "", constant.PrescriptionPending, constant.PrescriptionAccepted, constant.PrescriptionDeclined, constant.PrescriptionCancelled:
+	default:
+		return nil, errors.BadRequestError("invalid status")
+	}
+
+	oId, err := primitive.ObjectIDFromHex(uInfo.ID)
+	if err != nil {
+		logger.Error("Error converting hex Id to objectId at GetPrescriptions, error: ", err.Error())
+		return nil, errors.InternalServerError
+	}
+
+	filter := model.PrescriptionFilter{Status: status}
+	if constant.RoleName(uInfo.Role) == constant.Practitioner {
+		filter.PractitionerID = oId
+	} else {
+		filter.PatientID = oId
+	}
+
+	prescriptions, err := p.dbRepo.GetPrescriptions(ctx, &filter)
+	if err != nil {
+		logger.Error("Error fetching prescriptions, error: ", err.Error())
+		return nil, errors.InternalServerError
+	}
+
+	return prescriptions, nil
+}
+
+// AcceptPrescription creates the prescribed medication with its dosages and reminders. A plan whose
+// start date has passed is accepted with another start date.
+func (p *prescriptionService) AcceptPrescription(uInfo *model.ContextInfo, id string, data *model.AcceptPrescriptionRequest) (model.MedicationResponse, errors.InternalError) {
+	ctx := context.Background()
+
+	prescription, iErr := p.getAuthorizedPrescription(ctx, uInfo, policy.PrescriptionRespond, id)
+	if iErr != nil {
+		return model.MedicationResponse{}, iErr
+	}
+
+	plan := prescription.Medication
+	if data.StartDate != "" {
+		plan.StartDate = data.StartDate
+	}
+
+	if plan.TimeZone == "" {
+		patient, _, err := p.dbRepo.GetPatientByID(ctx, prescription.PatientID)
+		if err != nil {
+			logger.Error("Error fetching patient by id, error: ", err.Error())
+			return model.MedicationResponse{}, errors.InternalServerError
+		}
+		plan.TimeZone = patient.TimeZone
+	}
+
+	if iErr := checkPlan(&plan); iErr != nil {
+		if data.StartDate == "" {
+			return model.MedicationResponse{}, errors.BadRequestError(fmt.Sprintf("%s, accept the prescription with another start_date", iErr.Error()))
+		}
+		return model.MedicationResponse{}, iErr
+	}
+
+	// the prescription is claimed first so that accepting it twice does not create two medications
+	now := time.Now()
+	found, err := p.dbRepo.SetPrescriptionStatus(ctx, prescription.ID, constant.PrescriptionPending, constant.PrescriptionAccepted, now)
+	if err != nil {
+		logger.Error("Error accepting prescription, error: ", err.Error())
+		return model.MedicationResponse{}, errors.InternalServerError
+	}
+
+	if !found {
+		return model.MedicationResponse{}, errors.BadRequestError(fmt.Sprintf("prescription is already %s", prescription.Status))
+	}
+
+	medic, iErr := p.medicationService.CreateMedication(ctx, prescription.PatientID, &plan, prescription.PractitionerID)
+	if iErr != nil {
+		if _, err := p.dbRepo.SetPrescriptionStatus(ctx, prescription.ID, constant.PrescriptionAccepted, constant.PrescriptionPending, now); err != nil {
+			logger.Error("Error reopening prescription, error: ", err.Error())
+		}
+		return model.MedicationResponse{}, iErr
+	}
+
+	if err := p.dbRepo.SetPrescriptionMedication(ctx, prescription.ID, medic.ID); err != nil {
+		logger.Error("Error linking prescription to its medication, error: ", err.Error())
+	}
+	medic.Patient.Email = uInfo.Email
+
+	return medic, nil
+}
+
+func (p *prescriptionService) DeclinePrescription(uInfo *model.ContextInfo, id string) errors.InternalError {
+	return p.close(uInfo, policy.PrescriptionRespond, id, constant.PrescriptionDeclined)
+}
+
+func (p *prescriptionService) CancelPrescription(uInfo *model.ContextInfo, id string) errors.InternalError {
+	return p.close(uInfo, policy.PrescriptionCancel, id, constant.PrescriptionCancelled)
+}
+
+// close ends a pending prescription without creating its medication
+func (p *prescriptionService) close(uInfo *model.ContextInfo, action policy.Action, id, status string) errors.InternalError {
+	ctx := context.Background()
+
+	prescription, iErr := p.getAuthorizedPrescription(ctx, uInfo, action, id)
+	if iErr != nil {
+		return iErr
+	}
+
+	found, err := p.dbRepo.SetPrescriptionStatus(ctx, prescription.ID, constant.PrescriptionPending, status, time.Now())
+	if err != nil {
+		logger.Error("Error updating prescription status, error: ", err.Error())
+		return errors.InternalServerError
+	}
+
+	if !found {
+		return errors.BadRequestError(fmt.Sprintf("prescription is already %s", prescription.Status))
+	}
+
+	return nil
+}
+
+func (p *prescriptionService) getAuthorizedPrescription(ctx context.Context, uInfo *model.ContextInfo, action policy.Action, id string) (model.PrescriptionResponse, errors.InternalError) {
+	prescriptionID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return model.PrescriptionResponse{}, errors.BadRequestError("invalid id")
+	}
+
+	prescription, found, err := p.dbRepo.GetPrescription(ctx, prescriptionID)
+	if err != nil {
+		logger.Error("Error fetching prescription, error: ", err.Error())
+		return model.PrescriptionResponse{}, errors.InternalServerError
+	}
+
+	if !found {
+		return model.PrescriptionResponse{}, errors.ResourceNotFoundError("prescription not found")
+	}
+
+	if err := policy.Authorize(uInfo, action, policy.Prescription(&prescription)); err != nil {
+		return model.PrescriptionResponse{}, err
+	}
+
+	return prescription, nil
+}
+
+func (p *prescriptionService) sendPrescription(ctx context.Context, patient *model.PatientResponse, practitionerName, medicationName string) error {
+	emailData := map[string]string{
+		"Name":             patient.FullName,
+		"PractitionerName": practitionerName,
+		"Medication":       medicationName,
+		"Link":             fmt.Sprintf("%s/prescriptions", strings.TrimRight(config.GetConfig().AppBaseURL, "/")),
+	}
+
+	html, err := utility.RenderTemplate(prescriptionTemplate, emailData)
+	if err != nil {
+		return err
+	}
+
+	msg := &notification.Message{Subject: fmt.Sprintf(prescriptionSubject, practitionerName, medicationName), HTML: html}
+	_, err = p.notifier.Send(ctx, []string{constant.ChannelEmail}, notification.Recipient{Name: patient.FullName, Email: patient.Email}, msg)
+	return err
+}
+
+// checkPlan validates a medication plan the way it is validated when the medication is created
+func checkPlan(plan *model.MedicationRequest) errors.InternalError {
+	startDate, err := utility.FormatTime(plan.StartDate)
+	if err != nil {
+		return errors.BadRequestError(fmt.Sprint("StartDate: ", err.Error()))
+	}
+
+	if len(plan.Phases) > 0 {
+		plan.TotalNumberOfDosage = utility.PhaseDosageCount(plan.Phases)
+	}
+
+	// as needed medications may be taken without limit
+	if plan.TotalNumberOfDosage < 0 || (plan.TotalNumberOfDosage == 0 && !utility.IsAsNeeded(plan.Schedule)) {
+		return errors.BadRequestError("invalid value for total number of dosage")
+	}
+
+	if _, err := utility.GetDosages(startDate, plan); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package utility
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidActionToken = errors.New("invalid link")
+	ErrExpiredActionToken = errors.New("this link has expired")
+)
+
+// SignDosageAction returns the token of a one-click link that sets the status of a dosage. The
+// token carries the dosage, the status and its expiry, signed with secret so it cannot be altered.
+func SignDosageAction(secret string, dosageID primitive.ObjectID, status string, expiresAt time.Time) string {
+	payload := fmt.Sprintf("%s:%s:%d", dosageID.Hex(), status, expiresAt.Unix())
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + signDosageAction(secret, payload)
+}
+
+// VerifyDosageAction checks the signature and the expiry of a token made by SignDosageAction and
+// returns the dosage and the status it sets
+func VerifyDosageAction(secret, token string, now time.Time) (dosageID primitive.ObjectID, status string, expiresAt time.Time, err error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return primitive.NilObjectID, "", time.Time{}, ErrInvalidActionToken
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return primitive.NilObjectID, "", time.Time{}, ErrInvalidActionToken
+	}
+
+	payload := string(b)
+	if !hmac.Equal([]byte(signature), []byte(signDosageAction(secret, payload))) {
+		return primitive.NilObjectID, "", time.Time{}, ErrInvalidActionToken
+	}
+
+	parts := strings.Split(payload, ":")
+	if len(parts) != 3 {
+		return primitive.NilObjectID, "", time.Time{}, ErrInvalidActionToken
+	}
+
+	dosageID, err = primitive.ObjectIDFromHex(parts[0])
+	if err != nil {
+		return primitive.NilObjectID, "", time.Time{}, ErrInvalidActionToken
+	}
+
+	expiry, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return primitive.NilObjectID, "", time.Time{}, ErrInvalidActionToken
+	}
+
+	expiresAt = time.Unix(expiry, 0)
+	if !now.Before(expiresAt) {
+		return primitive.NilObjectID, "", time.Time{}, ErrExpiredActionToken
+	}
+
+	return dosageID, parts[1], expiresAt, nil
+}
+
+// signDosageAction signs the payload of a dosage action token, the key is derived from secret so
+// that the signature cannot be replayed where secret signs something else
+func signDosageAction(secret, payload string) string {
+	key := hmac.New(sha256.New, []byte(secret))
+	key.Write([]byte("dosage-action"))
+
+	mac := hmac.New(sha256.New, key.Sum(nil))
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
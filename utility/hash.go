@@ -1,12 +1,19 @@
 package utility
 
 import (
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"golang.org/x/crypto/bcrypt"
 	"math/rand"
 	"time"
 )
 
-var saltLen = 8
+var (
+	saltLen  = 8
+	tokenLen = 32
+)
 
 func HashPassword(password string) (hashed string, salt string, err error) {
 	salt = randomSalt()
@@ -36,3 +43,19 @@ func randomSalt() string {
 
 	return salt
 }
+
+// GenerateToken returns a random url safe token for refresh tokens and emailed links
+func GenerateToken() (string, error) {
+	b := make([]byte, tokenLen)
+	if _, err := crand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken returns the hash a token is stored under. Tokens are random so a fast hash is enough.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
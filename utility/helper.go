@@ -1,6 +1,10 @@
 package utility
 
-import "medbuddy-backend/internal/model"
+import (
+	"medbuddy-backend/internal/constant"
+	"medbuddy-backend/internal/model"
+	"strconv"
+)
 
 func RequestsToPatientResponse(patient *model.Patient, user *model.User) model.PatientResponse {
 	return model.PatientResponse{
@@ -8,7 +12,10 @@ func RequestsToPatientResponse(patient *model.Patient, user *model.User) model.P
 		FullName: patient.FullName,
 		UserID:   patient.UserID,
 		Email:    patient.Email,
+		TimeZone: patient.TimeZone,
 		User:     *user,
+
+		NotificationPreferences: patient.NotificationPreferences,
 	}
 }
 
@@ -24,6 +31,17 @@ func RequestsToPractitionerResponse(pract *model.Practitioner, user *model.User)
 	}
 }
 
+func RequestsToCaregiverResponse(caregiver *model.Caregiver, user *model.User) model.CaregiverResponse {
+	return model.CaregiverResponse{
+		ID:       caregiver.ID,
+		FullName: caregiver.FullName,
+		UserID:   caregiver.UserID,
+		Email:    caregiver.Email,
+		Phone:    caregiver.Phone,
+		User:     *user,
+	}
+}
+
 func MedicineRequestToMedicine(medicine *model.MedicineRequest) model.Medicine {
 	return model.Medicine{
 		ID:           medicine.ID,
@@ -37,3 +55,23 @@ func MedicineRequestToMedicine(medicine *model.MedicineRequest) model.Medicine {
 		UpdatedAt:    medicine.UpdatedAt,
 	}
 }
+
+// ParsePagination reads the page and limit query values, falling back to the first page and the
+// default limit when they are missing or invalid
+func ParsePagination(page, limit string) (int, int) {
+	p, err := strconv.Atoi(page)
+	if err != nil || p < 1 {
+		p = 1
+	}
+
+	l, err := strconv.Atoi(limit)
+	if err != nil || l < 1 {
+		l = constant.DefaultPageLimit
+	}
+
+	if l > constant.MaxPageLimit {
+		l = constant.MaxPageLimit
+	}
+
+	return p, l
+}
@@ -0,0 +1,82 @@
+package utility
+
+import (
+	"fmt"
+	"medbuddy-backend/internal/errors"
+	"medbuddy-backend/internal/model"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DosageUnits reads the number of units taken per dosage from the start of a dosage quantity, and
+// the unit that follows it, e.g. 2 and "tablets" from "2 tablets" or 0.5 and "tablet" from "1/2 tablet"
+func DosageUnits(quantity string) (units float64, unit string, err error) {
+	quantity = strings.TrimSpace(quantity)
+	end := strings.IndexFunc(quantity, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.' && r != '/'
+	})
+	if end < 0 {
+		end = len(quantity)
+	}
+
+	number := quantity[:end]
+	if numerator, denominator, ok := strings.Cut(number, "/"); ok {
+		n, err1 := strconv.ParseFloat(numerator, 64)
+		d, err2 := strconv.ParseFloat(denominator, 64)
+		if err1 == nil && err2 == nil && d > 0 {
+			units = n / d
+		}
+	} else {
+		units, _ = strconv.ParseFloat(number, 64)
+	}
+
+	if units <= 0 {
+		return 0, "", fmt.Errorf("dosage quantity '%s' does not start with the number of units taken, e.g. '2 tablets'", quantity)
+	}
+
+	return units, strings.TrimSpace(quantity[end:]), nil
+}
+
+// ValidateInventory checks that the stock of a medication can be counted down, every dosage
+// quantity has to start with the number of units taken
+func ValidateInventory(dosageQuantity string, phases []model.Phase) errors.InternalError {
+	if len(phases) == 0 {
+		if _, _, err := DosageUnits(dosageQuantity); err != nil {
+			return errors.BadRequestError(fmt.Sprintf("%s, to keep count of the stock", err.Error()))
+		}
+		return nil
+	}
+
+	for i, phase := range phases {
+		if _, _, err := DosageUnits(phase.DosageQuantity); err != nil {
+			return errors.BadRequestError(fmt.Sprintf("phase %v: %s, to keep count of the stock", i+1, err.Error()))
+		}
+	}
+
+	return nil
+}
+
+// SupplyRunsOut returns the reminder time of the first of the upcoming dosages the stock does not
+// cover, and false when it covers all of them. Dosages without a quantity are taken at 'quantity'.
+func SupplyRunsOut(stock float64, dosages []model.Dosage, quantity string) (time.Time, bool) {
+	for _, dosage := range dosages {
+		q := dosage.Quantity
+		if q == "" {
+			q = quantity
+		}
+
+		units, _, err := DosageUnits(q)
+		if err != nil {
+			continue
+		}
+
+		// a little leeway for the rounding of fractions of units
+		if units > stock+1e-9 {
+			return dosage.ReminderTime, true
+		}
+		stock -= units
+	}
+
+	return time.Time{}, false
+}
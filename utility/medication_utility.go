@@ -5,41 +5,135 @@ import (
 	"medbuddy-backend/internal/constant"
 	"medbuddy-backend/internal/errors"
 	"medbuddy-backend/internal/model"
+	"sort"
 	"time"
 )
 
+// GetDosages generates the dosages of a new medication from its start date, in the time zone of
+// the medication. As needed medications have no scheduled dosages.
 func GetDosages(startDate time.Time, medic *model.MedicationRequest) ([]model.Dosage, errors.InternalError) {
-	if medic.DailyDosage != len(medic.DosageTimes) {
-		return nil, errors.BadRequestError("'dailyDosage' should match the length of dosage times")
+	if err := ValidateSchedule(medic); err != nil {
+		return nil, err
 	}
 
-	now := time.Now()
-	currentDay := time.Date(now.Year(), now.Month(), now.Day(), 00, 00, 00, 00, time.Local)
-	if startDate.Before(currentDay) {
+	loc, err := LoadTimeZone(medic.TimeZone)
+	if err != nil {
+		return nil, errors.BadRequestError(err.Error())
+	}
+
+	startDate = DateIn(startDate, loc)
+	if startDate.Before(DateIn(time.Now().In(loc), loc)) {
 		return nil, errors.BadRequestError("invalid startDate")
 	}
 
+	if IsAsNeeded(medic.Schedule) {
+		return nil, nil
+	}
+
+	var dosages []model.Dosage
+	if len(medic.Phases) > 0 {
+		dosages = generatePhaseDosages(startDate, time.Time{}, medic.Phases)
+	} else {
+		times, err := parseTime(medic.DosageTimes)
+		if err != nil {
+			return nil, errors.BadRequestError(err.Error())
+		}
+
+		if ScheduleType(medic.Schedule) == constant.ScheduleInterval {
+			dosages = generateIntervalDosages(medic.Schedule, startDate, time.Time{}, times[0], medic.TotalNumberOfDosage)
+		} else {
+			day := nextDosingDay(medic.Schedule, startDate, startDate)
+			dosages = generateDosages(medic.Schedule, startDate, day, times, 0, medic.TotalNumberOfDosage)
+		}
+		setQuantity(dosages, medic.DosageQuantity)
+	}
+
+	if len(dosages) > 0 {
+		if dosages[0].ReminderTime.Before(time.Now()) {
+			return nil, errors.BadRequestError("invalid reminder time for your first dosage")
+		}
+	}
+
+	return dosages, nil
+}
+
+// GetUpcomingDosages generates the dosages of a medication from the given time onwards. Dosage
+// times on the first day that are not after 'from' are skipped, so it can be used to reschedule
+// a medication that has already started. Schedules and phases are followed from the start date
+// of the medication, the total number of dosages is not used for phases.
+func GetUpcomingDosages(startDate, from time.Time, medic *model.MedicationRequest) ([]model.Dosage, errors.InternalError) {
+	if err := ValidateSchedule(medic); err != nil {
+		return nil, err
+	}
+
+	loc, err := LoadTimeZone(medic.TimeZone)
+	if err != nil {
+		return nil, errors.BadRequestError(err.Error())
+	}
+	startDate = DateIn(startDate, loc)
+	from = from.In(loc)
+
+	if IsAsNeeded(medic.Schedule) {
+		return nil, nil
+	}
+
+	if len(medic.Phases) > 0 {
+		return generatePhaseDosages(startDate, from, medic.Phases), nil
+	}
+
 	times, err := parseTime(medic.DosageTimes)
 	if err != nil {
 		return nil, errors.BadRequestError(err.Error())
 	}
 
+	if ScheduleType(medic.Schedule) == constant.ScheduleInterval {
+		dosages := generateIntervalDosages(medic.Schedule, startDate, from, times[0], medic.TotalNumberOfDosage)
+		setQuantity(dosages, medic.DosageQuantity)
+		return dosages, nil
+	}
+
+	sort.Slice(times, func(i, j int) bool {
+		return times[i].Before(times[j])
+	})
+
+	// find the first dosage time that is still ahead on the first day
+	day := DateIn(from, loc)
+	offset := len(times)
+	if isDosingDay(medic.Schedule, startDate, day) {
+		for i, t := range times {
+			if atTimeOfDay(day, t).After(from) {
+				offset = i
+				break
+			}
+		}
+	}
+
+	if offset >= len(times) {
+		day = nextDosingDay(medic.Schedule, startDate, day.AddDate(0, 0, 1))
+		offset = 0
+	}
+
+	dosages := generateDosages(medic.Schedule, startDate, day, times, offset, medic.TotalNumberOfDosage)
+	setQuantity(dosages, medic.DosageQuantity)
+	return dosages, nil
+}
+
+// generateDosages creates 'total' dosages starting on 'day', which must be a dosing day of the
+// schedule, cycling through the dosage times from index 'offset'
+func generateDosages(schedule *model.Schedule, startDate, day time.Time, times []time.Time, offset, total int) []model.Dosage {
 	var dosages []model.Dosage
-	var counter int
-	var previousTimeRef = startDate
-	for i := 0; i < medic.TotalNumberOfDosage; i++ {
-		currentDosageTime := times[counter]
-		reminderTime := time.Date(previousTimeRef.Year(), previousTimeRef.Month(), previousTimeRef.Day(), currentDosageTime.Hour(),
-			currentDosageTime.Minute(), currentDosageTime.Second(), currentDosageTime.Nanosecond(), time.Local)
+	var counter = offset
+	for i := 0; i < total; i++ {
+		reminderTime := atTimeOfDay(day, times[counter])
 		if i > 0 {
-			// check if this current reminder time is before the previous saved time
-			// if it is, then add a day to the current reminder time to move to the next day
-			if reminderTime.Before(dosages[i-1].ReminderTime) {
-				reminderTime = reminderTime.Add(24 * time.Hour)
+			// check if this current reminder time is not after the previous saved time
+			// if it is not, then move the current reminder time to the next dosing day
+			if !reminderTime.After(dosages[i-1].ReminderTime) {
+				day = nextDosingDay(schedule, startDate, day.AddDate(0, 0, 1))
+				reminderTime = atTimeOfDay(day, times[counter])
 			}
 		}
 
-		previousTimeRef = reminderTime
 		dose := model.Dosage{
 			ReminderTime: reminderTime,
 			Status:       constant.DosageNotTaken,
@@ -53,13 +147,22 @@ func GetDosages(startDate time.Time, medic *model.MedicationRequest) ([]model.Do
 		}
 	}
 
-	if len(dosages) > 0 {
-		if dosages[0].ReminderTime.Before(time.Now()) {
-			return nil, errors.BadRequestError("invalid reminder time for your first dosage")
-		}
+	return dosages
+}
+
+// GetDosageTimes returns the time of day of the first 'dailyDosage' dosages. It is used for
+// medications that were created before the dosage times were stored on the medication.
+func GetDosageTimes(dosages []model.DosageResponse, dailyDosage int) []string {
+	sort.Slice(dosages, func(i, j int) bool {
+		return dosages[i].ReminderTime.Before(dosages[j].ReminderTime)
+	})
+
+	var times []string
+	for i := 0; i < len(dosages) && i < dailyDosage; i++ {
+		times = append(times, dosages[i].ReminderTime.In(time.Local).Format(time.TimeOnly))
 	}
 
-	return dosages, nil
+	return times
 }
 
 func parseTime(times []string) ([]time.Time, error) {
@@ -88,7 +191,18 @@ func MedicationToMedicationResponse(medic *model.Medication) model.MedicationRes
 		EndDate:             medic.EndDate,
 		DosageQuantity:      medic.DosageQuantity,
 		DailyDosage:         medic.DailyDosage,
+		DosageTimes:         medic.DosageTimes,
+		Schedule:            medic.Schedule,
+		Phases:              medic.Phases,
+		TimeZone:            medic.TimeZone,
+		Inventory:           medic.Inventory,
+		PausedAt:            medic.PausedAt,
+		ResumeAt:            medic.ResumeAt,
+		DiscontinuedAt:      medic.DiscontinuedAt,
+		DiscontinuedReason:  medic.DiscontinuedReason,
 		DosagesTaken:        medic.DosagesTaken,
+		DosagesSkipped:      medic.DosagesSkipped,
+		DosagesMissed:       medic.DosagesMissed,
 		TotalNumberOfDosage: medic.TotalNumberOfDosage,
 		Treatment:           medic.Treatment,
 		Comment:             medic.Comment,
@@ -96,5 +210,38 @@ func MedicationToMedicationResponse(medic *model.Medication) model.MedicationRes
 		UpdatedAt:           medic.UpdatedAt,
 		MedicineID:          medic.MedicineID,
 		PatientID:           medic.PatientID,
+		PrescribedBy:        medic.PrescribedBy,
+	}
+}
+
+func MedicationResponseToMedication(medic *model.MedicationResponse) model.Medication {
+	return model.Medication{
+		ID:                  medic.ID,
+		Name:                medic.Name,
+		StartDate:           medic.StartDate,
+		EndDate:             medic.EndDate,
+		DosageQuantity:      medic.DosageQuantity,
+		DailyDosage:         medic.DailyDosage,
+		DosageTimes:         medic.DosageTimes,
+		Schedule:            medic.Schedule,
+		Phases:              medic.Phases,
+		TimeZone:            medic.TimeZone,
+		Inventory:           medic.Inventory,
+		PausedAt:            medic.PausedAt,
+		ResumeAt:            medic.ResumeAt,
+		DiscontinuedAt:      medic.DiscontinuedAt,
+		DiscontinuedReason:  medic.DiscontinuedReason,
+		TotalNumberOfDosage: medic.TotalNumberOfDosage,
+		DosagesTaken:        medic.DosagesTaken,
+		DosagesSkipped:      medic.DosagesSkipped,
+		DosagesMissed:       medic.DosagesMissed,
+		Treatment:           medic.Treatment,
+		Comment:             medic.Comment,
+		IsActive:            medic.IsActive,
+		CreatedAt:           medic.CreatedAt,
+		UpdatedAt:           medic.UpdatedAt,
+		PatientID:           medic.PatientID,
+		MedicineID:          medic.MedicineID,
+		PrescribedBy:        medic.PrescribedBy,
 	}
 }
@@ -0,0 +1,283 @@
+package utility
+
+import (
+	"fmt"
+	"medbuddy-backend/internal/constant"
+	"medbuddy-backend/internal/errors"
+	"medbuddy-backend/internal/model"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxScheduleDays bounds the day counts of cyclic and every n days schedules
+const maxScheduleDays = 365
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// ScheduleType returns the type of the schedule of a medication, medications without a schedule
+// are taken daily
+func ScheduleType(schedule *model.Schedule) string {
+	if schedule == nil || schedule.Type == "" {
+		return constant.ScheduleDaily
+	}
+
+	return schedule.Type
+}
+
+// IsAsNeeded reports whether the medication is taken as needed, without reminders
+func IsAsNeeded(schedule *model.Schedule) bool {
+	return ScheduleType(schedule) == constant.ScheduleAsNeeded
+}
+
+// ValidateSchedule checks that the dosage times, daily dosage and schedule of a medication agree
+// with each other. Weekdays are lowercased in place.
+func ValidateSchedule(medic *model.MedicationRequest) errors.InternalError {
+	if len(medic.Phases) > 0 {
+		return validatePhases(medic)
+	}
+
+	if strings.TrimSpace(medic.DosageQuantity) == "" {
+		return errors.BadRequestError("'dosage_quantity' is required, unless the medication is taken in phases")
+	}
+
+	schedule := medic.Schedule
+
+	switch ScheduleType(schedule) {
+	case constant.ScheduleAsNeeded:
+		if schedule.MaxPerDay < 1 {
+			return errors.BadRequestError("as needed schedules need a 'max_per_day' of at least 1")
+		}
+		if len(medic.DosageTimes) > 0 {
+			return errors.BadRequestError("as needed schedules have no dosage times, dosages are recorded when taken")
+		}
+		return nil
+
+	case constant.ScheduleInterval:
+		if schedule.IntervalHours < 1 || schedule.IntervalHours > constant.MaxScheduleIntervalHours {
+			return errors.BadRequestError(fmt.Sprintf("interval schedules need an 'interval_hours' between 1 and %v", constant.MaxScheduleIntervalHours))
+		}
+		if len(medic.DosageTimes) != 1 {
+			return errors.BadRequestError("interval schedules take a single dosage time, the time of the first dosage")
+		}
+		_, err := parseTime(medic.DosageTimes)
+		if err != nil {
+			return errors.BadRequestError(err.Error())
+		}
+		return nil
+
+	case constant.ScheduleWeekly:
+		if len(schedule.Weekdays) == 0 {
+			return errors.BadRequestError("weekly schedules need at least one weekday, e.g. 'monday'")
+		}
+		seen := map[string]bool{}
+		for i, day := range schedule.Weekdays {
+			day = strings.ToLower(strings.TrimSpace(day))
+			if _, ok := weekdays[day]; !ok {
+				return errors.BadRequestError(fmt.Sprintf("invalid weekday: %v", schedule.Weekdays[i]))
+			}
+			if seen[day] {
+				return errors.BadRequestError(fmt.Sprintf("weekday %v is given more than once", day))
+			}
+			seen[day] = true
+			schedule.Weekdays[i] = day
+		}
+
+	case constant.ScheduleCyclic:
+		if schedule.DaysOn < 1 || schedule.DaysOn > maxScheduleDays || schedule.DaysOff < 1 || schedule.DaysOff > maxScheduleDays {
+			return errors.BadRequestError(fmt.Sprintf("cyclic schedules need 'days_on' and 'days_off' between 1 and %v", maxScheduleDays))
+		}
+
+	case constant.ScheduleEveryNDays:
+		if schedule.EveryNDays < 2 || schedule.EveryNDays > maxScheduleDays {
+			return errors.BadRequestError(fmt.Sprintf("every n days schedules need an 'every_n_days' between 2 and %v, use a daily schedule for every day", maxScheduleDays))
+		}
+
+	case constant.ScheduleDaily:
+	default:
+		return errors.BadRequestError(fmt.Sprintf("invalid schedule type: %v", schedule.Type))
+	}
+
+	if medic.DailyDosage != len(medic.DosageTimes) {
+		return errors.BadRequestError("'dailyDosage' should match the length of dosage times")
+	}
+
+	if _, err := parseTime(medic.DosageTimes); err != nil {
+		return errors.BadRequestError(err.Error())
+	}
+
+	return nil
+}
+
+// validatePhases checks the phases of a tapered or titrated medication, which are taken daily at
+// the dosage times of each phase
+func validatePhases(medic *model.MedicationRequest) errors.InternalError {
+	if scheduleType := ScheduleType(medic.Schedule); scheduleType != constant.ScheduleDaily {
+		return errors.BadRequestError(fmt.Sprintf("phases are taken daily, they cannot follow a %v schedule", scheduleType))
+	}
+
+	if medic.DailyDosage > 0 || len(medic.DosageTimes) > 0 {
+		return errors.BadRequestError("the dosage times of a medication taken in phases are given per phase")
+	}
+
+	for i, phase := range medic.Phases {
+		if strings.TrimSpace(phase.DosageQuantity) == "" {
+			return errors.BadRequestError(fmt.Sprintf("phase %v: 'dosage_quantity' is required", i+1))
+		}
+		if phase.Days < 1 || phase.Days > maxScheduleDays {
+			return errors.BadRequestError(fmt.Sprintf("phase %v: 'days' should be between 1 and %v", i+1, maxScheduleDays))
+		}
+		if _, err := parseTime(phase.DosageTimes); err != nil {
+			return errors.BadRequestError(fmt.Sprintf("phase %v: %v", i+1, err.Error()))
+		}
+	}
+
+	return nil
+}
+
+// PhaseDosageCount returns the number of dosages of a medication taken in phases
+func PhaseDosageCount(phases []model.Phase) int {
+	var count int
+	for _, phase := range phases {
+		count += phase.Days * len(phase.DosageTimes)
+	}
+
+	return count
+}
+
+// isDosingDay reports whether dosages are due on day, days are counted from the start date of
+// the medication
+func isDosingDay(schedule *model.Schedule, startDate, day time.Time) bool {
+	days := daysBetween(startDate, day)
+
+	switch ScheduleType(schedule) {
+	case constant.ScheduleWeekly:
+		for _, d := range schedule.Weekdays {
+			if weekdays[d] == day.Weekday() {
+				return true
+			}
+		}
+		return false
+	case constant.ScheduleCyclic:
+		return days >= 0 && days%(schedule.DaysOn+schedule.DaysOff) < schedule.DaysOn
+	case constant.ScheduleEveryNDays:
+		return days >= 0 && days%schedule.EveryNDays == 0
+	default:
+		return true
+	}
+}
+
+// nextDosingDay returns the first dosing day on or after day
+func nextDosingDay(schedule *model.Schedule, startDate, day time.Time) time.Time {
+	for !isDosingDay(schedule, startDate, day) {
+		day = day.AddDate(0, 0, 1)
+	}
+
+	return day
+}
+
+// ResumePhases returns the start date of a medication taken in phases that was paused at pausedAt
+// and is resumed at now, and the time its dosages are generated from. The phases carry on where
+// they were paused, moved on by as few whole days as it takes for the next dosage to be ahead.
+func ResumePhases(startDate time.Time, phases []model.Phase, pausedAt, now time.Time, loc *time.Location) (time.Time, time.Time) {
+	paused := generatePhaseDosages(DateIn(startDate, loc), pausedAt, phases)
+	if len(paused) == 0 {
+		return startDate, now
+	}
+
+	next := paused[0].ReminderTime
+	days := daysBetween(next, now.In(loc))
+	if days < 0 {
+		return startDate, now
+	}
+	if !next.AddDate(0, 0, days).After(now) {
+		days++
+	}
+
+	return startDate.AddDate(0, 0, days), pausedAt.In(loc).AddDate(0, 0, days)
+}
+
+// daysBetween counts the calendar days from the day of 'from' to the day of 'to'
+func daysBetween(from, to time.Time) int {
+	f := time.Date(from.Year(), from.Month(), from.Day(), 00, 00, 00, 00, time.UTC)
+	t := time.Date(to.Year(), to.Month(), to.Day(), 00, 00, 00, 00, time.UTC)
+	return int(t.Sub(f).Hours() / 24)
+}
+
+// generateIntervalDosages creates 'total' dosages every IntervalHours from the dosage time on the
+// start date, skipping the ones that are not after 'from'
+func generateIntervalDosages(schedule *model.Schedule, startDate, from time.Time, firstTime time.Time, total int) []model.Dosage {
+	interval := time.Duration(schedule.IntervalHours) * time.Hour
+	first := atTimeOfDay(startDate, firstTime)
+
+	var skip int64
+	if !first.After(from) {
+		skip = int64(from.Sub(first)/interval) + 1
+	}
+
+	var dosages []model.Dosage
+	for i := 0; i < total; i++ {
+		dosages = append(dosages, model.Dosage{
+			ReminderTime: first.Add(time.Duration(skip+int64(i)) * interval),
+			Status:       constant.DosageNotTaken,
+			IsActive:     true,
+		})
+	}
+
+	return dosages
+}
+
+// atTimeOfDay returns the time t of day on the day of 'day', in the time zone of day
+func atTimeOfDay(day, t time.Time) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), day.Location())
+}
+
+// generatePhaseDosages creates the dosages of the phases after 'from', the phases follow each other
+// from the start date. Each dosage carries the dosage quantity of its phase.
+func generatePhaseDosages(startDate, from time.Time, phases []model.Phase) []model.Dosage {
+	var dosages []model.Dosage
+	day := DateIn(startDate, startDate.Location())
+	for _, phase := range phases {
+		// phases have been validated, their dosage times parse
+		times, _ := parseTime(phase.DosageTimes)
+		sort.Slice(times, func(i, j int) bool {
+			return times[i].Before(times[j])
+		})
+
+		for d := 0; d < phase.Days; d++ {
+			for _, t := range times {
+				reminderTime := atTimeOfDay(day, t)
+				if !reminderTime.After(from) {
+					continue
+				}
+
+				dosages = append(dosages, model.Dosage{
+					ReminderTime: reminderTime,
+					Status:       constant.DosageNotTaken,
+					IsActive:     true,
+					Quantity:     phase.DosageQuantity,
+				})
+			}
+			day = day.AddDate(0, 0, 1)
+		}
+	}
+
+	return dosages
+}
+
+// setQuantity sets the dosage quantity of the dosages that do not have one yet
+func setQuantity(dosages []model.Dosage, quantity string) {
+	for i := range dosages {
+		if dosages[i].Quantity == "" {
+			dosages[i].Quantity = quantity
+		}
+	}
+}
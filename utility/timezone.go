@@ -0,0 +1,67 @@
+package utility
+
+import (
+	"fmt"
+	"medbuddy-backend/internal/model"
+	"time"
+)
+
+// LoadTimeZone returns the location of an IANA time zone name, e.g. Africa/Lagos. An empty name
+// is the server's time zone.
+func LoadTimeZone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil || name == "Local" {
+		return nil, fmt.Errorf("invalid time zone: %v", name)
+	}
+
+	return loc, nil
+}
+
+// DateIn returns the start of the calendar day of date in loc. Dates parsed by FormatTime are at
+// midnight UTC, only their year, month and day are kept.
+func DateIn(date time.Time, loc *time.Location) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 00, 00, 00, 00, loc)
+}
+
+// ParseTimeZoneQuery returns the location of a time_zone query parameter, nil when it is not set
+func ParseTimeZoneQuery(value string) (*time.Location, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	return LoadTimeZone(value)
+}
+
+// DosagesIn renders the times of the dosages in loc, they are left as they are when loc is nil
+func DosagesIn(dosages []model.DosageResponse, loc *time.Location) {
+	if loc == nil {
+		return
+	}
+
+	for i := range dosages {
+		DosageIn(&dosages[i], loc)
+	}
+}
+
+// DosageIn renders the times of the dosage in loc, they are left as they are when loc is nil
+func DosageIn(dosage *model.DosageResponse, loc *time.Location) {
+	if loc == nil {
+		return
+	}
+
+	dosage.ReminderTime = dosage.ReminderTime.In(loc)
+	if !dosage.TimeTaken.IsZero() {
+		dosage.TimeTaken = dosage.TimeTaken.In(loc)
+	}
+	if !dosage.TimeSkipped.IsZero() {
+		dosage.TimeSkipped = dosage.TimeSkipped.In(loc)
+	}
+	if dosage.TimeMissed != nil {
+		timeMissed := dosage.TimeMissed.In(loc)
+		dosage.TimeMissed = &timeMissed
+	}
+}